@@ -4,23 +4,60 @@ package api
 
 import (
 	"context"
+	"time"
 
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
 	"github.com/bnkamalesh/padlock/pkg/appcontext"
 	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/invites"
+	"github.com/bnkamalesh/padlock/pkg/orgs"
+	"github.com/bnkamalesh/padlock/pkg/push"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/subjects"
 	"github.com/bnkamalesh/padlock/pkg/users"
+	"github.com/bnkamalesh/padlock/pkg/webauthn"
+	"github.com/bnkamalesh/padlock/pkg/webhooks"
 )
 
 type API struct {
-	appCtx *appcontext.AppContext
-	apps   *apps.Apps
-	users  *users.Users
+	appCtx   *appcontext.AppContext
+	apps     *apps.Apps
+	users    *users.Users
+	webauthn *webauthn.WebAuthn
+	push     *push.Push
+	rbac     *rbac.RBAC
+	orgs     *orgs.Orgs
+	invites  *invites.Invites
+	apiKeys  *apikeys.APIKeys
+	subjects *subjects.Subjects
+	webhooks *webhooks.Webhooks
 }
 
-func New(appCtx *appcontext.AppContext, a *apps.Apps, u *users.Users) *API {
+func New(
+	appCtx *appcontext.AppContext,
+	a *apps.Apps,
+	u *users.Users,
+	wa *webauthn.WebAuthn,
+	p *push.Push,
+	r *rbac.RBAC,
+	o *orgs.Orgs,
+	inv *invites.Invites,
+	ak *apikeys.APIKeys,
+	sub *subjects.Subjects,
+	wh *webhooks.Webhooks,
+) *API {
 	api := &API{
-		appCtx: appCtx,
-		apps:   a,
-		users:  u,
+		appCtx:   appCtx,
+		apps:     a,
+		users:    u,
+		webauthn: wa,
+		push:     p,
+		rbac:     r,
+		orgs:     o,
+		invites:  inv,
+		apiKeys:  ak,
+		subjects: sub,
+		webhooks: wh,
 	}
 
 	return api
@@ -35,3 +72,169 @@ func (a *API) Login(ctx context.Context, source, email, password string) (*users
 func (a *API) AuthenticatedUser(ctx context.Context, source, token string) (*users.User, error) {
 	return a.users.AuthUser(ctx, source, token)
 }
+
+// UpdateUser updates the non-empty name & password of the user, if the version provided is still
+// the latest. The email/phone can only be changed using RequestContactChange. Users can update
+// themselves, else rbac.PermUsers is required
+func (a *API) UpdateUser(ctx context.Context, u users.User) (*users.User, error) {
+	caller := users.FromContext(ctx)
+	if caller == nil || caller.ID != u.ID {
+		err := a.rbac.Authorize(ctx, rbac.PermUsers, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a.users.Update(ctx, u)
+}
+
+// ListUsers lists all the users matching the filter, requires rbac.PermUsers
+func (a *API) ListUsers(ctx context.Context, filter users.ListFilter) ([]users.User, int64, error) {
+	err := a.rbac.Authorize(ctx, rbac.PermUsers, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return a.users.List(ctx, filter)
+}
+
+// Authorize checks if the authenticated user is allowed the permission on the app (0 for
+// platform wide permissions)
+func (a *API) Authorize(ctx context.Context, perm rbac.Permission, appID int64) error {
+	return a.rbac.Authorize(ctx, perm, appID)
+}
+
+// AssignRole assigns a role to a user
+func (a *API) AssignRole(ctx context.Context, assignment rbac.Assignment) (*rbac.Assignment, error) {
+	return a.rbac.Assign(ctx, assignment)
+}
+
+// RevokeRole removes a role assignment
+func (a *API) RevokeRole(ctx context.Context, id int64) error {
+	return a.rbac.Revoke(ctx, id)
+}
+
+// RoleAssignments lists all the roles assigned to the user
+func (a *API) RoleAssignments(ctx context.Context, userID int64) ([]rbac.Assignment, error) {
+	return a.rbac.Assignments(ctx, userID)
+}
+
+// RequestContactChange initiates changing the email or phone of the user
+func (a *API) RequestContactChange(ctx context.Context, u *users.User, field, value string) (*users.ContactChange, error) {
+	switch field {
+	case string(users.ContactEmail):
+		{
+			return a.users.RequestContactChange(ctx, u, users.ContactEmail, value)
+		}
+	case string(users.ContactPhone):
+		{
+			return a.users.RequestContactChange(ctx, u, users.ContactPhone, value)
+		}
+	}
+	return nil, users.ErrInvalidContact
+}
+
+// ConfirmContactChange applies a contact change after verifying the code, and revokes all other
+// sessions of the user
+func (a *API) ConfirmContactChange(ctx context.Context, sessionToken string, u *users.User, changeID, code string) (*users.User, error) {
+	return a.users.ConfirmContactChange(ctx, sessionToken, u, changeID, code)
+}
+
+// ReadContactRevert returns the field (email/phone) of the contact change, which would be reverted
+// using the token sent to the old contact
+func (a *API) ReadContactRevert(ctx context.Context, token string) (string, error) {
+	field, err := a.users.ReadContactRevert(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	return string(field), nil
+}
+
+// RevertContactChange cancels or reverts a contact change using the token sent to the old contact
+func (a *API) RevertContactChange(ctx context.Context, token string) error {
+	return a.users.RevertContactChange(ctx, token)
+}
+
+// SendPhoneOTP sends a one time code to the phone of the user, as a text message or voice call
+func (a *API) SendPhoneOTP(ctx context.Context, u *users.User, channel string) (*users.PhoneOTP, error) {
+	return a.users.SendPhoneOTP(ctx, u, channel)
+}
+
+// VerifyPhoneOTP verifies the one time code sent to the phone of the user
+func (a *API) VerifyPhoneOTP(ctx context.Context, u *users.User, code string) error {
+	return a.users.VerifyPhoneOTP(ctx, u, code)
+}
+
+// RequestPasswordless sends a one time sign in code or link, based on kind, to the email
+func (a *API) RequestPasswordless(ctx context.Context, source, email, kind string) error {
+	return a.users.RequestPasswordless(ctx, source, email, users.PasswordlessKind(kind))
+}
+
+// LoginWithEmailCode signs in the user using the one time code sent by email
+func (a *API) LoginWithEmailCode(ctx context.Context, source, email, code string) (*users.User, string, error) {
+	return a.users.LoginWithEmailCode(ctx, source, email, code)
+}
+
+// LoginWithMagicLink signs in the user using the token of the sign in link sent by email
+func (a *API) LoginWithMagicLink(ctx context.Context, source, token string) (*users.User, string, error) {
+	return a.users.LoginWithMagicLink(ctx, source, token)
+}
+
+// BeginWebAuthnRegistration starts registering a new WebAuthn authenticator for the user
+func (a *API) BeginWebAuthnRegistration(ctx context.Context, u *users.User) (*webauthn.CreationOptions, error) {
+	return a.webauthn.BeginRegistration(ctx, u)
+}
+
+// FinishWebAuthnRegistration verifies the authenticator's response and registers the credential
+func (a *API) FinishWebAuthnRegistration(ctx context.Context, u *users.User, name string, resp webauthn.RegistrationResponse) (*webauthn.Credential, error) {
+	return a.webauthn.FinishRegistration(ctx, u, name, resp)
+}
+
+// BeginWebAuthnAssertion starts verifying the user using a registered WebAuthn authenticator
+func (a *API) BeginWebAuthnAssertion(ctx context.Context, u *users.User) (*webauthn.RequestOptions, error) {
+	return a.webauthn.BeginAssertion(ctx, u)
+}
+
+// FinishWebAuthnAssertion verifies the signature generated by the authenticator
+func (a *API) FinishWebAuthnAssertion(ctx context.Context, u *users.User, resp webauthn.AssertionResponse) (*webauthn.AssertionResult, error) {
+	return a.webauthn.FinishAssertion(ctx, u, resp)
+}
+
+// WebAuthnCredentials lists all the WebAuthn authenticators registered by the user
+func (a *API) WebAuthnCredentials(ctx context.Context, u *users.User) ([]webauthn.Credential, error) {
+	return a.webauthn.Credentials(ctx, u)
+}
+
+// DeleteWebAuthnCredential removes a WebAuthn authenticator of the user
+func (a *API) DeleteWebAuthnCredential(ctx context.Context, u *users.User, id int64) error {
+	return a.webauthn.DeleteCredential(ctx, u, id)
+}
+
+// EnrollPushDevice enrolls a device of the user for approving sign ins
+func (a *API) EnrollPushDevice(ctx context.Context, u *users.User, name, publicKey, pushToken string) (*push.Device, error) {
+	return a.push.EnrollDevice(ctx, u, name, publicKey, pushToken)
+}
+
+// PushDevices lists all the devices enrolled by the user for approving sign ins
+func (a *API) PushDevices(ctx context.Context, u *users.User) ([]push.Device, error) {
+	return a.push.Devices(ctx, u)
+}
+
+// RemovePushDevice removes an enrolled device of the user
+func (a *API) RemovePushDevice(ctx context.Context, u *users.User, id int64) error {
+	return a.push.RemoveDevice(ctx, u, id)
+}
+
+// PushChallenge pushes a sign in approval request to all the enrolled devices of the user
+func (a *API) PushChallenge(ctx context.Context, u *users.User, source string) (*push.Challenge, error) {
+	return a.push.Challenge(ctx, u, source)
+}
+
+// PushRespond records a device's approval/denial of a sign in request
+func (a *API) PushRespond(ctx context.Context, challengeID string, r push.Response) (*push.Challenge, error) {
+	return a.push.Respond(ctx, challengeID, r)
+}
+
+// PushStatus returns the status of a sign in approval request, waiting for at most wait duration
+// for it to be approved/denied
+func (a *API) PushStatus(ctx context.Context, u *users.User, challengeID string, wait time.Duration) (*push.Challenge, error) {
+	return a.push.Status(ctx, u, challengeID, wait)
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"context"
+
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+)
+
+// CreateAPIKey creates a new API key for the application, the full key is only available in
+// the response
+func (a *API) CreateAPIKey(ctx context.Context, k apikeys.APIKey) (*apikeys.APIKey, error) {
+	return a.apiKeys.Create(ctx, k)
+}
+
+// APIKeys lists the API keys of the application
+func (a *API) APIKeys(ctx context.Context, appID int64) ([]apikeys.APIKey, error) {
+	return a.apiKeys.List(ctx, appID)
+}
+
+// RevokeAPIKey revokes an API key of the application
+func (a *API) RevokeAPIKey(ctx context.Context, appID, id int64) error {
+	return a.apiKeys.Revoke(ctx, appID, id)
+}
+
+// AuthenticatedApp resolves the API key to the application it belongs to
+func (a *API) AuthenticatedApp(ctx context.Context, key string) (*apps.App, *apikeys.APIKey, error) {
+	return a.apiKeys.Authenticate(ctx, key)
+}
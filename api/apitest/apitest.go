@@ -0,0 +1,144 @@
+// Package apitest builds the API with all its services, backed by a test database & in memory
+// dependencies. It's used by the tests of the servers & the client, which need the complete API
+package apitest
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/api"
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/invites"
+	"github.com/bnkamalesh/padlock/pkg/orgs"
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/platform/notifier"
+	"github.com/bnkamalesh/padlock/pkg/platform/sms"
+	"github.com/bnkamalesh/padlock/pkg/push"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/subjects"
+	"github.com/bnkamalesh/padlock/pkg/users"
+	"github.com/bnkamalesh/padlock/pkg/webauthn"
+	"github.com/bnkamalesh/padlock/pkg/webhooks"
+)
+
+const (
+	// Password is the password of all the users created by the fixture
+	Password = "password"
+	// RPOrigin is the WebAuthn origin accepted by the API
+	RPOrigin = "https://padlock.test"
+)
+
+// Fixture is the API along with the dependencies the tests inspect, e.g. the messages sent
+type Fixture struct {
+	AppCtx   *appcontext.AppContext
+	API      *api.API
+	Notifier *notifier.Recorder
+	SMS      *sms.Fake
+	Push     *push.Loopback
+
+	users *users.Users
+	rbac  *rbac.RBAC
+}
+
+// User creates a user with the email & Password
+func (f *Fixture) User(t testing.TB, u users.User) *users.User {
+	t.Helper()
+
+	created, err := f.users.Create(context.Background(), u, Password)
+	if err != nil {
+		t.Fatalf("apitest: creating user %s: %v", u.Email, err)
+	}
+	return created
+}
+
+// Admin creates a user (refer User) with the platform wide admin role
+func (f *Fixture) Admin(t testing.TB, u users.User) *users.User {
+	t.Helper()
+
+	created := f.User(t, u)
+	_, err := f.rbac.Assign(
+		rbac.SystemContext(context.Background()),
+		rbac.Assignment{UserID: created.ID, Role: rbac.RoleAdmin},
+	)
+	if err != nil {
+		t.Fatalf("apitest: assigning admin to %s: %v", u.Email, err)
+	}
+	return created
+}
+
+// LastMatch returns the first submatch of re, in the last message sent to the recipient by
+// email/SMS using the notifier
+func (f *Fixture) LastMatch(t testing.TB, to string, re *regexp.Regexp) string {
+	t.Helper()
+
+	msgs := f.Notifier.Messages()
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].To != to {
+			continue
+		}
+		m := re.FindStringSubmatch(msgs[i].Body)
+		if m == nil {
+			t.Fatalf("apitest: no match in %q", msgs[i].Body)
+		}
+		return m[1]
+	}
+	t.Fatalf("apitest: no message sent to %s", to)
+	return ""
+}
+
+func New(t testing.TB, driver database.Driver, db *sql.DB) *Fixture {
+	t.Helper()
+
+	appCtx := appcontext.New(logger.New())
+	c := cache.NewMemory()
+	f := &Fixture{
+		AppCtx:   appCtx,
+		Notifier: notifier.NewRecorder(),
+		SMS:      sms.NewFake(nil),
+		Push:     push.NewLoopback(),
+	}
+
+	f.rbac = rbac.New(appCtx, driver, db)
+	o := orgs.New(appCtx, driver, db, f.rbac)
+	a := apps.New(appCtx, driver, db, f.rbac, o)
+	f.users = users.New(appCtx, driver, db, c, f.Notifier, f.SMS)
+	wh := webhooks.New(appCtx, driver, webhooks.DefaultConfig, db, f.rbac)
+
+	f.API = api.New(
+		appCtx,
+		a,
+		f.users,
+		webauthn.New(
+			appCtx,
+			driver,
+			webauthn.Config{RPID: "padlock.test", RPName: "Padlock", Origins: []string{RPOrigin}},
+			db,
+			c,
+		),
+		push.New(appCtx, driver, push.DefaultConfig, db, c, f.Push),
+		f.rbac,
+		o,
+		invites.New(
+			appCtx,
+			driver,
+			invites.Config{Secret: []byte("apitest"), Validity: time.Hour},
+			db,
+			f.users,
+			o,
+			f.rbac,
+			f.Notifier,
+		),
+		apikeys.New(appCtx, driver, db, f.rbac, a),
+		subjects.New(appCtx, driver, subjects.DefaultConfig, db, wh),
+		wh,
+	)
+
+	return f
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+
+	"github.com/bnkamalesh/padlock/pkg/apps"
+)
+
+// CreateApp registers a new application, owned by the organisation app.OrgID
+func (a *API) CreateApp(ctx context.Context, app apps.App) (*apps.App, error) {
+	return a.apps.Create(ctx, app)
+}
+
+// ListApps lists the applications the authenticated user can access
+func (a *API) ListApps(ctx context.Context, filter apps.ListFilter) ([]apps.App, int64, error) {
+	return a.apps.List(ctx, filter)
+}
+
+// App returns the details of the application
+func (a *API) App(ctx context.Context, id int64) (*apps.App, error) {
+	return a.apps.Read(ctx, id)
+}
+
+// UpdateApp updates the non-empty details of the application
+func (a *API) UpdateApp(ctx context.Context, app apps.App) (*apps.App, error) {
+	return a.apps.Update(ctx, app)
+}
+
+// DeleteApp deletes the application
+func (a *API) DeleteApp(ctx context.Context, id int64) (*apps.App, error) {
+	return a.apps.Delete(ctx, apps.App{ID: id})
+}
+
+// AppOwners lists the direct owners of the application
+func (a *API) AppOwners(ctx context.Context, id int64) ([]apps.Owner, error) {
+	return a.apps.Owners(ctx, id)
+}
+
+// SetAppOwner makes the user an owner of the application
+func (a *API) SetAppOwner(ctx context.Context, id, userID int64) (*apps.App, error) {
+	u, err := a.users.Read(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return a.apps.SetOwner(ctx, apps.App{ID: id}, *u)
+}
+
+// RemoveAppOwner removes the user from the direct owners of the application
+func (a *API) RemoveAppOwner(ctx context.Context, id, userID int64) error {
+	return a.apps.RemoveOwner(ctx, id, userID)
+}
@@ -0,0 +1,3 @@
+package grpc
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. padlock.proto
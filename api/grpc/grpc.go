@@ -0,0 +1,131 @@
+// Package grpc has the gRPC server for the APIs
+package grpc
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/bnkamalesh/padlock/api"
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/health"
+)
+
+// serviceName is the fully qualified name of the Padlock service, as used by the health service
+const serviceName = "padlock.v1.Padlock"
+
+// healthInterval is the interval at which the dependencies are checked, to update the status
+// on the health service
+const healthInterval = time.Second * 5
+
+type Server struct {
+	address string
+	appCtx  *appcontext.AppContext
+	api     *api.API
+	server  *grpc.Server
+	health  *grpchealth.Server
+	checker *health.Checker
+	// healthCtx is cancelled by stopHealth, to stop updating the health service
+	healthCtx  context.Context
+	stopHealth context.CancelFunc
+}
+
+// Start starts listening on the address, it blocks till the server is stopped
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+
+	go s.watchHealth(s.healthCtx)
+
+	err = s.server.Serve(listener)
+	if err == grpc.ErrServerStopped {
+		return nil
+	}
+	return err
+}
+
+// Drain sets all the services as not serving on the health service, so that the clients (and
+// load balancers) stop sending new requests, while the server continues serving them
+func (s *Server) Drain() {
+	s.health.Shutdown()
+}
+
+// watchHealth updates the status on the health service, based on the status of the
+// dependencies, till the context is cancelled
+func (s *Server) watchHealth(ctx context.Context) {
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		report := s.checker.Check()
+		if !report.Up() {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		// the overall status of the server is the empty service name
+		s.health.SetServingStatus("", status)
+		s.health.SetServingStatus(serviceName, status)
+
+		select {
+		case <-ctx.Done():
+			{
+				return
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown stops accepting new connections, and waits for the in-flight requests to complete.
+// If the context is done before they complete, the remaining connections are closed
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Drain()
+	s.stopHealth()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		{
+			return nil
+		}
+	case <-ctx.Done():
+		{
+			s.server.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func NewServer(host, port string, api *api.API, appCtx *appcontext.AppContext, hc *health.Checker) (*Server, error) {
+	s := &Server{
+		address: net.JoinHostPort(host, port),
+		appCtx:  appCtx,
+		api:     api,
+		health:  grpchealth.NewServer(),
+		checker: hc,
+	}
+	s.healthCtx, s.stopHealth = context.WithCancel(context.Background())
+
+	s.server = grpc.NewServer(
+		// the request context should be the first, so that execution starts with it
+		grpc.UnaryInterceptor(chain(s.reqCtx, s.errors, s.authentication)),
+	)
+
+	RegisterPadlockServer(s.server, s)
+	healthpb.RegisterHealthServer(s.server, s.health)
+	reflection.Register(s.server)
+
+	return s, nil
+}
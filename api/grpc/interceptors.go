@@ -0,0 +1,159 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/OneOfOne/xxhash"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+const (
+	methodPrefix = "/" + serviceName + "/"
+
+	trailerErrorCode = "x-error-code"
+	trailerRequestID = "x-request-id"
+)
+
+var (
+	// publicMethods do not require any authentication
+	publicMethods = map[string]bool{
+		methodPrefix + "Login": true,
+	}
+
+	// apiKeyMethods are authenticated with the API key of an application, instead of a session,
+	// and the key should be allowed the scope
+	apiKeyMethods = map[string]apikeys.Scope{
+		methodPrefix + "EnrollSubject":   apikeys.ScopeEnroll,
+		methodPrefix + "UnenrollSubject": apikeys.ScopeEnroll,
+		methodPrefix + "VerifySubject":   apikeys.ScopeVerify,
+	}
+)
+
+// chain chains the interceptors, the first one is the outermost
+func chain(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, h := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, h)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// metadataValue returns the first value of the key in the incoming metadata
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func sourceID(ctx context.Context) string {
+	addr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+		// the port changes for every connection, and is not part of the source
+		if idx := strings.LastIndex(addr, ":"); idx > 0 {
+			addr = addr[:idx]
+		}
+	}
+
+	h := xxhash.New64()
+	rdr := strings.NewReader(addr + metadataValue(ctx, "user-agent"))
+	io.Copy(h, rdr)
+	return fmt.Sprintf("%d", h.Sum64())
+}
+
+// reqCtx injects appcontext.RequestContext into the context
+func (s *Server) reqCtx(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, _ = s.appCtx.NewReqContext(ctx, sourceID(ctx))
+	return handler(ctx, req)
+}
+
+// errors converts the errors returned by the handlers to gRPC status errors, with the status
+// code appropriate for the code of the error. The error code & request ID are set in the
+// trailer, and the request ID is added to the status details as well
+func (s *Server) errors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return nil, err
+	}
+
+	code := apperr.CodeOf(err)
+	requestID := ""
+	if rctx := s.appCtx.ReqContext(ctx); rctx != nil {
+		requestID = rctx.ID
+	}
+	grpc.SetTrailer(ctx, metadata.Pairs(trailerErrorCode, string(code), trailerRequestID, requestID))
+
+	st := status.New(code.GRPCCode(), apperr.Message(err))
+	withID, dErr := st.WithDetails(&errdetails.RequestInfo{RequestId: requestID})
+	if dErr == nil {
+		st = withID
+	}
+	return nil, st.Err()
+}
+
+// authentication authenticates the user using the session token in the 'authorization'
+// metadata, or the application using the API key in the 'x-api-key' metadata; depending on
+// the method. The other services (e.g. health) are not authenticated
+func (s *Server) authentication(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !strings.HasPrefix(info.FullMethod, methodPrefix) || publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	if scope, ok := apiKeyMethods[info.FullMethod]; ok {
+		key := metadataValue(ctx, "x-api-key")
+		if key == "" {
+			return nil, apikeys.ErrInvalidKey
+		}
+
+		app, k, err := s.api.AuthenticatedApp(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if !k.Allows(scope) {
+			return nil, apikeys.ErrScope
+		}
+
+		return handler(apikeys.SetContext(ctx, app, k), req)
+	}
+
+	token := metadataValue(ctx, "authorization")
+	if token == "" {
+		return nil, rbac.ErrUnauthenticated
+	}
+
+	u, err := s.api.AuthenticatedUser(ctx, sourceID(ctx), token)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(users.SetSessionContext(users.SetContext(ctx, u), token), req)
+}
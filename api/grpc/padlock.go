@@ -0,0 +1,205 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/totp"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func userProto(u *users.User) *User {
+	return &User{
+		Id:        u.ID,
+		Name:      u.Name,
+		Email:     u.Email,
+		Phone:     u.Phone,
+		Version:   u.Version,
+		CreatedAt: formatTime(u.CreatedAt),
+		UpdatedAt: formatTime(u.UpdatedAt),
+	}
+}
+
+func appProto(app *apps.App) *App {
+	a := &App{
+		Id:          app.ID,
+		Name:        app.Name,
+		Description: app.Description,
+		OrgId:       app.OrgID,
+		CreatedAt:   formatTime(app.CreatedAt),
+		UpdatedAt:   formatTime(app.UpdatedAt),
+	}
+
+	if app.TOTP != nil {
+		a.Totp = &TOTP{
+			Issuer:    app.TOTP.Issuer,
+			Algorithm: string(app.TOTP.Algorithm),
+			Digits:    int32(app.TOTP.Digits),
+			Period:    int32(app.TOTP.Period),
+		}
+	}
+
+	return a
+}
+
+func appFromProto(a *App) apps.App {
+	app := apps.App{
+		ID:          a.Id,
+		Name:        a.Name,
+		Description: a.Description,
+		OrgID:       a.OrgId,
+	}
+
+	if t := a.Totp; t != nil {
+		// the algorithm is passed through as is, so that an unsupported one is rejected while
+		// validating the app
+		app.TOTP = totp.New(t.Issuer, int(t.Digits), int(t.Period), totp.Algo(t.Algorithm))
+	}
+
+	return app
+}
+
+// subjectApp returns the application authenticated by the API key, after making sure it's the
+// application requested
+func subjectApp(ctx context.Context, appID int64) (*apps.App, error) {
+	app := apikeys.AppFromContext(ctx)
+	if app == nil || app.ID != appID {
+		return nil, apikeys.ErrScope
+	}
+	return app, nil
+}
+
+func (s *Server) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+	source := ""
+	if rctx := s.appCtx.ReqContext(ctx); rctx != nil {
+		source = rctx.Source
+	}
+
+	u, token, err := s.api.Login(ctx, source, req.Email, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{User: userProto(u), Token: token}, nil
+}
+
+func (s *Server) AuthenticatedUser(ctx context.Context, req *AuthenticatedUserRequest) (*User, error) {
+	// the user is authenticated by the interceptor
+	u := users.FromContext(ctx)
+	if u == nil {
+		return nil, users.ErrSessionID
+	}
+	return userProto(u), nil
+}
+
+func (s *Server) CreateApp(ctx context.Context, req *App) (*App, error) {
+	app, err := s.api.CreateApp(ctx, appFromProto(req))
+	if err != nil {
+		return nil, err
+	}
+	return appProto(app), nil
+}
+
+func (s *Server) ListApps(ctx context.Context, req *ListAppsRequest) (*ListAppsResponse, error) {
+	list, total, err := s.api.ListApps(ctx, apps.ListFilter{
+		OrgID:  req.OrgId,
+		Name:   req.Name,
+		Offset: int(req.Offset),
+		Limit:  int(req.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ListAppsResponse{
+		Apps:  make([]*App, 0, len(list)),
+		Total: total,
+	}
+	for i := range list {
+		resp.Apps = append(resp.Apps, appProto(&list[i]))
+	}
+
+	return resp, nil
+}
+
+func (s *Server) GetApp(ctx context.Context, req *AppRequest) (*App, error) {
+	app, err := s.api.App(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return appProto(app), nil
+}
+
+func (s *Server) UpdateApp(ctx context.Context, req *App) (*App, error) {
+	app, err := s.api.UpdateApp(ctx, appFromProto(req))
+	if err != nil {
+		return nil, err
+	}
+	return appProto(app), nil
+}
+
+func (s *Server) DeleteApp(ctx context.Context, req *AppRequest) (*App, error) {
+	app, err := s.api.DeleteApp(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return appProto(app), nil
+}
+
+func (s *Server) EnrollSubject(ctx context.Context, req *SubjectRequest) (*Enrollment, error) {
+	app, err := subjectApp(ctx, req.AppId)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := s.api.EnrollSubject(ctx, app, req.SubjectId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{SubjectId: e.ExternalID, Secret: e.Secret, Uri: e.URI}, nil
+}
+
+func (s *Server) UnenrollSubject(ctx context.Context, req *SubjectRequest) (*UnenrollResponse, error) {
+	app, err := subjectApp(ctx, req.AppId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.api.UnenrollSubject(ctx, app, req.SubjectId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UnenrollResponse{}, nil
+}
+
+func (s *Server) VerifySubject(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
+	app, err := subjectApp(ctx, req.AppId)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.api.VerifySubject(ctx, app, req.SubjectId, req.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VerifyResponse{
+		Status:            string(result.Status),
+		RemainingAttempts: int32(result.RemainingAttempts),
+		LockedUntil:       formatTime(result.LockedUntil),
+	}, nil
+}
+
+// compile time check, the server should implement all the methods of the service
+var _ PadlockServer = (*Server)(nil)
@@ -0,0 +1,1268 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: padlock.proto
+
+package grpc
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type User struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Email                string   `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	Phone                string   `protobuf:"bytes,4,opt,name=phone,proto3" json:"phone,omitempty"`
+	Version              int64    `protobuf:"varint,5,opt,name=version,proto3" json:"version,omitempty"`
+	CreatedAt            string   `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt            string   `protobuf:"bytes,7,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *User) Reset()         { *m = User{} }
+func (m *User) String() string { return proto.CompactTextString(m) }
+func (*User) ProtoMessage()    {}
+func (*User) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{0}
+}
+
+func (m *User) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_User.Unmarshal(m, b)
+}
+func (m *User) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_User.Marshal(b, m, deterministic)
+}
+func (m *User) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_User.Merge(m, src)
+}
+func (m *User) XXX_Size() int {
+	return xxx_messageInfo_User.Size(m)
+}
+func (m *User) XXX_DiscardUnknown() {
+	xxx_messageInfo_User.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_User proto.InternalMessageInfo
+
+func (m *User) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *User) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *User) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *User) GetPhone() string {
+	if m != nil {
+		return m.Phone
+	}
+	return ""
+}
+
+func (m *User) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *User) GetCreatedAt() string {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return ""
+}
+
+func (m *User) GetUpdatedAt() string {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return ""
+}
+
+type LoginRequest struct {
+	Email                string   `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	Password             string   `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LoginRequest) Reset()         { *m = LoginRequest{} }
+func (m *LoginRequest) String() string { return proto.CompactTextString(m) }
+func (*LoginRequest) ProtoMessage()    {}
+func (*LoginRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{1}
+}
+
+func (m *LoginRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LoginRequest.Unmarshal(m, b)
+}
+func (m *LoginRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LoginRequest.Marshal(b, m, deterministic)
+}
+func (m *LoginRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LoginRequest.Merge(m, src)
+}
+func (m *LoginRequest) XXX_Size() int {
+	return xxx_messageInfo_LoginRequest.Size(m)
+}
+func (m *LoginRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LoginRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LoginRequest proto.InternalMessageInfo
+
+func (m *LoginRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *LoginRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+type LoginResponse struct {
+	User                 *User    `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Token                string   `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LoginResponse) Reset()         { *m = LoginResponse{} }
+func (m *LoginResponse) String() string { return proto.CompactTextString(m) }
+func (*LoginResponse) ProtoMessage()    {}
+func (*LoginResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{2}
+}
+
+func (m *LoginResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LoginResponse.Unmarshal(m, b)
+}
+func (m *LoginResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LoginResponse.Marshal(b, m, deterministic)
+}
+func (m *LoginResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LoginResponse.Merge(m, src)
+}
+func (m *LoginResponse) XXX_Size() int {
+	return xxx_messageInfo_LoginResponse.Size(m)
+}
+func (m *LoginResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_LoginResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LoginResponse proto.InternalMessageInfo
+
+func (m *LoginResponse) GetUser() *User {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
+
+func (m *LoginResponse) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+type AuthenticatedUserRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AuthenticatedUserRequest) Reset()         { *m = AuthenticatedUserRequest{} }
+func (m *AuthenticatedUserRequest) String() string { return proto.CompactTextString(m) }
+func (*AuthenticatedUserRequest) ProtoMessage()    {}
+func (*AuthenticatedUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{3}
+}
+
+func (m *AuthenticatedUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AuthenticatedUserRequest.Unmarshal(m, b)
+}
+func (m *AuthenticatedUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AuthenticatedUserRequest.Marshal(b, m, deterministic)
+}
+func (m *AuthenticatedUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AuthenticatedUserRequest.Merge(m, src)
+}
+func (m *AuthenticatedUserRequest) XXX_Size() int {
+	return xxx_messageInfo_AuthenticatedUserRequest.Size(m)
+}
+func (m *AuthenticatedUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AuthenticatedUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AuthenticatedUserRequest proto.InternalMessageInfo
+
+type TOTP struct {
+	Issuer string `protobuf:"bytes,1,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	// algorithm is one of SHA1, SHA256, SHA512
+	Algorithm            string   `protobuf:"bytes,2,opt,name=algorithm,proto3" json:"algorithm,omitempty"`
+	Digits               int32    `protobuf:"varint,3,opt,name=digits,proto3" json:"digits,omitempty"`
+	Period               int32    `protobuf:"varint,4,opt,name=period,proto3" json:"period,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TOTP) Reset()         { *m = TOTP{} }
+func (m *TOTP) String() string { return proto.CompactTextString(m) }
+func (*TOTP) ProtoMessage()    {}
+func (*TOTP) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{4}
+}
+
+func (m *TOTP) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TOTP.Unmarshal(m, b)
+}
+func (m *TOTP) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TOTP.Marshal(b, m, deterministic)
+}
+func (m *TOTP) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TOTP.Merge(m, src)
+}
+func (m *TOTP) XXX_Size() int {
+	return xxx_messageInfo_TOTP.Size(m)
+}
+func (m *TOTP) XXX_DiscardUnknown() {
+	xxx_messageInfo_TOTP.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TOTP proto.InternalMessageInfo
+
+func (m *TOTP) GetIssuer() string {
+	if m != nil {
+		return m.Issuer
+	}
+	return ""
+}
+
+func (m *TOTP) GetAlgorithm() string {
+	if m != nil {
+		return m.Algorithm
+	}
+	return ""
+}
+
+func (m *TOTP) GetDigits() int32 {
+	if m != nil {
+		return m.Digits
+	}
+	return 0
+}
+
+func (m *TOTP) GetPeriod() int32 {
+	if m != nil {
+		return m.Period
+	}
+	return 0
+}
+
+type App struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description          string   `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	OrgId                int64    `protobuf:"varint,4,opt,name=org_id,json=orgId,proto3" json:"org_id,omitempty"`
+	Totp                 *TOTP    `protobuf:"bytes,5,opt,name=totp,proto3" json:"totp,omitempty"`
+	CreatedAt            string   `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt            string   `protobuf:"bytes,7,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *App) Reset()         { *m = App{} }
+func (m *App) String() string { return proto.CompactTextString(m) }
+func (*App) ProtoMessage()    {}
+func (*App) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{5}
+}
+
+func (m *App) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_App.Unmarshal(m, b)
+}
+func (m *App) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_App.Marshal(b, m, deterministic)
+}
+func (m *App) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_App.Merge(m, src)
+}
+func (m *App) XXX_Size() int {
+	return xxx_messageInfo_App.Size(m)
+}
+func (m *App) XXX_DiscardUnknown() {
+	xxx_messageInfo_App.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_App proto.InternalMessageInfo
+
+func (m *App) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *App) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *App) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *App) GetOrgId() int64 {
+	if m != nil {
+		return m.OrgId
+	}
+	return 0
+}
+
+func (m *App) GetTotp() *TOTP {
+	if m != nil {
+		return m.Totp
+	}
+	return nil
+}
+
+func (m *App) GetCreatedAt() string {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return ""
+}
+
+func (m *App) GetUpdatedAt() string {
+	if m != nil {
+		return m.UpdatedAt
+	}
+	return ""
+}
+
+type AppRequest struct {
+	Id                   int64    `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AppRequest) Reset()         { *m = AppRequest{} }
+func (m *AppRequest) String() string { return proto.CompactTextString(m) }
+func (*AppRequest) ProtoMessage()    {}
+func (*AppRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{6}
+}
+
+func (m *AppRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AppRequest.Unmarshal(m, b)
+}
+func (m *AppRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AppRequest.Marshal(b, m, deterministic)
+}
+func (m *AppRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AppRequest.Merge(m, src)
+}
+func (m *AppRequest) XXX_Size() int {
+	return xxx_messageInfo_AppRequest.Size(m)
+}
+func (m *AppRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AppRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AppRequest proto.InternalMessageInfo
+
+func (m *AppRequest) GetId() int64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+type ListAppsRequest struct {
+	OrgId                int64    `protobuf:"varint,1,opt,name=org_id,json=orgId,proto3" json:"org_id,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Offset               int32    `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	Limit                int32    `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListAppsRequest) Reset()         { *m = ListAppsRequest{} }
+func (m *ListAppsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListAppsRequest) ProtoMessage()    {}
+func (*ListAppsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{7}
+}
+
+func (m *ListAppsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListAppsRequest.Unmarshal(m, b)
+}
+func (m *ListAppsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListAppsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListAppsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListAppsRequest.Merge(m, src)
+}
+func (m *ListAppsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListAppsRequest.Size(m)
+}
+func (m *ListAppsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListAppsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListAppsRequest proto.InternalMessageInfo
+
+func (m *ListAppsRequest) GetOrgId() int64 {
+	if m != nil {
+		return m.OrgId
+	}
+	return 0
+}
+
+func (m *ListAppsRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ListAppsRequest) GetOffset() int32 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *ListAppsRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type ListAppsResponse struct {
+	Apps                 []*App   `protobuf:"bytes,1,rep,name=apps,proto3" json:"apps,omitempty"`
+	Total                int64    `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListAppsResponse) Reset()         { *m = ListAppsResponse{} }
+func (m *ListAppsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListAppsResponse) ProtoMessage()    {}
+func (*ListAppsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{8}
+}
+
+func (m *ListAppsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListAppsResponse.Unmarshal(m, b)
+}
+func (m *ListAppsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListAppsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListAppsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListAppsResponse.Merge(m, src)
+}
+func (m *ListAppsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListAppsResponse.Size(m)
+}
+func (m *ListAppsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListAppsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListAppsResponse proto.InternalMessageInfo
+
+func (m *ListAppsResponse) GetApps() []*App {
+	if m != nil {
+		return m.Apps
+	}
+	return nil
+}
+
+func (m *ListAppsResponse) GetTotal() int64 {
+	if m != nil {
+		return m.Total
+	}
+	return 0
+}
+
+type SubjectRequest struct {
+	// app_id should be the application of the API key
+	AppId int64 `protobuf:"varint,1,opt,name=app_id,json=appId,proto3" json:"app_id,omitempty"`
+	// subject_id is the identifier of the user, as used by the application
+	SubjectId            string   `protobuf:"bytes,2,opt,name=subject_id,json=subjectId,proto3" json:"subject_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SubjectRequest) Reset()         { *m = SubjectRequest{} }
+func (m *SubjectRequest) String() string { return proto.CompactTextString(m) }
+func (*SubjectRequest) ProtoMessage()    {}
+func (*SubjectRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{9}
+}
+
+func (m *SubjectRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SubjectRequest.Unmarshal(m, b)
+}
+func (m *SubjectRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SubjectRequest.Marshal(b, m, deterministic)
+}
+func (m *SubjectRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SubjectRequest.Merge(m, src)
+}
+func (m *SubjectRequest) XXX_Size() int {
+	return xxx_messageInfo_SubjectRequest.Size(m)
+}
+func (m *SubjectRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SubjectRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SubjectRequest proto.InternalMessageInfo
+
+func (m *SubjectRequest) GetAppId() int64 {
+	if m != nil {
+		return m.AppId
+	}
+	return 0
+}
+
+func (m *SubjectRequest) GetSubjectId() string {
+	if m != nil {
+		return m.SubjectId
+	}
+	return ""
+}
+
+type Enrollment struct {
+	SubjectId            string   `protobuf:"bytes,1,opt,name=subject_id,json=subjectId,proto3" json:"subject_id,omitempty"`
+	Secret               string   `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
+	Uri                  string   `protobuf:"bytes,3,opt,name=uri,proto3" json:"uri,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Enrollment) Reset()         { *m = Enrollment{} }
+func (m *Enrollment) String() string { return proto.CompactTextString(m) }
+func (*Enrollment) ProtoMessage()    {}
+func (*Enrollment) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{10}
+}
+
+func (m *Enrollment) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Enrollment.Unmarshal(m, b)
+}
+func (m *Enrollment) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Enrollment.Marshal(b, m, deterministic)
+}
+func (m *Enrollment) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Enrollment.Merge(m, src)
+}
+func (m *Enrollment) XXX_Size() int {
+	return xxx_messageInfo_Enrollment.Size(m)
+}
+func (m *Enrollment) XXX_DiscardUnknown() {
+	xxx_messageInfo_Enrollment.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Enrollment proto.InternalMessageInfo
+
+func (m *Enrollment) GetSubjectId() string {
+	if m != nil {
+		return m.SubjectId
+	}
+	return ""
+}
+
+func (m *Enrollment) GetSecret() string {
+	if m != nil {
+		return m.Secret
+	}
+	return ""
+}
+
+func (m *Enrollment) GetUri() string {
+	if m != nil {
+		return m.Uri
+	}
+	return ""
+}
+
+type UnenrollResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UnenrollResponse) Reset()         { *m = UnenrollResponse{} }
+func (m *UnenrollResponse) String() string { return proto.CompactTextString(m) }
+func (*UnenrollResponse) ProtoMessage()    {}
+func (*UnenrollResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{11}
+}
+
+func (m *UnenrollResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnenrollResponse.Unmarshal(m, b)
+}
+func (m *UnenrollResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnenrollResponse.Marshal(b, m, deterministic)
+}
+func (m *UnenrollResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnenrollResponse.Merge(m, src)
+}
+func (m *UnenrollResponse) XXX_Size() int {
+	return xxx_messageInfo_UnenrollResponse.Size(m)
+}
+func (m *UnenrollResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnenrollResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UnenrollResponse proto.InternalMessageInfo
+
+type VerifyRequest struct {
+	AppId                int64    `protobuf:"varint,1,opt,name=app_id,json=appId,proto3" json:"app_id,omitempty"`
+	SubjectId            string   `protobuf:"bytes,2,opt,name=subject_id,json=subjectId,proto3" json:"subject_id,omitempty"`
+	Code                 string   `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *VerifyRequest) Reset()         { *m = VerifyRequest{} }
+func (m *VerifyRequest) String() string { return proto.CompactTextString(m) }
+func (*VerifyRequest) ProtoMessage()    {}
+func (*VerifyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{12}
+}
+
+func (m *VerifyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_VerifyRequest.Unmarshal(m, b)
+}
+func (m *VerifyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_VerifyRequest.Marshal(b, m, deterministic)
+}
+func (m *VerifyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_VerifyRequest.Merge(m, src)
+}
+func (m *VerifyRequest) XXX_Size() int {
+	return xxx_messageInfo_VerifyRequest.Size(m)
+}
+func (m *VerifyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_VerifyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_VerifyRequest proto.InternalMessageInfo
+
+func (m *VerifyRequest) GetAppId() int64 {
+	if m != nil {
+		return m.AppId
+	}
+	return 0
+}
+
+func (m *VerifyRequest) GetSubjectId() string {
+	if m != nil {
+		return m.SubjectId
+	}
+	return ""
+}
+
+func (m *VerifyRequest) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+type VerifyResponse struct {
+	// status is one of valid, invalid, replayed, locked_out
+	Status               string   `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	RemainingAttempts    int32    `protobuf:"varint,2,opt,name=remaining_attempts,json=remainingAttempts,proto3" json:"remaining_attempts,omitempty"`
+	LockedUntil          string   `protobuf:"bytes,3,opt,name=locked_until,json=lockedUntil,proto3" json:"locked_until,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *VerifyResponse) Reset()         { *m = VerifyResponse{} }
+func (m *VerifyResponse) String() string { return proto.CompactTextString(m) }
+func (*VerifyResponse) ProtoMessage()    {}
+func (*VerifyResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_25a7d5c9fb1b1fc1, []int{13}
+}
+
+func (m *VerifyResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_VerifyResponse.Unmarshal(m, b)
+}
+func (m *VerifyResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_VerifyResponse.Marshal(b, m, deterministic)
+}
+func (m *VerifyResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_VerifyResponse.Merge(m, src)
+}
+func (m *VerifyResponse) XXX_Size() int {
+	return xxx_messageInfo_VerifyResponse.Size(m)
+}
+func (m *VerifyResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_VerifyResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_VerifyResponse proto.InternalMessageInfo
+
+func (m *VerifyResponse) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *VerifyResponse) GetRemainingAttempts() int32 {
+	if m != nil {
+		return m.RemainingAttempts
+	}
+	return 0
+}
+
+func (m *VerifyResponse) GetLockedUntil() string {
+	if m != nil {
+		return m.LockedUntil
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*User)(nil), "padlock.v1.User")
+	proto.RegisterType((*LoginRequest)(nil), "padlock.v1.LoginRequest")
+	proto.RegisterType((*LoginResponse)(nil), "padlock.v1.LoginResponse")
+	proto.RegisterType((*AuthenticatedUserRequest)(nil), "padlock.v1.AuthenticatedUserRequest")
+	proto.RegisterType((*TOTP)(nil), "padlock.v1.TOTP")
+	proto.RegisterType((*App)(nil), "padlock.v1.App")
+	proto.RegisterType((*AppRequest)(nil), "padlock.v1.AppRequest")
+	proto.RegisterType((*ListAppsRequest)(nil), "padlock.v1.ListAppsRequest")
+	proto.RegisterType((*ListAppsResponse)(nil), "padlock.v1.ListAppsResponse")
+	proto.RegisterType((*SubjectRequest)(nil), "padlock.v1.SubjectRequest")
+	proto.RegisterType((*Enrollment)(nil), "padlock.v1.Enrollment")
+	proto.RegisterType((*UnenrollResponse)(nil), "padlock.v1.UnenrollResponse")
+	proto.RegisterType((*VerifyRequest)(nil), "padlock.v1.VerifyRequest")
+	proto.RegisterType((*VerifyResponse)(nil), "padlock.v1.VerifyResponse")
+}
+
+func init() { proto.RegisterFile("padlock.proto", fileDescriptor_25a7d5c9fb1b1fc1) }
+
+var fileDescriptor_25a7d5c9fb1b1fc1 = []byte{
+	// 797 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x55, 0xdd, 0x6e, 0xe3, 0x44,
+	0x14, 0x96, 0x9b, 0x38, 0xdd, 0x9c, 0x6c, 0xda, 0xec, 0x08, 0x2a, 0x63, 0x8a, 0x14, 0xcc, 0x5e,
+	0x54, 0x42, 0x49, 0xb4, 0x85, 0x3b, 0xb8, 0x20, 0x2c, 0xbb, 0xa8, 0x6a, 0x11, 0x95, 0x69, 0x90,
+	0xe0, 0x26, 0x9a, 0xd8, 0x53, 0x67, 0x5a, 0xdb, 0x33, 0xcc, 0x8c, 0x8b, 0xe0, 0xa1, 0x78, 0x0c,
+	0x9e, 0x8a, 0x0b, 0x34, 0x3f, 0x6e, 0xec, 0x24, 0x48, 0x45, 0xbd, 0x89, 0xfc, 0x9d, 0x33, 0x73,
+	0xce, 0x77, 0x7e, 0xbe, 0x09, 0x0c, 0x39, 0x4e, 0x73, 0x96, 0xdc, 0x4f, 0xb9, 0x60, 0x8a, 0x21,
+	0xa8, 0xe1, 0xc3, 0x9b, 0xe8, 0x2f, 0x0f, 0xba, 0x0b, 0x49, 0x04, 0x3a, 0x82, 0x03, 0x9a, 0x06,
+	0xde, 0xd8, 0x3b, 0xeb, 0xc4, 0x07, 0x34, 0x45, 0x08, 0xba, 0x25, 0x2e, 0x48, 0x70, 0x30, 0xf6,
+	0xce, 0xfa, 0xb1, 0xf9, 0x46, 0x1f, 0x80, 0x4f, 0x0a, 0x4c, 0xf3, 0xa0, 0x63, 0x8c, 0x16, 0x68,
+	0x2b, 0x5f, 0xb3, 0x92, 0x04, 0x5d, 0x6b, 0x35, 0x00, 0x05, 0x70, 0xf8, 0x40, 0x84, 0xa4, 0xac,
+	0x0c, 0x7c, 0x13, 0xb4, 0x86, 0xe8, 0x13, 0x80, 0x44, 0x10, 0xac, 0x48, 0xba, 0xc4, 0x2a, 0xe8,
+	0x99, 0x4b, 0x7d, 0x67, 0x99, 0x2b, 0xed, 0xae, 0x78, 0x5a, 0xbb, 0x0f, 0xad, 0xdb, 0x59, 0xe6,
+	0x2a, 0xfa, 0x06, 0x5e, 0x5e, 0xb1, 0x8c, 0x96, 0x31, 0xf9, 0xad, 0x22, 0x52, 0x6d, 0x38, 0x79,
+	0x4d, 0x4e, 0x21, 0xbc, 0xe0, 0x58, 0xca, 0xdf, 0x99, 0x48, 0x5d, 0x05, 0x8f, 0x38, 0xba, 0x84,
+	0xa1, 0x8b, 0x20, 0x39, 0x2b, 0x25, 0x41, 0xaf, 0xa1, 0x5b, 0x49, 0x22, 0x4c, 0x84, 0xc1, 0xf9,
+	0x68, 0xba, 0x69, 0xcf, 0x54, 0xb7, 0x26, 0x36, 0x5e, 0x9d, 0x48, 0xb1, 0x7b, 0x52, 0xba, 0x78,
+	0x16, 0x44, 0x21, 0x04, 0xf3, 0x4a, 0xad, 0x49, 0xa9, 0x68, 0xa2, 0x19, 0x9a, 0x0b, 0x96, 0x5a,
+	0x94, 0x43, 0xf7, 0xe6, 0xc7, 0x9b, 0x6b, 0x74, 0x02, 0x3d, 0x2a, 0x65, 0xe5, 0x32, 0xf4, 0x63,
+	0x87, 0xd0, 0x29, 0xf4, 0x71, 0x9e, 0x31, 0x41, 0xd5, 0xba, 0x70, 0x51, 0x37, 0x06, 0x7d, 0x2b,
+	0xa5, 0x19, 0x55, 0xd2, 0x74, 0xdb, 0x8f, 0x1d, 0xd2, 0x76, 0x4e, 0x04, 0x65, 0xa9, 0xe9, 0xb7,
+	0x1f, 0x3b, 0x14, 0xfd, 0xed, 0x41, 0x67, 0xce, 0xf9, 0x93, 0x06, 0x39, 0x86, 0x41, 0x4a, 0x64,
+	0x22, 0x28, 0x57, 0x7a, 0x40, 0x76, 0x9c, 0x4d, 0x13, 0xfa, 0x10, 0x7a, 0x4c, 0x64, 0x4b, 0x6a,
+	0xb3, 0x74, 0x62, 0x9f, 0x89, 0xec, 0x22, 0xd5, 0xad, 0x52, 0x4c, 0xf1, 0xc0, 0xdf, 0x6d, 0x95,
+	0x2e, 0x35, 0x36, 0xde, 0x67, 0x4e, 0xf8, 0x14, 0x60, 0xce, 0x79, 0x3d, 0xdf, 0xad, 0x72, 0xa2,
+	0x3b, 0x38, 0xbe, 0xa2, 0x52, 0xcd, 0x39, 0x97, 0xf5, 0x91, 0x0d, 0x57, 0xaf, 0xc9, 0x75, 0x5f,
+	0xe1, 0x27, 0xd0, 0x63, 0xb7, 0xb7, 0x92, 0xa8, 0xba, 0xa9, 0x16, 0xe9, 0xe1, 0xe6, 0xb4, 0xa0,
+	0xca, 0xf5, 0xd4, 0x82, 0xe8, 0x07, 0x18, 0x6d, 0x72, 0xb9, 0x65, 0xf9, 0x0c, 0xba, 0x98, 0x73,
+	0x19, 0x78, 0xe3, 0xce, 0xd9, 0xe0, 0xfc, 0xb8, 0xd9, 0x01, 0xcd, 0xda, 0x38, 0xed, 0xae, 0x28,
+	0x9c, 0x9b, 0xdc, 0x9d, 0xd8, 0x82, 0xe8, 0x3d, 0x1c, 0xfd, 0x54, 0xad, 0xee, 0x48, 0xa2, 0x1a,
+	0xcc, 0x31, 0xe7, 0x0d, 0xe6, 0x98, 0xf3, 0x8b, 0x54, 0x37, 0x48, 0xda, 0x83, 0xda, 0xe5, 0x36,
+	0xc3, 0x59, 0x2e, 0xd2, 0x68, 0x01, 0xf0, 0xae, 0x14, 0x2c, 0xcf, 0x0b, 0x52, 0xaa, 0xad, 0xc3,
+	0xde, 0xd6, 0x61, 0x5d, 0xb1, 0x24, 0x89, 0x20, 0xca, 0xc5, 0x71, 0x08, 0x8d, 0xa0, 0x53, 0x09,
+	0xea, 0x46, 0xaf, 0x3f, 0x23, 0x04, 0xa3, 0x45, 0x49, 0x4c, 0xe0, 0xba, 0xda, 0xe8, 0x17, 0x18,
+	0xfe, 0x4c, 0x04, 0xbd, 0xfd, 0xe3, 0x59, 0x8c, 0xf5, 0x28, 0x12, 0x96, 0x12, 0x97, 0xcd, 0x7c,
+	0x47, 0x7f, 0xc2, 0x51, 0x1d, 0xda, 0xb5, 0x56, 0x53, 0x55, 0x58, 0x55, 0xb2, 0xd6, 0x89, 0x45,
+	0x68, 0x02, 0x48, 0x68, 0x59, 0x97, 0xb4, 0xcc, 0x96, 0x58, 0x29, 0x52, 0x70, 0x25, 0x4d, 0x12,
+	0x3f, 0x7e, 0xf5, 0xe8, 0x99, 0x3b, 0x07, 0xfa, 0x14, 0x5e, 0xea, 0x89, 0x90, 0x74, 0x59, 0x95,
+	0xea, 0xf1, 0xb1, 0x1a, 0x58, 0xdb, 0x42, 0x9b, 0xce, 0xff, 0xe9, 0xc2, 0xe1, 0xb5, 0x1d, 0x1c,
+	0xfa, 0x1a, 0x7c, 0xf3, 0x1c, 0xa0, 0xa0, 0x39, 0xcb, 0xe6, 0x1b, 0x13, 0x7e, 0xb4, 0xc7, 0xe3,
+	0x38, 0x5f, 0xc2, 0xab, 0x1d, 0xfd, 0xa3, 0xd7, 0xad, 0xad, 0xf8, 0x8f, 0xe7, 0x21, 0xdc, 0x79,
+	0x68, 0xd0, 0x04, 0xfa, 0x6f, 0x8d, 0x4a, 0xb4, 0x8e, 0xb7, 0x57, 0x2b, 0xdc, 0x36, 0xa0, 0x77,
+	0xf0, 0xa2, 0x5e, 0x4f, 0xf4, 0x71, 0x8b, 0x62, 0x5b, 0x20, 0xe1, 0xe9, 0x7e, 0xa7, 0x2b, 0xe1,
+	0x0d, 0xf4, 0xbe, 0x27, 0xda, 0x84, 0x4e, 0xb6, 0xb7, 0xd9, 0xdd, 0xdf, 0xc9, 0x3c, 0x81, 0xfe,
+	0xc2, 0xe8, 0xf5, 0x69, 0x44, 0xbf, 0x84, 0xfe, 0x77, 0x24, 0x27, 0x8a, 0xfc, 0xaf, 0x24, 0x6f,
+	0x61, 0x68, 0xd7, 0xdc, 0x89, 0x06, 0x85, 0xcd, 0x13, 0x6d, 0x25, 0x85, 0xad, 0xa8, 0x0d, 0x75,
+	0x5c, 0xc2, 0x71, 0xbd, 0xd4, 0x4f, 0x09, 0xd3, 0xea, 0xd4, 0xb6, 0x1a, 0xd0, 0xfb, 0x5a, 0x0d,
+	0x75, 0xa8, 0xd6, 0x62, 0xb4, 0x84, 0x12, 0x86, 0xfb, 0x5c, 0x36, 0xce, 0xb7, 0x93, 0x5f, 0x3f,
+	0xcf, 0xa8, 0x5a, 0x57, 0xab, 0x69, 0xc2, 0x8a, 0xd9, 0xaa, 0xbc, 0xc7, 0x05, 0xce, 0x89, 0x5c,
+	0xcf, 0xdc, 0x95, 0x19, 0xe6, 0x74, 0x96, 0x09, 0x9e, 0x7c, 0xa5, 0x7f, 0x56, 0x3d, 0xf3, 0xb7,
+	0xfd, 0xc5, 0xbf, 0x03, 0x00, 0xc6, 0x86, 0xb0, 0x66, 0xc7, 0x07, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// PadlockClient is the client API for Padlock service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type PadlockClient interface {
+	// Login signs in with email & password, the session token is returned in the response
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	// AuthenticatedUser validates the session token, and returns the user it belongs to
+	AuthenticatedUser(ctx context.Context, in *AuthenticatedUserRequest, opts ...grpc.CallOption) (*User, error)
+	CreateApp(ctx context.Context, in *App, opts ...grpc.CallOption) (*App, error)
+	ListApps(ctx context.Context, in *ListAppsRequest, opts ...grpc.CallOption) (*ListAppsResponse, error)
+	GetApp(ctx context.Context, in *AppRequest, opts ...grpc.CallOption) (*App, error)
+	// UpdateApp updates the non-empty fields of the application
+	UpdateApp(ctx context.Context, in *App, opts ...grpc.CallOption) (*App, error)
+	DeleteApp(ctx context.Context, in *AppRequest, opts ...grpc.CallOption) (*App, error)
+	// EnrollSubject enrolls a user of the application for TOTP verification
+	EnrollSubject(ctx context.Context, in *SubjectRequest, opts ...grpc.CallOption) (*Enrollment, error)
+	UnenrollSubject(ctx context.Context, in *SubjectRequest, opts ...grpc.CallOption) (*UnenrollResponse, error)
+	VerifySubject(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error)
+}
+
+type padlockClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewPadlockClient(cc *grpc.ClientConn) PadlockClient {
+	return &padlockClient{cc}
+}
+
+func (c *padlockClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, "/padlock.v1.Padlock/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *padlockClient) AuthenticatedUser(ctx context.Context, in *AuthenticatedUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/padlock.v1.Padlock/AuthenticatedUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *padlockClient) CreateApp(ctx context.Context, in *App, opts ...grpc.CallOption) (*App, error) {
+	out := new(App)
+	err := c.cc.Invoke(ctx, "/padlock.v1.Padlock/CreateApp", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *padlockClient) ListApps(ctx context.Context, in *ListAppsRequest, opts ...grpc.CallOption) (*ListAppsResponse, error) {
+	out := new(ListAppsResponse)
+	err := c.cc.Invoke(ctx, "/padlock.v1.Padlock/ListApps", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *padlockClient) GetApp(ctx context.Context, in *AppRequest, opts ...grpc.CallOption) (*App, error) {
+	out := new(App)
+	err := c.cc.Invoke(ctx, "/padlock.v1.Padlock/GetApp", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *padlockClient) UpdateApp(ctx context.Context, in *App, opts ...grpc.CallOption) (*App, error) {
+	out := new(App)
+	err := c.cc.Invoke(ctx, "/padlock.v1.Padlock/UpdateApp", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *padlockClient) DeleteApp(ctx context.Context, in *AppRequest, opts ...grpc.CallOption) (*App, error) {
+	out := new(App)
+	err := c.cc.Invoke(ctx, "/padlock.v1.Padlock/DeleteApp", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *padlockClient) EnrollSubject(ctx context.Context, in *SubjectRequest, opts ...grpc.CallOption) (*Enrollment, error) {
+	out := new(Enrollment)
+	err := c.cc.Invoke(ctx, "/padlock.v1.Padlock/EnrollSubject", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *padlockClient) UnenrollSubject(ctx context.Context, in *SubjectRequest, opts ...grpc.CallOption) (*UnenrollResponse, error) {
+	out := new(UnenrollResponse)
+	err := c.cc.Invoke(ctx, "/padlock.v1.Padlock/UnenrollSubject", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *padlockClient) VerifySubject(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error) {
+	out := new(VerifyResponse)
+	err := c.cc.Invoke(ctx, "/padlock.v1.Padlock/VerifySubject", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PadlockServer is the server API for Padlock service.
+type PadlockServer interface {
+	// Login signs in with email & password, the session token is returned in the response
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	// AuthenticatedUser validates the session token, and returns the user it belongs to
+	AuthenticatedUser(context.Context, *AuthenticatedUserRequest) (*User, error)
+	CreateApp(context.Context, *App) (*App, error)
+	ListApps(context.Context, *ListAppsRequest) (*ListAppsResponse, error)
+	GetApp(context.Context, *AppRequest) (*App, error)
+	// UpdateApp updates the non-empty fields of the application
+	UpdateApp(context.Context, *App) (*App, error)
+	DeleteApp(context.Context, *AppRequest) (*App, error)
+	// EnrollSubject enrolls a user of the application for TOTP verification
+	EnrollSubject(context.Context, *SubjectRequest) (*Enrollment, error)
+	UnenrollSubject(context.Context, *SubjectRequest) (*UnenrollResponse, error)
+	VerifySubject(context.Context, *VerifyRequest) (*VerifyResponse, error)
+}
+
+// UnimplementedPadlockServer can be embedded to have forward compatible implementations.
+type UnimplementedPadlockServer struct {
+}
+
+func (*UnimplementedPadlockServer) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (*UnimplementedPadlockServer) AuthenticatedUser(ctx context.Context, req *AuthenticatedUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AuthenticatedUser not implemented")
+}
+func (*UnimplementedPadlockServer) CreateApp(ctx context.Context, req *App) (*App, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateApp not implemented")
+}
+func (*UnimplementedPadlockServer) ListApps(ctx context.Context, req *ListAppsRequest) (*ListAppsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListApps not implemented")
+}
+func (*UnimplementedPadlockServer) GetApp(ctx context.Context, req *AppRequest) (*App, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetApp not implemented")
+}
+func (*UnimplementedPadlockServer) UpdateApp(ctx context.Context, req *App) (*App, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateApp not implemented")
+}
+func (*UnimplementedPadlockServer) DeleteApp(ctx context.Context, req *AppRequest) (*App, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteApp not implemented")
+}
+func (*UnimplementedPadlockServer) EnrollSubject(ctx context.Context, req *SubjectRequest) (*Enrollment, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnrollSubject not implemented")
+}
+func (*UnimplementedPadlockServer) UnenrollSubject(ctx context.Context, req *SubjectRequest) (*UnenrollResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnenrollSubject not implemented")
+}
+func (*UnimplementedPadlockServer) VerifySubject(ctx context.Context, req *VerifyRequest) (*VerifyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifySubject not implemented")
+}
+
+func RegisterPadlockServer(s *grpc.Server, srv PadlockServer) {
+	s.RegisterService(&_Padlock_serviceDesc, srv)
+}
+
+func _Padlock_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PadlockServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/padlock.v1.Padlock/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PadlockServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Padlock_AuthenticatedUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuthenticatedUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PadlockServer).AuthenticatedUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/padlock.v1.Padlock/AuthenticatedUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PadlockServer).AuthenticatedUser(ctx, req.(*AuthenticatedUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Padlock_CreateApp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(App)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PadlockServer).CreateApp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/padlock.v1.Padlock/CreateApp",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PadlockServer).CreateApp(ctx, req.(*App))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Padlock_ListApps_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAppsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PadlockServer).ListApps(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/padlock.v1.Padlock/ListApps",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PadlockServer).ListApps(ctx, req.(*ListAppsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Padlock_GetApp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PadlockServer).GetApp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/padlock.v1.Padlock/GetApp",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PadlockServer).GetApp(ctx, req.(*AppRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Padlock_UpdateApp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(App)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PadlockServer).UpdateApp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/padlock.v1.Padlock/UpdateApp",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PadlockServer).UpdateApp(ctx, req.(*App))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Padlock_DeleteApp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PadlockServer).DeleteApp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/padlock.v1.Padlock/DeleteApp",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PadlockServer).DeleteApp(ctx, req.(*AppRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Padlock_EnrollSubject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PadlockServer).EnrollSubject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/padlock.v1.Padlock/EnrollSubject",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PadlockServer).EnrollSubject(ctx, req.(*SubjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Padlock_UnenrollSubject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PadlockServer).UnenrollSubject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/padlock.v1.Padlock/UnenrollSubject",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PadlockServer).UnenrollSubject(ctx, req.(*SubjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Padlock_VerifySubject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PadlockServer).VerifySubject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/padlock.v1.Padlock/VerifySubject",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PadlockServer).VerifySubject(ctx, req.(*VerifyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Padlock_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "padlock.v1.Padlock",
+	HandlerType: (*PadlockServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Login",
+			Handler:    _Padlock_Login_Handler,
+		},
+		{
+			MethodName: "AuthenticatedUser",
+			Handler:    _Padlock_AuthenticatedUser_Handler,
+		},
+		{
+			MethodName: "CreateApp",
+			Handler:    _Padlock_CreateApp_Handler,
+		},
+		{
+			MethodName: "ListApps",
+			Handler:    _Padlock_ListApps_Handler,
+		},
+		{
+			MethodName: "GetApp",
+			Handler:    _Padlock_GetApp_Handler,
+		},
+		{
+			MethodName: "UpdateApp",
+			Handler:    _Padlock_UpdateApp_Handler,
+		},
+		{
+			MethodName: "DeleteApp",
+			Handler:    _Padlock_DeleteApp_Handler,
+		},
+		{
+			MethodName: "EnrollSubject",
+			Handler:    _Padlock_EnrollSubject_Handler,
+		},
+		{
+			MethodName: "UnenrollSubject",
+			Handler:    _Padlock_UnenrollSubject_Handler,
+		},
+		{
+			MethodName: "VerifySubject",
+			Handler:    _Padlock_VerifySubject_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "padlock.proto",
+}
@@ -0,0 +1,71 @@
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/bnkamalesh/padlock/api/apitest"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/health"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// testClient serves the API of the fixture on a local port, and returns a client connected to it
+func testClient(t *testing.T, f *apitest.Fixture) PadlockClient {
+	t.Helper()
+
+	s, err := NewServer("127.0.0.1", "0", f.API, f.AppCtx, health.New(health.DefaultConfig, f.AppCtx.Logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.server.Serve(listener)
+	t.Cleanup(s.server.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return NewPadlockClient(conn)
+}
+
+func TestCreateAppTOTP(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		f := apitest.New(t, driver, db)
+		jane := f.Admin(t, users.User{Name: "Jane", Email: "jane@example.com"})
+		orgID := databasetest.Org(t, driver, db, "acme")
+		client := testClient(t, f)
+
+		login, err := client.Login(context.Background(), &LoginRequest{Email: jane.Email, Password: apitest.Password})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", login.Token)
+
+		_, err = client.CreateApp(ctx, &App{Name: "billing", OrgId: orgID, Totp: &TOTP{Algorithm: "MD5"}})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument for an unsupported algorithm, got %v", err)
+		}
+
+		app, err := client.CreateApp(ctx, &App{Name: "billing", OrgId: orgID, Totp: &TOTP{Algorithm: "SHA512", Digits: 8}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if app.Totp == nil || app.Totp.Algorithm != "SHA512" || app.Totp.Digits != 8 {
+			t.Fatalf("expected the TOTP settings to be saved, got %+v", app.Totp)
+		}
+	})
+}
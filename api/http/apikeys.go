@@ -0,0 +1,109 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
+)
+
+// APIKeyAuthentication authenticates the application calling Padlock, using the API key in the
+// 'X-API-Key' header. The application & key are made available in the request context
+func (s *Server) APIKeyAuthentication(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		errResponse(w, apikeys.ErrInvalidKey)
+		return
+	}
+
+	ctx := r.Context()
+	app, k, err := s.api.AuthenticatedApp(ctx, key)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	*r = *r.WithContext(apikeys.SetContext(ctx, app, k))
+}
+
+// APIKeyScope returns a middleware which allows the request to proceed only if the API key is
+// allowed the scope. It should be used after APIKeyAuthentication
+func (s *Server) APIKeyScope(scope apikeys.Scope) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		k := apikeys.FromContext(r.Context())
+		if k == nil || !k.Allows(scope) {
+			errResponse(w, apikeys.ErrScope)
+			return
+		}
+	}
+}
+
+// CreateAPIKey creates an API key for the application
+func (s *Server) CreateAPIKey(w http.ResponseWriter, req *http.Request) {
+	id, ok := appID(w, req)
+	if !ok {
+		return
+	}
+
+	k := apikeys.APIKey{}
+	err := json.NewDecoder(req.Body).Decode(&k)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+	k.AppID = id
+
+	created, err := s.api.CreateAPIKey(req.Context(), k)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R201(w, created)
+}
+
+// APIKeys lists the API keys of the application
+func (s *Server) APIKeys(w http.ResponseWriter, req *http.Request) {
+	id, ok := appID(w, req)
+	if !ok {
+		return
+	}
+
+	list, err := s.api.APIKeys(req.Context(), id)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// RevokeAPIKey revokes an API key of the application
+func (s *Server) RevokeAPIKey(w http.ResponseWriter, req *http.Request) {
+	id, ok := appID(w, req)
+	if !ok {
+		return
+	}
+
+	keyID, err := strconv.ParseInt(webgo.Context(req).Params["id"], 10, 64)
+	if err != nil {
+		errResponse(w, apikeys.ErrNotFound)
+		return
+	}
+
+	err = s.api.RevokeAPIKey(req.Context(), id, keyID)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
+
+// AuthenticatedApp responds with the application authenticated by the API key
+func (s *Server) AuthenticatedApp(w http.ResponseWriter, req *http.Request) {
+	webgo.R200(w, apikeys.AppFromContext(req.Context()))
+}
@@ -0,0 +1,212 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+)
+
+// appID returns the application ID from the URI parameters, it responds with 404 and returns
+// false if the ID is invalid
+func appID(w http.ResponseWriter, req *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(webgo.Context(req).Params["appID"], 10, 64)
+	if err != nil || id < 1 {
+		errResponse(w, apps.ErrNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeApp decodes the application in the request body, it responds with 400 and returns false
+// if the payload is invalid
+func decodeApp(w http.ResponseWriter, req *http.Request) (apps.App, bool) {
+	app := apps.App{}
+	err := json.NewDecoder(req.Body).Decode(&app)
+	if err != nil {
+		errResponse(w, apperr.New(apperr.CodeInvalidInput, "Sorry, invalid application payload: "+err.Error()))
+		return app, false
+	}
+	return app, true
+}
+
+// CreateApp registers a new application
+func (s *Server) CreateApp(w http.ResponseWriter, req *http.Request) {
+	app, ok := decodeApp(w, req)
+	if !ok {
+		return
+	}
+
+	created, err := s.api.CreateApp(req.Context(), app)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R201(w, created)
+}
+
+// ListApps lists the applications accessible by the authenticated user, filtered & paginated
+// based on the query parameters
+func (s *Server) ListApps(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	filter := apps.ListFilter{
+		Name: q.Get("name"),
+	}
+
+	for key, ptr := range map[string]*int{"offset": &filter.Offset, "limit": &filter.Limit} {
+		str := q.Get(key)
+		if str == "" {
+			continue
+		}
+		v, err := strconv.Atoi(str)
+		if err != nil {
+			errResponse(w, apperr.New(apperr.CodeInvalidInput, "Sorry, "+key+" should be a number"))
+			return
+		}
+		*ptr = v
+	}
+
+	if str := q.Get("orgId"); str != "" {
+		orgID, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			errResponse(w, apps.ErrInvalidOrg)
+			return
+		}
+		filter.OrgID = orgID
+	}
+
+	list, total, err := s.api.ListApps(req.Context(), filter)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, AppsList{Apps: list, Total: total})
+}
+
+// App responds with the details of an application
+func (s *Server) App(w http.ResponseWriter, req *http.Request) {
+	id, ok := appID(w, req)
+	if !ok {
+		return
+	}
+
+	app, err := s.api.App(req.Context(), id)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, app)
+}
+
+// UpdateApp updates the non-empty details of an application
+func (s *Server) UpdateApp(w http.ResponseWriter, req *http.Request) {
+	id, ok := appID(w, req)
+	if !ok {
+		return
+	}
+
+	app, ok := decodeApp(w, req)
+	if !ok {
+		return
+	}
+	app.ID = id
+
+	updated, err := s.api.UpdateApp(req.Context(), app)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, updated)
+}
+
+// DeleteApp deletes an application
+func (s *Server) DeleteApp(w http.ResponseWriter, req *http.Request) {
+	id, ok := appID(w, req)
+	if !ok {
+		return
+	}
+
+	_, err := s.api.DeleteApp(req.Context(), id)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
+
+// AppOwners lists the direct owners of an application
+func (s *Server) AppOwners(w http.ResponseWriter, req *http.Request) {
+	id, ok := appID(w, req)
+	if !ok {
+		return
+	}
+
+	list, err := s.api.AppOwners(req.Context(), id)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// ownerID returns the user ID of the owner from the URI parameters
+func ownerID(w http.ResponseWriter, req *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(webgo.Context(req).Params["userID"], 10, 64)
+	if err != nil || id < 1 {
+		errResponse(w, apps.ErrOwnerNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+// SetAppOwner makes a user an owner of an application
+func (s *Server) SetAppOwner(w http.ResponseWriter, req *http.Request) {
+	id, ok := appID(w, req)
+	if !ok {
+		return
+	}
+
+	userID, ok := ownerID(w, req)
+	if !ok {
+		return
+	}
+
+	app, err := s.api.SetAppOwner(req.Context(), id, userID)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, app)
+}
+
+// RemoveAppOwner removes a user from the direct owners of an application
+func (s *Server) RemoveAppOwner(w http.ResponseWriter, req *http.Request) {
+	id, ok := appID(w, req)
+	if !ok {
+		return
+	}
+
+	userID, ok := ownerID(w, req)
+	if !ok {
+		return
+	}
+
+	err := s.api.RemoveAppOwner(req.Context(), id, userID)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
@@ -0,0 +1,612 @@
+package http
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/api/apitest"
+	"github.com/bnkamalesh/padlock/pkg/invites"
+	"github.com/bnkamalesh/padlock/pkg/orgs"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/health"
+	"github.com/bnkamalesh/padlock/pkg/push"
+	"github.com/bnkamalesh/padlock/pkg/users"
+	"github.com/bnkamalesh/padlock/pkg/webauthn"
+)
+
+var (
+	codeRegex   = regexp.MustCompile(`code is (\d+)`)
+	linkRegex   = regexp.MustCompile(regexp.QuoteMeta(users.MagicLinkPath) + `([\w-]+)`)
+	revertRegex = regexp.MustCompile(regexp.QuoteMeta(users.ContactRevertPath) + `(\S+)`)
+	inviteRegex = regexp.MustCompile(regexp.QuoteMeta(invites.InvitePath) + `(\S+)`)
+)
+
+// contract calls the routes of the server, and validates the responses against the OpenAPI
+// document served by the server
+type contract struct {
+	t      *testing.T
+	s      *Server
+	spec   map[string]interface{}
+	called map[string]bool
+
+	// session & apiKey are used for the routes documented to require them
+	session string
+	apiKey  string
+}
+
+// result is a response which conforms to the document
+type result struct {
+	header http.Header
+	body   string
+	data   interface{}
+}
+
+func (r *result) field(key string) interface{} {
+	m, _ := r.data.(map[string]interface{})
+	return m[key]
+}
+
+func (r *result) int(key string) int64 {
+	n, _ := r.field(key).(json.Number)
+	i, _ := n.Int64()
+	return i
+}
+
+func (r *result) str(key string) string {
+	s, _ := r.field(key).(string)
+	return s
+}
+
+func (r *result) len() int {
+	list, _ := r.data.([]interface{})
+	return len(list)
+}
+
+func newContract(t *testing.T, f *apitest.Fixture) *contract {
+	t.Helper()
+
+	s, err := NewServer("127.0.0.1", "0", "padlock.test", f.API, f.AppCtx, health.New(health.DefaultConfig, f.AppCtx.Logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &contract{t: t, s: s, called: make(map[string]bool, len(s.routes))}
+	d := json.NewDecoder(bytes.NewReader(s.openAPI))
+	d.UseNumber()
+	err = d.Decode(&c.spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// object returns the value of the key in the object, or in its descendants if more keys are
+// provided
+func object(v interface{}, keys ...string) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	for _, k := range keys {
+		m, _ = m[k].(map[string]interface{})
+	}
+	return m
+}
+
+// resolve returns the schema referred to, if it's a reference
+func (c *contract) resolve(s map[string]interface{}) map[string]interface{} {
+	ref, ok := s["$ref"].(string)
+	if !ok {
+		return s
+	}
+	resolved := object(c.spec, "components", "schemas")[strings.TrimPrefix(ref, schemaRefPath)]
+	if resolved == nil {
+		c.t.Fatalf("contract: unresolved reference %s", ref)
+	}
+	return c.resolve(object(resolved))
+}
+
+// validate validates the JSON value against the schema, 'at' is the location of the value
+func (c *contract) validate(s map[string]interface{}, v interface{}, at string) {
+	c.t.Helper()
+
+	s = c.resolve(s)
+	typ, _ := s["type"].(string)
+	if typ == "" {
+		// any value
+		return
+	}
+
+	switch typ {
+	case "object":
+		{
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				c.t.Errorf("%s: expected an object, got %v", at, v)
+				return
+			}
+			props := object(s, "properties")
+			for k, pv := range m {
+				ps, ok := props[k]
+				if !ok {
+					ps, ok = s["additionalProperties"]
+				}
+				if !ok {
+					c.t.Errorf("%s: '%s' is not documented", at, k)
+					continue
+				}
+				c.validate(object(ps), pv, at+"."+k)
+			}
+		}
+	case "array":
+		{
+			list, ok := v.([]interface{})
+			if !ok {
+				c.t.Errorf("%s: expected an array, got %v", at, v)
+				return
+			}
+			for i, item := range list {
+				c.validate(object(s, "items"), item, fmt.Sprintf("%s[%d]", at, i))
+			}
+		}
+	case "string":
+		{
+			str, ok := v.(string)
+			if !ok {
+				c.t.Errorf("%s: expected a string, got %v", at, v)
+				return
+			}
+			switch s["format"] {
+			case "date-time":
+				{
+					_, err := time.Parse(time.RFC3339Nano, str)
+					if err != nil {
+						c.t.Errorf("%s: expected a date-time, got %q", at, str)
+					}
+				}
+			case "byte":
+				{
+					_, err := base64.StdEncoding.DecodeString(str)
+					if err != nil {
+						c.t.Errorf("%s: expected base64 encoded bytes, got %q", at, str)
+					}
+				}
+			}
+		}
+	case "integer":
+		{
+			n, ok := v.(json.Number)
+			if _, err := n.Int64(); !ok || err != nil {
+				c.t.Errorf("%s: expected an integer, got %v", at, v)
+			}
+		}
+	case "number":
+		{
+			if _, ok := v.(json.Number); !ok {
+				c.t.Errorf("%s: expected a number, got %v", at, v)
+			}
+		}
+	case "boolean":
+		{
+			if _, ok := v.(bool); !ok {
+				c.t.Errorf("%s: expected a boolean, got %v", at, v)
+			}
+		}
+	default:
+		{
+			c.t.Errorf("%s: unknown type %q in the document", at, typ)
+		}
+	}
+}
+
+func decodeJSON(b []byte) (interface{}, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	err := d.Decode(&v)
+	return v, err
+}
+
+// call calls the route with the path (its pattern with the parameters filled in), and validates
+// the request payload & the response against the document. The status should be the expected
+// status of the response, the documented success status or an error status
+func (c *contract) call(name, path string, payload interface{}, status int) *result {
+	c.t.Helper()
+
+	var route *webgo.Route
+	for _, r := range c.s.routes {
+		if r.Name == name {
+			route = r
+		}
+	}
+	if route == nil {
+		c.t.Fatalf("%s: no such route", name)
+	}
+
+	p, _ := openAPIPath(route.Pattern)
+	op := object(c.spec, "paths", p, strings.ToLower(route.Method))
+	if op == nil {
+		c.t.Fatalf("%s: %s %s is not documented", name, route.Method, p)
+	}
+	at := fmt.Sprintf("%s (%s %s)", name, route.Method, path)
+
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		v, _ := decodeJSON(b)
+		c.validate(object(op, "requestBody", "content", "application/json", "schema"), v, at+" request")
+		body = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(route.Method, path, body)
+	security, _ := op["security"].([]interface{})
+	for _, sec := range security {
+		if _, ok := object(sec)[string(authSession)]; ok {
+			req.Header.Set("Authorization", c.session)
+		}
+		if _, ok := object(sec)[string(authAPIKey)]; ok {
+			req.Header.Set("X-API-Key", c.apiKey)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	c.s.ServeHTTP(rec, req)
+	c.called[name] = true
+
+	if rec.Code != status {
+		c.t.Fatalf("%s: expected %d, got %d: %s", at, status, rec.Code, rec.Body.String())
+	}
+	// the handler should respond with the success status of its doc, which the document is built from
+	if d := c.s.docs[name]; status >= 200 && status <= 299 && d.Status != status {
+		c.t.Errorf("%s: responded with %d, but %d is documented", at, status, d.Status)
+	}
+
+	resp := object(op, "responses", strconv.Itoa(status))
+	if resp == nil {
+		if status >= 200 && status <= 299 {
+			c.t.Fatalf("%s: %d is not the documented status", at, status)
+		}
+		resp = object(op, "responses", "default")
+	}
+
+	r := &result{header: rec.Header(), body: rec.Body.String()}
+	content := object(resp, "content")
+	if len(content) == 0 {
+		if rec.Body.Len() > 0 {
+			c.t.Errorf("%s: expected no content, got %s", at, rec.Body.String())
+		}
+		return r
+	}
+
+	if _, ok := content["text/html"]; ok {
+		if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+			c.t.Errorf("%s: expected HTML, got %s", at, rec.Header().Get("Content-Type"))
+		}
+		return r
+	}
+
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		c.t.Errorf("%s: expected JSON, got %s", at, rec.Header().Get("Content-Type"))
+	}
+
+	v, err := decodeJSON(rec.Body.Bytes())
+	if err != nil {
+		c.t.Fatalf("%s: invalid JSON %q: %v", at, rec.Body.String(), err)
+	}
+	c.validate(object(content, "application/json", "schema"), v, at)
+
+	if env := object(v); env["status"] != nil && env["status"] != json.Number(strconv.Itoa(status)) {
+		c.t.Errorf("%s: expected the status %d in the payload, got %v", at, status, env["status"])
+	}
+	r.data = object(v)["data"]
+	return r
+}
+
+// login signs in with the email & the fixture password, and uses the session for the following
+// calls
+func (c *contract) login(email string) *result {
+	c.t.Helper()
+
+	r := c.call("login", "/login", LoginRequest{Email: email, Password: apitest.Password}, http.StatusOK)
+	c.session = r.header.Get("Authorization")
+	return r
+}
+
+// covered verifies that all the routes of the server were called
+func (c *contract) covered() {
+	c.t.Helper()
+	for _, r := range c.s.routes {
+		if !c.called[r.Name] {
+			c.t.Errorf("%s (%s %s) was not called", r.Name, r.Method, r.Pattern)
+		}
+	}
+}
+
+func signECDSA(t *testing.T, key *ecdsa.PrivateKey, msg []byte) []byte {
+	t.Helper()
+	digest := sha256.Sum256(msg)
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func TestContract(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		f := apitest.New(t, driver, db)
+		c := newContract(t, f)
+		jane := f.Admin(t, users.User{Name: "Jane", Email: "jane@example.com", Phone: "+14155550100"})
+		john := f.User(t, users.User{Name: "John", Email: "john@example.com"})
+
+		c.call("home", "/", nil, http.StatusOK)
+		c.call("openapi", "/openapi.json", nil, http.StatusOK)
+		c.call("healthz", "/healthz", nil, http.StatusOK)
+		c.call("readyz", "/readyz", nil, http.StatusOK)
+
+		// sign in
+		c.call("login", "/login", LoginRequest{Email: jane.Email, Password: "wrong"}, http.StatusUnauthorized)
+		c.call("me", "/me", nil, http.StatusUnauthorized)
+		c.login(jane.Email)
+
+		c.call("login.email", "/login/email", PasswordlessRequest{Email: jane.Email, Kind: "code"}, http.StatusAccepted)
+		c.call(
+			"login.email.verify",
+			"/login/email/verify",
+			EmailCodeLoginRequest{Email: jane.Email, Code: f.LastMatch(t, jane.Email, codeRegex)},
+			http.StatusOK,
+		)
+		c.call("login.email", "/login/email", PasswordlessRequest{Email: jane.Email, Kind: "link"}, http.StatusAccepted)
+		c.call("login.link", users.MagicLinkPath+f.LastMatch(t, jane.Email, linkRegex), nil, http.StatusOK)
+
+		c.call("auth.required", "/restricted", nil, http.StatusOK)
+		me := c.call("me", "/me", nil, http.StatusOK)
+		c.call("users.list", "/users?limit=10&createdFrom=2020-01-01T00:00:00Z", nil, http.StatusOK)
+		c.call(
+			"users.update",
+			fmt.Sprintf("/users/%d", jane.ID),
+			users.User{Name: "Jane Doe", Version: me.int("version")},
+			http.StatusOK,
+		)
+
+		c.call("me.otp.phone", "/me/otp/phone", PhoneOTPRequest{Channel: "sms"}, http.StatusAccepted)
+		msgs := f.SMS.Messages()
+		otp := codeRegex.FindStringSubmatch(msgs[len(msgs)-1].Message)
+		c.call("me.otp.phone.verify", "/me/otp/phone/verify", CodeRequest{Code: otp[1]}, http.StatusOK)
+
+		// organisations & applications
+		org := c.call("orgs.create", "/orgs", map[string]string{"name": "acme"}, http.StatusCreated)
+		orgPath := fmt.Sprintf("/orgs/%d", org.int("id"))
+		c.call("orgs.list", "/orgs", nil, http.StatusOK)
+		c.call("orgs.read", orgPath, nil, http.StatusOK)
+		c.call("orgs.update", orgPath, map[string]string{"name": "acme inc"}, http.StatusOK)
+		c.call("orgs.members.set", fmt.Sprintf("%s/members/%d", orgPath, john.ID), map[string]string{"role": "member"}, http.StatusOK)
+		c.call("orgs.members", orgPath+"/members", nil, http.StatusOK)
+
+		app := c.call(
+			"apps.create",
+			"/apps",
+			map[string]interface{}{"name": "billing", "orgId": org.int("id")},
+			http.StatusCreated,
+		)
+		appPath := fmt.Sprintf("/apps/%d", app.int("id"))
+		c.call("apps.list", "/apps?limit=10", nil, http.StatusOK)
+		c.call("apps.read", appPath, nil, http.StatusOK)
+		c.call("apps.update", appPath, map[string]string{"description": "Invoicing"}, http.StatusOK)
+		c.call("apps.owners.set", fmt.Sprintf("%s/owners/%d", appPath, john.ID), nil, http.StatusOK)
+		if c.call("apps.owners", appPath+"/owners", nil, http.StatusOK).len() == 0 {
+			t.Fatal("expected the owners to be listed")
+		}
+		c.call("apps.owners.remove", fmt.Sprintf("%s/owners/%d", appPath, john.ID), nil, http.StatusNoContent)
+
+		team := c.call("orgs.teams.create", orgPath+"/teams", map[string]string{"name": "developers"}, http.StatusCreated)
+		teamPath := fmt.Sprintf("%s/teams/%d", orgPath, team.int("id"))
+		c.call("orgs.teams", orgPath+"/teams", nil, http.StatusOK)
+		c.call("orgs.teams.members.add", fmt.Sprintf("%s/members/%d", teamPath, john.ID), nil, http.StatusOK)
+		c.call("orgs.teams.members", teamPath+"/members", nil, http.StatusOK)
+		c.call("orgs.teams.apps.grant", fmt.Sprintf("%s/apps/%d", teamPath, app.int("id")), map[string]string{"role": "developer"}, http.StatusOK)
+		c.call("orgs.teams.apps", teamPath+"/apps", nil, http.StatusOK)
+		c.call("orgs.teams.apps.revoke", fmt.Sprintf("%s/apps/%d", teamPath, app.int("id")), nil, http.StatusNoContent)
+		c.call("orgs.teams.members.remove", fmt.Sprintf("%s/members/%d", teamPath, john.ID), nil, http.StatusNoContent)
+		c.call("orgs.teams.delete", teamPath, nil, http.StatusNoContent)
+
+		role := c.call(
+			"roles.assign",
+			"/roles",
+			map[string]interface{}{"userId": john.ID, "role": "viewer", "appId": app.int("id")},
+			http.StatusCreated,
+		)
+		c.call("users.roles", fmt.Sprintf("/users/%d/roles", john.ID), nil, http.StatusOK)
+		c.call("roles.revoke", fmt.Sprintf("/roles/%d", role.int("id")), nil, http.StatusNoContent)
+
+		// invitations
+		orgInvite := c.call(
+			"orgs.invites.create",
+			orgPath+"/invites",
+			map[string]string{"email": "alice@example.com", "role": "member"},
+			http.StatusCreated,
+		)
+		c.call("orgs.invites", orgPath+"/invites", nil, http.StatusOK)
+		invalid := c.call("orgs.invites", "/orgs/acme/invites", nil, http.StatusNotFound)
+		if !strings.Contains(invalid.body, orgs.ErrNotFound.Error()) {
+			t.Fatalf("expected the organisation not to be found, got %s", invalid.body)
+		}
+		token := f.LastMatch(t, "alice@example.com", inviteRegex)
+		c.call("invites.read", invites.InvitePath+token, nil, http.StatusOK)
+		c.call("invites.accept", invites.InvitePath+token+"/accept", AcceptInviteRequest{Name: "Alice", Password: "password"}, http.StatusOK)
+		c.call("invites.read", invites.InvitePath+token, nil, http.StatusConflict)
+		if orgInvite.int("id") == 0 {
+			t.Fatal("expected the invitation ID")
+		}
+
+		c.call(
+			"apps.invites.create",
+			appPath+"/invites",
+			map[string]string{"email": john.Email, "role": "viewer"},
+			http.StatusCreated,
+		)
+		c.call("apps.invites", appPath+"/invites", nil, http.StatusOK)
+		c.call("invites.decline", invites.InvitePath+f.LastMatch(t, john.Email, inviteRegex)+"/decline", nil, http.StatusNoContent)
+
+		revoked := c.call(
+			"apps.invites.create",
+			appPath+"/invites",
+			map[string]string{"email": jane.Email, "role": "developer"},
+			http.StatusCreated,
+		)
+		c.call("me.invites", "/me/invites", nil, http.StatusOK)
+		c.call("invites.revoke", fmt.Sprintf("/invites/%d", revoked.int("id")), nil, http.StatusNoContent)
+
+		// webhooks, API keys & subjects
+		hook := c.call(
+			"apps.webhooks.create",
+			appPath+"/webhooks",
+			map[string]interface{}{"url": "https://example.com/hooks", "events": []string{"subject.enrolled", "subject.recovery_code_used"}, "active": true},
+			http.StatusCreated,
+		)
+		hookPath := fmt.Sprintf("%s/webhooks/%d", appPath, hook.int("id"))
+		c.call("apps.webhooks", appPath+"/webhooks", nil, http.StatusOK)
+		c.call(
+			"apps.webhooks.update",
+			hookPath,
+			map[string]interface{}{"url": "https://example.com/padlock", "events": []string{"subject.enrolled", "subject.recovery_code_used"}, "active": true},
+			http.StatusOK,
+		)
+
+		key := c.call(
+			"apps.keys.create",
+			appPath+"/keys",
+			map[string]interface{}{"name": "backend", "scopes": []string{"enroll", "verify"}},
+			http.StatusCreated,
+		)
+		c.call("apps.keys", appPath+"/keys", nil, http.StatusOK)
+		c.apiKey = key.str("key")
+		c.call("app", "/app", nil, http.StatusOK)
+
+		subjectPath := appPath + "/subjects/jane"
+		enrollment := c.call("apps.subjects.enroll", subjectPath+"/enroll", nil, http.StatusCreated)
+		codes, _ := enrollment.field("recoveryCodes").([]interface{})
+		if len(codes) == 0 {
+			t.Fatalf("expected the recovery codes, got %s", enrollment.body)
+		}
+		c.call("apps.subjects.verify", subjectPath+"/verify", CodeRequest{Code: codes[0].(string)}, http.StatusOK)
+		c.call("apps.subjects.unenroll", subjectPath+"/unenroll", nil, http.StatusNoContent)
+		c.call("apps.subjects.verify", subjectPath+"/verify", CodeRequest{Code: "123456"}, http.StatusNotFound)
+		c.call("apps.keys.revoke", fmt.Sprintf("%s/keys/%d", appPath, key.int("id")), nil, http.StatusNoContent)
+		c.call("app", "/app", nil, http.StatusUnauthorized)
+
+		deliveries := c.call("apps.webhooks.deliveries", hookPath+"/deliveries?limit=10", nil, http.StatusOK)
+		list, _ := deliveries.data.([]interface{})
+		if len(list) != 1 {
+			t.Fatalf("expected the recovery code usage to be delivered, got %s", deliveries.body)
+		}
+		deliveryPath := fmt.Sprintf("%s/deliveries/%s", appPath, object(list[0])["id"])
+		c.call("apps.deliveries.read", deliveryPath, nil, http.StatusOK)
+		c.call("apps.deliveries.redeliver", deliveryPath+"/redeliver", nil, http.StatusOK)
+		c.call("apps.webhooks.delete", hookPath, nil, http.StatusNoContent)
+
+		// push approvals
+		deviceKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(deviceKey.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		device := c.call(
+			"me.push.devices.enroll",
+			"/me/push/devices",
+			PushDeviceRequest{Name: "phone", PublicKey: base64.StdEncoding.EncodeToString(der), PushToken: "phone"},
+			http.StatusCreated,
+		)
+		c.call("me.push.devices.list", "/me/push/devices", nil, http.StatusOK)
+
+		ch := c.call("me.push.challenge", "/me/push/challenges", nil, http.StatusCreated)
+		received := f.Push.Receive("phone")
+		if len(received) != 1 {
+			t.Fatalf("expected the challenge to be pushed, got %+v", received)
+		}
+		number := int(ch.int("number"))
+		c.call(
+			"push.challenge.respond",
+			"/push/challenges/"+ch.str("id")+"/respond",
+			push.Response{
+				DeviceID:  device.int("id"),
+				Approve:   true,
+				Number:    number,
+				Signature: signECDSA(t, deviceKey, push.SignedMessage(ch.str("id"), received[0].Nonce, true, number)),
+			},
+			http.StatusOK,
+		)
+		status := c.call("me.push.challenge.status", "/me/push/challenges/"+ch.str("id")+"?wait=0", nil, http.StatusOK)
+		if status.str("status") != "approved" {
+			t.Fatalf("expected the challenge to be approved, got %s", status.body)
+		}
+		c.call("me.push.devices.remove", fmt.Sprintf("/me/push/devices/%d", device.int("id")), nil, http.StatusNoContent)
+
+		// WebAuthn, the ceremonies are verified by the tests of the webauthn package; here the
+		// responses which fail verification are validated
+		c.call("me.webauthn.register.begin", "/me/webauthn/register/begin", nil, http.StatusOK)
+		c.call(
+			"me.webauthn.register.finish",
+			"/me/webauthn/register/finish",
+			WebAuthnRegistrationRequest{Name: "key", Credential: webauthn.RegistrationResponse{ID: "key", RawID: []byte("key"), Type: "public-key"}},
+			http.StatusBadRequest,
+		)
+		c.call("me.webauthn.list", "/me/webauthn", nil, http.StatusOK)
+		c.call("me.webauthn.assert.begin", "/me/webauthn/assert/begin", nil, http.StatusNotFound)
+		c.call(
+			"me.webauthn.assert.finish",
+			"/me/webauthn/assert/finish",
+			webauthn.AssertionResponse{ID: "key", RawID: []byte("key"), Type: "public-key"},
+			http.StatusBadRequest,
+		)
+		c.call("me.webauthn.delete", "/me/webauthn/1", nil, http.StatusNotFound)
+
+		c.call("apps.delete", appPath, nil, http.StatusNoContent)
+		c.call("orgs.members.remove", fmt.Sprintf("%s/members/%d", orgPath, john.ID), nil, http.StatusNoContent)
+		c.call("orgs.delete", orgPath, nil, http.StatusNoContent)
+
+		// contact change, the revert signs out of all the sessions
+		cc := c.call(
+			"me.contact.change",
+			"/me/contact",
+			ContactChangeRequest{Field: "phone", Value: "+14155550101"},
+			http.StatusAccepted,
+		)
+		c.call(
+			"me.contact.confirm",
+			"/me/contact/"+cc.str("id")+"/confirm",
+			CodeRequest{Code: f.LastMatch(t, "+14155550101", codeRegex)},
+			http.StatusOK,
+		)
+		revert := users.ContactRevertPath + f.LastMatch(t, jane.Phone, revertRegex)
+		c.call("users.contact.revert.page", revert, nil, http.StatusOK)
+		c.call("users.contact.revert", revert, nil, http.StatusOK)
+		c.call("users.contact.revert", revert, nil, http.StatusNotFound)
+		c.call("me", "/me", nil, http.StatusUnauthorized)
+
+		c.covered()
+	})
+}
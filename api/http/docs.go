@@ -0,0 +1,583 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/invites"
+	"github.com/bnkamalesh/padlock/pkg/orgs"
+	"github.com/bnkamalesh/padlock/pkg/platform/health"
+	"github.com/bnkamalesh/padlock/pkg/push"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/subjects"
+	"github.com/bnkamalesh/padlock/pkg/users"
+	"github.com/bnkamalesh/padlock/pkg/webauthn"
+	"github.com/bnkamalesh/padlock/pkg/webhooks"
+)
+
+// auth is the authentication required by a route
+type auth string
+
+const (
+	authNone = auth("")
+	// authSession requires the session token of the user
+	authSession = auth("session")
+	// authAPIKey requires an API key of the application
+	authAPIKey = auth("apiKey")
+)
+
+// param is a query parameter
+type param struct {
+	Name   string
+	Type   string
+	Format string
+}
+
+// doc documents a route, it's used to generate the OpenAPI document. Every route should have a
+// doc, keyed by the route name (verified by checkDocs)
+type doc struct {
+	Summary string
+	Auth    auth
+	// StringID is true if the 'id' path parameter is a string, path parameters are integers
+	// except tokens & subject IDs
+	StringID bool
+	Query    []param
+	// Request is a value of the type of the request payload, if any
+	Request interface{}
+	// Status is the HTTP status of a successful response
+	Status int
+	// Response is a value of the type of the data in a successful response, if any
+	Response interface{}
+	// HTML is true if the response is an HTML page instead of JSON, e.g. of links opened in
+	// the browser
+	HTML bool
+	// Raw is true if the response is not wrapped in 'data', e.g. the OpenAPI document
+	Raw bool
+}
+
+func (d doc) paramType(name string) string {
+	switch name {
+	case "token", "subjectID":
+		{
+			return "string"
+		}
+	case "id":
+		{
+			if d.StringID {
+				return "string"
+			}
+		}
+	}
+	return "integer"
+}
+
+var paginationQuery = []param{
+	{Name: "offset", Type: "integer"},
+	{Name: "limit", Type: "integer"},
+}
+
+var docs = map[string]doc{
+	"home": {
+		Summary:  "Hello world",
+		Status:   http.StatusOK,
+		Response: "",
+	},
+	"openapi": {
+		Summary:  "The OpenAPI document of the HTTP API",
+		Status:   http.StatusOK,
+		Response: map[string]interface{}{},
+		Raw:      true,
+	},
+	"healthz": {
+		Summary:  "Liveness probe, it does not check the dependencies",
+		Status:   http.StatusOK,
+		Response: Liveness{},
+	},
+	"readyz": {
+		Summary:  "Readiness probe, with the status of every dependency. It responds with 503 if any of them is down, or if the server is shutting down",
+		Status:   http.StatusOK,
+		Response: health.Report{},
+	},
+	"login": {
+		Summary:  "Sign in with email & password, the session token is set in the Authorization header & cookie",
+		Request:  LoginRequest{},
+		Status:   http.StatusOK,
+		Response: users.User{},
+	},
+	"login.email": {
+		Summary:  "Send a sign in code or link to the email",
+		Request:  PasswordlessRequest{},
+		Status:   http.StatusAccepted,
+		Response: "",
+	},
+	"login.email.verify": {
+		Summary:  "Sign in with the code sent to the email",
+		Request:  EmailCodeLoginRequest{},
+		Status:   http.StatusOK,
+		Response: users.User{},
+	},
+	"login.link": {
+		Summary:  "Sign in with the link sent to the email",
+		Status:   http.StatusOK,
+		Response: users.User{},
+	},
+	"auth.required": {
+		Summary:  "Check if the session is valid",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: "",
+	},
+	"me": {
+		Summary:  "The authenticated user, it's used to validate the session",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: users.User{},
+	},
+	"users.list": {
+		Summary: "List the users",
+		Auth:    authSession,
+		Query: append([]param{
+			{Name: "email", Type: "string"},
+			{Name: "name", Type: "string"},
+			{Name: "createdFrom", Type: "string", Format: "date-time"},
+			{Name: "createdTo", Type: "string", Format: "date-time"},
+		}, paginationQuery...),
+		Status:   http.StatusOK,
+		Response: UsersList{},
+	},
+	"users.roles": {
+		Summary:  "List the roles assigned to the user",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []rbac.Assignment{},
+	},
+	"users.update": {
+		Summary:  "Update the name/password of the user, the payload should include the version of the user as last read. The email/phone cannot be updated, refer me.contact.change",
+		Auth:     authSession,
+		Request:  users.User{},
+		Status:   http.StatusOK,
+		Response: users.User{},
+	},
+	"users.contact.revert.page": {
+		Summary:  "Show the confirmation page to revert a contact change, opened using the link sent to the old contact",
+		Status:   http.StatusOK,
+		Response: "",
+		HTML:     true,
+	},
+	"users.contact.revert": {
+		Summary:  "Revert a contact change, on submitting the confirmation page",
+		Status:   http.StatusOK,
+		Response: "",
+		HTML:     true,
+	},
+	"roles.assign": {
+		Summary:  "Assign a role to a user",
+		Auth:     authSession,
+		Request:  rbac.Assignment{},
+		Status:   http.StatusCreated,
+		Response: rbac.Assignment{},
+	},
+	"roles.revoke": {
+		Summary: "Revoke a role assignment",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"me.contact.change": {
+		Summary:  "Change the email/phone of the authenticated user, a code is sent to the new contact",
+		Auth:     authSession,
+		Request:  ContactChangeRequest{},
+		Status:   http.StatusAccepted,
+		Response: users.ContactChange{},
+	},
+	"me.contact.confirm": {
+		Summary:  "Confirm the contact change with the code sent to the new contact",
+		Auth:     authSession,
+		StringID: true,
+		Request:  CodeRequest{},
+		Status:   http.StatusOK,
+		Response: users.User{},
+	},
+	"me.otp.phone": {
+		Summary:  "Send a one time code to the phone of the authenticated user",
+		Auth:     authSession,
+		Request:  PhoneOTPRequest{},
+		Status:   http.StatusAccepted,
+		Response: users.PhoneOTP{},
+	},
+	"me.otp.phone.verify": {
+		Summary:  "Verify the one time code sent to the phone",
+		Auth:     authSession,
+		Request:  CodeRequest{},
+		Status:   http.StatusOK,
+		Response: "",
+	},
+	"me.webauthn.list": {
+		Summary:  "List the WebAuthn credentials of the authenticated user",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []webauthn.Credential{},
+	},
+	"me.webauthn.delete": {
+		Summary: "Delete a WebAuthn credential",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"me.webauthn.register.begin": {
+		Summary:  "Begin registering a WebAuthn credential",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: webauthn.CreationOptions{},
+	},
+	"me.webauthn.register.finish": {
+		Summary:  "Finish registering a WebAuthn credential",
+		Auth:     authSession,
+		Request:  WebAuthnRegistrationRequest{},
+		Status:   http.StatusCreated,
+		Response: webauthn.Credential{},
+	},
+	"me.webauthn.assert.begin": {
+		Summary:  "Begin a WebAuthn assertion",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: webauthn.RequestOptions{},
+	},
+	"me.webauthn.assert.finish": {
+		Summary:  "Finish a WebAuthn assertion",
+		Auth:     authSession,
+		Request:  webauthn.AssertionResponse{},
+		Status:   http.StatusOK,
+		Response: webauthn.AssertionResult{},
+	},
+	"me.push.devices.list": {
+		Summary:  "List the push devices of the authenticated user",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []push.Device{},
+	},
+	"me.push.devices.enroll": {
+		Summary:  "Enroll a device for push approvals",
+		Auth:     authSession,
+		Request:  PushDeviceRequest{},
+		Status:   http.StatusCreated,
+		Response: push.Device{},
+	},
+	"me.push.devices.remove": {
+		Summary: "Remove a push device",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"me.push.challenge": {
+		Summary:  "Send a push challenge to the devices of the authenticated user",
+		Auth:     authSession,
+		Status:   http.StatusCreated,
+		Response: push.Challenge{},
+	},
+	"me.push.challenge.status": {
+		Summary:  "Status of the push challenge, waits for up to 'wait' seconds for a change",
+		Auth:     authSession,
+		StringID: true,
+		Query:    []param{{Name: "wait", Type: "integer"}},
+		Status:   http.StatusOK,
+		Response: push.Challenge{},
+	},
+	"push.challenge.respond": {
+		Summary:  "Respond to a push challenge, signed by the device",
+		StringID: true,
+		Request:  push.Response{},
+		Status:   http.StatusOK,
+		Response: push.Challenge{},
+	},
+	"apps.list": {
+		Summary: "List the applications accessible by the authenticated user",
+		Auth:    authSession,
+		Query: append([]param{
+			{Name: "name", Type: "string"},
+			{Name: "orgId", Type: "integer"},
+		}, paginationQuery...),
+		Status:   http.StatusOK,
+		Response: AppsList{},
+	},
+	"apps.create": {
+		Summary:  "Register an application",
+		Auth:     authSession,
+		Request:  apps.App{},
+		Status:   http.StatusCreated,
+		Response: apps.App{},
+	},
+	"apps.read": {
+		Summary:  "Read the application",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: apps.App{},
+	},
+	"apps.update": {
+		Summary:  "Update the non-empty fields of the application",
+		Auth:     authSession,
+		Request:  apps.App{},
+		Status:   http.StatusOK,
+		Response: apps.App{},
+	},
+	"apps.delete": {
+		Summary: "Delete the application",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"apps.owners": {
+		Summary:  "List the owners of the application",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []apps.Owner{},
+	},
+	"apps.owners.set": {
+		Summary:  "Add an owner to the application",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: apps.App{},
+	},
+	"apps.owners.remove": {
+		Summary: "Remove an owner of the application",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"apps.keys": {
+		Summary:  "List the API keys of the application",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []apikeys.APIKey{},
+	},
+	"apps.keys.create": {
+		Summary:  "Create an API key, the key is available only in this response",
+		Auth:     authSession,
+		Request:  apikeys.APIKey{},
+		Status:   http.StatusCreated,
+		Response: apikeys.APIKey{},
+	},
+	"apps.keys.revoke": {
+		Summary: "Revoke an API key",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"apps.webhooks": {
+		Summary:  "List the webhook endpoints of the application",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []webhooks.Endpoint{},
+	},
+	"apps.webhooks.create": {
+		Summary:  "Create a webhook endpoint, the signing secret is available only in this response",
+		Auth:     authSession,
+		Request:  webhooks.Endpoint{},
+		Status:   http.StatusCreated,
+		Response: webhooks.Endpoint{},
+	},
+	"apps.webhooks.update": {
+		Summary:  "Update the webhook endpoint",
+		Auth:     authSession,
+		Request:  webhooks.Endpoint{},
+		Status:   http.StatusOK,
+		Response: webhooks.Endpoint{},
+	},
+	"apps.webhooks.delete": {
+		Summary: "Delete the webhook endpoint",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"apps.webhooks.deliveries": {
+		Summary:  "List the recent deliveries of the webhook endpoint",
+		Auth:     authSession,
+		Query:    []param{{Name: "limit", Type: "integer"}},
+		Status:   http.StatusOK,
+		Response: []webhooks.Delivery{},
+	},
+	"apps.deliveries.read": {
+		Summary:  "Read the webhook delivery, along with its attempts",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: webhooks.Delivery{},
+	},
+	"apps.deliveries.redeliver": {
+		Summary:  "Deliver the webhook again",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: webhooks.Delivery{},
+	},
+	"app": {
+		Summary:  "The application of the API key",
+		Auth:     authAPIKey,
+		Status:   http.StatusOK,
+		Response: apps.App{},
+	},
+	"apps.subjects.enroll": {
+		Summary:  "Enroll a user of the application for TOTP verification",
+		Auth:     authAPIKey,
+		Status:   http.StatusCreated,
+		Response: subjects.Enrollment{},
+	},
+	"apps.subjects.unenroll": {
+		Summary: "Unenroll a user of the application",
+		Auth:    authAPIKey,
+		Status:  http.StatusNoContent,
+	},
+	"apps.subjects.verify": {
+		Summary:  "Verify the TOTP code, or a recovery code of a user of the application",
+		Auth:     authAPIKey,
+		Request:  CodeRequest{},
+		Status:   http.StatusOK,
+		Response: subjects.Result{},
+	},
+	"orgs.list": {
+		Summary:  "List the organisations of the authenticated user",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []orgs.Organisation{},
+	},
+	"orgs.create": {
+		Summary:  "Create an organisation",
+		Auth:     authSession,
+		Request:  orgs.Organisation{},
+		Status:   http.StatusCreated,
+		Response: orgs.Organisation{},
+	},
+	"orgs.read": {
+		Summary:  "Read the organisation",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: orgs.Organisation{},
+	},
+	"orgs.update": {
+		Summary:  "Update the organisation",
+		Auth:     authSession,
+		Request:  orgs.Organisation{},
+		Status:   http.StatusOK,
+		Response: orgs.Organisation{},
+	},
+	"orgs.delete": {
+		Summary: "Delete the organisation",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"orgs.members": {
+		Summary:  "List the members of the organisation",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []orgs.Member{},
+	},
+	"orgs.members.set": {
+		Summary:  "Add a member to the organisation, or change the role of a member",
+		Auth:     authSession,
+		Request:  orgs.Member{},
+		Status:   http.StatusOK,
+		Response: orgs.Member{},
+	},
+	"orgs.members.remove": {
+		Summary: "Remove a member of the organisation",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"orgs.teams": {
+		Summary:  "List the teams of the organisation",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []orgs.Team{},
+	},
+	"orgs.teams.create": {
+		Summary:  "Create a team in the organisation",
+		Auth:     authSession,
+		Request:  orgs.Team{},
+		Status:   http.StatusCreated,
+		Response: orgs.Team{},
+	},
+	"orgs.teams.delete": {
+		Summary: "Delete the team",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"orgs.teams.members": {
+		Summary:  "List the members of the team",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []orgs.TeamMember{},
+	},
+	"orgs.teams.members.add": {
+		Summary:  "Add a member of the organisation to the team",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: orgs.TeamMember{},
+	},
+	"orgs.teams.members.remove": {
+		Summary: "Remove a member of the team",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"orgs.teams.apps": {
+		Summary:  "List the applications accessible by the team",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []orgs.TeamApp{},
+	},
+	"orgs.teams.apps.grant": {
+		Summary:  "Grant the team access to an application",
+		Auth:     authSession,
+		Request:  orgs.TeamApp{},
+		Status:   http.StatusOK,
+		Response: orgs.TeamApp{},
+	},
+	"orgs.teams.apps.revoke": {
+		Summary: "Revoke the access of the team to an application",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"me.invites": {
+		Summary:  "List the pending invitations to the authenticated user",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []invites.Invitation{},
+	},
+	"orgs.invites": {
+		Summary:  "List the pending invitations of the organisation",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []invites.Invitation{},
+	},
+	"orgs.invites.create": {
+		Summary:  "Invite a user to the organisation",
+		Auth:     authSession,
+		Request:  invites.Invitation{},
+		Status:   http.StatusCreated,
+		Response: invites.Invitation{},
+	},
+	"apps.invites": {
+		Summary:  "List the pending invitations of the application",
+		Auth:     authSession,
+		Status:   http.StatusOK,
+		Response: []invites.Invitation{},
+	},
+	"apps.invites.create": {
+		Summary:  "Invite a user to the application",
+		Auth:     authSession,
+		Request:  invites.Invitation{},
+		Status:   http.StatusCreated,
+		Response: invites.Invitation{},
+	},
+	"invites.revoke": {
+		Summary: "Revoke the invitation",
+		Auth:    authSession,
+		Status:  http.StatusNoContent,
+	},
+	"invites.read": {
+		Summary:  "Read the invitation, using the link sent to the invitee",
+		Status:   http.StatusOK,
+		Response: invites.Invitation{},
+	},
+	"invites.accept": {
+		Summary:  "Accept the invitation, the payload is required only if the invitee does not have an account",
+		Request:  AcceptInviteRequest{},
+		Status:   http.StatusOK,
+		Response: users.User{},
+	},
+	"invites.decline": {
+		Summary: "Decline the invitation",
+		Status:  http.StatusNoContent,
+	},
+}
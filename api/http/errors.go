@@ -0,0 +1,61 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+)
+
+const (
+	headerRequestID = "X-Request-ID"
+	// maxRequestIDLength is the maximum length of the request ID accepted from the caller
+	maxRequestIDLength = 64
+)
+
+var errRouteNotFound = apperr.New(apperr.CodeNotFound, "Sorry, the requested resource does not exist")
+
+// errResponse responds with the error, the HTTP status is based on the code of the error. The
+// request ID is read from the response header, which is set by MiddlewareReqCtx
+func errResponse(w http.ResponseWriter, err error) {
+	code := apperr.CodeOf(err)
+	webgo.SendError(
+		w,
+		ErrorResponse{
+			Code:      code,
+			Message:   apperr.Message(err),
+			RequestID: w.Header().Get(headerRequestID),
+		},
+		code.HTTPStatus(),
+	)
+}
+
+// invalidPayload responds with the error encountered while decoding the request payload
+func invalidPayload(w http.ResponseWriter, err error) {
+	errResponse(w, apperr.New(apperr.CodeInvalidInput, "Sorry, invalid payload: "+err.Error()))
+}
+
+// notFound responds to the requests which do not match any route
+func notFound(w http.ResponseWriter, r *http.Request) {
+	errResponse(w, errRouteNotFound)
+}
+
+// validRequestID returns true if the request ID provided by the caller can be used as is, it
+// should be short & have only alphanumerics, '-', '_' or '.'
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			{
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -2,11 +2,55 @@ package http
 
 import (
 	"encoding/json"
+	"html/template"
 	"net/http"
+	"strconv"
 
 	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/users"
 )
 
+// revertPage is the page opened by the revert link sent to the old contact. The link only shows
+// the confirmation, the change is reverted on submitting it; so that links opened by mail
+// scanners or previews do not revert the change
+var revertPage = template.Must(template.New("revert").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Revert {{.Field}} change</title>
+</head>
+<body>
+{{if .Error}}<p>{{.Error}}</p>
+{{else if .Reverted}}<p>The change was reverted, and you have been signed out of all sessions.</p>
+{{else}}<p>A request was made to change the {{.Field}} of your account. If this was not you, revert
+the change. You will be signed out of all sessions.</p>
+<form method="post"><button type="submit">Revert the change</button></form>
+{{end}}</body>
+</html>
+`))
+
+type revertPageData struct {
+	Field    string
+	Error    string
+	Reverted bool
+}
+
+func renderRevertPage(w http.ResponseWriter, status int, data revertPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = revertPage.Execute(w, data)
+}
+
+func renderRevertError(w http.ResponseWriter, err error) {
+	renderRevertPage(
+		w,
+		apperr.CodeOf(err).HTTPStatus(),
+		revertPageData{Error: apperr.Message(err)},
+	)
+}
+
 func (s *Server) Login(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	rctx := s.appCtx.ReqContext(ctx)
@@ -15,20 +59,31 @@ func (s *Server) Login(w http.ResponseWriter, req *http.Request) {
 		source = rctx.Source
 	}
 
-	payload := make(map[string]string)
+	payload := LoginRequest{}
 	decoder := json.NewDecoder(req.Body)
 	err := decoder.Decode(&payload)
 	if err != nil {
-		webgo.R400(w, err)
+		invalidPayload(w, err)
 		return
 	}
 
-	u, token, err := s.api.Login(ctx, source, payload["email"], payload["password"])
+	u, token, err := s.api.Login(ctx, source, payload.Email, payload.Password)
 	if err != nil {
-		webgo.R400(w, err.Error())
+		errResponse(w, err)
 		return
 	}
 
+	setSession(w, token)
+	webgo.R200(w, u)
+}
+
+// Me responds with the authenticated user
+func (s *Server) Me(w http.ResponseWriter, req *http.Request) {
+	webgo.R200(w, users.FromContext(req.Context()))
+}
+
+// setSession sets the session token in the response header & cookie
+func setSession(w http.ResponseWriter, token string) {
 	w.Header().Set("Authorization", token)
 	http.SetCookie(
 		w,
@@ -41,5 +96,200 @@ func (s *Server) Login(w http.ResponseWriter, req *http.Request) {
 			Path:     "/",
 		},
 	)
+}
+
+func (s *Server) source(req *http.Request) string {
+	rctx := s.appCtx.ReqContext(req.Context())
+	if rctx == nil {
+		return ""
+	}
+	return rctx.Source
+}
+
+// RequestPasswordless sends a sign in code or link to the email in the payload
+func (s *Server) RequestPasswordless(w http.ResponseWriter, req *http.Request) {
+	payload := PasswordlessRequest{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	err = s.api.RequestPasswordless(req.Context(), s.source(req), payload.Email, payload.Kind)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.SendResponse(w, "If the email is registered, you will receive a sign in "+payload.Kind, http.StatusAccepted)
+}
+
+// LoginWithEmailCode signs in using the email & the code sent to it
+func (s *Server) LoginWithEmailCode(w http.ResponseWriter, req *http.Request) {
+	payload := EmailCodeLoginRequest{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	u, token, err := s.api.LoginWithEmailCode(req.Context(), s.source(req), payload.Email, payload.Code)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	setSession(w, token)
+	webgo.R200(w, u)
+}
+
+// LoginWithMagicLink signs in using the link sent by email
+func (s *Server) LoginWithMagicLink(w http.ResponseWriter, req *http.Request) {
+	u, token, err := s.api.LoginWithMagicLink(req.Context(), s.source(req), webgo.Context(req).Params["token"])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	setSession(w, token)
+	webgo.R200(w, u)
+}
+
+// UpdateUser updates the user identified by the ID in the URI. The payload should include the
+// version of the user as last read
+func (s *Server) UpdateUser(w http.ResponseWriter, req *http.Request) {
+	wctx := webgo.Context(req)
+	id, err := strconv.ParseInt(wctx.Params["id"], 10, 64)
+	if err != nil {
+		errResponse(w, users.ErrInvalidID)
+		return
+	}
+
+	u := users.User{}
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&u)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+	u.ID = id
+
+	usr, err := s.api.UpdateUser(req.Context(), u)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, usr)
+}
+
+// RequestContactChange initiates the change of email/phone of the authenticated user
+func (s *Server) RequestContactChange(w http.ResponseWriter, req *http.Request) {
+	payload := ContactChangeRequest{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	ctx := req.Context()
+	cc, err := s.api.RequestContactChange(ctx, users.FromContext(ctx), payload.Field, payload.Value)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.SendResponse(w, cc, http.StatusAccepted)
+}
+
+// ConfirmContactChange confirms the email/phone change of the authenticated user
+func (s *Server) ConfirmContactChange(w http.ResponseWriter, req *http.Request) {
+	payload := CodeRequest{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	ctx := req.Context()
+	u, err := s.api.ConfirmContactChange(
+		ctx,
+		users.SessionFromContext(ctx),
+		users.FromContext(ctx),
+		webgo.Context(req).Params["id"],
+		payload.Code,
+	)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
 	webgo.R200(w, u)
 }
+
+// ContactRevertPage shows the confirmation page to revert a contact change, opened using the
+// link sent to the old contact
+func (s *Server) ContactRevertPage(w http.ResponseWriter, req *http.Request) {
+	field, err := s.api.ReadContactRevert(req.Context(), webgo.Context(req).Params["token"])
+	if err != nil {
+		renderRevertError(w, err)
+		return
+	}
+
+	renderRevertPage(w, http.StatusOK, revertPageData{Field: field})
+}
+
+// RevertContactChange cancels/reverts a contact change, on submitting the confirmation page
+func (s *Server) RevertContactChange(w http.ResponseWriter, req *http.Request) {
+	err := s.api.RevertContactChange(req.Context(), webgo.Context(req).Params["token"])
+	if err != nil {
+		renderRevertError(w, err)
+		return
+	}
+
+	renderRevertPage(w, http.StatusOK, revertPageData{Reverted: true})
+}
+
+// SendPhoneOTP sends a one time code to the phone of the authenticated user
+func (s *Server) SendPhoneOTP(w http.ResponseWriter, req *http.Request) {
+	payload := PhoneOTPRequest{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	ctx := req.Context()
+	otp, err := s.api.SendPhoneOTP(ctx, users.FromContext(ctx), payload.Channel)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.SendResponse(w, otp, http.StatusAccepted)
+}
+
+// VerifyPhoneOTP verifies the one time code sent to the phone of the authenticated user
+func (s *Server) VerifyPhoneOTP(w http.ResponseWriter, req *http.Request) {
+	payload := CodeRequest{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	ctx := req.Context()
+	err = s.api.VerifyPhoneOTP(ctx, users.FromContext(ctx), payload.Code)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, "Verified")
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/platform/health"
+)
+
+// Healthz responds with 200 as long as the process is able to serve requests, it does not
+// check the dependencies (it's the liveness probe)
+func (s *Server) Healthz(w http.ResponseWriter, req *http.Request) {
+	webgo.R200(w, Liveness{Status: health.StatusUp})
+}
+
+// Readyz responds with the status of the dependencies, with 503 if any of them is down or if
+// the server is shutting down (it's the readiness probe)
+func (s *Server) Readyz(w http.ResponseWriter, req *http.Request) {
+	if s.Draining() {
+		webgo.SendResponse(
+			w,
+			health.Report{
+				Status:       health.StatusDown,
+				Dependencies: []health.Dependency{},
+				CheckedAt:    time.Now(),
+			},
+			http.StatusServiceUnavailable,
+		)
+		return
+	}
+
+	report := s.health.Check()
+	if !report.Up() {
+		webgo.SendResponse(w, report, http.StatusServiceUnavailable)
+		return
+	}
+	webgo.R200(w, report)
+}
@@ -2,38 +2,117 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
 	"github.com/bnkamalesh/webgo"
 	"github.com/bnkamalesh/webgo/middleware"
 
 	"github.com/bnkamalesh/padlock/api"
 	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/health"
 )
 
+// readHeaderTimeout is the maximum duration for reading the request headers, the handlers
+// read the body with the request context
+const readHeaderTimeout = time.Second * 10
+
 type Server struct {
 	appDomain string
 	appCtx    *appcontext.AppContext
 	api       *api.API
+	health    *health.Checker
 	router    *webgo.Router
+	routes    []*webgo.Route
+	docs      map[string]doc
+	// openAPI is the JSON encoded OpenAPI document
+	openAPI []byte
+	server  *http.Server
+	// draining is set to 1 once the server starts shutting down
+	draining int32
 }
 
+// ServeHTTP serves the request with the routes & middleware of the server, so that the server
+// can be used as an http.Handler (e.g. with httptest)
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// Start starts listening on the address, it blocks till the server is shutdown
 func (s *Server) Start() error {
-	s.router.Start()
-	return nil
+	s.appCtx.Logger.Info("HTTP server, listening on", s.server.Addr)
+	err := s.server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Drain marks the server as shutting down, so that the clients (and load balancers) stop
+// sending new requests, while the server continues serving them till Shutdown is called
+func (s *Server) Drain() {
+	atomic.StoreInt32(&s.draining, 1)
+}
+
+// Draining returns true if the server is shutting down
+func (s *Server) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
 }
 
-func NewServer(host, port, appdomain string, api *api.API, appCtx *appcontext.AppContext) (*Server, error) {
+// Shutdown stops accepting new connections, and waits for the in-flight requests to complete.
+// If the context is done before they complete, the remaining connections are closed
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Drain()
+	err := s.server.Shutdown(ctx)
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		cerr := s.server.Close()
+		if cerr != nil {
+			return cerr
+		}
+	}
+	return err
+}
+
+func NewServer(host, port, appdomain string, api *api.API, appCtx *appcontext.AppContext, hc *health.Checker) (*Server, error) {
 	s := &Server{
 		appCtx:    appCtx,
 		api:       api,
+		health:    hc,
 		appDomain: appdomain,
 	}
 
+	s.routes = routes(s)
+	s.docs = docs
+	err := checkDocs(s.routes, s.docs)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := newOpenAPI(s.routes, s.docs)
+	if err != nil {
+		return nil, err
+	}
+	s.openAPI, err = json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
 	router := webgo.NewRouter(&webgo.Config{
 		Host: host,
 		Port: port,
-	}, routes(s))
+	}, s.routes)
+	router.NotFound = notFound
 	webgo.LOGHANDLER = appCtx.Logger
 	s.router = router
+	s.server = &http.Server{
+		Addr:              net.JoinHostPort(host, port),
+		Handler:           router,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
 	// This should be final middleware added, so that execution starts with this
 	defer router.Use(s.MiddlewareReqCtx)
 
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/invites"
+	"github.com/bnkamalesh/padlock/pkg/orgs"
+)
+
+// Invite invites a collaborator to the organisation 'orgID' or the application 'appID', based
+// on which URI parameter is available
+func (s *Server) Invite(w http.ResponseWriter, req *http.Request) {
+	inv := invites.Invitation{}
+	err := json.NewDecoder(req.Body).Decode(&inv)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	inv.OrgID, inv.AppID, err = inviteTarget(req)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	created, err := s.api.Invite(req.Context(), inv)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R201(w, created)
+}
+
+// inviteTarget returns the organisation & application IDs from the URI parameters. The IDs are
+// path parameters, so an invalid ID is reported as the organisation/application not found
+func inviteTarget(req *http.Request) (int64, int64, error) {
+	params := webgo.Context(req).Params
+	orgID, appID := int64(0), int64(0)
+	var err error
+	if str, ok := params["orgID"]; ok {
+		orgID, err = strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, 0, orgs.ErrNotFound
+		}
+	}
+	if str, ok := params["appID"]; ok {
+		appID, err = strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, 0, apps.ErrNotFound
+		}
+	}
+	return orgID, appID, nil
+}
+
+// PendingInvites lists the pending invitations of an organisation or an application
+func (s *Server) PendingInvites(w http.ResponseWriter, req *http.Request) {
+	orgID, appID, err := inviteTarget(req)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	list, err := s.api.PendingInvites(req.Context(), orgID, appID)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// ReceivedInvites lists the pending invitations sent to the authenticated user
+func (s *Server) ReceivedInvites(w http.ResponseWriter, req *http.Request) {
+	list, err := s.api.ReceivedInvites(req.Context())
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// RevokeInvite revokes a pending invitation
+func (s *Server) RevokeInvite(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(webgo.Context(req).Params["id"], 10, 64)
+	if err != nil {
+		errResponse(w, invites.ErrNotFound)
+		return
+	}
+
+	err = s.api.RevokeInvite(req.Context(), id)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
+
+// Invitation responds with the details of the invitation, identified by the token
+func (s *Server) Invitation(w http.ResponseWriter, req *http.Request) {
+	inv, err := s.api.Invitation(req.Context(), webgo.Context(req).Params["token"])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, inv)
+}
+
+// AcceptInvite accepts the invitation identified by the token. 'name' & 'password' are required
+// in the payload only if the invitee does not have an account yet
+func (s *Server) AcceptInvite(w http.ResponseWriter, req *http.Request) {
+	payload := AcceptInviteRequest{}
+	if req.ContentLength != 0 {
+		err := json.NewDecoder(req.Body).Decode(&payload)
+		if err != nil {
+			invalidPayload(w, err)
+			return
+		}
+	}
+
+	u, err := s.api.AcceptInvite(
+		req.Context(),
+		webgo.Context(req).Params["token"],
+		payload.Name,
+		payload.Password,
+	)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, u)
+}
+
+// DeclineInvite declines the invitation identified by the token
+func (s *Server) DeclineInvite(w http.ResponseWriter, req *http.Request) {
+	err := s.api.DeclineInvite(req.Context(), webgo.Context(req).Params["token"])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
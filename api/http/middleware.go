@@ -7,15 +7,21 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bnkamalesh/padlock/pkg/rbac"
 	"github.com/bnkamalesh/padlock/pkg/users"
 
 	"github.com/OneOfOne/xxhash"
-	"github.com/bnkamalesh/webgo"
 )
 
 func sourceID(r *http.Request) string {
+	addr := r.RemoteAddr
+	// the port changes for every connection, and is not part of the source
+	if idx := strings.LastIndex(addr, ":"); idx > 0 {
+		addr = addr[:idx]
+	}
+
 	h := xxhash.New64()
-	rdr := strings.NewReader(r.RemoteAddr + r.Header.Get("User-Agent"))
+	rdr := strings.NewReader(addr + r.Header.Get("User-Agent"))
 	io.Copy(h, rdr)
 	return fmt.Sprintf("%d", h.Sum64())
 }
@@ -37,6 +43,15 @@ func (rw *responseWriter) WriteHeader(code int) {
 func (s *Server) MiddlewareReqCtx(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 
 	ctx, rctx := s.appCtx.NewReqContext(r.Context(), sourceID(r))
+	if id := r.Header.Get(headerRequestID); validRequestID(id) {
+		rctx.ID = id
+	}
+	w.Header().Set(headerRequestID, rctx.ID)
+	if s.Draining() {
+		// the client should open a new connection for the next request, which would reach
+		// another instance
+		w.Header().Set("Connection", "close")
+	}
 	r = r.WithContext(
 		ctx,
 	)
@@ -59,36 +74,25 @@ func (s *Server) Authentication(w http.ResponseWriter, r *http.Request) {
 				s.appCtx.Logger.Error(err)
 			}
 		} else {
-			if cookie.Expires.Before(time.Now()) {
-				webgo.R403(w, "Sorry, you should be authorized to access this page")
-				return
-			}
-			token = cookie.String()
+			token = cookie.Value
 		}
 	}
 
 	if token == "" {
-		webgo.R403(w, "Sorry, you should be authorized to access this page")
+		errResponse(w, rbac.ErrUnauthenticated)
 		return
 	}
 
 	ctx := r.Context()
 	u, err := s.api.AuthenticatedUser(ctx, sourceID(r), token)
 	if err != nil {
-		switch err {
-		case users.ErrSessionID, users.ErrSessionIDExpired:
-			{
-				webgo.R403(w, err.Error())
-			}
-		default:
-			{
-				webgo.R500(w, err.Error())
-			}
-		}
+		errResponse(w, err)
 		return
 	}
 
-	r = r.WithContext(
-		users.SetContext(ctx, u),
+	// webgo shares the same request instance across all the handlers of a route, so the
+	// request is updated in place for the following handlers to get the user
+	*r = *r.WithContext(
+		users.SetSessionContext(users.SetContext(ctx, u), token),
 	)
 }
@@ -0,0 +1,445 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/webgo"
+)
+
+// The OpenAPI document is generated from the routes, their docs (docs.go) & the Go types of the
+// payloads; so the paths & schemas cannot drift from the handlers. checkDocs verifies that every
+// route is documented, and in debug mode every response is checked against the documented status
+
+const (
+	openAPIVersion = "3.0.3"
+	schemaRefPath  = "#/components/schemas/"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	durationType  = reflect.TypeOf(time.Duration(0))
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+	// thisPkg is the import path of this package, its types are named without the package
+	// prefix in the document
+	thisPkg = reflect.TypeOf(Server{}).PkgPath()
+)
+
+type openAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components openAPIComponents                `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*schema         `json:"schemas"`
+	SecuritySchemes map[string]*securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+}
+
+// schemaBuilder builds the schemas of Go types, named struct types are added to the components
+// & referred to
+type schemaBuilder struct {
+	components map[string]*schema
+}
+
+func componentName(t reflect.Type) string {
+	if t.PkgPath() == thisPkg {
+		return t.Name()
+	}
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+func (sb *schemaBuilder) schema(t reflect.Type) (*schema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		{
+			return &schema{Type: "string", Format: "date-time"}, nil
+		}
+	case durationType:
+		{
+			return &schema{Type: "integer", Format: "int64"}, nil
+		}
+	case rawJSONType:
+		{
+			// any JSON value
+			return &schema{}, nil
+		}
+	}
+
+	if t.Implements(marshalerType) {
+		// custom encodings are documented only as far as the JSON type is known
+		if t.Kind() == reflect.String || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8) {
+			return &schema{Type: "string"}, nil
+		}
+		return &schema{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		{
+			return &schema{Type: "boolean"}, nil
+		}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		{
+			return &schema{Type: "integer", Format: "int32"}, nil
+		}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		{
+			return &schema{Type: "integer", Format: "int64"}, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		{
+			return &schema{Type: "number"}, nil
+		}
+	case reflect.String:
+		{
+			return &schema{Type: "string"}, nil
+		}
+	case reflect.Interface:
+		{
+			return &schema{}, nil
+		}
+	case reflect.Slice, reflect.Array:
+		{
+			if t.Elem().Kind() == reflect.Uint8 {
+				return &schema{Type: "string", Format: "byte"}, nil
+			}
+			items, err := sb.schema(t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			return &schema{Type: "array", Items: items}, nil
+		}
+	case reflect.Map:
+		{
+			if t.Key().Kind() != reflect.String {
+				return nil, fmt.Errorf("map keys of %s should be strings", t)
+			}
+			values, err := sb.schema(t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			return &schema{Type: "object", AdditionalProperties: values}, nil
+		}
+	case reflect.Struct:
+		{
+			if t.Name() == "" {
+				return sb.structSchema(t)
+			}
+
+			name := componentName(t)
+			if _, ok := sb.components[name]; !ok {
+				// the placeholder is referred to, if the type is recursive
+				sb.components[name] = &schema{}
+				s, err := sb.structSchema(t)
+				if err != nil {
+					return nil, err
+				}
+				*sb.components[name] = *s
+			}
+			return &schema{Ref: schemaRefPath + name}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s cannot be represented in JSON", t)
+}
+
+// structSchema builds the schema of a struct, following the rules of encoding/json
+func (sb *schemaBuilder) structSchema(t reflect.Type) (*schema, error) {
+	s := &schema{Type: "object", Properties: make(map[string]*schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded, err := sb.structSchema(ft)
+				if err != nil {
+					return nil, err
+				}
+				for k, v := range embedded.Properties {
+					s.Properties[k] = v
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs, err := sb.schema(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %s", t, f.Name, err.Error())
+		}
+		s.Properties[name] = fs
+	}
+
+	return s, nil
+}
+
+// openAPIPath converts the webgo route pattern to an OpenAPI path, & returns the path parameters
+func openAPIPath(pattern string) (string, []string) {
+	segments := strings.Split(pattern, "/")
+	params := make([]string, 0, len(segments))
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") {
+			params = append(params, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// envelope is the schema of a successful response, as wrapped by webgo
+func envelope(data *schema) *schema {
+	return &schema{
+		Type: "object",
+		Properties: map[string]*schema{
+			"data":   data,
+			"status": &schema{Type: "integer", Format: "int32"},
+		},
+	}
+}
+
+func jsonContent(s *schema) map[string]*mediaType {
+	return map[string]*mediaType{
+		"application/json": &mediaType{Schema: s},
+	}
+}
+
+// newOpenAPI generates the OpenAPI document of the routes
+func newOpenAPI(routes []*webgo.Route, docs map[string]doc) (*openAPI, error) {
+	sb := &schemaBuilder{components: make(map[string]*schema)}
+	spec := &openAPI{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title:       "Padlock",
+			Description: "Authentication & multi factor verification for users & registered applications",
+			Version:     "1.0.0",
+		},
+		Paths: make(map[string]map[string]*operation),
+		Components: openAPIComponents{
+			Schemas: sb.components,
+			SecuritySchemes: map[string]*securityScheme{
+				string(authSession): &securityScheme{
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "Authorization",
+					Description: "The session token returned by the sign in APIs, it's set as a cookie as well",
+				},
+				string(authAPIKey): &securityScheme{
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "X-API-Key",
+					Description: "An API key of the registered application",
+				},
+			},
+		},
+	}
+
+	errResp, err := sb.schema(reflect.TypeOf(ErrorResponse{}))
+	if err != nil {
+		return nil, err
+	}
+	errSchema := &schema{
+		Type: "object",
+		Properties: map[string]*schema{
+			"errors": errResp,
+			"status": &schema{Type: "integer", Format: "int32"},
+		},
+	}
+
+	for _, route := range routes {
+		d := docs[route.Name]
+		p, pathParams := openAPIPath(route.Pattern)
+
+		op := &operation{
+			OperationID: route.Name,
+			Summary:     d.Summary,
+			Tags:        []string{strings.Split(route.Name, ".")[0]},
+			Responses: map[string]*response{
+				"default": &response{
+					Description: "Error",
+					Content:     jsonContent(errSchema),
+				},
+			},
+		}
+
+		if d.Auth != authNone {
+			op.Security = []map[string][]string{{string(d.Auth): []string{}}}
+		}
+
+		for _, name := range pathParams {
+			op.Parameters = append(op.Parameters, parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &schema{Type: d.paramType(name)},
+			})
+		}
+		for _, q := range d.Query {
+			op.Parameters = append(op.Parameters, parameter{
+				Name:   q.Name,
+				In:     "query",
+				Schema: &schema{Type: q.Type, Format: q.Format},
+			})
+		}
+
+		if d.Request != nil {
+			s, err := sb.schema(reflect.TypeOf(d.Request))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", route.Name, err.Error())
+			}
+			op.RequestBody = &requestBody{Required: true, Content: jsonContent(s)}
+		}
+
+		resp := &response{Description: http.StatusText(d.Status)}
+		if d.Status != http.StatusNoContent {
+			data := &schema{}
+			if d.Response != nil {
+				s, err := sb.schema(reflect.TypeOf(d.Response))
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s", route.Name, err.Error())
+				}
+				data = s
+			}
+			if !d.Raw {
+				data = envelope(data)
+			}
+			resp.Content = jsonContent(data)
+		}
+		if d.HTML {
+			// the errors are shown on the page as well
+			html := map[string]*mediaType{
+				"text/html": &mediaType{Schema: &schema{Type: "string"}},
+			}
+			resp.Content = html
+			op.Responses["default"].Content = html
+		}
+		op.Responses[fmt.Sprintf("%d", d.Status)] = resp
+
+		if spec.Paths[p] == nil {
+			spec.Paths[p] = make(map[string]*operation)
+		}
+		spec.Paths[p][strings.ToLower(route.Method)] = op
+	}
+
+	return spec, nil
+}
+
+// checkDocs verifies that every route is documented, and all the docs are of existing routes
+func checkDocs(routes []*webgo.Route, docs map[string]doc) error {
+	names := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		names[route.Name] = true
+
+		d, ok := docs[route.Name]
+		if !ok {
+			return fmt.Errorf("route '%s' (%s %s) is not documented", route.Name, route.Method, route.Pattern)
+		}
+
+		if d.Status < 200 || d.Status > 299 {
+			return fmt.Errorf("route '%s' should be documented with a 2xx status", route.Name)
+		}
+
+		if d.Status == http.StatusNoContent && d.Response != nil {
+			return fmt.Errorf("route '%s' responds with no content, but has a response documented", route.Name)
+		}
+	}
+
+	undocumented := make([]string, 0)
+	for name := range docs {
+		if !names[name] {
+			undocumented = append(undocumented, name)
+		}
+	}
+	if len(undocumented) > 0 {
+		sort.Strings(undocumented)
+		return fmt.Errorf("docs of non existent routes: %s", strings.Join(undocumented, ", "))
+	}
+
+	return nil
+}
+
+// OpenAPI responds with the OpenAPI 3 document of the HTTP API. Unlike the other handlers, the
+// document is not wrapped in 'data', so that it can be used as is by the OpenAPI tools
+func (s *Server) OpenAPI(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set(webgo.HeaderContentType, webgo.JSONContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(s.openAPI)
+}
@@ -0,0 +1,329 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/orgs"
+)
+
+// orgParams parses the integer URI parameters of the request, in the given order. It responds
+// with 404 and returns false if any of them is invalid
+func orgParams(w http.ResponseWriter, req *http.Request, names ...string) ([]int64, bool) {
+	params := webgo.Context(req).Params
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		id, err := strconv.ParseInt(params[name], 10, 64)
+		if err != nil || id < 1 {
+			errResponse(w, apperr.New(apperr.CodeNotFound, "Sorry, invalid "+name+" provided"))
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// CreateOrg creates an organisation owned by the authenticated user
+func (s *Server) CreateOrg(w http.ResponseWriter, req *http.Request) {
+	org := orgs.Organisation{}
+	err := json.NewDecoder(req.Body).Decode(&org)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	created, err := s.api.CreateOrg(req.Context(), org)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R201(w, created)
+}
+
+// Orgs lists the organisations of the authenticated user
+func (s *Server) Orgs(w http.ResponseWriter, req *http.Request) {
+	list, err := s.api.Orgs(req.Context())
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// Org responds with the details of an organisation
+func (s *Server) Org(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID")
+	if !ok {
+		return
+	}
+
+	org, err := s.api.Org(req.Context(), ids[0])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, org)
+}
+
+// UpdateOrg updates the details of an organisation
+func (s *Server) UpdateOrg(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID")
+	if !ok {
+		return
+	}
+
+	org := orgs.Organisation{}
+	err := json.NewDecoder(req.Body).Decode(&org)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+	org.ID = ids[0]
+
+	updated, err := s.api.UpdateOrg(req.Context(), org)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, updated)
+}
+
+// DeleteOrg deletes an organisation
+func (s *Server) DeleteOrg(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID")
+	if !ok {
+		return
+	}
+
+	err := s.api.DeleteOrg(req.Context(), ids[0])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
+
+// OrgMembers lists the members of an organisation
+func (s *Server) OrgMembers(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID")
+	if !ok {
+		return
+	}
+
+	list, err := s.api.OrgMembers(req.Context(), ids[0])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// SetOrgMember adds a user to an organisation, or updates the role of an existing member
+func (s *Server) SetOrgMember(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID", "userID")
+	if !ok {
+		return
+	}
+
+	m := orgs.Member{}
+	err := json.NewDecoder(req.Body).Decode(&m)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+	m.OrgID, m.UserID = ids[0], ids[1]
+
+	updated, err := s.api.SetOrgMember(req.Context(), m)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, updated)
+}
+
+// RemoveOrgMember removes a member from an organisation
+func (s *Server) RemoveOrgMember(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID", "userID")
+	if !ok {
+		return
+	}
+
+	err := s.api.RemoveOrgMember(req.Context(), ids[0], ids[1])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
+
+// CreateTeam creates a team within an organisation
+func (s *Server) CreateTeam(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID")
+	if !ok {
+		return
+	}
+
+	t := orgs.Team{}
+	err := json.NewDecoder(req.Body).Decode(&t)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+	t.OrgID = ids[0]
+
+	created, err := s.api.CreateTeam(req.Context(), t)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R201(w, created)
+}
+
+// Teams lists the teams of an organisation
+func (s *Server) Teams(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID")
+	if !ok {
+		return
+	}
+
+	list, err := s.api.Teams(req.Context(), ids[0])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// DeleteTeam deletes a team of an organisation
+func (s *Server) DeleteTeam(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID", "teamID")
+	if !ok {
+		return
+	}
+
+	err := s.api.DeleteTeam(req.Context(), ids[0], ids[1])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
+
+// TeamMembers lists the members of a team
+func (s *Server) TeamMembers(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID", "teamID")
+	if !ok {
+		return
+	}
+
+	list, err := s.api.TeamMembers(req.Context(), ids[0], ids[1])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// AddTeamMember adds a member of the organisation to a team
+func (s *Server) AddTeamMember(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID", "teamID", "userID")
+	if !ok {
+		return
+	}
+
+	tm, err := s.api.AddTeamMember(req.Context(), ids[0], ids[1], ids[2])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, tm)
+}
+
+// RemoveTeamMember removes a user from a team
+func (s *Server) RemoveTeamMember(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID", "teamID", "userID")
+	if !ok {
+		return
+	}
+
+	err := s.api.RemoveTeamMember(req.Context(), ids[0], ids[1], ids[2])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
+
+// TeamApps lists the applications granted to a team
+func (s *Server) TeamApps(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID", "teamID")
+	if !ok {
+		return
+	}
+
+	list, err := s.api.TeamApps(req.Context(), ids[0], ids[1])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// GrantTeamApp grants a team a role on an application of the organisation
+func (s *Server) GrantTeamApp(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID", "teamID", "appID")
+	if !ok {
+		return
+	}
+
+	ta := orgs.TeamApp{}
+	err := json.NewDecoder(req.Body).Decode(&ta)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+	ta.TeamID, ta.AppID = ids[1], ids[2]
+
+	granted, err := s.api.GrantTeamApp(req.Context(), ids[0], ta)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, granted)
+}
+
+// RevokeTeamApp revokes the access of a team to an application
+func (s *Server) RevokeTeamApp(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "orgID", "teamID", "appID")
+	if !ok {
+		return
+	}
+
+	err := s.api.RevokeTeamApp(req.Context(), ids[0], ids[1], ids[2])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/platform/health"
+	"github.com/bnkamalesh/padlock/pkg/users"
+	"github.com/bnkamalesh/padlock/pkg/webauthn"
+)
+
+// The request & response payloads of the handlers, which are not types of the pkg packages.
+// They're used for generating the OpenAPI document as well, so any change here is reflected
+// in the document
+
+// LoginRequest is the payload of Login
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// PasswordlessRequest is the payload of RequestPasswordless
+type PasswordlessRequest struct {
+	Email string `json:"email"`
+	// Kind is either 'code' or 'link'
+	Kind string `json:"kind"`
+}
+
+// EmailCodeLoginRequest is the payload of LoginWithEmailCode
+type EmailCodeLoginRequest struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+// CodeRequest is the payload of the handlers which verify a one time code
+type CodeRequest struct {
+	Code string `json:"code"`
+}
+
+// ContactChangeRequest is the payload of RequestContactChange
+type ContactChangeRequest struct {
+	// Field is either 'email' or 'phone'
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// PhoneOTPRequest is the payload of SendPhoneOTP
+type PhoneOTPRequest struct {
+	// Channel is either 'sms' or 'voice'
+	Channel string `json:"channel"`
+}
+
+// PushDeviceRequest is the payload of EnrollPushDevice
+type PushDeviceRequest struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"`
+	PushToken string `json:"pushToken"`
+}
+
+// WebAuthnRegistrationRequest is the payload of FinishWebAuthnRegistration
+type WebAuthnRegistrationRequest struct {
+	Name       string                        `json:"name"`
+	Credential webauthn.RegistrationResponse `json:"credential"`
+}
+
+// AcceptInviteRequest is the payload of AcceptInvite, it's required only if the invitee does
+// not have an account yet
+type AcceptInviteRequest struct {
+	Name     string `json:"name,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// ErrorResponse is the error in the response of all the handlers, as 'errors' in the payload
+type ErrorResponse struct {
+	// Code is the machine readable code of the error, it never changes for an error
+	Code    apperr.Code `json:"code"`
+	Message string      `json:"message"`
+	// RequestID is the ID of the request, which can be used to trace the request in the logs
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// UsersList is the response of ListUsers
+type UsersList struct {
+	Users []users.User `json:"users"`
+	// Total is the number of users matching the filters, irrespective of the pagination
+	Total int64 `json:"total"`
+}
+
+// AppsList is the response of ListApps
+type AppsList struct {
+	Apps []apps.App `json:"apps"`
+	// Total is the number of apps matching the filters, irrespective of the pagination
+	Total int64 `json:"total"`
+}
+
+// Liveness is the response of Healthz
+type Liveness struct {
+	Status health.Status `json:"status"`
+}
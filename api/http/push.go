@@ -0,0 +1,119 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/push"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// EnrollPushDevice enrolls a device of the authenticated user for approving sign ins
+func (s *Server) EnrollPushDevice(w http.ResponseWriter, req *http.Request) {
+	payload := PushDeviceRequest{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	ctx := req.Context()
+	d, err := s.api.EnrollPushDevice(ctx, users.FromContext(ctx), payload.Name, payload.PublicKey, payload.PushToken)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R201(w, d)
+}
+
+// PushDevices lists the devices enrolled by the authenticated user
+func (s *Server) PushDevices(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	list, err := s.api.PushDevices(ctx, users.FromContext(ctx))
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// RemovePushDevice removes an enrolled device of the authenticated user
+func (s *Server) RemovePushDevice(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(webgo.Context(req).Params["id"], 10, 64)
+	if err != nil {
+		errResponse(w, push.ErrDeviceNotFound)
+		return
+	}
+
+	ctx := req.Context()
+	err = s.api.RemovePushDevice(ctx, users.FromContext(ctx), id)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
+
+// PushChallenge sends a sign in approval request to the devices of the authenticated user
+func (s *Server) PushChallenge(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	ch, err := s.api.PushChallenge(ctx, users.FromContext(ctx), s.source(req))
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R201(w, ch)
+}
+
+// PushStatus responds with the status of the sign in approval request. If the query parameter
+// 'wait' (in seconds) is provided, the request is held till the status changes or wait elapses
+func (s *Server) PushStatus(w http.ResponseWriter, req *http.Request) {
+	wait := time.Duration(0)
+	if str := req.URL.Query().Get("wait"); str != "" {
+		secs, err := strconv.Atoi(str)
+		if err != nil || secs < 0 {
+			errResponse(w, apperr.New(apperr.CodeInvalidInput, "Sorry, wait should be the number of seconds to wait"))
+			return
+		}
+		wait = time.Duration(secs) * time.Second
+	}
+
+	ctx := req.Context()
+	ch, err := s.api.PushStatus(ctx, users.FromContext(ctx), webgo.Context(req).Params["id"], wait)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, ch)
+}
+
+// PushRespond records the approval/denial of a sign in request, sent by an enrolled device. The
+// response is authenticated using the signature of the device
+func (s *Server) PushRespond(w http.ResponseWriter, req *http.Request) {
+	payload := push.Response{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	ch, err := s.api.PushRespond(req.Context(), webgo.Context(req).Params["id"], payload)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, ch)
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// Permission returns a middleware which allows the request to proceed only if the authenticated
+// user is allowed the permission. The app is identified by the URI parameter 'appID', if any.
+// It should be used after the Authentication middleware
+func (s *Server) Permission(perm rbac.Permission) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		appID := int64(0)
+		if str := webgo.Context(r).Params["appID"]; str != "" {
+			id, err := strconv.ParseInt(str, 10, 64)
+			if err != nil {
+				errResponse(w, apperr.New(apperr.CodeNotFound, "Sorry, invalid application ID"))
+				return
+			}
+			appID = id
+		}
+
+		err := s.api.Authorize(r.Context(), perm, appID)
+		if err != nil {
+			errResponse(w, err)
+			return
+		}
+	}
+}
+
+// ListUsers lists users, filtered & paginated based on the query parameters
+func (s *Server) ListUsers(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	filter := users.ListFilter{
+		Email: q.Get("email"),
+		Name:  q.Get("name"),
+	}
+	filter.Offset, _ = strconv.Atoi(q.Get("offset"))
+	filter.Limit, _ = strconv.Atoi(q.Get("limit"))
+
+	for key, ptr := range map[string]**time.Time{"createdFrom": &filter.CreatedFrom, "createdTo": &filter.CreatedTo} {
+		str := q.Get(key)
+		if str == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, str)
+		if err != nil {
+			errResponse(w, apperr.New(apperr.CodeInvalidInput, "Sorry, "+key+" should be an RFC3339 timestamp"))
+			return
+		}
+		*ptr = &t
+	}
+
+	list, total, err := s.api.ListUsers(req.Context(), filter)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, UsersList{Users: list, Total: total})
+}
+
+// AssignRole assigns a role to a user
+func (s *Server) AssignRole(w http.ResponseWriter, req *http.Request) {
+	payload := rbac.Assignment{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	a, err := s.api.AssignRole(req.Context(), payload)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R201(w, a)
+}
+
+// RevokeRole removes a role assignment
+func (s *Server) RevokeRole(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(webgo.Context(req).Params["id"], 10, 64)
+	if err != nil {
+		errResponse(w, rbac.ErrNotFound)
+		return
+	}
+
+	err = s.api.RevokeRole(req.Context(), id)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
+
+// RoleAssignments lists the roles assigned to a user
+func (s *Server) RoleAssignments(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(webgo.Context(req).Params["id"], 10, 64)
+	if err != nil {
+		errResponse(w, users.ErrInvalidID)
+		return
+	}
+
+	list, err := s.api.RoleAssignments(req.Context(), id)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
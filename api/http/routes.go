@@ -4,6 +4,11 @@ import (
 	"net/http"
 
 	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
+	"github.com/bnkamalesh/padlock/pkg/invites"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/users"
 )
 
 func helloworld(w http.ResponseWriter, req *http.Request) {
@@ -18,17 +23,485 @@ func routes(s *Server) []*webgo.Route {
 			Method:   http.MethodGet,
 			Handlers: []http.HandlerFunc{helloworld},
 		},
+		&webgo.Route{
+			Name:     "openapi",
+			Pattern:  "/openapi.json",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.OpenAPI},
+		},
+		&webgo.Route{
+			Name:     "healthz",
+			Pattern:  "/healthz",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Healthz},
+		},
+		&webgo.Route{
+			Name:     "readyz",
+			Pattern:  "/readyz",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Readyz},
+		},
 		&webgo.Route{
 			Name:     "login",
 			Pattern:  "/login",
 			Method:   http.MethodPost,
 			Handlers: []http.HandlerFunc{s.Login},
 		},
+		&webgo.Route{
+			Name:     "login.email",
+			Pattern:  "/login/email",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.RequestPasswordless},
+		},
+		&webgo.Route{
+			Name:     "login.email.verify",
+			Pattern:  "/login/email/verify",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.LoginWithEmailCode},
+		},
+		&webgo.Route{
+			Name:     "login.link",
+			Pattern:  users.MagicLinkPath + ":token",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.LoginWithMagicLink},
+		},
 		&webgo.Route{
 			Name:     "auth.required",
 			Pattern:  "/restricted",
 			Method:   http.MethodGet,
 			Handlers: []http.HandlerFunc{s.Authentication, helloworld},
 		},
+		&webgo.Route{
+			Name:     "me",
+			Pattern:  "/me",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.Me},
+		},
+		&webgo.Route{
+			Name:     "users.list",
+			Pattern:  "/users",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.Permission(rbac.PermUsers), s.ListUsers},
+		},
+		&webgo.Route{
+			Name:     "users.roles",
+			Pattern:  "/users/:id/roles",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.RoleAssignments},
+		},
+		&webgo.Route{
+			Name:     "roles.assign",
+			Pattern:  "/roles",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.AssignRole},
+		},
+		&webgo.Route{
+			Name:     "roles.revoke",
+			Pattern:  "/roles/:id",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.RevokeRole},
+		},
+		&webgo.Route{
+			Name:     "users.update",
+			Pattern:  "/users/:id",
+			Method:   http.MethodPatch,
+			Handlers: []http.HandlerFunc{s.Authentication, s.UpdateUser},
+		},
+		&webgo.Route{
+			Name:     "me.contact.change",
+			Pattern:  "/me/contact",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.RequestContactChange},
+		},
+		&webgo.Route{
+			Name:     "me.contact.confirm",
+			Pattern:  "/me/contact/:id/confirm",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.ConfirmContactChange},
+		},
+		&webgo.Route{
+			Name:     "me.otp.phone",
+			Pattern:  "/me/otp/phone",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.SendPhoneOTP},
+		},
+		&webgo.Route{
+			Name:     "me.otp.phone.verify",
+			Pattern:  "/me/otp/phone/verify",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.VerifyPhoneOTP},
+		},
+		&webgo.Route{
+			Name:     "me.webauthn.list",
+			Pattern:  "/me/webauthn",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.WebAuthnCredentials},
+		},
+		&webgo.Route{
+			Name:     "me.webauthn.delete",
+			Pattern:  "/me/webauthn/:id",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.DeleteWebAuthnCredential},
+		},
+		&webgo.Route{
+			Name:     "me.webauthn.register.begin",
+			Pattern:  "/me/webauthn/register/begin",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.BeginWebAuthnRegistration},
+		},
+		&webgo.Route{
+			Name:     "me.webauthn.register.finish",
+			Pattern:  "/me/webauthn/register/finish",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.FinishWebAuthnRegistration},
+		},
+		&webgo.Route{
+			Name:     "me.webauthn.assert.begin",
+			Pattern:  "/me/webauthn/assert/begin",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.BeginWebAuthnAssertion},
+		},
+		&webgo.Route{
+			Name:     "me.webauthn.assert.finish",
+			Pattern:  "/me/webauthn/assert/finish",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.FinishWebAuthnAssertion},
+		},
+		&webgo.Route{
+			Name:     "me.push.devices.list",
+			Pattern:  "/me/push/devices",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.PushDevices},
+		},
+		&webgo.Route{
+			Name:     "me.push.devices.enroll",
+			Pattern:  "/me/push/devices",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.EnrollPushDevice},
+		},
+		&webgo.Route{
+			Name:     "me.push.devices.remove",
+			Pattern:  "/me/push/devices/:id",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.RemovePushDevice},
+		},
+		&webgo.Route{
+			Name:     "me.push.challenge",
+			Pattern:  "/me/push/challenges",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.PushChallenge},
+		},
+		&webgo.Route{
+			Name:     "me.push.challenge.status",
+			Pattern:  "/me/push/challenges/:id",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.PushStatus},
+		},
+		&webgo.Route{
+			Name:     "push.challenge.respond",
+			Pattern:  "/push/challenges/:id/respond",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.PushRespond},
+		},
+		&webgo.Route{
+			Name:     "apps.list",
+			Pattern:  "/apps",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.ListApps},
+		},
+		&webgo.Route{
+			Name:     "apps.create",
+			Pattern:  "/apps",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.CreateApp},
+		},
+		&webgo.Route{
+			Name:     "apps.read",
+			Pattern:  "/apps/:appID",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.App},
+		},
+		&webgo.Route{
+			Name:     "apps.update",
+			Pattern:  "/apps/:appID",
+			Method:   http.MethodPatch,
+			Handlers: []http.HandlerFunc{s.Authentication, s.UpdateApp},
+		},
+		&webgo.Route{
+			Name:     "apps.delete",
+			Pattern:  "/apps/:appID",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.DeleteApp},
+		},
+		&webgo.Route{
+			Name:     "apps.owners",
+			Pattern:  "/apps/:appID/owners",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.AppOwners},
+		},
+		&webgo.Route{
+			Name:     "apps.owners.set",
+			Pattern:  "/apps/:appID/owners/:userID",
+			Method:   http.MethodPut,
+			Handlers: []http.HandlerFunc{s.Authentication, s.SetAppOwner},
+		},
+		&webgo.Route{
+			Name:     "apps.owners.remove",
+			Pattern:  "/apps/:appID/owners/:userID",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.RemoveAppOwner},
+		},
+		&webgo.Route{
+			Name:     "apps.keys",
+			Pattern:  "/apps/:appID/keys",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.APIKeys},
+		},
+		&webgo.Route{
+			Name:     "apps.keys.create",
+			Pattern:  "/apps/:appID/keys",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.CreateAPIKey},
+		},
+		&webgo.Route{
+			Name:     "apps.keys.revoke",
+			Pattern:  "/apps/:appID/keys/:id",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.RevokeAPIKey},
+		},
+		&webgo.Route{
+			Name:     "apps.webhooks",
+			Pattern:  "/apps/:appID/webhooks",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.Webhooks},
+		},
+		&webgo.Route{
+			Name:     "apps.webhooks.create",
+			Pattern:  "/apps/:appID/webhooks",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.CreateWebhook},
+		},
+		&webgo.Route{
+			Name:     "apps.webhooks.update",
+			Pattern:  "/apps/:appID/webhooks/:id",
+			Method:   http.MethodPut,
+			Handlers: []http.HandlerFunc{s.Authentication, s.UpdateWebhook},
+		},
+		&webgo.Route{
+			Name:     "apps.webhooks.delete",
+			Pattern:  "/apps/:appID/webhooks/:id",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.DeleteWebhook},
+		},
+		&webgo.Route{
+			Name:     "apps.webhooks.deliveries",
+			Pattern:  "/apps/:appID/webhooks/:id/deliveries",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.WebhookDeliveries},
+		},
+		&webgo.Route{
+			Name:     "apps.deliveries.read",
+			Pattern:  "/apps/:appID/deliveries/:deliveryID",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.WebhookDelivery},
+		},
+		&webgo.Route{
+			Name:     "apps.deliveries.redeliver",
+			Pattern:  "/apps/:appID/deliveries/:deliveryID/redeliver",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.RedeliverWebhook},
+		},
+		&webgo.Route{
+			Name:     "app",
+			Pattern:  "/app",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.APIKeyAuthentication, s.AuthenticatedApp},
+		},
+		&webgo.Route{
+			Name:     "apps.subjects.enroll",
+			Pattern:  "/apps/:appID/subjects/:subjectID/enroll",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.APIKeyAuthentication, s.APIKeyScope(apikeys.ScopeEnroll), s.EnrollSubject},
+		},
+		&webgo.Route{
+			Name:     "apps.subjects.unenroll",
+			Pattern:  "/apps/:appID/subjects/:subjectID/unenroll",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.APIKeyAuthentication, s.APIKeyScope(apikeys.ScopeEnroll), s.UnenrollSubject},
+		},
+		&webgo.Route{
+			Name:     "apps.subjects.verify",
+			Pattern:  "/apps/:appID/subjects/:subjectID/verify",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.APIKeyAuthentication, s.APIKeyScope(apikeys.ScopeVerify), s.VerifySubject},
+		},
+		&webgo.Route{
+			Name:     "orgs.list",
+			Pattern:  "/orgs",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.Orgs},
+		},
+		&webgo.Route{
+			Name:     "orgs.create",
+			Pattern:  "/orgs",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.CreateOrg},
+		},
+		&webgo.Route{
+			Name:     "orgs.read",
+			Pattern:  "/orgs/:orgID",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.Org},
+		},
+		&webgo.Route{
+			Name:     "orgs.update",
+			Pattern:  "/orgs/:orgID",
+			Method:   http.MethodPatch,
+			Handlers: []http.HandlerFunc{s.Authentication, s.UpdateOrg},
+		},
+		&webgo.Route{
+			Name:     "orgs.delete",
+			Pattern:  "/orgs/:orgID",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.DeleteOrg},
+		},
+		&webgo.Route{
+			Name:     "orgs.members",
+			Pattern:  "/orgs/:orgID/members",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.OrgMembers},
+		},
+		&webgo.Route{
+			Name:     "orgs.members.set",
+			Pattern:  "/orgs/:orgID/members/:userID",
+			Method:   http.MethodPut,
+			Handlers: []http.HandlerFunc{s.Authentication, s.SetOrgMember},
+		},
+		&webgo.Route{
+			Name:     "orgs.members.remove",
+			Pattern:  "/orgs/:orgID/members/:userID",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.RemoveOrgMember},
+		},
+		&webgo.Route{
+			Name:     "orgs.teams",
+			Pattern:  "/orgs/:orgID/teams",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.Teams},
+		},
+		&webgo.Route{
+			Name:     "orgs.teams.create",
+			Pattern:  "/orgs/:orgID/teams",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.CreateTeam},
+		},
+		&webgo.Route{
+			Name:     "orgs.teams.delete",
+			Pattern:  "/orgs/:orgID/teams/:teamID",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.DeleteTeam},
+		},
+		&webgo.Route{
+			Name:     "orgs.teams.members",
+			Pattern:  "/orgs/:orgID/teams/:teamID/members",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.TeamMembers},
+		},
+		&webgo.Route{
+			Name:     "orgs.teams.members.add",
+			Pattern:  "/orgs/:orgID/teams/:teamID/members/:userID",
+			Method:   http.MethodPut,
+			Handlers: []http.HandlerFunc{s.Authentication, s.AddTeamMember},
+		},
+		&webgo.Route{
+			Name:     "orgs.teams.members.remove",
+			Pattern:  "/orgs/:orgID/teams/:teamID/members/:userID",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.RemoveTeamMember},
+		},
+		&webgo.Route{
+			Name:     "orgs.teams.apps",
+			Pattern:  "/orgs/:orgID/teams/:teamID/apps",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.TeamApps},
+		},
+		&webgo.Route{
+			Name:     "orgs.teams.apps.grant",
+			Pattern:  "/orgs/:orgID/teams/:teamID/apps/:appID",
+			Method:   http.MethodPut,
+			Handlers: []http.HandlerFunc{s.Authentication, s.GrantTeamApp},
+		},
+		&webgo.Route{
+			Name:     "orgs.teams.apps.revoke",
+			Pattern:  "/orgs/:orgID/teams/:teamID/apps/:appID",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.RevokeTeamApp},
+		},
+		&webgo.Route{
+			Name:     "me.invites",
+			Pattern:  "/me/invites",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.ReceivedInvites},
+		},
+		&webgo.Route{
+			Name:     "orgs.invites",
+			Pattern:  "/orgs/:orgID/invites",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.PendingInvites},
+		},
+		&webgo.Route{
+			Name:     "orgs.invites.create",
+			Pattern:  "/orgs/:orgID/invites",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.Invite},
+		},
+		&webgo.Route{
+			Name:     "apps.invites",
+			Pattern:  "/apps/:appID/invites",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Authentication, s.PendingInvites},
+		},
+		&webgo.Route{
+			Name:     "apps.invites.create",
+			Pattern:  "/apps/:appID/invites",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.Authentication, s.Invite},
+		},
+		&webgo.Route{
+			Name:     "invites.revoke",
+			Pattern:  "/invites/:id",
+			Method:   http.MethodDelete,
+			Handlers: []http.HandlerFunc{s.Authentication, s.RevokeInvite},
+		},
+		&webgo.Route{
+			Name:     "invites.read",
+			Pattern:  invites.InvitePath + ":token",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.Invitation},
+		},
+		&webgo.Route{
+			Name:     "invites.accept",
+			Pattern:  invites.InvitePath + ":token/accept",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.AcceptInvite},
+		},
+		&webgo.Route{
+			Name:     "invites.decline",
+			Pattern:  invites.InvitePath + ":token/decline",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.DeclineInvite},
+		},
+		&webgo.Route{
+			Name:     "users.contact.revert.page",
+			Pattern:  users.ContactRevertPath + ":token",
+			Method:   http.MethodGet,
+			Handlers: []http.HandlerFunc{s.ContactRevertPage},
+		},
+		&webgo.Route{
+			Name:     "users.contact.revert",
+			Pattern:  users.ContactRevertPath + ":token",
+			Method:   http.MethodPost,
+			Handlers: []http.HandlerFunc{s.RevertContactChange},
+		},
 	}
 }
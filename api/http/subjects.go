@@ -0,0 +1,84 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+)
+
+// subjectApp returns the application authenticated by the API key, after making sure it's the
+// application in the URL. It responds with 403 and returns false otherwise
+func subjectApp(w http.ResponseWriter, req *http.Request) (*apps.App, bool) {
+	id, ok := appID(w, req)
+	if !ok {
+		return nil, false
+	}
+
+	app := apikeys.AppFromContext(req.Context())
+	if app == nil || app.ID != id {
+		errResponse(w, apikeys.ErrScope)
+		return nil, false
+	}
+
+	return app, true
+}
+
+// EnrollSubject enrolls a user of the application, identified by the application's own user ID
+func (s *Server) EnrollSubject(w http.ResponseWriter, req *http.Request) {
+	app, ok := subjectApp(w, req)
+	if !ok {
+		return
+	}
+
+	enrollment, err := s.api.EnrollSubject(req.Context(), app, webgo.Context(req).Params["subjectID"])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R201(w, enrollment)
+}
+
+// UnenrollSubject removes the enrollment of a user of the application
+func (s *Server) UnenrollSubject(w http.ResponseWriter, req *http.Request) {
+	app, ok := subjectApp(w, req)
+	if !ok {
+		return
+	}
+
+	err := s.api.UnenrollSubject(req.Context(), app, webgo.Context(req).Params["subjectID"])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
+
+// VerifySubject verifies the code of a user of the application. A failed verification is not
+// an error, the status of the result says whether the code was valid
+func (s *Server) VerifySubject(w http.ResponseWriter, req *http.Request) {
+	app, ok := subjectApp(w, req)
+	if !ok {
+		return
+	}
+
+	payload := CodeRequest{}
+	err := json.NewDecoder(req.Body).Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	result, err := s.api.VerifySubject(req.Context(), app, webgo.Context(req).Params["subjectID"], payload.Code)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, result)
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/users"
+	"github.com/bnkamalesh/padlock/pkg/webauthn"
+)
+
+// BeginWebAuthnRegistration responds with the options for navigator.credentials.create()
+func (s *Server) BeginWebAuthnRegistration(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	opts, err := s.api.BeginWebAuthnRegistration(ctx, users.FromContext(ctx))
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, opts)
+}
+
+// FinishWebAuthnRegistration registers the credential created by navigator.credentials.create()
+func (s *Server) FinishWebAuthnRegistration(w http.ResponseWriter, req *http.Request) {
+	payload := WebAuthnRegistrationRequest{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	ctx := req.Context()
+	cred, err := s.api.FinishWebAuthnRegistration(ctx, users.FromContext(ctx), payload.Name, payload.Credential)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R201(w, cred)
+}
+
+// BeginWebAuthnAssertion responds with the options for navigator.credentials.get()
+func (s *Server) BeginWebAuthnAssertion(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	opts, err := s.api.BeginWebAuthnAssertion(ctx, users.FromContext(ctx))
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, opts)
+}
+
+// FinishWebAuthnAssertion verifies the assertion generated by navigator.credentials.get()
+func (s *Server) FinishWebAuthnAssertion(w http.ResponseWriter, req *http.Request) {
+	payload := webauthn.AssertionResponse{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&payload)
+	if err != nil {
+		invalidPayload(w, err)
+		return
+	}
+
+	ctx := req.Context()
+	result, err := s.api.FinishWebAuthnAssertion(ctx, users.FromContext(ctx), payload)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, result)
+}
+
+// WebAuthnCredentials lists the authenticators registered by the authenticated user
+func (s *Server) WebAuthnCredentials(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	creds, err := s.api.WebAuthnCredentials(ctx, users.FromContext(ctx))
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, creds)
+}
+
+// DeleteWebAuthnCredential removes an authenticator of the authenticated user
+func (s *Server) DeleteWebAuthnCredential(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(webgo.Context(req).Params["id"], 10, 64)
+	if err != nil {
+		errResponse(w, webauthn.ErrCredentialNotFound)
+		return
+	}
+
+	ctx := req.Context()
+	err = s.api.DeleteWebAuthnCredential(ctx, users.FromContext(ctx), id)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bnkamalesh/webgo"
+
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/webhooks"
+)
+
+// decodeWebhook decodes the endpoint in the request body, and sets the IDs from the URL
+func decodeWebhook(w http.ResponseWriter, req *http.Request, appID, id int64) (webhooks.Endpoint, bool) {
+	e := webhooks.Endpoint{}
+	err := json.NewDecoder(req.Body).Decode(&e)
+	if err != nil {
+		invalidPayload(w, err)
+		return e, false
+	}
+	e.AppID = appID
+	e.ID = id
+	return e, true
+}
+
+// CreateWebhook creates a webhook endpoint for the application
+func (s *Server) CreateWebhook(w http.ResponseWriter, req *http.Request) {
+	id, ok := appID(w, req)
+	if !ok {
+		return
+	}
+
+	e, ok := decodeWebhook(w, req, id, 0)
+	if !ok {
+		return
+	}
+
+	created, err := s.api.CreateWebhook(req.Context(), e)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R201(w, created)
+}
+
+// Webhooks lists the webhook endpoints of the application
+func (s *Server) Webhooks(w http.ResponseWriter, req *http.Request) {
+	id, ok := appID(w, req)
+	if !ok {
+		return
+	}
+
+	list, err := s.api.Webhooks(req.Context(), id)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// UpdateWebhook replaces the URL, events & active status of a webhook endpoint
+func (s *Server) UpdateWebhook(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "appID", "id")
+	if !ok {
+		return
+	}
+
+	e, ok := decodeWebhook(w, req, ids[0], ids[1])
+	if !ok {
+		return
+	}
+
+	updated, err := s.api.UpdateWebhook(req.Context(), e)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, updated)
+}
+
+// DeleteWebhook deletes a webhook endpoint, along with its deliveries
+func (s *Server) DeleteWebhook(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "appID", "id")
+	if !ok {
+		return
+	}
+
+	err := s.api.DeleteWebhook(req.Context(), ids[0], ids[1])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R204(w)
+}
+
+// WebhookDeliveries lists the latest deliveries of a webhook endpoint. The number of deliveries
+// can be set with the 'limit' query parameter
+func (s *Server) WebhookDeliveries(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "appID", "id")
+	if !ok {
+		return
+	}
+
+	limit := 0
+	if str := req.URL.Query().Get("limit"); str != "" {
+		l, err := strconv.Atoi(str)
+		if err != nil {
+			errResponse(w, apperr.New(apperr.CodeInvalidInput, "Sorry, invalid limit provided"))
+			return
+		}
+		limit = l
+	}
+
+	list, err := s.api.WebhookDeliveries(req.Context(), ids[0], ids[1], limit)
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, list)
+}
+
+// WebhookDelivery responds with a delivery, along with the log of its attempts
+func (s *Server) WebhookDelivery(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "appID", "deliveryID")
+	if !ok {
+		return
+	}
+
+	d, err := s.api.WebhookDelivery(req.Context(), ids[0], ids[1])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, d)
+}
+
+// RedeliverWebhook queues a delivery to be delivered again
+func (s *Server) RedeliverWebhook(w http.ResponseWriter, req *http.Request) {
+	ids, ok := orgParams(w, req, "appID", "deliveryID")
+	if !ok {
+		return
+	}
+
+	d, err := s.api.RedeliverWebhook(req.Context(), ids[0], ids[1])
+	if err != nil {
+		errResponse(w, err)
+		return
+	}
+
+	webgo.R200(w, d)
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+
+	"github.com/bnkamalesh/padlock/pkg/invites"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// Invite invites a collaborator by email, to an organisation or an application
+func (a *API) Invite(ctx context.Context, inv invites.Invitation) (*invites.Invitation, error) {
+	return a.invites.Invite(ctx, inv)
+}
+
+// PendingInvites lists the pending invitations of an organisation or an application
+func (a *API) PendingInvites(ctx context.Context, orgID, appID int64) ([]invites.Invitation, error) {
+	return a.invites.Pending(ctx, orgID, appID)
+}
+
+// ReceivedInvites lists the pending invitations sent to the authenticated user
+func (a *API) ReceivedInvites(ctx context.Context) ([]invites.Invitation, error) {
+	return a.invites.Received(ctx)
+}
+
+// RevokeInvite revokes a pending invitation
+func (a *API) RevokeInvite(ctx context.Context, id int64) error {
+	return a.invites.Revoke(ctx, id)
+}
+
+// Invitation returns the details of the invitation the token was issued for
+func (a *API) Invitation(ctx context.Context, token string) (*invites.Invitation, error) {
+	return a.invites.Details(ctx, token)
+}
+
+// AcceptInvite accepts the invitation, creating an account for the invitee if required
+func (a *API) AcceptInvite(ctx context.Context, token, name, password string) (*users.User, error) {
+	return a.invites.Accept(ctx, token, name, password)
+}
+
+// DeclineInvite declines the invitation
+func (a *API) DeclineInvite(ctx context.Context, token string) error {
+	return a.invites.Decline(ctx, token)
+}
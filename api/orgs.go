@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+
+	"github.com/bnkamalesh/padlock/pkg/orgs"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// CreateOrg creates a new organisation owned by the authenticated user
+func (a *API) CreateOrg(ctx context.Context, org orgs.Organisation) (*orgs.Organisation, error) {
+	u := users.FromContext(ctx)
+	if u == nil {
+		return nil, rbac.ErrUnauthenticated
+	}
+	return a.orgs.Create(ctx, org, u.ID)
+}
+
+// Orgs lists the organisations the authenticated user is a member of
+func (a *API) Orgs(ctx context.Context) ([]orgs.Organisation, error) {
+	return a.orgs.List(ctx)
+}
+
+// Org returns the details of the organisation
+func (a *API) Org(ctx context.Context, id int64) (*orgs.Organisation, error) {
+	return a.orgs.Read(ctx, id)
+}
+
+// UpdateOrg updates the details of the organisation
+func (a *API) UpdateOrg(ctx context.Context, org orgs.Organisation) (*orgs.Organisation, error) {
+	return a.orgs.Update(ctx, org)
+}
+
+// DeleteOrg deletes the organisation, along with its teams & applications
+func (a *API) DeleteOrg(ctx context.Context, id int64) error {
+	return a.orgs.Delete(ctx, id)
+}
+
+// OrgMembers lists the members of the organisation
+func (a *API) OrgMembers(ctx context.Context, orgID int64) ([]orgs.Member, error) {
+	return a.orgs.Members(ctx, orgID)
+}
+
+// SetOrgMember adds a member to the organisation, or updates the role of an existing member
+func (a *API) SetOrgMember(ctx context.Context, m orgs.Member) (*orgs.Member, error) {
+	return a.orgs.SetMember(ctx, m)
+}
+
+// RemoveOrgMember removes the member from the organisation
+func (a *API) RemoveOrgMember(ctx context.Context, orgID, userID int64) error {
+	return a.orgs.RemoveMember(ctx, orgID, userID)
+}
+
+// CreateTeam creates a team within the organisation
+func (a *API) CreateTeam(ctx context.Context, t orgs.Team) (*orgs.Team, error) {
+	return a.orgs.CreateTeam(ctx, t)
+}
+
+// Teams lists the teams of the organisation
+func (a *API) Teams(ctx context.Context, orgID int64) ([]orgs.Team, error) {
+	return a.orgs.Teams(ctx, orgID)
+}
+
+// DeleteTeam deletes the team
+func (a *API) DeleteTeam(ctx context.Context, orgID, teamID int64) error {
+	return a.orgs.DeleteTeam(ctx, orgID, teamID)
+}
+
+// TeamMembers lists the members of the team
+func (a *API) TeamMembers(ctx context.Context, orgID, teamID int64) ([]orgs.TeamMember, error) {
+	return a.orgs.TeamMembers(ctx, orgID, teamID)
+}
+
+// AddTeamMember adds a member of the organisation to the team
+func (a *API) AddTeamMember(ctx context.Context, orgID, teamID, userID int64) (*orgs.TeamMember, error) {
+	return a.orgs.AddTeamMember(ctx, orgID, teamID, userID)
+}
+
+// RemoveTeamMember removes the user from the team
+func (a *API) RemoveTeamMember(ctx context.Context, orgID, teamID, userID int64) error {
+	return a.orgs.RemoveTeamMember(ctx, orgID, teamID, userID)
+}
+
+// TeamApps lists the applications granted to the team
+func (a *API) TeamApps(ctx context.Context, orgID, teamID int64) ([]orgs.TeamApp, error) {
+	return a.orgs.TeamApps(ctx, orgID, teamID)
+}
+
+// GrantTeamApp grants the team a role on an application of the organisation
+func (a *API) GrantTeamApp(ctx context.Context, orgID int64, ta orgs.TeamApp) (*orgs.TeamApp, error) {
+	return a.orgs.GrantTeamApp(ctx, orgID, ta)
+}
+
+// RevokeTeamApp revokes the access of the team to the application
+func (a *API) RevokeTeamApp(ctx context.Context, orgID, teamID, appID int64) error {
+	return a.orgs.RevokeTeamApp(ctx, orgID, teamID, appID)
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"context"
+
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/subjects"
+)
+
+// EnrollSubject enrolls a user of the application for TOTP verification
+func (a *API) EnrollSubject(ctx context.Context, app *apps.App, externalID string) (*subjects.Enrollment, error) {
+	return a.subjects.Enroll(ctx, app, externalID)
+}
+
+// UnenrollSubject removes the TOTP enrollment of a user of the application
+func (a *API) UnenrollSubject(ctx context.Context, app *apps.App, externalID string) error {
+	return a.subjects.Unenroll(ctx, app, externalID)
+}
+
+// VerifySubject verifies the TOTP code, or a recovery code of a user of the application
+func (a *API) VerifySubject(ctx context.Context, app *apps.App, externalID, code string) (*subjects.Result, error) {
+	return a.subjects.Verify(ctx, app, externalID, code)
+}
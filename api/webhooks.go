@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+
+	"github.com/bnkamalesh/padlock/pkg/webhooks"
+)
+
+// CreateWebhook creates a webhook endpoint for the application, the signing secret is only
+// available in the response
+func (a *API) CreateWebhook(ctx context.Context, e webhooks.Endpoint) (*webhooks.Endpoint, error) {
+	return a.webhooks.Create(ctx, e)
+}
+
+// Webhooks lists the webhook endpoints of the application
+func (a *API) Webhooks(ctx context.Context, appID int64) ([]webhooks.Endpoint, error) {
+	return a.webhooks.List(ctx, appID)
+}
+
+// UpdateWebhook updates a webhook endpoint of the application
+func (a *API) UpdateWebhook(ctx context.Context, e webhooks.Endpoint) (*webhooks.Endpoint, error) {
+	return a.webhooks.Update(ctx, e)
+}
+
+// DeleteWebhook deletes a webhook endpoint of the application
+func (a *API) DeleteWebhook(ctx context.Context, appID, id int64) error {
+	return a.webhooks.Delete(ctx, appID, id)
+}
+
+// WebhookDeliveries lists the latest deliveries of a webhook endpoint
+func (a *API) WebhookDeliveries(ctx context.Context, appID, endpointID int64, limit int) ([]webhooks.Delivery, error) {
+	return a.webhooks.Deliveries(ctx, appID, endpointID, limit)
+}
+
+// WebhookDelivery returns a delivery along with the log of its attempts
+func (a *API) WebhookDelivery(ctx context.Context, appID, id int64) (*webhooks.Delivery, error) {
+	return a.webhooks.Delivery(ctx, appID, id)
+}
+
+// RedeliverWebhook queues a delivery to be delivered again
+func (a *API) RedeliverWebhook(ctx context.Context, appID, id int64) (*webhooks.Delivery, error) {
+	return a.webhooks.Redeliver(ctx, appID, id)
+}
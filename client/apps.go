@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	padlockhttp "github.com/bnkamalesh/padlock/api/http"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+)
+
+// CreateApp registers an application, owned by the authenticated user or the organisation
+func (c *Client) CreateApp(ctx context.Context, app apps.App) (*apps.App, error) {
+	created := &apps.App{}
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/apps", body: app, auth: authSession}, created)
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// ListApps lists the applications accessible by the authenticated user. It returns the total
+// number of applications matching the filter as well, irrespective of the pagination
+func (c *Client) ListApps(ctx context.Context, filter apps.ListFilter) ([]apps.App, int64, error) {
+	q := url.Values{}
+	if filter.Name != "" {
+		q.Set("name", filter.Name)
+	}
+	if filter.OrgID != 0 {
+		q.Set("orgId", strconv.FormatInt(filter.OrgID, 10))
+	}
+	if filter.Offset != 0 {
+		q.Set("offset", strconv.Itoa(filter.Offset))
+	}
+	if filter.Limit != 0 {
+		q.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	list := padlockhttp.AppsList{}
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/apps", query: q, auth: authSession}, &list)
+	if err != nil {
+		return nil, 0, err
+	}
+	return list.Apps, list.Total, nil
+}
+
+// App returns the application
+func (c *Client) App(ctx context.Context, id int64) (*apps.App, error) {
+	app := &apps.App{}
+	_, err := c.do(ctx, request{method: http.MethodGet, path: fmt.Sprintf("/apps/%d", id), auth: authSession}, app)
+	if err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// UpdateApp updates the non-empty fields of the application
+func (c *Client) UpdateApp(ctx context.Context, app apps.App) (*apps.App, error) {
+	updated := &apps.App{}
+	_, err := c.do(
+		ctx,
+		request{
+			method: http.MethodPatch,
+			path:   fmt.Sprintf("/apps/%d", app.ID),
+			body:   app,
+			auth:   authSession,
+		},
+		updated,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteApp deletes the application, along with its API keys, subjects & webhooks
+func (c *Client) DeleteApp(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: fmt.Sprintf("/apps/%d", id), auth: authSession}, nil)
+	return err
+}
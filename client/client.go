@@ -0,0 +1,318 @@
+// Package client is the Go client of the Padlock HTTP API. It supports both session (as
+// returned by Login) & API key authentication, and retries idempotent requests which failed
+// for temporary reasons
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerAuthorization = "Authorization"
+	headerAPIKey        = "X-API-Key"
+	headerRetryAfter    = "Retry-After"
+	headerRequestID     = "X-Request-ID"
+)
+
+var (
+	ErrInvalidURL = errors.New("Sorry, invalid base URL provided")
+	ErrNoSession  = errors.New("Sorry, the client has no session, please login")
+	ErrNoAPIKey   = errors.New("Sorry, the client has no API key configured")
+)
+
+// Config has all the configurations of the client
+type Config struct {
+	// BaseURL is the URL at which Padlock is served, e.g. https://padlock.example.com
+	BaseURL string
+	// APIKey is an API key of the application, required for the subject methods
+	APIKey string
+	// Session is the session token of the user, it's updated on Login
+	Session string
+	// HTTPClient is used for all the requests, http.DefaultClient is used if nil
+	HTTPClient *http.Client
+	// MaxRetries is the number of times an idempotent request is retried, if it failed with a
+	// network error or a temporary server error. A negative value disables retries
+	MaxRetries int
+	// Backoff is the wait before the first retry, it's doubled on every retry
+	Backoff time.Duration
+	// MaxBackoff is the maximum wait between retries
+	MaxBackoff time.Duration
+}
+
+// DefaultConfig is the default configuration of the client, except the BaseURL & credentials
+var DefaultConfig = Config{
+	MaxRetries: 3,
+	Backoff:    time.Millisecond * 200,
+	MaxBackoff: time.Second * 5,
+}
+
+// Client is the Padlock client, it's safe for concurrent use
+type Client struct {
+	baseURL    *url.URL
+	apiKey     string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	mu      sync.RWMutex
+	session string
+}
+
+// envelope is the response body of the API, 'data' is set on success & 'errors' on failure
+type envelope struct {
+	Data   json.RawMessage `json:"data"`
+	Errors json.RawMessage `json:"errors"`
+	Status int             `json:"status"`
+}
+
+// SetSession sets the session token used for the requests requiring a user
+func (c *Client) SetSession(token string) {
+	c.mu.Lock()
+	c.session = token
+	c.mu.Unlock()
+}
+
+// Session returns the session token of the client
+func (c *Client) Session() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.session
+}
+
+// idempotent returns true if the request can be safely retried
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		{
+			return true
+		}
+	}
+	return false
+}
+
+// temporary returns true if the response status is of a failure which may not recur
+func temporary(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		{
+			return true
+		}
+	}
+	return false
+}
+
+// wait returns the duration to wait before the retry. The backoff is exponential with jitter,
+// unless the server asked to retry after a specific duration
+func (c *Client) wait(retry int, resp *http.Response) time.Duration {
+	if resp != nil {
+		secs, err := strconv.Atoi(resp.Header.Get(headerRetryAfter))
+		if err == nil && secs >= 0 {
+			d := time.Duration(secs) * time.Second
+			if d > c.maxBackoff {
+				d = c.maxBackoff
+			}
+			return d
+		}
+	}
+
+	d := c.backoff << uint(retry)
+	if d <= 0 || d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	// half of the backoff is randomized, so that clients failing together do not retry together
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// auth is the authentication required by a request
+type auth int
+
+const (
+	authNone auth = iota
+	authSession
+	authAPIKey
+)
+
+// request is a request to the API
+type request struct {
+	method string
+	// path should be escaped, see segment
+	path  string
+	query url.Values
+	body  interface{}
+	auth  auth
+}
+
+// segment escapes a value to be used in the path
+func segment(v string) string {
+	return url.PathEscape(v)
+}
+
+// do sends the request, and decodes the data of the response into out (if not nil). It returns
+// the response, for the callers to read the headers
+func (c *Client) do(ctx context.Context, r request, out interface{}) (*http.Response, error) {
+	u := *c.baseURL
+	u.RawPath = strings.TrimSuffix(u.EscapedPath(), "/") + r.path
+	p, err := url.PathUnescape(u.RawPath)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = p
+	if len(r.query) > 0 {
+		u.RawQuery = r.query.Encode()
+	}
+
+	var payload []byte
+	if r.body != nil {
+		b, err := json.Marshal(r.body)
+		if err != nil {
+			return nil, err
+		}
+		payload = b
+	}
+
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	if payload != nil {
+		header.Set("Content-Type", "application/json")
+	}
+
+	switch r.auth {
+	case authSession:
+		{
+			session := c.Session()
+			if session == "" {
+				return nil, ErrNoSession
+			}
+			header.Set(headerAuthorization, session)
+		}
+	case authAPIKey:
+		{
+			if c.apiKey == "" {
+				return nil, ErrNoAPIKey
+			}
+			header.Set(headerAPIKey, c.apiKey)
+		}
+	}
+
+	retries := 0
+	if idempotent(r.method) {
+		retries = c.maxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+
+		req, err := http.NewRequest(r.method, u.String(), body)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header = header
+
+		resp, err := c.httpClient.Do(req)
+		if err == nil && (!temporary(resp.StatusCode) || attempt >= retries) {
+			return resp, decode(resp, out)
+		}
+
+		if err != nil && (ctx.Err() != nil || attempt >= retries) {
+			return nil, err
+		}
+
+		wait := c.wait(attempt, resp)
+		if resp != nil {
+			// the body is drained, so that the connection can be reused
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			{
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		case <-timer.C:
+		}
+	}
+}
+
+// decode decodes the response body, it returns *Error if the response is not successful
+func decode(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newError(resp.StatusCode, resp.Header, body)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent || len(body) == 0 {
+		return nil
+	}
+
+	env := envelope{}
+	err = json.Unmarshal(body, &env)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(env.Data, out)
+}
+
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultConfig.MaxRetries
+	} else if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultConfig.Backoff
+	}
+
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultConfig.MaxBackoff
+	}
+
+	return &Client{
+		baseURL:    u,
+		apiKey:     cfg.APIKey,
+		httpClient: cfg.HTTPClient,
+		maxRetries: cfg.MaxRetries,
+		backoff:    cfg.Backoff,
+		maxBackoff: cfg.MaxBackoff,
+		session:    cfg.Session,
+	}, nil
+}
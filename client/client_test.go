@@ -0,0 +1,428 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/api/apitest"
+	padlockhttp "github.com/bnkamalesh/padlock/api/http"
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/orgs"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/health"
+	"github.com/bnkamalesh/padlock/pkg/subjects"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// failure is a response sent instead of the API's, e.g. by a proxy in front of Padlock
+type failure struct {
+	status     int
+	retryAfter string
+}
+
+// testServer serves the HTTP API, it records the requests & responds with the failures queued
+// before passing the requests to the API
+type testServer struct {
+	*httptest.Server
+	fixture *apitest.Fixture
+	api     *padlockhttp.Server
+
+	mu       sync.Mutex
+	failures []failure
+	requests []*http.Request
+}
+
+func (ts *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ts.mu.Lock()
+	ts.requests = append(ts.requests, r)
+	var f *failure
+	if len(ts.failures) > 0 {
+		f = &ts.failures[0]
+		ts.failures = ts.failures[1:]
+	}
+	ts.mu.Unlock()
+
+	if f != nil {
+		if f.retryAfter != "" {
+			w.Header().Set(headerRetryAfter, f.retryAfter)
+		}
+		w.WriteHeader(f.status)
+		io.WriteString(w, "temporarily unavailable")
+		return
+	}
+
+	ts.api.ServeHTTP(w, r)
+}
+
+// fail queues n failures with the status
+func (ts *testServer) fail(n, status int, retryAfter string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for i := 0; i < n; i++ {
+		ts.failures = append(ts.failures, failure{status: status, retryAfter: retryAfter})
+	}
+}
+
+// received returns the requests received so far, & clears them
+func (ts *testServer) received() []*http.Request {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	list := ts.requests
+	ts.requests = nil
+	return list
+}
+
+func (ts *testServer) client(t *testing.T, cfg Config) *Client {
+	t.Helper()
+
+	cfg.BaseURL = ts.URL
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// login returns a client with the session of a platform admin
+func (ts *testServer) login(t *testing.T, cfg Config) *Client {
+	t.Helper()
+
+	u := ts.fixture.Admin(t, users.User{Name: "Jane", Email: "jane@example.com"})
+	c := ts.client(t, cfg)
+	_, err := c.Login(context.Background(), u.Email, apitest.Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.received()
+	return c
+}
+
+// app registers an application, & returns it along with an API key of it
+func (ts *testServer) app(t *testing.T, c *Client) (*apps.App, string) {
+	t.Helper()
+
+	ctx := context.Background()
+	org := &orgs.Organisation{}
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/orgs", body: orgs.Organisation{Name: "acme"}, auth: authSession}, org)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app, err := c.CreateApp(ctx, apps.App{Name: "billing", OrgID: org.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := &apikeys.APIKey{}
+	_, err = c.do(
+		ctx,
+		request{
+			method: http.MethodPost,
+			path:   fmt.Sprintf("/apps/%d/keys", app.ID),
+			body:   apikeys.APIKey{Name: "backend", Scopes: []apikeys.Scope{apikeys.ScopeEnroll, apikeys.ScopeVerify}},
+			auth:   authSession,
+		},
+		key,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts.received()
+	return app, key.Key
+}
+
+func testClient(t *testing.T, fn func(t *testing.T, ts *testServer)) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		f := apitest.New(t, driver, db)
+		s, err := padlockhttp.NewServer(
+			"127.0.0.1",
+			"0",
+			"padlock.test",
+			f.API,
+			f.AppCtx,
+			health.New(health.DefaultConfig, f.AppCtx.Logger),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ts := &testServer{fixture: f, api: s}
+		ts.Server = httptest.NewServer(ts)
+		t.Cleanup(ts.Close)
+		fn(t, ts)
+	})
+}
+
+func TestSession(t *testing.T) {
+	testClient(t, func(t *testing.T, ts *testServer) {
+		ctx := context.Background()
+		u := ts.fixture.User(t, users.User{Name: "Jane", Email: "jane@example.com"})
+		c := ts.client(t, Config{})
+
+		_, err := c.Me(ctx)
+		if err != ErrNoSession {
+			t.Fatalf("expected ErrNoSession, got %v", err)
+		}
+		if len(ts.received()) != 0 {
+			t.Fatal("expected no request without a session")
+		}
+
+		_, err = c.Login(ctx, u.Email, "wrong")
+		if !IsUnauthenticated(err) || c.Session() != "" {
+			t.Fatalf("expected the login to fail, got %v & session %q", err, c.Session())
+		}
+
+		_, err = c.Login(ctx, u.Email, apitest.Password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Session() == "" {
+			t.Fatal("expected the session to be retained")
+		}
+
+		me, err := c.Me(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if me.ID != u.ID {
+			t.Fatalf("expected %d, got %+v", u.ID, me)
+		}
+
+		reqs := ts.received()
+		last := reqs[len(reqs)-1]
+		if last.Header.Get(headerAuthorization) != c.Session() || last.Header.Get(headerAPIKey) != "" {
+			t.Fatalf("expected only the session to be sent, got %v", last.Header)
+		}
+
+		// the session can be provided in the config, e.g. by another instance
+		other := ts.client(t, Config{Session: c.Session()})
+		_, err = other.Me(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		other.SetSession("invalid")
+		_, err = other.Me(ctx)
+		if !IsUnauthenticated(err) {
+			t.Fatalf("expected the session to be invalid, got %v", err)
+		}
+	})
+}
+
+func TestAPIKey(t *testing.T) {
+	testClient(t, func(t *testing.T, ts *testServer) {
+		ctx := context.Background()
+		app, key := ts.app(t, ts.login(t, Config{}))
+
+		_, err := ts.client(t, Config{}).EnrollSubject(ctx, app.ID, "jane")
+		if err != ErrNoAPIKey {
+			t.Fatalf("expected ErrNoAPIKey, got %v", err)
+		}
+
+		c := ts.client(t, Config{APIKey: key})
+		enrollment, err := c.EnrollSubject(ctx, app.ID, "jane doe/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reqs := ts.received()
+		if len(reqs) != 1 || reqs[0].URL.EscapedPath() != fmt.Sprintf("/apps/%d/subjects/jane%%20doe%%2F1/enroll", app.ID) {
+			t.Fatalf("expected the subject ID to be escaped, got %v", reqs)
+		}
+		if reqs[0].Header.Get(headerAPIKey) != key || reqs[0].Header.Get(headerAuthorization) != "" {
+			t.Fatalf("expected only the API key to be sent, got %v", reqs[0].Header)
+		}
+
+		result, err := c.VerifySubject(ctx, app.ID, "jane doe/1", enrollment.RecoveryCodes[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != subjects.StatusValid {
+			t.Fatalf("expected the recovery code to be valid, got %+v", result)
+		}
+
+		err = c.UnenrollSubject(ctx, app.ID, "jane doe/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = ts.client(t, Config{APIKey: key + "x"}).EnrollSubject(ctx, app.ID, "jane")
+		if !IsUnauthenticated(err) {
+			t.Fatalf("expected the API key to be invalid, got %v", err)
+		}
+	})
+}
+
+func TestErrors(t *testing.T) {
+	testClient(t, func(t *testing.T, ts *testServer) {
+		ctx := context.Background()
+		c := ts.login(t, Config{MaxRetries: -1})
+
+		_, err := c.App(ctx, 404)
+		e, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("expected *Error, got %v", err)
+		}
+		if e.StatusCode != http.StatusNotFound || e.Code != apperr.CodeNotFound || e.Message != apps.ErrNotFound.Error() {
+			t.Fatalf("expected the error of the envelope, got %+v", e)
+		}
+		if e.RequestID == "" || !IsNotFound(err) || StatusCode(err) != http.StatusNotFound {
+			t.Fatalf("expected the request ID & the helpers to work, got %+v", e)
+		}
+
+		_, err = c.CreateApp(ctx, apps.App{})
+		if !IsInvalidInput(err) {
+			t.Fatalf("expected invalid input, got %v", err)
+		}
+
+		// responses which are not from Padlock are mapped based on the status
+		ts.fail(1, http.StatusBadGateway, "")
+		_, err = c.Me(ctx)
+		e, ok = err.(*Error)
+		if !ok || e.Code != apperr.CodeUpstream || e.Message != "temporarily unavailable" || e.RequestID != "" {
+			t.Fatalf("expected an upstream error, got %+v", err)
+		}
+
+		ts.fail(1, http.StatusServiceUnavailable, "")
+		_, err = c.Me(ctx)
+		if CodeOf(err) != apperr.CodeInternal || StatusCode(err) != http.StatusServiceUnavailable {
+			t.Fatalf("expected an internal error, got %v", err)
+		}
+
+		ts.Close()
+		_, err = c.Me(ctx)
+		if err == nil || CodeOf(err) != "" || StatusCode(err) != 0 {
+			t.Fatalf("expected a network error, got %v", err)
+		}
+	})
+}
+
+func TestRetry(t *testing.T) {
+	testClient(t, func(t *testing.T, ts *testServer) {
+		ctx := context.Background()
+		c := ts.login(t, Config{MaxRetries: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond * 10})
+		app, _ := ts.app(t, c)
+
+		ts.fail(2, http.StatusServiceUnavailable, "")
+		_, err := c.Me(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(ts.received()); n != 3 {
+			t.Fatalf("expected the GET to be retried twice, got %d requests", n)
+		}
+
+		ts.fail(1, http.StatusTooManyRequests, "")
+		err = c.DeleteApp(ctx, app.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(ts.received()); n != 2 {
+			t.Fatalf("expected the DELETE to be retried, got %d requests", n)
+		}
+
+		ts.fail(1, http.StatusServiceUnavailable, "")
+		_, err = c.CreateApp(ctx, apps.App{Name: "billing"})
+		if StatusCode(err) != http.StatusServiceUnavailable {
+			t.Fatalf("expected the POST to fail, got %v", err)
+		}
+		if n := len(ts.received()); n != 1 {
+			t.Fatalf("expected the POST not to be retried, got %d requests", n)
+		}
+
+		// errors which would recur are not retried
+		_, err = c.App(ctx, app.ID)
+		if !IsNotFound(err) {
+			t.Fatalf("expected the app to be deleted, got %v", err)
+		}
+		if n := len(ts.received()); n != 1 {
+			t.Fatalf("expected the 404 not to be retried, got %d requests", n)
+		}
+
+		ts.fail(3, http.StatusBadGateway, "")
+		_, err = c.Me(ctx)
+		if StatusCode(err) != http.StatusBadGateway {
+			t.Fatalf("expected the retries to be exhausted, got %v", err)
+		}
+		if n := len(ts.received()); n != 3 {
+			t.Fatalf("expected 3 attempts, got %d requests", n)
+		}
+
+		ts.fail(1, http.StatusServiceUnavailable, "")
+		noRetry := ts.client(t, Config{MaxRetries: -1, Session: c.Session()})
+		_, err = noRetry.Me(ctx)
+		if StatusCode(err) != http.StatusServiceUnavailable {
+			t.Fatalf("expected no retries, got %v", err)
+		}
+	})
+}
+
+func TestRetryAfter(t *testing.T) {
+	c, err := New(Config{BaseURL: "http://padlock.test", Backoff: time.Millisecond * 100, MaxBackoff: time.Second * 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		retryAfter string
+		min        time.Duration
+		max        time.Duration
+	}{
+		{"2", time.Second * 2, time.Second * 2},
+		{"0", 0, 0},
+		{"60", time.Second * 5, time.Second * 5},
+		// dates are not supported, the backoff is used instead
+		{"Fri, 31 Dec 1999 23:59:59 GMT", time.Millisecond * 50, time.Millisecond * 100},
+		{"", time.Millisecond * 50, time.Millisecond * 100},
+	}
+
+	for _, tc := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set(headerRetryAfter, tc.retryAfter)
+		wait := c.wait(0, resp)
+		if wait < tc.min || wait > tc.max {
+			t.Errorf("Retry-After %q: expected a wait within %s & %s, got %s", tc.retryAfter, tc.min, tc.max, wait)
+		}
+	}
+
+	// the backoff doubles on every retry
+	wait := c.wait(2, nil)
+	if wait < time.Millisecond*200 || wait > time.Millisecond*400 {
+		t.Errorf("expected a wait within 200ms & 400ms, got %s", wait)
+	}
+
+	testClient(t, func(t *testing.T, ts *testServer) {
+		c := ts.login(t, Config{Backoff: time.Millisecond, MaxBackoff: time.Second * 5})
+
+		ts.fail(1, http.StatusTooManyRequests, "1")
+		start := time.Now()
+		_, err := c.Me(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed < time.Second {
+			t.Fatalf("expected the retry after a second, got %s", elapsed)
+		}
+
+		// the wait is cut short by the context
+		ts.fail(1, http.StatusServiceUnavailable, "5")
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		_, err = c.Me(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the context deadline, got %v", err)
+		}
+	})
+}
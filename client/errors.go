@@ -0,0 +1,128 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	padlockhttp "github.com/bnkamalesh/padlock/api/http"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+)
+
+// Error is the error responded by the API
+type Error struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+	// Code is the machine readable code of the error, it should be used to handle the errors
+	Code    apperr.Code
+	Message string
+	// RequestID is the ID of the request, which can be used to trace the request in the logs
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(status int, header http.Header, body []byte) *Error {
+	e := &Error{
+		StatusCode: status,
+		RequestID:  header.Get(headerRequestID),
+	}
+
+	env := envelope{}
+	err := json.Unmarshal(body, &env)
+	if err == nil && len(env.Errors) > 0 {
+		resp := padlockhttp.ErrorResponse{}
+		if json.Unmarshal(env.Errors, &resp) == nil {
+			e.Code = resp.Code
+			e.Message = resp.Message
+			if resp.RequestID != "" {
+				e.RequestID = resp.RequestID
+			}
+		}
+	}
+
+	if e.Message == "" {
+		// the response is not from Padlock, e.g. from a proxy
+		e.Message = strings.TrimSpace(string(body))
+		if e.Message == "" {
+			e.Message = http.StatusText(status)
+		}
+	}
+
+	if e.Code == "" {
+		e.Code = codeOfStatus(status)
+	}
+
+	return e
+}
+
+// codeOfStatus returns the error code for the HTTP status, for the responses without a code
+func codeOfStatus(status int) apperr.Code {
+	for _, code := range []apperr.Code{
+		apperr.CodeInvalidInput,
+		apperr.CodeUnauthenticated,
+		apperr.CodePermissionDenied,
+		apperr.CodeNotFound,
+		apperr.CodeConflict,
+		apperr.CodeExpired,
+		apperr.CodeRateLimited,
+		apperr.CodeUpstream,
+	} {
+		if code.HTTPStatus() == status {
+			return code
+		}
+	}
+	return apperr.CodeInternal
+}
+
+// CodeOf returns the code of the error, if it was responded by the API. It returns an empty
+// code otherwise, e.g. for network errors
+func CodeOf(err error) apperr.Code {
+	e, ok := err.(*Error)
+	if !ok {
+		return ""
+	}
+	return e.Code
+}
+
+// StatusCode returns the HTTP status of the error, if it was responded by the API. It returns 0
+// otherwise
+func StatusCode(err error) int {
+	e, ok := err.(*Error)
+	if !ok {
+		return 0
+	}
+	return e.StatusCode
+}
+
+// IsInvalidInput returns true if the request was invalid, e.g. a required field was empty
+func IsInvalidInput(err error) bool {
+	return CodeOf(err) == apperr.CodeInvalidInput
+}
+
+// IsUnauthenticated returns true if the session/API key was missing, invalid or expired
+func IsUnauthenticated(err error) bool {
+	return CodeOf(err) == apperr.CodeUnauthenticated
+}
+
+// IsPermissionDenied returns true if the user/API key is not allowed to do the action
+func IsPermissionDenied(err error) bool {
+	return CodeOf(err) == apperr.CodePermissionDenied
+}
+
+// IsNotFound returns true if the resource does not exist
+func IsNotFound(err error) bool {
+	return CodeOf(err) == apperr.CodeNotFound
+}
+
+// IsConflict returns true if the resource already exists, or was updated concurrently
+func IsConflict(err error) bool {
+	return CodeOf(err) == apperr.CodeConflict
+}
+
+// IsRateLimited returns true if there were too many requests/attempts
+func IsRateLimited(err error) bool {
+	return CodeOf(err) == apperr.CodeRateLimited
+}
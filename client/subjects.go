@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	padlockhttp "github.com/bnkamalesh/padlock/api/http"
+	"github.com/bnkamalesh/padlock/pkg/subjects"
+)
+
+// The subject methods are authenticated with the API key, and the application ID should be of
+// the API key
+
+func subjectPath(appID int64, subjectID, action string) string {
+	return fmt.Sprintf("/apps/%d/subjects/%s/%s", appID, segment(subjectID), action)
+}
+
+// EnrollSubject enrolls a user of the application for TOTP verification. The enrollment is
+// confirmed on the first successful verification, till then the subject can be enrolled again
+func (c *Client) EnrollSubject(ctx context.Context, appID int64, subjectID string) (*subjects.Enrollment, error) {
+	e := &subjects.Enrollment{}
+	_, err := c.do(
+		ctx,
+		request{method: http.MethodPost, path: subjectPath(appID, subjectID, "enroll"), auth: authAPIKey},
+		e,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// UnenrollSubject removes the TOTP enrollment of the subject
+func (c *Client) UnenrollSubject(ctx context.Context, appID int64, subjectID string) error {
+	_, err := c.do(
+		ctx,
+		request{method: http.MethodPost, path: subjectPath(appID, subjectID, "unenroll"), auth: authAPIKey},
+		nil,
+	)
+	return err
+}
+
+// VerifySubject verifies the TOTP code, or one of the recovery codes of the subject. An invalid
+// code is not an error, the result's status should be checked
+func (c *Client) VerifySubject(ctx context.Context, appID int64, subjectID, code string) (*subjects.Result, error) {
+	result := &subjects.Result{}
+	_, err := c.do(
+		ctx,
+		request{
+			method: http.MethodPost,
+			path:   subjectPath(appID, subjectID, "verify"),
+			body:   padlockhttp.CodeRequest{Code: code},
+			auth:   authAPIKey,
+		},
+		result,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	padlockhttp "github.com/bnkamalesh/padlock/api/http"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// Login signs in with email & password. The session token is retained by the client, and is
+// used for the following requests
+func (c *Client) Login(ctx context.Context, email, password string) (*users.User, error) {
+	u := &users.User{}
+	resp, err := c.do(
+		ctx,
+		request{
+			method: http.MethodPost,
+			path:   "/login",
+			body:   padlockhttp.LoginRequest{Email: email, Password: password},
+		},
+		u,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetSession(resp.Header.Get(headerAuthorization))
+	return u, nil
+}
+
+// Me validates the session of the client, and returns the user it belongs to
+func (c *Client) Me(ctx context.Context) (*users.User, error) {
+	u := &users.User{}
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/me", auth: authSession}, u)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/users"
+	"github.com/bnkamalesh/padlock/pkg/webhooks"
+)
+
+const timeFormat = time.RFC3339
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(timeFormat)
+}
+
+func init() {
+	register(
+		command{
+			name:    "user create",
+			summary: "create a user, the password is read from stdin if -password is not set",
+			setup:   userCreate,
+		},
+		command{
+			name:    "user list",
+			summary: "list the users",
+			setup:   userList,
+		},
+		command{
+			name:    "user disable",
+			summary: "disable (soft delete) a user & revoke all their sessions",
+			setup:   userDisable,
+		},
+		command{
+			name:    "user purge",
+			summary: "permanently delete the users disabled (soft deleted) before the grace period",
+			setup:   userPurge,
+		},
+		command{
+			name:    "user reset-mfa",
+			summary: "remove the WebAuthn credentials & push devices of a user, and revoke all their sessions",
+			setup:   userResetMFA,
+		},
+		command{
+			name:    "app create",
+			summary: "register an application for an organisation",
+			setup:   appCreate,
+		},
+		command{
+			name:    "app list",
+			summary: "list the applications",
+			setup:   appList,
+		},
+		command{
+			name:    "app rotate-secret",
+			summary: "rotate the webhook signing secret of an application's endpoint(s)",
+			setup:   appRotateSecret,
+		},
+		command{
+			name:    "apikey create",
+			summary: "create an API key for an application, the key is shown only once",
+			setup:   apiKeyCreate,
+		},
+		command{
+			name:    "apikey revoke",
+			summary: "revoke an API key of an application",
+			setup:   apiKeyRevoke,
+		},
+		command{
+			name:    "session revoke",
+			summary: "revoke all the sessions of a user, signing them out everywhere",
+			setup:   sessionRevoke,
+		},
+	)
+}
+
+type userRows []users.User
+
+func (ur userRows) header() []string {
+	return []string{"ID", "EMAIL", "NAME", "PHONE", "CREATED"}
+}
+
+func (ur userRows) rows() [][]string {
+	rows := make([][]string, 0, len(ur))
+	for _, u := range ur {
+		rows = append(rows, []string{fmt.Sprint(u.ID), u.Email, u.Name, u.Phone, formatTime(u.CreatedAt)})
+	}
+	return rows
+}
+
+type appRows []apps.App
+
+func (ar appRows) header() []string {
+	return []string{"ID", "NAME", "ORG", "DESCRIPTION", "CREATED"}
+}
+
+func (ar appRows) rows() [][]string {
+	rows := make([][]string, 0, len(ar))
+	for _, a := range ar {
+		rows = append(rows, []string{fmt.Sprint(a.ID), a.Name, fmt.Sprint(a.OrgID), a.Description, formatTime(a.CreatedAt)})
+	}
+	return rows
+}
+
+type apiKeyRows []apikeys.APIKey
+
+func (kr apiKeyRows) header() []string {
+	return []string{"ID", "APP", "NAME", "SCOPES", "KEY", "EXPIRES"}
+}
+
+func (kr apiKeyRows) rows() [][]string {
+	rows := make([][]string, 0, len(kr))
+	for _, k := range kr {
+		scopes := make([]string, 0, len(k.Scopes))
+		for _, s := range k.Scopes {
+			scopes = append(scopes, string(s))
+		}
+		key := k.Key
+		if key == "" {
+			key = k.Prefix + "..."
+		}
+		rows = append(rows, []string{fmt.Sprint(k.ID), fmt.Sprint(k.AppID), k.Name, strings.Join(scopes, ","), key, formatTime(k.ExpiresAt)})
+	}
+	return rows
+}
+
+type endpointRows []webhooks.Endpoint
+
+func (er endpointRows) header() []string {
+	return []string{"ID", "APP", "URL", "SECRET"}
+}
+
+func (er endpointRows) rows() [][]string {
+	rows := make([][]string, 0, len(er))
+	for _, e := range er {
+		rows = append(rows, []string{fmt.Sprint(e.ID), fmt.Sprint(e.AppID), e.URL, e.Secret})
+	}
+	return rows
+}
+
+// userFlags registers the flags identifying a user, by ID or email
+func userFlags(fs *flag.FlagSet) func(ctx context.Context, s *services) (*users.User, error) {
+	id := fs.Int64("id", 0, "ID of the user")
+	email := fs.String("email", "", "email of the user, if -id is not set")
+
+	return func(ctx context.Context, s *services) (*users.User, error) {
+		if *id > 0 {
+			return s.users.Read(ctx, *id)
+		}
+		if *email != "" {
+			return s.users.ReadByEmail(ctx, *email)
+		}
+		return nil, errors.New("either -id or -email is required")
+	}
+}
+
+func userCreate(fs *flag.FlagSet) run {
+	email := fs.String("email", "", "email of the user")
+	name := fs.String("name", "", "name of the user")
+	phone := fs.String("phone", "", "phone number of the user, with the country code")
+	password := fs.String("password", "", "password of the user, it's read from stdin if not set (preferred, since flags are visible in the process list)")
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		pwd := *password
+		if pwd == "" {
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return nil, fmt.Errorf("reading the password from stdin: %v", err)
+			}
+			pwd = strings.TrimRight(line, "\r\n")
+		}
+		if pwd == "" {
+			return nil, errors.New("the password is required")
+		}
+
+		u, err := s.users.Create(
+			ctx,
+			users.User{
+				Email: *email,
+				Name:  *name,
+				Phone: *phone,
+			},
+			pwd,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return userRows{*u}, nil
+	}
+}
+
+func userList(fs *flag.FlagSet) run {
+	filter := users.ListFilter{}
+	fs.StringVar(&filter.Email, "email", "", "list users whose email contains the value")
+	fs.StringVar(&filter.Name, "name", "", "list users whose name contains the value")
+	fs.IntVar(&filter.Offset, "offset", 0, "number of users to skip")
+	fs.IntVar(&filter.Limit, "limit", 20, "maximum number of users to list")
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		list, _, err := s.users.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		return userRows(list), nil
+	}
+}
+
+func userDisable(fs *flag.FlagSet) run {
+	user := userFlags(fs)
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		u, err := user(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.users.Delete(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		// the sessions are cached, so they'd be valid till they expire unless revoked
+		err = s.users.RevokeSessions(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		return outcome{Action: "disabled", ID: u.ID}, nil
+	}
+}
+
+func userPurge(fs *flag.FlagSet) run {
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		count, err := s.users.Purge(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return outcome{Action: "purged", Count: int(count)}, nil
+	}
+}
+
+func userResetMFA(fs *flag.FlagSet) run {
+	user := userFlags(fs)
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		u, err := user(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		count := 0
+
+		creds, err := s.webauthn.Credentials(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range creds {
+			err = s.webauthn.DeleteCredential(ctx, u, c.ID)
+			if err != nil {
+				return nil, err
+			}
+			count++
+		}
+
+		devices, err := s.push.Devices(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range devices {
+			err = s.push.RemoveDevice(ctx, u, d.ID)
+			if err != nil {
+				return nil, err
+			}
+			count++
+		}
+
+		err = s.users.RevokeSessions(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		return outcome{Action: "mfa-reset", ID: u.ID, Count: count}, nil
+	}
+}
+
+func appCreate(fs *flag.FlagSet) run {
+	app := apps.App{}
+	fs.StringVar(&app.Name, "name", "", "name of the application")
+	fs.StringVar(&app.Description, "description", "", "description of the application")
+	fs.Int64Var(&app.OrgID, "org", 0, "ID of the organisation which owns the application")
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		created, err := s.apps.Create(ctx, app)
+		if err != nil {
+			return nil, err
+		}
+		return appRows{*created}, nil
+	}
+}
+
+func appList(fs *flag.FlagSet) run {
+	filter := apps.ListFilter{}
+	fs.Int64Var(&filter.OrgID, "org", 0, "list the applications of the organisation")
+	fs.StringVar(&filter.Name, "name", "", "list applications whose name contains the value")
+	fs.IntVar(&filter.Offset, "offset", 0, "number of applications to skip")
+	fs.IntVar(&filter.Limit, "limit", 20, "maximum number of applications to list")
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		list, _, err := s.apps.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		return appRows(list), nil
+	}
+}
+
+func appRotateSecret(fs *flag.FlagSet) run {
+	appID := fs.Int64("app", 0, "ID of the application")
+	endpointID := fs.Int64("endpoint", 0, "ID of the webhook endpoint, all the endpoints of the application are rotated if not set")
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		ids := []int64{*endpointID}
+		if *endpointID == 0 {
+			list, err := s.webhooks.List(ctx, *appID)
+			if err != nil {
+				return nil, err
+			}
+			if len(list) == 0 {
+				return nil, errors.New("the application has no webhook endpoints")
+			}
+
+			ids = make([]int64, 0, len(list))
+			for _, e := range list {
+				ids = append(ids, e.ID)
+			}
+		}
+
+		rotated := make(endpointRows, 0, len(ids))
+		for _, id := range ids {
+			e, err := s.webhooks.RotateSecret(ctx, *appID, id)
+			if err != nil {
+				return nil, err
+			}
+			rotated = append(rotated, *e)
+		}
+		return rotated, nil
+	}
+}
+
+func apiKeyCreate(fs *flag.FlagSet) run {
+	key := apikeys.APIKey{}
+	fs.Int64Var(&key.AppID, "app", 0, "ID of the application")
+	fs.StringVar(&key.Name, "name", "", "name of the key, to identify it")
+	scopes := fs.String("scopes", string(apikeys.ScopeVerify), "comma separated scopes of the key (enroll, verify, admin)")
+	validity := fs.Duration("validity", 0, "duration for which the key is valid, it does not expire if not set")
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		for _, scope := range strings.Split(*scopes, ",") {
+			key.Scopes = append(key.Scopes, apikeys.Scope(strings.TrimSpace(scope)))
+		}
+		if *validity > 0 {
+			expiry := time.Now().UTC().Add(*validity)
+			key.ExpiresAt = &expiry
+		}
+
+		created, err := s.apiKeys.Create(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return apiKeyRows{*created}, nil
+	}
+}
+
+func apiKeyRevoke(fs *flag.FlagSet) run {
+	appID := fs.Int64("app", 0, "ID of the application")
+	id := fs.Int64("id", 0, "ID of the key")
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		err := s.apiKeys.Revoke(ctx, *appID, *id)
+		if err != nil {
+			return nil, err
+		}
+		return outcome{Action: "revoked", ID: *id}, nil
+	}
+}
+
+func sessionRevoke(fs *flag.FlagSet) run {
+	user := userFlags(fs)
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		u, err := user(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.users.RevokeSessions(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		return outcome{Action: "sessions-revoked", ID: u.ID}, nil
+	}
+}
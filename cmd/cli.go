@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bnkamalesh/padlock/pkg/config"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// run runs an admin command, its result is printed in the chosen output format
+type run func(ctx context.Context, s *services) (interface{}, error)
+
+// command is an admin command, which acts on the services directly (i.e. with the system's
+// permissions), rather than through the APIs
+type command struct {
+	// name is the name of the command including its group, e.g. "user create"
+	name    string
+	summary string
+	// setup registers the flags of the command, and returns the function to run it
+	setup func(fs *flag.FlagSet) run
+	// dbOnly is set for the commands which only need the database, the services other than
+	// the database are not initialized for them
+	dbOnly bool
+}
+
+var commands = []command{}
+
+func register(cmds ...command) {
+	commands = append(commands, cmds...)
+}
+
+// findCommand returns the command matching the beginning of args, along with the rest of the args
+func findCommand(args []string) (*command, []string) {
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) < len(words) {
+			continue
+		}
+
+		matched := true
+		for j, w := range words {
+			matched = matched && args[j] == w
+		}
+		if matched {
+			return &commands[i], args[len(words):]
+		}
+	}
+	return nil, args
+}
+
+// tabular is implemented by the results which can be printed as a table
+type tabular interface {
+	header() []string
+	rows() [][]string
+}
+
+// texter is implemented by the results which are printed as plain text, rather than a table
+type texter interface {
+	text() string
+}
+
+// outcome is the result of the commands which do not return a resource, e.g. revoke
+type outcome struct {
+	Action string `json:"action"`
+	ID     int64  `json:"id"`
+	// Count is the number of resources affected, if more than one
+	Count int `json:"count,omitempty"`
+}
+
+func (o outcome) header() []string {
+	return []string{"ACTION", "ID", "COUNT"}
+}
+
+func (o outcome) rows() [][]string {
+	return [][]string{{o.Action, fmt.Sprint(o.ID), fmt.Sprint(o.Count)}}
+}
+
+func printResult(w io.Writer, format string, result interface{}) error {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	if t, ok := result.(texter); ok {
+		_, err := io.WriteString(w, t.text())
+		return err
+	}
+
+	t, ok := result.(tabular)
+	if !ok {
+		return fmt.Errorf("the result cannot be printed as a table, use -output json")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.header(), "\t"))
+	for _, row := range t.rows() {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// runCommand parses the flags & the configuration, runs the command & prints its result. It
+// returns the exit code
+func runCommand(cmd *command, args []string) int {
+	fs := flag.NewFlagSet("padlock "+cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: padlock %s [flags]\n\n%s\n\nFlags:\n", cmd.name, cmd.summary)
+		fs.PrintDefaults()
+	}
+	output := fs.String("output", outputTable, "output format, table or json")
+	r := cmd.setup(fs)
+	loader := config.NewLoader(fs)
+	_ = fs.Parse(args)
+
+	if *output != outputTable && *output != outputJSON {
+		fmt.Fprintf(os.Stderr, "invalid output format %q, it should be table or json\n", *output)
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments %v\n", fs.Args())
+		return 2
+	}
+
+	cfg, err := loader.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var s *services
+	if cmd.dbOnly {
+		s = &services{driver: database.Driver(cfg.Database.Driver)}
+		s.db, err = openDB(cfg)
+	} else {
+		// only the problems are logged, so that the output is not polluted
+		s, err = newServices(cfg, logger.New("warn", "error", "fatal"))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer s.close()
+
+	result, err := r(rbac.SystemContext(context.Background()), s)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	err = printResult(os.Stdout, *output, result)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, `Usage:
+  padlock [serve] [flags]              start the HTTP & gRPC servers
+  padlock config print [flags]         print the effective configuration, with the secrets redacted
+`)
+
+	names := make([]string, 0, len(commands))
+	summaries := make(map[string]string, len(commands))
+	for _, c := range commands {
+		names = append(names, c.name)
+		summaries[c.name] = c.summary
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  padlock %-28s %s\n", name+" [flags]", summaries[name])
+	}
+
+	fmt.Fprint(w, `
+Run 'padlock <command> -h' for the flags of the command. The configuration flags are accepted by
+all the commands, and can be set using the environment variables shown alongside, or in the
+configuration file. The admin commands print a table, or JSON with -output json
+`)
+}
@@ -1,120 +1,81 @@
 package main
 
 import (
-	"context"
+	"flag"
 	"fmt"
-	"log"
-	"time"
+	"os"
+	"strings"
 
-	"github.com/bnkamalesh/padlock/api"
-	"github.com/bnkamalesh/padlock/api/http"
-	"github.com/bnkamalesh/padlock/pkg/appcontext"
-	"github.com/bnkamalesh/padlock/pkg/apps"
-	"github.com/bnkamalesh/padlock/pkg/platform/cache"
-	"github.com/bnkamalesh/padlock/pkg/platform/logger"
-	"github.com/bnkamalesh/padlock/pkg/totp"
-	"github.com/bnkamalesh/padlock/pkg/users"
+	"github.com/bnkamalesh/padlock/pkg/config"
 )
 
 func main() {
-	l := logger.New("*")
-
-	cacheHandler, err := cache.New(cache.Config{
-		Hosts:        []string{""},
-		DialTimeout:  time.Second * 3,
-		ReadTimeout:  time.Millisecond * 100,
-		WriteTimeout: time.Second * 1,
-	})
-
-	if err != nil {
-		l.Fatal(err)
-		return
-	}
-
-	pgdb, err := postgresHandler(postgreConfig{
-		Host:     "127.0.0.1",
-		Port:     "5432",
-		Username: "padlockpostgres",
-		Password: "z1A3hell0WorLd553",
-		DBName:   "padlock",
-	})
-	if err != nil {
-		l.Fatal(err)
-		return
-	}
-
-	appCtx := appcontext.New(l)
-	appCtx.Logging = true
-	if appCtx.Logging {
-		if !appCtx.Debug {
-			l = logger.New("info", "warn", "error", "fatal")
-		} else {
-			l = logger.New("*")
+	args := os.Args[1:]
+	switch {
+	case len(args) == 0 || strings.HasPrefix(args[0], "-"):
+		{
+			os.Exit(serveCommand(args))
+		}
+	case args[0] == "serve":
+		{
+			os.Exit(serveCommand(args[1:]))
+		}
+	case args[0] == "config" && len(args) > 1 && args[1] == "print":
+		{
+			os.Exit(configPrint(args[2:]))
+		}
+	case args[0] == "help":
+		{
+			printUsage(os.Stdout)
+			return
 		}
-		appCtx.Logger = l
 	}
 
-	appsHandler := apps.New(appCtx, pgdb)
-	usersHandler := users.New(appCtx, pgdb, cacheHandler)
-
-	test(l, appsHandler, usersHandler)
-
-	api := api.New(appCtx, appsHandler, usersHandler)
-
-	httpServer, err := http.NewServer(
-		"",
-		"8080",
-		"localhost",
-		api,
-		appCtx,
-	)
-	if err != nil {
-		log.Fatal(err)
-		return
+	cmd, rest := findCommand(args)
+	if cmd == nil {
+		printUsage(os.Stderr)
+		os.Exit(2)
 	}
+	os.Exit(runCommand(cmd, rest))
+}
 
-	err = httpServer.Start()
-	if err != nil {
-		log.Fatal(err)
+// loadConfig parses the flags of the configuration & loads it
+func loadConfig(name string, args []string) (*config.Config, error) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		printUsage(fs.Output())
+		fmt.Fprintln(fs.Output(), "\nFlags:")
+		fs.PrintDefaults()
 	}
+	loader := config.NewLoader(fs)
+	_ = fs.Parse(args)
+	return loader.Load()
 }
 
-func test(l logger.Logger, aH *apps.Apps, uH *users.Users) {
-	ctx := context.Background()
-	u, err := uH.Create(
-		ctx,
-		users.User{
-			Email: "bnkamalesh@gmail.com",
-			Phone: "+91-8792485305",
-			Name:  "KBN",
-		},
-		"123456",
-	)
+func serveCommand(args []string) int {
+	cfg, err := loadConfig("padlock serve", args)
 	if err != nil {
-		l.Error(err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
+	return serve(cfg)
+}
 
-	a, err := aH.CreateAndSetOwner(
-		ctx,
-		apps.App{
-			Name:        "KBN-App",
-			Description: "",
-			TOTP:        totp.New("KBN-App", 6, 30, totp.AlgoSHA1),
-		},
-		*u,
-	)
-
-	if err != nil {
-		l.Error(err)
-		return
+func configPrint(args []string) int {
+	cfg, err := loadConfig("padlock config print", args)
+	if cfg == nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
-	fmt.Println("Created app!", a.Name)
 
-	u, _, err = uH.Login(ctx, "localhost", "bnkamalesh@gmail.com", "123456")
+	perr := config.Print(os.Stdout, cfg)
+	if perr != nil {
+		fmt.Fprintln(os.Stderr, perr)
+		return 1
+	}
 	if err != nil {
-		l.Error(err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
-	fmt.Println("Logged in!")
+	return 0
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/bnkamalesh/padlock/pkg/migrations"
+)
+
+func init() {
+	register(
+		command{
+			name:    "migrate up",
+			summary: "apply the pending database migrations",
+			setup:   migrateUp,
+			dbOnly:  true,
+		},
+		command{
+			name:    "migrate down",
+			summary: "roll back the last applied database migration(s)",
+			setup:   migrateDown,
+			dbOnly:  true,
+		},
+		command{
+			name:    "migrate status",
+			summary: "list the database migrations, and whether they're applied",
+			setup:   migrateStatus,
+			dbOnly:  true,
+		},
+	)
+}
+
+type statusRows []migrations.Status
+
+func (sr statusRows) header() []string {
+	return []string{"VERSION", "NAME", "APPLIED"}
+}
+
+func (sr statusRows) rows() [][]string {
+	rows := make([][]string, 0, len(sr))
+	for _, s := range sr {
+		applied := "pending"
+		if s.Applied {
+			applied = formatTime(s.AppliedAt)
+		}
+		rows = append(rows, []string{fmt.Sprintf("%04d", s.Version), s.Name, applied})
+	}
+	return rows
+}
+
+type stepRows []migrations.Step
+
+func (sr stepRows) header() []string {
+	return []string{"VERSION", "NAME", "DIRECTION"}
+}
+
+func (sr stepRows) rows() [][]string {
+	rows := make([][]string, 0, len(sr))
+	for _, s := range sr {
+		rows = append(rows, []string{fmt.Sprintf("%04d", s.Version), s.Name, s.Direction})
+	}
+	return rows
+}
+
+// plan is the result of the dry runs, which is printed as the SQL script to be run
+type plan []migrations.Step
+
+func (p plan) text() string {
+	if len(p) == 0 {
+		return "-- nothing to migrate\n"
+	}
+
+	b := strings.Builder{}
+	for _, s := range p {
+		fmt.Fprintf(&b, "-- %04d_%s (%s)\n%s\n\n", s.Version, s.Name, s.Direction, strings.TrimSpace(s.SQL))
+	}
+	return b.String()
+}
+
+// migrateResult returns the result of a migration, the SQL is included only for dry runs
+func migrateResult(steps []migrations.Step, dryRun bool) interface{} {
+	if dryRun {
+		return plan(steps)
+	}
+
+	for i := range steps {
+		steps[i].SQL = ""
+	}
+	return stepRows(steps)
+}
+
+func migrateUp(fs *flag.FlagSet) run {
+	to := fs.Int("to", 0, "version to migrate to, all the pending migrations are applied if not set")
+	dryRun := fs.Bool("dry-run", false, "print the SQL of the pending migrations without applying them")
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		steps, err := s.migrator().Up(ctx, *to, *dryRun)
+		if err != nil {
+			return nil, err
+		}
+		return migrateResult(steps, *dryRun), nil
+	}
+}
+
+func migrateDown(fs *flag.FlagSet) run {
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	dryRun := fs.Bool("dry-run", false, "print the SQL of the roll back without running it")
+
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		if *steps < 1 {
+			return nil, errors.New("-steps should be at least 1")
+		}
+
+		list, err := s.migrator().Down(ctx, *steps, *dryRun)
+		if err != nil {
+			return nil, err
+		}
+		return migrateResult(list, *dryRun), nil
+	}
+}
+
+func migrateStatus(fs *flag.FlagSet) run {
+	return func(ctx context.Context, s *services) (interface{}, error) {
+		list, err := s.migrator().Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return statusRows(list), nil
+	}
+}
@@ -14,6 +14,7 @@ type postgreConfig struct {
 	DBName       string
 	Username     string
 	Password     string
+	SSLMode      string
 	DialTimeout  time.Duration
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
@@ -21,10 +22,10 @@ type postgreConfig struct {
 
 func postgresHandler(pcfg postgreConfig) (*sql.DB, error) {
 	psqlInfo := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		pcfg.Host, pcfg.Port,
 		pcfg.Username, pcfg.Password,
-		pcfg.DBName,
+		pcfg.DBName, pcfg.SSLMode,
 	)
 
 	db, err := sql.Open("postgres", psqlInfo)
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bnkamalesh/padlock/api/grpc"
+	"github.com/bnkamalesh/padlock/api/http"
+	"github.com/bnkamalesh/padlock/pkg/config"
+	"github.com/bnkamalesh/padlock/pkg/platform/health"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+// serve starts the HTTP & gRPC servers along with the workers, and shuts them down gracefully
+// on SIGTERM/SIGINT. It returns the exit code
+func serve(cfg *config.Config) int {
+	l := logger.New("*")
+	if cfg.Logging && !cfg.Debug {
+		l = logger.New("info", "warn", "error", "fatal")
+	}
+
+	var (
+		s       *services
+		workers []*worker
+	)
+	// abort logs the error & releases everything started so far, for a failure before serving.
+	// It returns the exit code
+	abort := func(err error) int {
+		l.Fatal(err)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+		defer cancel()
+		for _, w := range workers {
+			_ = w.stop(ctx)
+		}
+		if s != nil {
+			_ = s.close()
+		}
+		return 1
+	}
+
+	s, err := newServices(cfg, l)
+	if err != nil {
+		return abort(err)
+	}
+
+	if cfg.AutoMigrate {
+		// the replicas starting together wait for the one migrating, since it holds the lock
+		steps, err := s.migrator().Up(context.Background(), 0, false)
+		if err != nil {
+			return abort(err)
+		}
+		for _, step := range steps {
+			l.Info(fmt.Sprintf("applied migration %04d_%s", step.Version, step.Name))
+		}
+	}
+
+	workers = append(workers, startWorker(s.webhooks.Start), startWorker(s.users.StartPurge))
+
+	api := s.api()
+	checker := health.New(health.DefaultConfig, l)
+	checker.Add(string(s.driver), s.db.PingContext)
+	checker.Add("cache", health.WithoutContext(s.cache.Ping))
+
+	httpServer, err := http.NewServer(
+		cfg.HTTP.Host,
+		cfg.HTTP.Port,
+		cfg.HTTP.AppDomain,
+		api,
+		s.appCtx,
+		checker,
+	)
+	if err != nil {
+		return abort(err)
+	}
+
+	grpcServer, err := grpc.NewServer(cfg.GRPC.Host, cfg.GRPC.Port, api, s.appCtx, checker)
+	if err != nil {
+		return abort(err)
+	}
+
+	serverErrs := make(chan error, 2)
+	go func() {
+		serverErrs <- grpcServer.Start()
+	}()
+	go func() {
+		serverErrs <- httpServer.Start()
+	}()
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	select {
+	case sig := <-signals:
+		{
+			l.Info("received", sig.String(), "shutting down")
+		}
+	case err := <-serverErrs:
+		{
+			l.Error(err, "shutting down")
+		}
+	}
+
+	go func() {
+		// a second signal cuts off the graceful shutdown
+		<-signals
+		l.Warn("received another signal, exiting")
+		os.Exit(1)
+	}()
+
+	err = shutdown(
+		l,
+		shutdownConfig{
+			Delay:   cfg.Shutdown.Delay,
+			Timeout: cfg.Shutdown.Timeout,
+		},
+		[]server{httpServer, grpcServer},
+		workers,
+		s.cache,
+		s.db,
+	)
+	if err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/bnkamalesh/padlock/api"
+	"github.com/bnkamalesh/padlock/pkg/apikeys"
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/config"
+	"github.com/bnkamalesh/padlock/pkg/invites"
+	"github.com/bnkamalesh/padlock/pkg/migrations"
+	"github.com/bnkamalesh/padlock/pkg/orgs"
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/platform/notifier"
+	"github.com/bnkamalesh/padlock/pkg/platform/sms"
+	"github.com/bnkamalesh/padlock/pkg/push"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/subjects"
+	"github.com/bnkamalesh/padlock/pkg/users"
+	"github.com/bnkamalesh/padlock/pkg/webauthn"
+	"github.com/bnkamalesh/padlock/pkg/webhooks"
+)
+
+// services has all the service handlers, shared by the servers & the admin commands
+type services struct {
+	appCtx *appcontext.AppContext
+	cache  cache.Cache
+	db     *sql.DB
+	driver database.Driver
+
+	rbac     *rbac.RBAC
+	orgs     *orgs.Orgs
+	apps     *apps.Apps
+	apiKeys  *apikeys.APIKeys
+	users    *users.Users
+	invites  *invites.Invites
+	webauthn *webauthn.WebAuthn
+	push     *push.Push
+	webhooks *webhooks.Webhooks
+	subjects *subjects.Subjects
+}
+
+// newServices connects to the cache & the database, and initializes all the service handlers
+func newServices(cfg *config.Config, l logger.Logger) (*services, error) {
+	cacheHandler, err := cache.New(cache.Config{
+		Hosts:        cfg.Cache.Hosts,
+		Name:         strconv.Itoa(cfg.Cache.DB),
+		Password:     cfg.Cache.Password,
+		DialTimeout:  cfg.Cache.DialTimeout,
+		ReadTimeout:  cfg.Cache.ReadTimeout,
+		WriteTimeout: cfg.Cache.WriteTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	driver := database.Driver(cfg.Database.Driver)
+	pgdb, err := openDB(cfg)
+	if err != nil {
+		_ = cacheHandler.Close()
+		return nil, err
+	}
+
+	appCtx := appcontext.New(l)
+	appCtx.Logging = cfg.Logging
+	appCtx.Debug = cfg.Debug
+	appCtx.BaseURL = cfg.BaseURL
+
+	s := &services{
+		appCtx: appCtx,
+		cache:  cacheHandler,
+		db:     pgdb,
+		driver: driver,
+	}
+
+	notifierHandler := notifier.NewLog(l)
+	s.rbac = rbac.New(appCtx, driver, pgdb)
+	s.orgs = orgs.New(appCtx, driver, pgdb, s.rbac)
+	s.apps = apps.New(appCtx, driver, pgdb, s.rbac, s.orgs)
+	s.apiKeys = apikeys.New(appCtx, driver, pgdb, s.rbac, s.apps)
+	s.users = users.New(appCtx, driver, pgdb, cacheHandler, notifierHandler, sms.NewFake(l))
+	s.users.SetDefaultCountryCode(cfg.Phone.DefaultCountryCode)
+	s.invites = invites.New(
+		appCtx,
+		driver,
+		invites.Config{
+			Secret:   []byte(cfg.Invites.Secret),
+			Validity: cfg.Invites.Validity,
+		},
+		pgdb,
+		s.users,
+		s.orgs,
+		s.rbac,
+		notifierHandler,
+	)
+
+	s.webauthn = webauthn.New(
+		appCtx,
+		driver,
+		webauthn.Config{
+			RPID:    cfg.WebAuthn.RPID,
+			RPName:  cfg.WebAuthn.RPName,
+			Origins: cfg.WebAuthn.Origins,
+		},
+		pgdb,
+		cacheHandler,
+	)
+
+	s.push = push.New(
+		appCtx,
+		driver,
+		push.Config{Digits: cfg.Push.Digits, Choices: cfg.Push.Choices},
+		pgdb,
+		cacheHandler,
+		push.NewLoopback(),
+	)
+	s.webhooks = webhooks.New(appCtx, driver, webhooks.DefaultConfig, pgdb, s.rbac)
+	s.subjects = subjects.New(appCtx, driver, subjects.DefaultConfig, pgdb, s.webhooks)
+
+	return s, nil
+}
+
+// openDB connects to the database of the configured driver
+func openDB(cfg *config.Config) (*sql.DB, error) {
+	if database.Driver(cfg.Database.Driver) == database.SQLite {
+		return sqliteHandler(cfg.SQLite.Path)
+	}
+
+	return postgresHandler(postgreConfig{
+		Host:     cfg.Postgres.Host,
+		Port:     cfg.Postgres.Port,
+		Username: cfg.Postgres.Username,
+		Password: cfg.Postgres.Password,
+		DBName:   cfg.Postgres.DBName,
+		SSLMode:  cfg.Postgres.SSLMode,
+	})
+}
+
+// migrator returns the migrator of the database
+func (s *services) migrator() *migrations.Migrator {
+	if s.driver == database.SQLite {
+		return migrations.New(s.db, migrations.SQLite)
+	}
+	return migrations.New(s.db, migrations.Postgres)
+}
+
+func (s *services) api() *api.API {
+	return api.New(
+		s.appCtx,
+		s.apps,
+		s.users,
+		s.webauthn,
+		s.push,
+		s.rbac,
+		s.orgs,
+		s.invites,
+		s.apiKeys,
+		s.subjects,
+		s.webhooks,
+	)
+}
+
+// close closes the connections to the cache & the database
+func (s *services) close() error {
+	var err error
+	if s.cache != nil {
+		err = s.cache.Close()
+	}
+	dberr := s.db.Close()
+	if err != nil {
+		return err
+	}
+	return dberr
+}
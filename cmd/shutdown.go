@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+// shutdownConfig has the configurations for shutting down gracefully
+type shutdownConfig struct {
+	// Delay is the duration for which the servers are marked as draining before shutting them
+	// down, so that the load balancers stop sending new requests
+	Delay time.Duration
+	// Timeout is the maximum duration to wait for the in-flight requests & the workers to
+	// complete, post which they're cut off
+	Timeout time.Duration
+}
+
+// server is a server which can be shutdown gracefully
+type server interface {
+	Drain()
+	Shutdown(ctx context.Context) error
+}
+
+// worker is a background worker, which stops when its context is cancelled
+type worker struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startWorker(run func(ctx context.Context)) *worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &worker{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(w.done)
+		run(ctx)
+	}()
+	return w
+}
+
+// stop stops the worker, and waits for it to return till the context is done
+func (w *worker) stop(ctx context.Context) error {
+	w.cancel()
+	select {
+	case <-w.done:
+		{
+			return nil
+		}
+	case <-ctx.Done():
+		{
+			return ctx.Err()
+		}
+	}
+}
+
+// shutdown shuts down the servers, then stops the workers and finally closes the cache & the
+// database, since the former depend on the latter. It returns the first error encountered,
+// but attempts all the steps regardless
+func shutdown(l logger.Logger, cfg shutdownConfig, servers []server, workers []*worker, c cache.Cache, db *sql.DB) error {
+	for _, s := range servers {
+		s.Drain()
+	}
+	time.Sleep(cfg.Delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	errs := make(chan error, len(servers)+len(workers)+2)
+	wg := &sync.WaitGroup{}
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s server) {
+			defer wg.Done()
+			errs <- s.Shutdown(ctx)
+		}(s)
+	}
+	wg.Wait()
+	l.Info("servers shutdown")
+
+	for _, w := range workers {
+		errs <- w.stop(ctx)
+	}
+	l.Info("workers stopped")
+
+	errs <- c.Close()
+	errs <- db.Close()
+	close(errs)
+
+	var first error
+	for err := range errs {
+		if err == nil {
+			continue
+		}
+		l.Error(err)
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
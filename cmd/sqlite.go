@@ -0,0 +1,30 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteHandler opens the SQLite database at path, creating it if it does not exist. The
+// foreign keys are enforced like in Postgres, and the writers wait for each other rather than
+// failing immediately
+func sqliteHandler(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf(
+		"file:%s?_foreign_keys=1&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate",
+		path,
+	)
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
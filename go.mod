@@ -1,15 +1,21 @@
 module github.com/bnkamalesh/padlock
 
-go 1.12
+go 1.15
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/OneOfOne/xxhash v1.2.5
 	github.com/bnkamalesh/webgo v2.4.1+incompatible
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/go-redis/cache v6.3.5+incompatible
 	github.com/go-redis/redis v6.15.2+incompatible
+	github.com/golang/protobuf v1.3.2
 	github.com/google/uuid v1.1.1
 	github.com/lib/pq v1.0.0
+	github.com/mattn/go-sqlite3 v1.14.6
 	google.golang.org/appengine v1.5.0 // indirect
+	google.golang.org/genproto v0.0.0-20180817151627-c66870c02cf8
+	google.golang.org/grpc v1.23.0
 	gopkg.in/vmihailenco/msgpack.v2 v2.9.1
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,319 @@
+// Package apikeys handles the API keys of registered applications, used by the applications'
+// servers to call Padlock. Keys are shown only once while creating, and only their hashes
+// are stored
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// Scope limits what a key can be used for
+type Scope string
+
+type ctxKey string
+
+const (
+	// ScopeEnroll allows enrolling the users of the application for second factors
+	ScopeEnroll = Scope("enroll")
+	// ScopeVerify allows verifying the codes/factors of the users of the application
+	ScopeVerify = Scope("verify")
+	// ScopeAdmin allows everything, including the other scopes
+	ScopeAdmin = Scope("admin")
+
+	// keyPrefix is the fixed prefix of all keys, making them easy to identify (e.g. by secret
+	// scanners)
+	keyPrefix = "pdk"
+	// idLength is the length of the random, public part of the key used to look it up
+	idLength = 8
+
+	ctxKeyKey = ctxKey("apikey")
+	ctxAppKey = ctxKey("app")
+)
+
+var (
+	ErrInvalidName   = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no name provided")
+	ErrInvalidScope  = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no scopes provided")
+	ErrInvalidExpiry = apperr.New(apperr.CodeInvalidInput, "Sorry, the expiry should be in the future")
+	ErrInvalidKey    = apperr.New(apperr.CodeUnauthenticated, "Sorry, invalid API key")
+	ErrExpired       = apperr.New(apperr.CodeUnauthenticated, "Sorry, the API key has expired")
+	ErrRevoked       = apperr.New(apperr.CodeUnauthenticated, "Sorry, the API key was revoked")
+	ErrScope         = apperr.New(apperr.CodePermissionDenied, "Sorry, the API key is not allowed to perform this action")
+	ErrNotFound      = apperr.New(apperr.CodeNotFound, "Sorry, API key not found")
+	ErrUnexpected    = apperr.New(apperr.CodeInternal, "Sorry, an unexpected error occurred")
+
+	validScopes = map[Scope]bool{
+		ScopeEnroll: true,
+		ScopeVerify: true,
+		ScopeAdmin:  true,
+	}
+)
+
+// APIKey is a credential of an application
+type APIKey struct {
+	ID    int64  `json:"id,omitempty"`
+	AppID int64  `json:"appId,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// Prefix is the non-secret beginning of the key, to identify it
+	Prefix string  `json:"prefix,omitempty"`
+	Scopes []Scope `json:"scopes,omitempty"`
+	// Key is the full API key, it's only available in the response of Create
+	Key        string     `json:"key,omitempty"`
+	CreatedBy  int64      `json:"createdBy,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+
+	hash string
+}
+
+// Allows checks if the key is allowed the scope
+func (k *APIKey) Allows(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// keyHash returns the hash of the key, as stored
+func keyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newKey generates a new key of the format pdk_<id>_<secret>, and returns the key along with
+// its prefix (pdk_<id>)
+func newKey() (string, string, error) {
+	id, err := randomString(idLength)
+	if err != nil {
+		return "", "", err
+	}
+	// '_' is the separator, and not allowed in the ID
+	id = strings.Replace(id, "_", "-", -1)[:idLength]
+
+	secret, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix := fmt.Sprintf("%s_%s", keyPrefix, id)
+	return fmt.Sprintf("%s_%s", prefix, secret), prefix, nil
+}
+
+// prefixOf returns the prefix of the key
+func prefixOf(key string) (string, error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != keyPrefix || len(parts[1]) != idLength || parts[2] == "" {
+		return "", ErrInvalidKey
+	}
+	return parts[0] + "_" + parts[1], nil
+}
+
+// Authorizer checks if the caller, identified by the context, is allowed to manage the keys of
+// an app
+type Authorizer interface {
+	Authorize(ctx context.Context, perm rbac.Permission, appID int64) error
+}
+
+// AppReader reads the application a key belongs to
+type AppReader interface {
+	Read(ctx context.Context, id int64) (*apps.App, error)
+}
+
+// APIKeys handles all the service methods made available by this package
+type APIKeys struct {
+	appCtx *appcontext.AppContext
+	store  store
+	auth   Authorizer
+	apps   AppReader
+}
+
+func (ak *APIKeys) unexpected(err error) error {
+	if ak.appCtx.Logging {
+		ak.appCtx.Logger.Error(err)
+	}
+	return ErrUnexpected
+}
+
+// Create creates a new key for the application. The returned key has the full key set, which
+// should be shown to the user as it cannot be retrieved later
+func (ak *APIKeys) Create(ctx context.Context, k APIKey) (*APIKey, error) {
+	k.Name = strings.TrimSpace(k.Name)
+	if k.Name == "" {
+		return nil, ErrInvalidName
+	}
+
+	if len(k.Scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	for _, s := range k.Scopes {
+		if !validScopes[s] {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	now := time.Now().UTC()
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(now) {
+		return nil, ErrInvalidExpiry
+	}
+
+	err := ak.auth.Authorize(ctx, rbac.PermAppKeys, k.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, prefix, err := newKey()
+	if err != nil {
+		return nil, ak.unexpected(err)
+	}
+
+	k.ID = 0
+	k.Prefix = prefix
+	k.hash = keyHash(key)
+	k.CreatedAt = &now
+	k.LastUsedAt = nil
+	k.RevokedAt = nil
+	k.CreatedBy = 0
+	if u := users.FromContext(ctx); u != nil {
+		k.CreatedBy = u.ID
+	}
+
+	created, err := ak.store.Create(ctx, k)
+	if err != nil {
+		return nil, ak.unexpected(err)
+	}
+
+	created.Key = key
+	return created, nil
+}
+
+// List lists all the keys of the application, including the revoked & expired ones
+func (ak *APIKeys) List(ctx context.Context, appID int64) ([]APIKey, error) {
+	err := ak.auth.Authorize(ctx, rbac.PermAppKeys, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := ak.store.List(ctx, appID)
+	if err != nil {
+		return nil, ak.unexpected(err)
+	}
+	return list, nil
+}
+
+// Revoke revokes the key, after which it cannot be used anymore
+func (ak *APIKeys) Revoke(ctx context.Context, appID, id int64) error {
+	err := ak.auth.Authorize(ctx, rbac.PermAppKeys, appID)
+	if err != nil {
+		return err
+	}
+
+	err = ak.store.Revoke(ctx, appID, id, time.Now().UTC())
+	if err != nil {
+		if err == ErrNotFound {
+			return err
+		}
+		return ak.unexpected(err)
+	}
+	return nil
+}
+
+// Authenticate resolves the key to the application it belongs to. The last used time of the
+// key is updated on every successful authentication
+func (ak *APIKeys) Authenticate(ctx context.Context, key string) (*apps.App, *APIKey, error) {
+	prefix, err := prefixOf(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k, err := ak.store.ReadByPrefix(ctx, prefix)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, nil, ErrInvalidKey
+		}
+		return nil, nil, ak.unexpected(err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(k.hash), []byte(keyHash(key))) != 1 {
+		return nil, nil, ErrInvalidKey
+	}
+
+	now := time.Now().UTC()
+	if k.RevokedAt != nil {
+		return nil, nil, ErrRevoked
+	}
+
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(now) {
+		return nil, nil, ErrExpired
+	}
+
+	// the key is the credential of the app, so it is read on behalf of the system
+	app, err := ak.apps.Read(rbac.SystemContext(ctx), k.AppID)
+	if err != nil {
+		if apperr.Is(err, apps.ErrNotFound) {
+			return nil, nil, ErrInvalidKey
+		}
+		return nil, nil, err
+	}
+
+	err = ak.store.UpdateUsage(ctx, k.ID, now)
+	if err != nil {
+		return nil, nil, ak.unexpected(err)
+	}
+	k.LastUsedAt = &now
+
+	return app, k, nil
+}
+
+// SetContext sets the authenticated key & its application in the context
+func SetContext(ctx context.Context, app *apps.App, k *APIKey) context.Context {
+	return context.WithValue(context.WithValue(ctx, ctxAppKey, app), ctxKeyKey, k)
+}
+
+// AppFromContext returns the application authenticated by an API key, if any
+func AppFromContext(ctx context.Context) *apps.App {
+	app, _ := ctx.Value(ctxAppKey).(*apps.App)
+	return app
+}
+
+// FromContext returns the authenticated API key, if any
+func FromContext(ctx context.Context) *APIKey {
+	k, _ := ctx.Value(ctxKeyKey).(*APIKey)
+	return k
+}
+
+func New(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB, auth Authorizer, a AppReader) *APIKeys {
+	return &APIKeys{
+		appCtx: appCtx,
+		store:  newStore(appCtx, driver, sdb),
+		auth:   auth,
+		apps:   a,
+	}
+}
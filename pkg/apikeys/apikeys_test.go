@@ -0,0 +1,239 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+)
+
+type allowAll struct{}
+
+func (allowAll) Authorize(ctx context.Context, perm rbac.Permission, appID int64) error {
+	return nil
+}
+
+// appReader returns the apps with the given IDs, and apps.ErrNotFound for the rest
+type appReader map[int64]bool
+
+func (ar appReader) Read(ctx context.Context, id int64) (*apps.App, error) {
+	if !ar[id] {
+		return nil, apps.ErrNotFound
+	}
+	return &apps.App{ID: id}, nil
+}
+
+func TestKeyHash(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     string
+		b     string
+		equal bool
+	}{
+		{name: "same key", a: "pdk_abcdefgh_secret", b: "pdk_abcdefgh_secret", equal: true},
+		{name: "different secret", a: "pdk_abcdefgh_secret", b: "pdk_abcdefgh_secreT"},
+		{name: "different ID", a: "pdk_abcdefgh_secret", b: "pdk_abcdefgi_secret"},
+		{name: "prefix only", a: "pdk_abcdefgh_secret", b: "pdk_abcdefgh"},
+	}
+
+	for _, tt := range tests {
+		a, b := keyHash(tt.a), keyHash(tt.b)
+		if len(a) != 64 || strings.Contains(a, tt.a) {
+			t.Fatalf("%s: expected a hex encoded SHA-256 hash, got %s", tt.name, a)
+		}
+		if (a == b) != tt.equal {
+			t.Fatalf("%s: expected equal to be %t, got hashes %s & %s", tt.name, tt.equal, a, b)
+		}
+	}
+}
+
+func TestPrefixOf(t *testing.T) {
+	tests := []struct {
+		key    string
+		prefix string
+		err    error
+	}{
+		{key: "pdk_abcdefgh_secret", prefix: "pdk_abcdefgh"},
+		{key: "pdk_abcdefgh_sec_ret", prefix: "pdk_abcdefgh"},
+		{key: "pdk_abcdefgh_", err: ErrInvalidKey},
+		{key: "pdk_abcdefgh", err: ErrInvalidKey},
+		{key: "pdk_abcdefg_secret", err: ErrInvalidKey},
+		{key: "pk_abcdefgh_secret", err: ErrInvalidKey},
+		{key: "", err: ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		prefix, err := prefixOf(tt.key)
+		if !errors.Is(err, tt.err) || prefix != tt.prefix {
+			t.Fatalf("%q: expected %q & %v, got %q & %v", tt.key, tt.prefix, tt.err, prefix, err)
+		}
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		key, prefix, err := newKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := prefixOf(key)
+		if err != nil || p != prefix {
+			t.Fatalf("expected the prefix %s of %s, got %s & %v", prefix, key, p, err)
+		}
+
+		if seen[prefix] {
+			t.Fatalf("duplicate prefix %s", prefix)
+		}
+		seen[prefix] = true
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		scopes []Scope
+		scope  Scope
+		allows bool
+	}{
+		{scopes: []Scope{ScopeVerify}, scope: ScopeVerify, allows: true},
+		{scopes: []Scope{ScopeVerify}, scope: ScopeEnroll},
+		{scopes: []Scope{ScopeEnroll, ScopeVerify}, scope: ScopeEnroll, allows: true},
+		{scopes: []Scope{ScopeAdmin}, scope: ScopeEnroll, allows: true},
+		{scope: ScopeVerify},
+	}
+
+	for _, tt := range tests {
+		k := &APIKey{Scopes: tt.scopes}
+		if k.Allows(tt.scope) != tt.allows {
+			t.Fatalf("%v allows %s: expected %t", tt.scopes, tt.scope, tt.allows)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		ak := New(appcontext.New(logger.New()), driver, db, allowAll{}, appReader{})
+		appID := databasetest.App(t, driver, db, databasetest.Org(t, driver, db, "acme"), "billing")
+		past := time.Now().Add(-time.Minute)
+
+		tests := []struct {
+			name string
+			key  APIKey
+			err  error
+		}{
+			{name: "no name", key: APIKey{AppID: appID, Scopes: []Scope{ScopeVerify}}, err: ErrInvalidName},
+			{name: "no scopes", key: APIKey{AppID: appID, Name: "server"}, err: ErrInvalidScope},
+			{name: "invalid scope", key: APIKey{AppID: appID, Name: "server", Scopes: []Scope{"all"}}, err: ErrInvalidScope},
+			{name: "expired", key: APIKey{AppID: appID, Name: "server", Scopes: []Scope{ScopeVerify}, ExpiresAt: &past}, err: ErrInvalidExpiry},
+			{name: "valid", key: APIKey{AppID: appID, Name: "server", Scopes: []Scope{ScopeVerify}}},
+		}
+
+		for _, tt := range tests {
+			k, err := ak.Create(ctx, tt.key)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("%s: expected %v, got %v", tt.name, tt.err, err)
+			}
+			if err != nil {
+				continue
+			}
+
+			if !strings.HasPrefix(k.Key, k.Prefix+"_") || k.hash != keyHash(k.Key) {
+				t.Fatalf("%s: expected the key with prefix %s & its hash, got %+v", tt.name, k.Prefix, k)
+			}
+		}
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		orgID := databasetest.Org(t, driver, db, "acme")
+		appID := databasetest.App(t, driver, db, orgID, "billing")
+		deletedAppID := databasetest.App(t, driver, db, orgID, "shipping")
+		ak := New(appcontext.New(logger.New()), driver, db, allowAll{}, appReader{appID: true})
+
+		create := func(k APIKey) string {
+			t.Helper()
+
+			k.Name = "server"
+			k.Scopes = []Scope{ScopeVerify}
+			created, err := ak.Create(ctx, k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return created.Key
+		}
+
+		valid := create(APIKey{AppID: appID})
+		revoked := create(APIKey{AppID: appID})
+		ofDeletedApp := create(APIKey{AppID: deletedAppID})
+
+		list, err := ak.List(ctx, appID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ak.Revoke(ctx, appID, list[1].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// keys cannot be created with a past expiry, so it's expired in the store directly
+		expired, prefix, err := newKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		past := time.Now().Add(-time.Minute)
+		_, err = ak.store.Create(ctx, APIKey{
+			AppID:     appID,
+			Name:      "expired",
+			Prefix:    prefix,
+			Scopes:    []Scope{ScopeVerify},
+			ExpiresAt: &past,
+			CreatedAt: &past,
+			hash:      keyHash(expired),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		validPrefix, _ := prefixOf(valid)
+		tests := []struct {
+			name string
+			key  string
+			err  error
+		}{
+			{name: "valid", key: valid},
+			{name: "tampered secret", key: validPrefix + "_tampered", err: ErrInvalidKey},
+			{name: "unknown prefix", key: "pdk_abcdefgh" + strings.TrimPrefix(valid, validPrefix), err: ErrInvalidKey},
+			{name: "malformed", key: "invalid", err: ErrInvalidKey},
+			{name: "revoked", key: revoked, err: ErrRevoked},
+			{name: "expired", key: expired, err: ErrExpired},
+			{name: "deleted app", key: ofDeletedApp, err: ErrInvalidKey},
+		}
+
+		for _, tt := range tests {
+			app, k, err := ak.Authenticate(ctx, tt.key)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("%s: expected %v, got %v", tt.name, tt.err, err)
+			}
+			if err != nil {
+				continue
+			}
+
+			if app.ID != appID || k.AppID != appID || k.LastUsedAt == nil {
+				t.Fatalf("%s: expected the key of app %d, got %+v", tt.name, appID, k)
+			}
+		}
+	})
+}
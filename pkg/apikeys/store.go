@@ -0,0 +1,212 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+)
+
+const (
+	keysTable = "apiKeys"
+
+	keyColumns = "id,appid,name,prefix,hash,scopes,createdby,expiresat,lastusedat,revokedat,createdat"
+)
+
+type store interface {
+	Create(ctx context.Context, k APIKey) (*APIKey, error)
+	ReadByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	List(ctx context.Context, appID int64) ([]APIKey, error)
+	Revoke(ctx context.Context, appID, id int64, revokedAt time.Time) error
+	UpdateUsage(ctx context.Context, id int64, usedAt time.Time) error
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type dbStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// newStore returns the store of the driver
+func newStore(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB) store {
+	if driver == database.SQLite {
+		return &sqliteStore{
+			appCtx: appCtx,
+			db:     sdb,
+		}
+	}
+	return &dbStore{
+		appCtx: appCtx,
+		db:     sdb,
+	}
+}
+
+func (dbs *dbStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, fmt.Sprintf("$%d", i+1))
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+// scopes are stored as a comma separated string
+func joinScopes(scopes []Scope) string {
+	list := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		list = append(list, string(s))
+	}
+	return strings.Join(list, ",")
+}
+
+func splitScopes(str string) []Scope {
+	scopes := make([]Scope, 0, 3)
+	for _, s := range strings.Split(str, ",") {
+		if s != "" {
+			scopes = append(scopes, Scope(s))
+		}
+	}
+	return scopes
+}
+
+func scan(row scanner) (*APIKey, error) {
+	k := APIKey{}
+	scopes := ""
+	createdBy := sql.NullInt64{}
+	expiresAt := pq.NullTime{}
+	lastUsedAt := pq.NullTime{}
+	revokedAt := pq.NullTime{}
+	createdAt := pq.NullTime{}
+
+	err := row.Scan(
+		&k.ID,
+		&k.AppID,
+		&k.Name,
+		&k.Prefix,
+		&k.hash,
+		&scopes,
+		&createdBy,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+		&createdAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	k.Scopes = splitScopes(scopes)
+	k.CreatedBy = createdBy.Int64
+	if expiresAt.Valid {
+		k.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	if createdAt.Valid {
+		k.CreatedAt = &createdAt.Time
+	}
+
+	return &k, nil
+}
+
+func (dbs *dbStore) Create(ctx context.Context, k APIKey) (*APIKey, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s RETURNING id",
+		keysTable,
+		dbs.prepColVals("appid", "name", "prefix", "hash", "scopes", "createdby", "expiresat", "createdat"),
+	)
+
+	err := dbs.db.QueryRowContext(
+		ctx,
+		stmt,
+		k.AppID,
+		k.Name,
+		k.Prefix,
+		k.hash,
+		joinScopes(k.Scopes),
+		sql.NullInt64{Int64: k.CreatedBy, Valid: k.CreatedBy > 0},
+		k.ExpiresAt,
+		k.CreatedAt,
+	).Scan(&k.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+func (dbs *dbStore) ReadByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE prefix=$1", keyColumns, keysTable)
+	return scan(dbs.db.QueryRowContext(ctx, stmt, prefix))
+}
+
+func (dbs *dbStore) List(ctx context.Context, appID int64) ([]APIKey, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE appid=$1 ORDER BY id", keyColumns, keysTable)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, appID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]APIKey, 0)
+	for rows.Next() {
+		k, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *k)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) Revoke(ctx context.Context, appID, id int64, revokedAt time.Time) error {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET revokedat=$1 WHERE id=$2 AND appid=$3 AND revokedat IS NULL",
+		keysTable,
+	)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, revokedAt, id, appID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (dbs *dbStore) UpdateUsage(ctx context.Context, id int64, usedAt time.Time) error {
+	stmt := fmt.Sprintf("UPDATE %s SET lastusedat=$1 WHERE id=$2", keysTable)
+	_, err := dbs.db.ExecContext(ctx, stmt, usedAt, id)
+	return err
+}
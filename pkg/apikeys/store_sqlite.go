@@ -0,0 +1,125 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+)
+
+// sqliteStore is the store of SQLite, it behaves exactly like dbStore
+type sqliteStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// sqliteTime returns the time in UTC truncated to seconds, as stored by Postgres. SQLite stores
+// the times as text, which are compared correctly only if they're all in the same time zone
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Truncate(time.Second)
+}
+
+func (ss *sqliteStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, "?")
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func (ss *sqliteStore) Create(ctx context.Context, k APIKey) (*APIKey, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		keysTable,
+		ss.prepColVals("appid", "name", "prefix", "hash", "scopes", "createdby", "expiresat", "createdat"),
+	)
+
+	result, err := ss.db.ExecContext(
+		ctx,
+		stmt,
+		k.AppID,
+		k.Name,
+		k.Prefix,
+		k.hash,
+		joinScopes(k.Scopes),
+		sql.NullInt64{Int64: k.CreatedBy, Valid: k.CreatedBy > 0},
+		sqliteTime(k.ExpiresAt),
+		sqliteTime(k.CreatedAt),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	k.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+func (ss *sqliteStore) ReadByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE prefix=?", keyColumns, keysTable)
+	return scan(ss.db.QueryRowContext(ctx, stmt, prefix))
+}
+
+func (ss *sqliteStore) List(ctx context.Context, appID int64) ([]APIKey, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE appid=? ORDER BY id", keyColumns, keysTable)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, appID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]APIKey, 0)
+	for rows.Next() {
+		k, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *k)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) Revoke(ctx context.Context, appID, id int64, revokedAt time.Time) error {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET revokedat=? WHERE id=? AND appid=? AND revokedat IS NULL",
+		keysTable,
+	)
+
+	result, err := ss.db.ExecContext(ctx, stmt, sqliteTime(&revokedAt), id, appID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (ss *sqliteStore) UpdateUsage(ctx context.Context, id int64, usedAt time.Time) error {
+	stmt := fmt.Sprintf("UPDATE %s SET lastusedat=? WHERE id=?", keysTable)
+	_, err := ss.db.ExecContext(ctx, stmt, sqliteTime(&usedAt), id)
+	return err
+}
@@ -0,0 +1,97 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+func TestStoreKeys(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		st := newStore(appcontext.New(logger.New()), driver, db)
+		userID := databasetest.User(t, driver, db, "owner@example.com")
+		orgID := databasetest.Org(t, driver, db, "acme")
+		appID := databasetest.App(t, driver, db, orgID, "billing")
+		otherAppID := databasetest.App(t, driver, db, orgID, "shipping")
+
+		now := time.Now()
+		create := func(appID int64, prefix string, scopes ...Scope) *APIKey {
+			t.Helper()
+
+			k, err := st.Create(ctx, APIKey{
+				AppID:     appID,
+				Name:      prefix,
+				Prefix:    prefix,
+				Scopes:    scopes,
+				CreatedBy: userID,
+				CreatedAt: &now,
+				hash:      "hash-of-" + prefix,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return k
+		}
+
+		first := create(appID, "pk_first", ScopeEnroll, ScopeVerify)
+		second := create(appID, "pk_second", ScopeAdmin)
+		create(otherAppID, "pk_other", ScopeVerify)
+
+		k, err := st.ReadByPrefix(ctx, "pk_first")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k.ID != first.ID || k.hash != "hash-of-pk_first" || len(k.Scopes) != 2 || k.Scopes[1] != ScopeVerify {
+			t.Fatalf("expected %+v, got %+v", first, k)
+		}
+
+		_, err = st.ReadByPrefix(ctx, "pk_none")
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		list, err := st.List(ctx, appID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID {
+			t.Fatalf("expected the keys of the app, got %+v", list)
+		}
+
+		err = st.UpdateUsage(ctx, first.ID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = st.Revoke(ctx, otherAppID, first.ID, now)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected the key of another app to be not found, got %v", err)
+		}
+
+		err = st.Revoke(ctx, appID, first.ID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = st.Revoke(ctx, appID, first.ID, now)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected a revoked key to be not found, got %v", err)
+		}
+
+		k, err = st.ReadByPrefix(ctx, "pk_first")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k.RevokedAt == nil || k.LastUsedAt == nil {
+			t.Fatalf("expected the key to be used & revoked, got %+v", k)
+		}
+	})
+}
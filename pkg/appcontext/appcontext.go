@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/bnkamalesh/padlock/pkg/platform/logger"
 )
 
@@ -18,6 +20,8 @@ type AppContext struct {
 	Logger  logger.Logger
 	Logging bool
 	Debug   bool
+	// BaseURL is the public URL of the application, used for generating links sent to users
+	BaseURL string
 }
 
 func New(l logger.Logger) *AppContext {
@@ -28,6 +32,8 @@ func New(l logger.Logger) *AppContext {
 }
 
 type RequestContext struct {
+	// ID is the unique ID of the request, it's included in the logs & error responses
+	ID      string     `json:"id,omitempty"`
 	StartAt *time.Time `json:"startAt,omitempty"`
 	EndAt   *time.Time `json:"endAt,omitempty"`
 	Source  string     `json:"source,omitempty"`
@@ -36,6 +42,7 @@ type RequestContext struct {
 
 func (ac *AppContext) NewReqContext(ctx context.Context, source string) (context.Context, *RequestContext) {
 	rctx := &RequestContext{
+		ID:     uuid.New().String(),
 		Source: source,
 	}
 
@@ -0,0 +1,205 @@
+// Package apperr has the error type shared by all the packages, with a stable machine readable
+// code. The transports (HTTP, gRPC) use the code to respond with the appropriate status, instead
+// of every transport mapping every error of every package
+package apperr
+
+import (
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Code is the machine readable code of an error, it should never be changed once released
+type Code string
+
+const (
+	// CodeInvalidInput is used when the input provided is invalid, e.g. an invalid email
+	CodeInvalidInput = Code("invalid_input")
+	// CodeUnauthenticated is used when the credentials (session/API key) are missing or invalid
+	CodeUnauthenticated = Code("unauthenticated")
+	// CodePermissionDenied is used when the caller is not allowed to do the action
+	CodePermissionDenied = Code("permission_denied")
+	// CodeNotFound is used when the resource does not exist
+	CodeNotFound = Code("not_found")
+	// CodeConflict is used when the resource already exists, or was updated concurrently
+	CodeConflict = Code("conflict")
+	// CodeExpired is used when the resource existed, but is not valid anymore
+	CodeExpired = Code("expired")
+	// CodeRateLimited is used when there were too many requests/attempts
+	CodeRateLimited = Code("rate_limited")
+	// CodeUpstream is used when a service Padlock depends on (e.g. push notifications) failed
+	CodeUpstream = Code("upstream_error")
+	// CodeInternal is used for all unexpected errors, the details are never exposed
+	CodeInternal = Code("internal")
+)
+
+// messageInternal is the message of all internal errors, so that the details are never exposed
+const messageInternal = "Sorry, an unexpected error occurred"
+
+// HTTPStatus returns the HTTP status appropriate for the code
+func (c Code) HTTPStatus() int {
+	switch c {
+	case CodeInvalidInput:
+		{
+			return http.StatusBadRequest
+		}
+	case CodeUnauthenticated:
+		{
+			return http.StatusUnauthorized
+		}
+	case CodePermissionDenied:
+		{
+			return http.StatusForbidden
+		}
+	case CodeNotFound:
+		{
+			return http.StatusNotFound
+		}
+	case CodeConflict:
+		{
+			return http.StatusConflict
+		}
+	case CodeExpired:
+		{
+			return http.StatusGone
+		}
+	case CodeRateLimited:
+		{
+			return http.StatusTooManyRequests
+		}
+	case CodeUpstream:
+		{
+			return http.StatusBadGateway
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCode returns the gRPC status code appropriate for the code
+func (c Code) GRPCCode() codes.Code {
+	switch c {
+	case CodeInvalidInput:
+		{
+			return codes.InvalidArgument
+		}
+	case CodeUnauthenticated:
+		{
+			return codes.Unauthenticated
+		}
+	case CodePermissionDenied:
+		{
+			return codes.PermissionDenied
+		}
+	case CodeNotFound:
+		{
+			return codes.NotFound
+		}
+	case CodeConflict:
+		{
+			return codes.AlreadyExists
+		}
+	case CodeExpired:
+		{
+			return codes.FailedPrecondition
+		}
+	case CodeRateLimited:
+		{
+			return codes.ResourceExhausted
+		}
+	case CodeUpstream:
+		{
+			return codes.Unavailable
+		}
+	}
+	return codes.Internal
+}
+
+// Error is an error with a code. The errors returned by New are meant to be sentinel errors,
+// and Wrap attaches the underlying cause to a sentinel error
+type Error struct {
+	Code    Code
+	Message string
+
+	// sentinel is the error which was wrapped, it's used to match the error in Is
+	sentinel *Error
+	cause    error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Cause returns the underlying cause of the error, if any
+func (e *Error) Cause() error {
+	return e.cause
+}
+
+// Unwrap returns the underlying cause, for compatibility with errors.Is & errors.As of the
+// standard library
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is returns true if the error is the target, or the target was wrapped by the error
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return e == t || (e.sentinel != nil && e.sentinel == t)
+}
+
+// New returns a new error with the code & message
+func New(code Code, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// Wrap returns an error which has the code & message of the sentinel error, and the cause as
+// the underlying error. The returned error matches the sentinel in Is
+func Wrap(sentinel *Error, cause error) error {
+	if cause == nil {
+		return sentinel
+	}
+
+	return &Error{
+		Code:     sentinel.Code,
+		Message:  sentinel.Message,
+		sentinel: sentinel,
+		cause:    cause,
+	}
+}
+
+// Is returns true if err is the target, or wraps the target
+func Is(err, target error) bool {
+	if err == target {
+		return true
+	}
+
+	e, ok := err.(*Error)
+	if !ok {
+		return false
+	}
+	return e.Is(target)
+}
+
+// CodeOf returns the code of the error, errors which are not of type *Error are internal errors
+func CodeOf(err error) Code {
+	e, ok := err.(*Error)
+	if !ok {
+		return CodeInternal
+	}
+	return e.Code
+}
+
+// Message returns the message of the error which can be shown to the caller, the message of
+// internal errors is never exposed
+func Message(err error) string {
+	e, ok := err.(*Error)
+	if !ok || e.Code == CodeInternal {
+		return messageInternal
+	}
+	return e.Message
+}
@@ -4,20 +4,28 @@ package apps
 import (
 	"context"
 	"database/sql"
-	"errors"
 	"strings"
 	"time"
 
 	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
 	"github.com/bnkamalesh/padlock/pkg/totp"
 	"github.com/bnkamalesh/padlock/pkg/users"
 )
 
 var (
-	ErrUnexpected   = errors.New("Sorry, an unexpected error occurred")
-	ErrInvalidName  = errors.New("Sorry, invalid/no application name provided")
-	ErrInvalidID    = errors.New("Sorry, invalid/no application ID provided")
-	ErrInvalidOwner = errors.New("Sorry, invalid/no owner provided")
+	ErrUnexpected  = apperr.New(apperr.CodeInternal, "Sorry, an unexpected error occurred")
+	ErrInvalidName = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no application name provided")
+	ErrInvalidID   = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no application ID provided")
+	ErrInvalidOrg  = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no organisation provided")
+	ErrNotFound    = apperr.New(apperr.CodeNotFound, "Sorry, application not found")
+	ErrNameExists  = apperr.New(apperr.CodeConflict, "Sorry, an application with the name already exists")
+	ErrInvalidUser = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no user provided")
+	ErrInvalidTOTP = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid TOTP settings, digits should be between 6 & 8 and algorithm one of SHA1, SHA256 or SHA512")
+	// ErrOwnerNotFound is returned when removing a user who is not a direct owner of the app
+	ErrOwnerNotFound = apperr.New(apperr.CodeNotFound, "Sorry, the user is not an owner of the application")
 )
 
 // App holds all the info related to an application registered on this platform
@@ -28,6 +36,8 @@ type App struct {
 	Name string `json:"name,omitempty"`
 	// Description is a short description for the application
 	Description string `json:"description,omitempty"`
+	// OrgID is the ID of the organisation which owns the application
+	OrgID int64 `json:"orgId,omitempty"`
 	// TOTP stores all the base settings required for generating TOTP
 	TOTP *totp.TOTP `json:"totp,omitempty"`
 	// CreatedAt is the timestamp at which the application was registered on this platform
@@ -36,14 +46,98 @@ type App struct {
 	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
 }
 
+// Owner is a user who was made an owner of the application directly, rather than through
+// their organisation or team
+type Owner struct {
+	UserID    int64      `json:"userId,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// ListFilter has all the filters & pagination options available while listing apps
+type ListFilter struct {
+	// OrgID filters apps owned by the organisation
+	OrgID int64 `json:"orgId,omitempty"`
+	// Name filters apps whose name contains the given value (case insensitive)
+	Name string `json:"name,omitempty"`
+	// Offset is the number of records to skip
+	Offset int `json:"offset,omitempty"`
+	// Limit is the maximum number of records to return
+	Limit int `json:"limit,omitempty"`
+
+	// ids restricts the list to the apps accessible by the caller, unless all is true
+	ids []int64
+	all bool
+}
+
+func (lf *ListFilter) sanitize() {
+	lf.Name = strings.TrimSpace(lf.Name)
+	if lf.Offset < 0 {
+		lf.Offset = 0
+	}
+
+	if lf.Limit < 1 {
+		lf.Limit = defaultListLimit
+	} else if lf.Limit > maxListLimit {
+		lf.Limit = maxListLimit
+	}
+}
+
+// Authorizer checks if the caller, identified by the context, is allowed to act on an app
+type Authorizer interface {
+	// Authorize returns an error if the permission is not allowed on the app
+	Authorize(ctx context.Context, perm rbac.Permission, appID int64) error
+	// AppIDs returns the IDs of the apps on which the permission is allowed, all is true if
+	// it's allowed on every app
+	AppIDs(ctx context.Context, perm rbac.Permission) (ids []int64, all bool, err error)
+}
+
+// OrgAuthorizer checks if the caller, identified by the context, can create & manage the
+// applications of an organisation
+type OrgAuthorizer interface {
+	AuthorizeApps(ctx context.Context, orgID int64) error
+}
+
 // Apps handles all the service methods made available by this package
 type Apps struct {
-	appCtx *appcontext.AppContext
-	store  store
+	appCtx  *appcontext.AppContext
+	store   store
+	auth    Authorizer
+	orgAuth OrgAuthorizer
+}
+
+// storeErr returns the errors of this package as is, and logs & wraps the rest with
+// ErrUnexpected
+func (a *Apps) storeErr(err error) error {
+	if _, ok := err.(*apperr.Error); ok {
+		return err
+	}
+
+	if a.appCtx.Logging {
+		a.appCtx.Logger.Error(err)
+	}
+	return apperr.Wrap(ErrUnexpected, err)
+}
+
+func validateTOTP(t *totp.TOTP) error {
+	if t == nil {
+		return nil
+	}
+
+	err := t.Validate()
+	if err != nil {
+		return apperr.Wrap(ErrInvalidTOTP, err)
+	}
+	return nil
 }
 
 // Create accepts an App instance and inserts it in the data store. On success it'll return
-// the pointer of the app instance which was insterted
+// the pointer of the app instance which was insterted. The app is owned by the organisation
+// app.OrgID, and only its owners & admins can create apps
 func (a *Apps) Create(ctx context.Context, app App) (*App, error) {
 	now := time.Now().UTC()
 	app.CreatedAt = &now
@@ -54,10 +148,23 @@ func (a *Apps) Create(ctx context.Context, app App) (*App, error) {
 		return nil, ErrInvalidName
 	}
 
+	if app.OrgID < 1 {
+		return nil, ErrInvalidOrg
+	}
+
+	err := validateTOTP(app.TOTP)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.orgAuth.AuthorizeApps(ctx, app.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
 	ap, err := a.store.Create(ctx, app)
 	if err != nil {
-		a.appCtx.Logger.Error(err)
-		return nil, ErrUnexpected
+		return nil, a.storeErr(err)
 	}
 
 	return ap, nil
@@ -69,20 +176,34 @@ func (a *Apps) Read(ctx context.Context, id int64) (*App, error) {
 		return nil, ErrInvalidID
 	}
 
+	err := a.auth.Authorize(ctx, rbac.PermAppRead, id)
+	if err != nil {
+		return nil, err
+	}
+
 	app, err := a.store.Read(ctx, id)
 	if err != nil {
-		a.appCtx.Logger.Error(err)
-		return nil, ErrUnexpected
+		return nil, a.storeErr(err)
 	}
 	return app, nil
 }
 
-// ReadAll reads all the records from the store for the given IDs
+// ReadAll reads all the records from the store for the given IDs. Apps which the caller is not
+// allowed to read are skipped
 func (a *Apps) ReadAll(ctx context.Context, ids ...int64) ([]App, error) {
-	validIDs := make([]int64, 0, len(ids))
+	allowed, all, err := a.auth.AppIDs(ctx, rbac.PermAppRead)
+	if err != nil {
+		return nil, err
+	}
+
+	allowedIDs := make(map[int64]bool, len(allowed))
+	for _, id := range allowed {
+		allowedIDs[id] = true
+	}
 
+	validIDs := make([]int64, 0, len(ids))
 	for _, id := range ids {
-		if id < 1 {
+		if id < 1 || !(all || allowedIDs[id]) {
 			continue
 		}
 		validIDs = append(validIDs, id)
@@ -90,14 +211,41 @@ func (a *Apps) ReadAll(ctx context.Context, ids ...int64) ([]App, error) {
 
 	apps, err := a.store.ReadAll(ctx, validIDs...)
 	if err != nil {
-		a.appCtx.Logger.Error(err)
-		return nil, ErrUnexpected
+		return nil, a.storeErr(err)
 	}
 	return apps, nil
 }
 
+// List lists the apps which the caller can read, i.e. the apps granted to the caller directly,
+// through their teams, or owned by the organisations they administer. It also returns the total
+// number of matching apps (ignoring offset & limit)
+func (a *Apps) List(ctx context.Context, filter ListFilter) ([]App, int64, error) {
+	filter.sanitize()
+
+	ids, all, err := a.auth.AppIDs(ctx, rbac.PermAppRead)
+	if err != nil {
+		return nil, 0, err
+	}
+	filter.ids, filter.all = ids, all
+
+	apps, total, err := a.store.List(ctx, filter)
+	if err != nil {
+		return nil, 0, a.storeErr(err)
+	}
+	return apps, total, nil
+}
+
 // Update updates the details of the app in the store
 func (a *Apps) Update(ctx context.Context, app App) (*App, error) {
+	if app.ID < 1 {
+		return nil, ErrInvalidID
+	}
+
+	err := a.auth.Authorize(ctx, rbac.PermAppUpdate, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now().UTC()
 	app.UpdatedAt = &now
 
@@ -116,16 +264,33 @@ func (a *Apps) Update(ctx context.Context, app App) (*App, error) {
 		app.Description = oldApp.Description
 	}
 
+	if app.TOTP == nil {
+		app.TOTP = oldApp.TOTP
+	}
+
+	err = validateTOTP(app.TOTP)
+	if err != nil {
+		return nil, err
+	}
+
 	updatedApp, err := a.store.Update(ctx, app)
 	if err != nil {
-		a.appCtx.Logger.Error(err)
-		return nil, ErrUnexpected
+		return nil, a.storeErr(err)
 	}
 	return updatedApp, nil
 }
 
 // Delete deletes the app from the store
 func (a *Apps) Delete(ctx context.Context, app App) (*App, error) {
+	if app.ID < 1 {
+		return nil, ErrInvalidID
+	}
+
+	err := a.auth.Authorize(ctx, rbac.PermAppDelete, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	existingApp, err := a.Read(ctx, app.ID)
 	if err != nil {
 		return nil, err
@@ -133,54 +298,75 @@ func (a *Apps) Delete(ctx context.Context, app App) (*App, error) {
 
 	err = a.store.Delete(ctx, *existingApp)
 	if err != nil {
-		a.appCtx.Logger.Error(err)
-		return nil, ErrUnexpected
+		return nil, a.storeErr(err)
 	}
 	return existingApp, nil
 }
 
-func (a *Apps) SetOwner(ctx context.Context, app App, u users.User) (*App, error) {
-	if u.Validate() != nil {
-		return nil, ErrInvalidOwner
+// Owners lists the users who were made owners of the app directly
+func (a *Apps) Owners(ctx context.Context, id int64) ([]Owner, error) {
+	if id < 1 {
+		return nil, ErrInvalidID
 	}
-	ap, err := a.store.SetOwner(ctx, app, u)
+
+	err := a.auth.Authorize(ctx, rbac.PermAppRead, id)
 	if err != nil {
-		a.appCtx.Logger.Error(err)
-		return nil, ErrUnexpected
+		return nil, err
+	}
+
+	list, err := a.store.Owners(ctx, id)
+	if err != nil {
+		return nil, a.storeErr(err)
 	}
-	return ap, err
+	return list, nil
 }
 
-func (a *Apps) CreateAndSetOwner(ctx context.Context, app App, u users.User) (*App, error) {
-	now := time.Now().UTC()
-	app.CreatedAt = &now
-	// resetting ID, since it should ideally be ignored while creating/registering a new application
-	app.ID = 0
-	app.Name = strings.TrimSpace(app.Name)
-	if app.Name == "" {
-		return nil, ErrInvalidName
+// RemoveOwner removes the user from the direct owners of the app
+func (a *Apps) RemoveOwner(ctx context.Context, id, userID int64) error {
+	if id < 1 {
+		return ErrInvalidID
 	}
 
-	if u.Validate() != nil {
-		return nil, ErrInvalidOwner
+	err := a.auth.Authorize(ctx, rbac.PermAppMembers, id)
+	if err != nil {
+		return err
 	}
 
-	ap, err := a.store.CreateAndSetOwner(ctx, app, u)
+	err = a.store.RemoveOwner(ctx, id, userID)
 	if err != nil {
-		a.appCtx.Logger.Error(err)
-		return nil, ErrUnexpected
+		return a.storeErr(err)
 	}
+	return nil
+}
 
+// SetOwner makes the user an owner of the app, in addition to the owners & admins of the
+// organisation which owns the app
+func (a *Apps) SetOwner(ctx context.Context, app App, u users.User) (*App, error) {
+	if app.ID < 1 {
+		return nil, ErrInvalidID
+	}
+
+	if u.ID < 1 {
+		return nil, ErrInvalidUser
+	}
+
+	err := a.auth.Authorize(ctx, rbac.PermAppMembers, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ap, err := a.store.SetOwner(ctx, app, u)
+	if err != nil {
+		return nil, a.storeErr(err)
+	}
 	return ap, nil
 }
 
-func New(appCtx *appcontext.AppContext, sdb *sql.DB) *Apps {
-	dbs := &dbStore{
-		db:     sdb,
-		appCtx: appCtx,
-	}
+func New(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB, auth Authorizer, orgAuth OrgAuthorizer) *Apps {
 	return &Apps{
-		appCtx: appCtx,
-		store:  dbs,
+		appCtx:  appCtx,
+		store:   newStore(appCtx, driver, sdb),
+		auth:    auth,
+		orgAuth: orgAuth,
 	}
 }
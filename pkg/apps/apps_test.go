@@ -0,0 +1,67 @@
+package apps
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// fakeAuth allows everything, and records the app IDs authorized
+type fakeAuth struct {
+	authorized []int64
+}
+
+func (fa *fakeAuth) Authorize(ctx context.Context, perm rbac.Permission, appID int64) error {
+	fa.authorized = append(fa.authorized, appID)
+	return nil
+}
+
+func (fa *fakeAuth) AppIDs(ctx context.Context, perm rbac.Permission) ([]int64, bool, error) {
+	return nil, true, nil
+}
+
+func TestInvalidID(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		auth := &fakeAuth{}
+		a := &Apps{appCtx: appcontext.New(logger.New()), store: f.st, auth: auth}
+
+		calls := map[string]func() error{
+			"update": func() error {
+				_, err := a.Update(ctx, App{Name: "billing"})
+				return err
+			},
+			"delete": func() error {
+				_, err := a.Delete(ctx, App{})
+				return err
+			},
+			"owners": func() error {
+				_, err := a.Owners(ctx, 0)
+				return err
+			},
+			"set owner": func() error {
+				_, err := a.SetOwner(ctx, App{}, users.User{ID: 1})
+				return err
+			},
+			"remove owner": func() error {
+				return a.RemoveOwner(ctx, 0, 1)
+			},
+		}
+		for name, call := range calls {
+			err := call()
+			if !errors.Is(err, ErrInvalidID) {
+				t.Fatalf("%s: expected ErrInvalidID, got %v", name, err)
+			}
+		}
+
+		// the platform wide permissions (app ID 0) should never be checked for an app
+		if len(auth.authorized) != 0 {
+			t.Fatalf("expected no authorization, got %v", auth.authorized)
+		}
+	})
+}
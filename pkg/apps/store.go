@@ -8,23 +8,46 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
+
 	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/totp"
 	"github.com/bnkamalesh/padlock/pkg/users"
 )
 
 const (
-	appTable      = "applications"
-	appOwnerTable = "applicationOwners"
+	appTable = "applications"
+	// tables of the other packages, with rows referring to an application
+	teamAppsTable = "teamApplications"
+	invitesTable  = "invitations"
+	apiKeysTable  = "apiKeys"
+	subjectsTable = "subjects"
+	webhooksTable = "webhookEndpoints"
+
+	// pqUniqueViolation is the Postgres error code for unique constraint violation
+	pqUniqueViolation = "23505"
+
+	appColumns = "id,name,description,orgid,totp,createdat,updatedat"
 )
 
 type store interface {
 	Create(ctx context.Context, app App) (*App, error)
 	Read(ctx context.Context, id int64) (*App, error)
 	ReadAll(ctx context.Context, ids ...int64) ([]App, error)
+	List(ctx context.Context, filter ListFilter) ([]App, int64, error)
 	Update(ctx context.Context, app App) (*App, error)
 	Delete(ctx context.Context, app App) error
 	SetOwner(ctx context.Context, app App, u users.User) (*App, error)
-	CreateAndSetOwner(ctx context.Context, app App, u users.User) (*App, error)
+	Owners(ctx context.Context, appID int64) ([]Owner, error)
+	RemoveOwner(ctx context.Context, appID, userID int64) error
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
 }
 
 type dbStore struct {
@@ -32,6 +55,20 @@ type dbStore struct {
 	db     *sql.DB
 }
 
+// newStore returns the store of the driver
+func newStore(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB) store {
+	if driver == database.SQLite {
+		return &sqliteStore{
+			appCtx: appCtx,
+			db:     sdb,
+		}
+	}
+	return &dbStore{
+		appCtx: appCtx,
+		db:     sdb,
+	}
+}
+
 func (dbs *dbStore) rollback(tx *sql.Tx) {
 	err := tx.Rollback()
 	if err != nil && err != sql.ErrTxDone {
@@ -39,6 +76,11 @@ func (dbs *dbStore) rollback(tx *sql.Tx) {
 	}
 }
 
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == pqUniqueViolation
+}
+
 func (dbs *dbStore) prepColVals(cols ...string) string {
 	count := len(cols)
 	if count == 0 {
@@ -53,57 +95,172 @@ func (dbs *dbStore) prepColVals(cols ...string) string {
 	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
 }
 
+func (dbs *dbStore) scan(row scanner) (*App, error) {
+	app := App{}
+	description := sql.NullString{}
+	totpJSON := []byte{}
+	createdAt := pq.NullTime{}
+	updatedAt := pq.NullTime{}
+
+	err := row.Scan(
+		&app.ID,
+		&app.Name,
+		&description,
+		&app.OrgID,
+		&totpJSON,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.Wrap(ErrNotFound, err)
+		}
+		return nil, err
+	}
+
+	app.Description = description.String
+	if len(totpJSON) > 0 && string(totpJSON) != "null" {
+		app.TOTP = &totp.TOTP{}
+		err = json.Unmarshal(totpJSON, app.TOTP)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if createdAt.Valid {
+		app.CreatedAt = &createdAt.Time
+	}
+	if updatedAt.Valid {
+		app.UpdatedAt = &updatedAt.Time
+	}
+
+	return &app, nil
+}
+
+func (dbs *dbStore) list(ctx context.Context, stmt string, args ...interface{}) ([]App, error) {
+	rows, err := dbs.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]App, 0)
+	for rows.Next() {
+		app, err := dbs.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *app)
+	}
+
+	return list, rows.Err()
+}
+
 func (dbs *dbStore) Create(ctx context.Context, app App) (*App, error) {
 	stmt := fmt.Sprintf(
 		"INSERT INTO %s %s RETURNING id",
 		appTable,
-		dbs.prepColVals("name", "description", "totp", "createdAt", "updatedAt"),
+		dbs.prepColVals("name", "description", "orgid", "totp", "createdAt", "updatedAt"),
 	)
 
-	result := dbs.db.QueryRow(
+	b, err := json.Marshal(app.TOTP)
+	if err != nil {
+		return nil, err
+	}
+
+	result := dbs.db.QueryRowContext(
+		ctx,
 		stmt,
 		app.Name,
 		app.Description,
-		app.TOTP,
+		app.OrgID,
+		string(b),
 		app.CreatedAt,
 		app.UpdatedAt,
 	)
-	err := result.Scan(&app.ID)
+	err = result.Scan(&app.ID)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, apperr.Wrap(ErrNameExists, err)
+		}
 		return nil, err
 	}
-	fmt.Println("app.ID=", app.ID)
+
 	return &app, nil
 }
 
 func (dbs *dbStore) Read(ctx context.Context, id int64) (*App, error) {
-	return nil, nil
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=$1", appColumns, appTable)
+	return dbs.scan(dbs.db.QueryRowContext(ctx, stmt, id))
 }
+
 func (dbs *dbStore) ReadAll(ctx context.Context, ids ...int64) ([]App, error) {
-	return nil, nil
-}
-func (dbs *dbStore) Update(ctx context.Context, app App) (*App, error) {
-	return nil, nil
-}
-func (dbs *dbStore) Delete(ctx context.Context, app App) error {
-	return nil
+	if len(ids) == 0 {
+		return []App{}, nil
+	}
+
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id = ANY($1) ORDER BY name", appColumns, appTable)
+	return dbs.list(ctx, stmt, pq.Array(ids))
 }
 
-func (dbs *dbStore) SetOwner(ctx context.Context, app App, u users.User) (*App, error) {
-	return &app, nil
+func (dbs *dbStore) listConditions(filter ListFilter) (string, []interface{}) {
+	conds := []string{"TRUE"}
+	args := make([]interface{}, 0, 3)
+
+	if !filter.all {
+		args = append(args, pq.Array(filter.ids))
+		conds = append(conds, fmt.Sprintf("id = ANY($%d)", len(args)))
+	}
+
+	if filter.OrgID > 0 {
+		args = append(args, filter.OrgID)
+		conds = append(conds, fmt.Sprintf("orgid=$%d", len(args)))
+	}
+
+	if filter.Name != "" {
+		args = append(args, database.Contains(filter.Name))
+		conds = append(conds, fmt.Sprintf(`name ILIKE $%d ESCAPE '\'`, len(args)))
+	}
+
+	return strings.Join(conds, " AND "), args
 }
 
-func (dbs *dbStore) CreateAndSetOwner(ctx context.Context, app App, u users.User) (*App, error) {
-	tx, err := dbs.db.Begin()
+func (dbs *dbStore) List(ctx context.Context, filter ListFilter) ([]App, int64, error) {
+	where, args := dbs.listConditions(filter)
+
+	total := int64(0)
+	stmt := fmt.Sprintf("SELECT COUNT(id) FROM %s WHERE %s", appTable, where)
+	err := dbs.db.QueryRowContext(ctx, stmt, args...).Scan(&total)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	defer dbs.rollback(tx)
 
+	if total == 0 {
+		return []App{}, 0, nil
+	}
+
+	stmt = fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s ORDER BY id LIMIT $%d OFFSET $%d",
+		appColumns,
+		appTable,
+		where,
+		len(args)+1,
+		len(args)+2,
+	)
+	args = append(args, filter.Limit, filter.Offset)
+
+	list, err := dbs.list(ctx, stmt, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return list, total, nil
+}
+
+func (dbs *dbStore) Update(ctx context.Context, app App) (*App, error) {
 	stmt := fmt.Sprintf(
-		"INSERT INTO %s %s RETURNING id",
+		"UPDATE %s SET name=$1, description=$2, totp=$3, updatedat=$4 WHERE id=$5 RETURNING %s",
 		appTable,
-		dbs.prepColVals("name", "description", "totp", "createdAt", "updatedAt"),
+		appColumns,
 	)
 
 	b, err := json.Marshal(app.TOTP)
@@ -111,40 +268,125 @@ func (dbs *dbStore) CreateAndSetOwner(ctx context.Context, app App, u users.User
 		return nil, err
 	}
 
-	result := tx.QueryRow(
+	updated, err := dbs.scan(dbs.db.QueryRowContext(
+		ctx,
 		stmt,
 		app.Name,
 		app.Description,
 		string(b),
-		app.CreatedAt,
 		app.UpdatedAt,
-	)
-	err = result.Scan(&app.ID)
+		app.ID,
+	))
 	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, apperr.Wrap(ErrNameExists, err)
+		}
 		return nil, err
 	}
 
-	stmt = fmt.Sprintf(
-		"INSERT INTO %s %s",
-		appOwnerTable,
-		dbs.prepColVals("appid", "userid", "createdat"),
-	)
+	return updated, nil
+}
 
-	now := time.Now()
-	_, err = tx.Exec(
-		stmt,
-		app.ID,
-		u.ID,
-		now,
+// Delete deletes the app, along with its owners, team grants, invitations, API keys, enrolled
+// subjects & webhooks
+func (dbs *dbStore) Delete(ctx context.Context, app App) error {
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer dbs.rollback(tx)
+
+	for _, table := range []string{rbac.AssignmentsTable, teamAppsTable, invitesTable, apiKeysTable, subjectsTable, webhooksTable} {
+		stmt := fmt.Sprintf("DELETE FROM %s WHERE appid=$1", table)
+		_, err = tx.ExecContext(ctx, stmt, app.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=$1", appTable)
+	result, err := tx.ExecContext(ctx, stmt, app.ID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	return tx.Commit()
+}
+
+// SetOwner assigns the owner role of the app to the user, if not already assigned
+func (dbs *dbStore) SetOwner(ctx context.Context, app App, u users.User) (*App, error) {
+	stmt := fmt.Sprintf(
+		`INSERT INTO %s (userid, role, appid, createdat)
+		SELECT $1, $2, id, $3 FROM %s WHERE id=$4
+		ON CONFLICT (userid, role, appid) DO NOTHING`,
+		rbac.AssignmentsTable,
+		appTable,
 	)
+
+	_, err := dbs.db.ExecContext(ctx, stmt, u.ID, rbac.RoleOwner, time.Now().UTC(), app.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	err = tx.Commit()
+	return dbs.Read(ctx, app.ID)
+}
+
+func (dbs *dbStore) Owners(ctx context.Context, appID int64) ([]Owner, error) {
+	stmt := fmt.Sprintf(
+		"SELECT userid,createdat FROM %s WHERE appid=$1 AND role=$2 ORDER BY id",
+		rbac.AssignmentsTable,
+	)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, appID, rbac.RoleOwner)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &app, nil
+	list := make([]Owner, 0)
+	for rows.Next() {
+		o := Owner{}
+		createdAt := pq.NullTime{}
+		err := rows.Scan(&o.UserID, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		if createdAt.Valid {
+			o.CreatedAt = &createdAt.Time
+		}
+		list = append(list, o)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) RemoveOwner(ctx context.Context, appID, userID int64) error {
+	stmt := fmt.Sprintf(
+		"DELETE FROM %s WHERE appid=$1 AND userid=$2 AND role=$3",
+		rbac.AssignmentsTable,
+	)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, appID, userID, rbac.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrOwnerNotFound
+	}
+	return nil
 }
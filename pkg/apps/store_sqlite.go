@@ -0,0 +1,396 @@
+package apps
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/totp"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// sqliteStore is the store of SQLite, it behaves exactly like dbStore. SQLite (as bundled) does
+// not support RETURNING, so the rows are read again after writing them
+type sqliteStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func isSQLiteUniqueViolation(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// sqliteTime returns the time in UTC truncated to seconds, as stored by Postgres. SQLite stores
+// the times as text, which are compared correctly only if they're all in the same time zone
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Truncate(time.Second)
+}
+
+// sqliteIn returns the condition matching the column with any of the IDs, since SQLite does
+// not support arrays
+func sqliteIn(col string, ids []int64) (string, []interface{}) {
+	if len(ids) == 0 {
+		return "FALSE", nil
+	}
+
+	vals := make([]string, 0, len(ids))
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		vals = append(vals, "?")
+		args = append(args, id)
+	}
+	return fmt.Sprintf("%s IN (%s)", col, strings.Join(vals, ", ")), args
+}
+
+func (ss *sqliteStore) rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && err != sql.ErrTxDone {
+		ss.appCtx.Logger.Error(err)
+	}
+}
+
+func (ss *sqliteStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, "?")
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func (ss *sqliteStore) scan(row scanner) (*App, error) {
+	app := App{}
+	description := sql.NullString{}
+	totpJSON := []byte{}
+
+	err := row.Scan(
+		&app.ID,
+		&app.Name,
+		&description,
+		&app.OrgID,
+		&totpJSON,
+		&app.CreatedAt,
+		&app.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.Wrap(ErrNotFound, err)
+		}
+		return nil, err
+	}
+
+	app.Description = description.String
+	if len(totpJSON) > 0 && string(totpJSON) != "null" {
+		app.TOTP = &totp.TOTP{}
+		err = json.Unmarshal(totpJSON, app.TOTP)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &app, nil
+}
+
+func (ss *sqliteStore) list(ctx context.Context, stmt string, args ...interface{}) ([]App, error) {
+	rows, err := ss.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]App, 0)
+	for rows.Next() {
+		app, err := ss.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *app)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) Create(ctx context.Context, app App) (*App, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		appTable,
+		ss.prepColVals("name", "description", "orgid", "totp", "createdAt", "updatedAt"),
+	)
+
+	b, err := json.Marshal(app.TOTP)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ss.db.ExecContext(
+		ctx,
+		stmt,
+		app.Name,
+		app.Description,
+		app.OrgID,
+		string(b),
+		sqliteTime(app.CreatedAt),
+		sqliteTime(app.UpdatedAt),
+	)
+	if err != nil {
+		if isSQLiteUniqueViolation(err) {
+			return nil, apperr.Wrap(ErrNameExists, err)
+		}
+		return nil, err
+	}
+
+	app.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &app, nil
+}
+
+func (ss *sqliteStore) read(ctx context.Context, q queryer, id int64) (*App, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=?", appColumns, appTable)
+	return ss.scan(q.QueryRowContext(ctx, stmt, id))
+}
+
+func (ss *sqliteStore) Read(ctx context.Context, id int64) (*App, error) {
+	return ss.read(ctx, ss.db, id)
+}
+
+func (ss *sqliteStore) ReadAll(ctx context.Context, ids ...int64) ([]App, error) {
+	if len(ids) == 0 {
+		return []App{}, nil
+	}
+
+	cond, args := sqliteIn("id", ids)
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY name", appColumns, appTable, cond)
+	return ss.list(ctx, stmt, args...)
+}
+
+// listConditions prepares the WHERE clause & its arguments, for the given filter. LIKE of
+// SQLite is case insensitive (for ASCII), like ILIKE of Postgres
+func (ss *sqliteStore) listConditions(filter ListFilter) (string, []interface{}) {
+	conds := []string{"TRUE"}
+	args := make([]interface{}, 0, 3)
+
+	if !filter.all {
+		cond, ids := sqliteIn("id", filter.ids)
+		args = append(args, ids...)
+		conds = append(conds, cond)
+	}
+
+	if filter.OrgID > 0 {
+		args = append(args, filter.OrgID)
+		conds = append(conds, "orgid=?")
+	}
+
+	if filter.Name != "" {
+		args = append(args, database.Contains(filter.Name))
+		conds = append(conds, `name LIKE ? ESCAPE '\'`)
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+func (ss *sqliteStore) List(ctx context.Context, filter ListFilter) ([]App, int64, error) {
+	where, args := ss.listConditions(filter)
+
+	total := int64(0)
+	stmt := fmt.Sprintf("SELECT COUNT(id) FROM %s WHERE %s", appTable, where)
+	err := ss.db.QueryRowContext(ctx, stmt, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if total == 0 {
+		return []App{}, 0, nil
+	}
+
+	stmt = fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s ORDER BY id LIMIT ? OFFSET ?",
+		appColumns,
+		appTable,
+		where,
+	)
+	args = append(args, filter.Limit, filter.Offset)
+
+	list, err := ss.list(ctx, stmt, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return list, total, nil
+}
+
+func (ss *sqliteStore) Update(ctx context.Context, app App) (*App, error) {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET name=?, description=?, totp=?, updatedat=? WHERE id=?",
+		appTable,
+	)
+
+	b, err := json.Marshal(app.TOTP)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ss.rollback(tx)
+
+	result, err := tx.ExecContext(
+		ctx,
+		stmt,
+		app.Name,
+		app.Description,
+		string(b),
+		sqliteTime(app.UpdatedAt),
+		app.ID,
+	)
+	if err != nil {
+		if isSQLiteUniqueViolation(err) {
+			return nil, apperr.Wrap(ErrNameExists, err)
+		}
+		return nil, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, ErrNotFound
+	}
+
+	updated, err := ss.read(ctx, tx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, tx.Commit()
+}
+
+// Delete deletes the app, along with its owners, team grants, invitations, API keys, enrolled
+// subjects & webhooks
+func (ss *sqliteStore) Delete(ctx context.Context, app App) error {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer ss.rollback(tx)
+
+	for _, table := range []string{rbac.AssignmentsTable, teamAppsTable, invitesTable, apiKeysTable, subjectsTable, webhooksTable} {
+		stmt := fmt.Sprintf("DELETE FROM %s WHERE appid=?", table)
+		_, err = tx.ExecContext(ctx, stmt, app.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=?", appTable)
+	result, err := tx.ExecContext(ctx, stmt, app.ID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	return tx.Commit()
+}
+
+// SetOwner assigns the owner role of the app to the user, if not already assigned
+func (ss *sqliteStore) SetOwner(ctx context.Context, app App, u users.User) (*App, error) {
+	stmt := fmt.Sprintf(
+		`INSERT INTO %s (userid, role, appid, createdat)
+		SELECT ?, ?, id, ? FROM %s WHERE id=?
+		ON CONFLICT (userid, role, appid) DO NOTHING`,
+		rbac.AssignmentsTable,
+		appTable,
+	)
+
+	now := time.Now()
+	_, err := ss.db.ExecContext(ctx, stmt, u.ID, rbac.RoleOwner, sqliteTime(&now), app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ss.Read(ctx, app.ID)
+}
+
+func (ss *sqliteStore) Owners(ctx context.Context, appID int64) ([]Owner, error) {
+	stmt := fmt.Sprintf(
+		"SELECT userid,createdat FROM %s WHERE appid=? AND role=? ORDER BY id",
+		rbac.AssignmentsTable,
+	)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, appID, rbac.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Owner, 0)
+	for rows.Next() {
+		o := Owner{}
+		err := rows.Scan(&o.UserID, &o.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, o)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) RemoveOwner(ctx context.Context, appID, userID int64) error {
+	stmt := fmt.Sprintf(
+		"DELETE FROM %s WHERE appid=? AND userid=? AND role=?",
+		rbac.AssignmentsTable,
+	)
+
+	result, err := ss.db.ExecContext(ctx, stmt, appID, userID, rbac.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrOwnerNotFound
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package apps
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/totp"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+type fixture struct {
+	st     store
+	driver database.Driver
+	db     *sql.DB
+	orgID  int64
+}
+
+func testStore(t *testing.T, fn func(t *testing.T, f fixture)) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		fn(t, fixture{
+			st:     newStore(appcontext.New(logger.New()), driver, db),
+			driver: driver,
+			db:     db,
+			orgID:  databasetest.Org(t, driver, db, "acme"),
+		})
+	})
+}
+
+func (f fixture) create(t *testing.T, name string) *App {
+	t.Helper()
+
+	now := time.Now()
+	app, err := f.st.Create(context.Background(), App{
+		Name:      name,
+		OrgID:     f.orgID,
+		TOTP:      totp.New(name, 6, 30, totp.AlgoSHA1),
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func TestStoreCreateRead(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		created := f.create(t, "billing")
+
+		app, err := f.st.Read(ctx, created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if app.Name != "billing" || app.OrgID != f.orgID || app.TOTP == nil || app.TOTP.Digits != 6 {
+			t.Fatalf("expected %+v, got %+v", created, app)
+		}
+
+		now := time.Now()
+		_, err = f.st.Create(ctx, App{Name: "billing", OrgID: f.orgID, CreatedAt: &now})
+		if !errors.Is(err, ErrNameExists) {
+			t.Fatalf("expected ErrNameExists, got %v", err)
+		}
+
+		_, err = f.st.Read(ctx, created.ID+1)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStoreList(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		billing := f.create(t, "billing")
+		shipping := f.create(t, "shipping")
+		f.create(t, "hidden")
+
+		list, err := f.st.ReadAll(ctx, shipping.ID, billing.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != billing.ID || list[1].ID != shipping.ID {
+			t.Fatalf("expected billing & shipping ordered by name, got %+v", list)
+		}
+
+		tests := []struct {
+			name   string
+			filter ListFilter
+			names  []string
+			total  int64
+		}{
+			{
+				name:   "all",
+				filter: ListFilter{Limit: 10, all: true},
+				names:  []string{"billing", "shipping", "hidden"},
+				total:  3,
+			},
+			{
+				name:   "accessible",
+				filter: ListFilter{Limit: 10, ids: []int64{billing.ID, shipping.ID}},
+				names:  []string{"billing", "shipping"},
+				total:  2,
+			},
+			{
+				name:   "none accessible",
+				filter: ListFilter{Limit: 10},
+				names:  []string{},
+				total:  0,
+			},
+			{
+				name:   "name, case insensitive",
+				filter: ListFilter{Name: "SHIP", Limit: 10, all: true},
+				names:  []string{"shipping"},
+				total:  1,
+			},
+			{
+				name:   "name, wildcards are literal",
+				filter: ListFilter{Name: "b_lling%", Limit: 10, all: true},
+				names:  []string{},
+				total:  0,
+			},
+			{
+				name:   "other organisation",
+				filter: ListFilter{OrgID: f.orgID + 1, Limit: 10, all: true},
+				names:  []string{},
+				total:  0,
+			},
+			{
+				name:   "paginated",
+				filter: ListFilter{Offset: 1, Limit: 1, all: true},
+				names:  []string{"shipping"},
+				total:  3,
+			},
+		}
+
+		for _, tt := range tests {
+			list, total, err := f.st.List(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if total != tt.total || len(list) != len(tt.names) {
+				t.Fatalf("%s: expected %d of %d apps, got %d of %d", tt.name, len(tt.names), tt.total, len(list), total)
+			}
+			for i, app := range list {
+				if app.Name != tt.names[i] {
+					t.Fatalf("%s: expected %s, got %s", tt.name, tt.names[i], app.Name)
+				}
+			}
+		}
+	})
+}
+
+func TestStoreUpdate(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		app := f.create(t, "billing")
+		f.create(t, "shipping")
+
+		app.Description = "invoices & payments"
+		app.TOTP = totp.New("billing", 8, 60, totp.AlgoSHA256)
+		updated, err := f.st.Update(ctx, *app)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Description != app.Description || updated.TOTP.Digits != 8 || updated.TOTP.Algorithm != totp.AlgoSHA256 {
+			t.Fatalf("expected %+v, got %+v", app, updated)
+		}
+
+		app.Name = "shipping"
+		_, err = f.st.Update(ctx, *app)
+		if !errors.Is(err, ErrNameExists) {
+			t.Fatalf("expected ErrNameExists, got %v", err)
+		}
+
+		app.ID = 0
+		_, err = f.st.Update(ctx, *app)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStoreOwners(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		app := f.create(t, "billing")
+		userID := databasetest.User(t, f.driver, f.db, "jane@example.com")
+
+		for i := 0; i < 2; i++ {
+			// setting an existing owner again is a no-op
+			_, err := f.st.SetOwner(ctx, *app, users.User{ID: userID})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		owners, err := f.st.Owners(ctx, app.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(owners) != 1 || owners[0].UserID != userID {
+			t.Fatalf("expected user %d as the only owner, got %+v", userID, owners)
+		}
+
+		err = f.st.RemoveOwner(ctx, app.ID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.st.RemoveOwner(ctx, app.ID, userID)
+		if !errors.Is(err, ErrOwnerNotFound) {
+			t.Fatalf("expected ErrOwnerNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStoreDelete(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		app := f.create(t, "billing")
+		userID := databasetest.User(t, f.driver, f.db, "jane@example.com")
+		_, err := f.st.SetOwner(ctx, *app, users.User{ID: userID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.st.Delete(ctx, *app)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = f.st.Read(ctx, app.ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		owners, err := f.st.Owners(ctx, app.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(owners) != 0 {
+			t.Fatalf("expected the owners to be deleted, got %+v", owners)
+		}
+
+		err = f.st.Delete(ctx, *app)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
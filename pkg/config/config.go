@@ -0,0 +1,305 @@
+// Package config has the configuration of Padlock. It's loaded from a YAML/TOML file,
+// environment variables & flags, in the increasing order of precedence
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config has all the configurations required to run Padlock. The 'secret' fields can be read
+// from files as well (refer Loader), and are redacted when printed
+type Config struct {
+	// Logging enables the logs, Debug enables the debug logs & the access log
+	Logging bool `yaml:"logging" toml:"logging" help:"enable logging"`
+	Debug   bool `yaml:"debug" toml:"debug" help:"enable debug logs & the access log"`
+	// BaseURL is the public URL of Padlock, used for generating links sent to users
+	BaseURL string `yaml:"baseUrl" toml:"baseUrl" help:"public URL of Padlock, used in the links sent to users"`
+	// AutoMigrate applies the pending database migrations before the servers are started
+	AutoMigrate bool `yaml:"autoMigrate" toml:"autoMigrate" help:"apply the pending database migrations on serve"`
+
+	HTTP     HTTP     `yaml:"http" toml:"http"`
+	GRPC     GRPC     `yaml:"grpc" toml:"grpc"`
+	Database Database `yaml:"database" toml:"database"`
+	Postgres Postgres `yaml:"postgres" toml:"postgres"`
+	SQLite   SQLite   `yaml:"sqlite" toml:"sqlite"`
+	Cache    Cache    `yaml:"cache" toml:"cache"`
+	Invites  Invites  `yaml:"invites" toml:"invites"`
+	WebAuthn WebAuthn `yaml:"webauthn" toml:"webauthn"`
+	Phone    Phone    `yaml:"phone" toml:"phone"`
+	Push     Push     `yaml:"push" toml:"push"`
+	Shutdown Shutdown `yaml:"shutdown" toml:"shutdown"`
+}
+
+type HTTP struct {
+	Host string `yaml:"host" toml:"host" help:"HTTP listen host"`
+	Port string `yaml:"port" toml:"port" help:"HTTP listen port"`
+	// AppDomain is the domain for which the session cookies are set
+	AppDomain string `yaml:"appDomain" toml:"appDomain" help:"domain of the session cookies"`
+}
+
+type GRPC struct {
+	Host string `yaml:"host" toml:"host" help:"gRPC listen host"`
+	Port string `yaml:"port" toml:"port" help:"gRPC listen port"`
+}
+
+type Database struct {
+	// Driver is the database used, postgres or sqlite. SQLite is meant for single node
+	// deployments & tests
+	Driver string `yaml:"driver" toml:"driver" help:"database, postgres or sqlite"`
+}
+
+type Postgres struct {
+	Host     string `yaml:"host" toml:"host" help:"Postgres host"`
+	Port     string `yaml:"port" toml:"port" help:"Postgres port"`
+	DBName   string `yaml:"dbName" toml:"dbName" help:"Postgres database name"`
+	Username string `yaml:"username" toml:"username" help:"Postgres username"`
+	Password string `yaml:"password" toml:"password" secret:"true" help:"Postgres password"`
+	// SSLMode is one of disable, require, verify-ca & verify-full
+	SSLMode string `yaml:"sslMode" toml:"sslMode" help:"Postgres SSL mode (disable, require, verify-ca, verify-full)"`
+}
+
+type SQLite struct {
+	// Path is the path of the database file, it's created if it does not exist
+	Path string `yaml:"path" toml:"path" help:"path of the SQLite database file"`
+}
+
+type Cache struct {
+	// Hosts are the addresses (host:port) of the Redis servers
+	Hosts        []string      `yaml:"hosts" toml:"hosts" help:"comma separated Redis addresses (host:port)"`
+	DB           int           `yaml:"db" toml:"db" help:"Redis database number"`
+	Password     string        `yaml:"password" toml:"password" secret:"true" help:"Redis password"`
+	DialTimeout  time.Duration `yaml:"dialTimeout" toml:"dialTimeout" help:"Redis dial timeout"`
+	ReadTimeout  time.Duration `yaml:"readTimeout" toml:"readTimeout" help:"Redis read timeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout" toml:"writeTimeout" help:"Redis write timeout"`
+}
+
+type Invites struct {
+	// Secret is used to sign the invitation tokens
+	Secret   string        `yaml:"secret" toml:"secret" secret:"true" help:"secret used to sign the invitation tokens"`
+	Validity time.Duration `yaml:"validity" toml:"validity" help:"duration for which an invitation is valid"`
+}
+
+type WebAuthn struct {
+	RPID   string `yaml:"rpId" toml:"rpId" help:"WebAuthn relying party ID, i.e. the domain"`
+	RPName string `yaml:"rpName" toml:"rpName" help:"WebAuthn relying party name"`
+	// Origins are the origins allowed for the WebAuthn ceremonies, it defaults to BaseURL
+	Origins []string `yaml:"origins" toml:"origins" help:"comma separated origins allowed for WebAuthn (default is the base URL)"`
+}
+
+type Phone struct {
+	// DefaultCountryCode is the country code (e.g. "+1") of the phone numbers provided without
+	// one. Unless it's set, the phone numbers should be in E.164 format (e.g. "+15555550100")
+	DefaultCountryCode string `yaml:"defaultCountryCode" toml:"defaultCountryCode" help:"country code (e.g. +1) of the phone numbers provided without one"`
+}
+
+type Push struct {
+	// Digits is the number of digits of the number matched while approving a sign in
+	Digits int `yaml:"digits" toml:"digits" help:"number of digits of the number matched while approving a sign in"`
+	// Choices is the number of choices shown on the device, 0 requires the user to type the
+	// number instead
+	Choices int `yaml:"choices" toml:"choices" help:"number of choices shown on the device, 0 to type the number instead"`
+}
+
+type Shutdown struct {
+	// Delay is the duration for which the servers are marked as draining before shutting
+	// them down, so that the load balancers stop sending new requests
+	Delay time.Duration `yaml:"delay" toml:"delay" help:"duration for which the servers drain before shutting down"`
+	// Timeout is the maximum duration to wait for the in-flight requests
+	Timeout time.Duration `yaml:"timeout" toml:"timeout" help:"maximum duration to wait for the in-flight requests while shutting down"`
+}
+
+const minSecretLength = 16
+
+var (
+	sslModes = []string{"disable", "require", "verify-ca", "verify-full"}
+	drivers  = []string{"postgres", "sqlite"}
+
+	countryCodeRegex = regexp.MustCompile(`^\+[1-9][0-9]{0,3}$`)
+)
+
+// Default returns the default configuration, the secrets & the Postgres credentials have no
+// defaults and should be provided
+func Default() *Config {
+	return &Config{
+		Logging: true,
+		BaseURL: "http://localhost:8080",
+		HTTP: HTTP{
+			Port:      "8080",
+			AppDomain: "localhost",
+		},
+		GRPC: GRPC{
+			Port: "8081",
+		},
+		Database: Database{
+			Driver: "postgres",
+		},
+		Postgres: Postgres{
+			Host:    "127.0.0.1",
+			Port:    "5432",
+			DBName:  "padlock",
+			SSLMode: "disable",
+		},
+		SQLite: SQLite{
+			Path: "padlock.db",
+		},
+		Cache: Cache{
+			Hosts:        []string{"127.0.0.1:6379"},
+			DialTimeout:  time.Second * 3,
+			ReadTimeout:  time.Millisecond * 100,
+			WriteTimeout: time.Second * 1,
+		},
+		Invites: Invites{
+			Validity: time.Hour * 24 * 7,
+		},
+		WebAuthn: WebAuthn{
+			RPID:   "localhost",
+			RPName: "Padlock",
+		},
+		Push: Push{
+			Digits:  3,
+			Choices: 3,
+		},
+		Shutdown: Shutdown{
+			Delay:   time.Second * 5,
+			Timeout: time.Second * 30,
+		},
+	}
+}
+
+// ValidationError has all the problems found while validating the configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (ve *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(ve.Problems, "\n  - ")
+}
+
+func (ve *ValidationError) add(key, format string, args ...interface{}) {
+	ve.Problems = append(ve.Problems, key+": "+fmt.Sprintf(format, args...))
+}
+
+func validPort(port string) bool {
+	p, err := strconv.Atoi(port)
+	return err == nil && p > 0 && p < 65536
+}
+
+// Validate validates the configuration, it returns *ValidationError with all the problems
+// found, rather than only the first
+func (c *Config) Validate() error {
+	ve := &ValidationError{}
+
+	u, err := url.Parse(c.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		ve.add("baseUrl", "should be an absolute http(s) URL, got %q", c.BaseURL)
+	}
+
+	if !validPort(c.HTTP.Port) {
+		ve.add("http.port", "should be a port number, got %q", c.HTTP.Port)
+	}
+	if !validPort(c.GRPC.Port) {
+		ve.add("grpc.port", "should be a port number, got %q", c.GRPC.Port)
+	}
+	if c.HTTP.Host == c.GRPC.Host && c.HTTP.Port == c.GRPC.Port {
+		ve.add("grpc.port", "should be different from http.port")
+	}
+
+	switch c.Database.Driver {
+	case "postgres":
+		{
+			c.validatePostgres(ve)
+		}
+	case "sqlite":
+		{
+			if c.SQLite.Path == "" {
+				ve.add("sqlite.path", "is required")
+			}
+		}
+	default:
+		{
+			ve.add("database.driver", "should be one of %s, got %q", strings.Join(drivers, ", "), c.Database.Driver)
+		}
+	}
+
+	if len(c.Cache.Hosts) == 0 {
+		ve.add("cache.hosts", "at least one host is required")
+	}
+	for _, host := range c.Cache.Hosts {
+		if strings.TrimSpace(host) == "" {
+			ve.add("cache.hosts", "should not have empty hosts")
+			break
+		}
+	}
+	if c.Cache.DB < 0 {
+		ve.add("cache.db", "should not be negative")
+	}
+	if c.Cache.DialTimeout <= 0 || c.Cache.ReadTimeout <= 0 || c.Cache.WriteTimeout <= 0 {
+		ve.add("cache", "the timeouts should be greater than 0")
+	}
+
+	if len(c.Invites.Secret) < minSecretLength {
+		ve.add("invites.secret", "should be at least %d characters long", minSecretLength)
+	}
+	if c.Invites.Validity <= 0 {
+		ve.add("invites.validity", "should be greater than 0")
+	}
+
+	if c.WebAuthn.RPID == "" {
+		ve.add("webauthn.rpId", "is required")
+	}
+	if c.WebAuthn.RPName == "" {
+		ve.add("webauthn.rpName", "is required")
+	}
+
+	if c.Phone.DefaultCountryCode != "" && !countryCodeRegex.MatchString(c.Phone.DefaultCountryCode) {
+		ve.add("phone.defaultCountryCode", "should be + followed by the country code, got %q", c.Phone.DefaultCountryCode)
+	}
+
+	if c.Push.Digits < 3 || c.Push.Digits > 6 {
+		ve.add("push.digits", "should be between 3 & 6, got %d", c.Push.Digits)
+	}
+	if c.Push.Choices < 0 || c.Push.Choices == 1 || c.Push.Choices > 10 {
+		ve.add("push.choices", "should be 0 or between 2 & 10, got %d", c.Push.Choices)
+	}
+
+	if c.Shutdown.Delay < 0 {
+		ve.add("shutdown.delay", "should not be negative")
+	}
+	if c.Shutdown.Timeout <= 0 {
+		ve.add("shutdown.timeout", "should be greater than 0")
+	}
+
+	if len(ve.Problems) > 0 {
+		return ve
+	}
+	return nil
+}
+
+// validatePostgres validates the Postgres configuration, which is required only if it's the
+// database used
+func (c *Config) validatePostgres(ve *ValidationError) {
+	if c.Postgres.Host == "" {
+		ve.add("postgres.host", "is required")
+	}
+	if !validPort(c.Postgres.Port) {
+		ve.add("postgres.port", "should be a port number, got %q", c.Postgres.Port)
+	}
+	if c.Postgres.DBName == "" {
+		ve.add("postgres.dbName", "is required")
+	}
+	if c.Postgres.Username == "" {
+		ve.add("postgres.username", "is required")
+	}
+	validMode := false
+	for _, mode := range sslModes {
+		validMode = validMode || c.Postgres.SSLMode == mode
+	}
+	if !validMode {
+		ve.add("postgres.sslMode", "should be one of %s, got %q", strings.Join(sslModes, ", "), c.Postgres.SSLMode)
+	}
+}
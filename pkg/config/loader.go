@@ -0,0 +1,344 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// EnvPrefix is the prefix of all the environment variables of the configuration
+	EnvPrefix = "PADLOCK_"
+	// EnvFile is the environment variable with the path of the configuration file
+	EnvFile = EnvPrefix + "CONFIG"
+	// fileSuffix is the suffix of the environment variables of the secrets, which have the
+	// path of the file with the secret
+	fileSuffix = "_FILE"
+	redacted   = "[REDACTED]"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field is a single (leaf) configuration, e.g. postgres.password
+type field struct {
+	// keys are the keys in the file, e.g. [postgres, password]
+	keys   []string
+	index  []int
+	secret bool
+	help   string
+}
+
+// key returns the key of the field as used in the error messages, e.g. postgres.password
+func (f field) key() string {
+	return strings.Join(f.keys, ".")
+}
+
+// env returns the environment variable of the field, e.g. PADLOCK_POSTGRES_PASSWORD
+func (f field) env() string {
+	return EnvPrefix + strings.ToUpper(strings.Join(splitWords(f.keys), "_"))
+}
+
+// flag returns the flag name of the field, e.g. postgres.password
+func (f field) flag() string {
+	words := make([]string, 0, len(f.keys))
+	for _, k := range f.keys {
+		words = append(words, strings.Join(splitWords([]string{k}), "-"))
+	}
+	return strings.Join(words, ".")
+}
+
+// splitWords splits the camel cased keys into lower cased words, e.g. dialTimeout => dial, timeout
+func splitWords(keys []string) []string {
+	words := []string{}
+	for _, k := range keys {
+		start := 0
+		for i, r := range k {
+			if i > 0 && unicode.IsUpper(r) {
+				words = append(words, strings.ToLower(k[start:i]))
+				start = i
+			}
+		}
+		words = append(words, strings.ToLower(k[start:]))
+	}
+	return words
+}
+
+// fields returns all the leaf fields of the struct type, recursively
+func fields(t reflect.Type, keys []string, index []int) []field {
+	list := []field{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fkeys := append(append([]string{}, keys...), sf.Tag.Get("yaml"))
+		findex := append(append([]int{}, index...), i)
+		if sf.Type.Kind() == reflect.Struct {
+			list = append(list, fields(sf.Type, fkeys, findex)...)
+			continue
+		}
+
+		list = append(list, field{
+			keys:   fkeys,
+			index:  findex,
+			secret: sf.Tag.Get("secret") == "true",
+			help:   sf.Tag.Get("help"),
+		})
+	}
+	return list
+}
+
+// set sets the value of the field in the configuration, parsing the value as per the type of
+// the field. Lists are comma separated
+func set(c *Config, f field, value string) error {
+	v := reflect.ValueOf(c).Elem().FieldByIndex(f.index)
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, e.g. 5s, 1m30s", value)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		{
+			v.SetString(value)
+		}
+	case reflect.Bool:
+		{
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q", value)
+			}
+			v.SetBool(b)
+		}
+	case reflect.Int:
+		{
+			i, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid integer %q", value)
+			}
+			v.SetInt(int64(i))
+		}
+	case reflect.Slice:
+		{
+			list := []string{}
+			for _, s := range strings.Split(value, ",") {
+				s = strings.TrimSpace(s)
+				if s != "" {
+					list = append(list, s)
+				}
+			}
+			v.Set(reflect.ValueOf(list))
+		}
+	default:
+		{
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+	}
+	return nil
+}
+
+// flagValue records the value of a flag, so that it's applied after the file & the
+// environment variables
+type flagValue struct {
+	f      field
+	isBool bool
+	value  *string
+}
+
+func (fv *flagValue) String() string {
+	if fv.value == nil {
+		return ""
+	}
+	return *fv.value
+}
+
+func (fv *flagValue) Set(s string) error {
+	fv.value = &s
+	return nil
+}
+
+func (fv *flagValue) IsBoolFlag() bool {
+	return fv.isBool
+}
+
+// Loader loads the configuration from the defaults, the file, the environment variables & the
+// flags, each overriding the former. The file is YAML or TOML based on its extension (.yaml,
+// .yml or .toml), and its path is the flag 'config' or the environment variable PADLOCK_CONFIG.
+//
+// Every configuration has an environment variable & a flag, e.g. postgres.sslMode can be set
+// using PADLOCK_POSTGRES_SSL_MODE or -postgres.ssl-mode. The secrets can also be read from a
+// file, whose path is set in the environment variable with the suffix _FILE, e.g.
+// PADLOCK_POSTGRES_PASSWORD_FILE
+type Loader struct {
+	path      *string
+	flags     []*flagValue
+	lookupEnv func(key string) (string, bool)
+}
+
+// NewLoader registers the flags of the configuration on fs, the configuration should be
+// loaded only after fs is parsed
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{
+		path:      fs.String("config", "", "path of the configuration file (YAML or TOML)"),
+		lookupEnv: os.LookupEnv,
+	}
+
+	defaults := Default()
+	for _, f := range fields(reflect.TypeOf(Config{}), nil, nil) {
+		v := reflect.ValueOf(defaults).Elem().FieldByIndex(f.index)
+		fv := &flagValue{f: f, isBool: v.Kind() == reflect.Bool}
+		l.flags = append(l.flags, fv)
+
+		usage := f.help
+		if !f.secret && !v.IsZero() {
+			usage = fmt.Sprintf("%s (default %s)", usage, format(v))
+		}
+		fs.Var(fv, f.flag(), fmt.Sprintf("%s [%s]", usage, f.env()))
+	}
+	return l
+}
+
+// Load loads & validates the configuration. If it's invalid, it's still returned along with
+// *ValidationError, so that it can be printed
+func (l *Loader) Load() (*Config, error) {
+	c := Default()
+
+	path := *l.path
+	if path == "" {
+		path, _ = l.lookupEnv(EnvFile)
+	}
+	if path != "" {
+		err := loadFile(c, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := l.loadEnv(c)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fv := range l.flags {
+		if fv.value == nil {
+			continue
+		}
+		err := set(c, fv.f, *fv.value)
+		if err != nil {
+			return nil, fmt.Errorf("flag -%s: %v", fv.f.flag(), err)
+		}
+	}
+
+	if len(c.WebAuthn.Origins) == 0 {
+		c.WebAuthn.Origins = []string{c.BaseURL}
+	}
+
+	return c, c.Validate()
+}
+
+func loadFile(c *Config, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		{
+			// strict, so that a misspelt key is not silently ignored
+			err = yaml.UnmarshalStrict(data, c)
+		}
+	case ".toml":
+		{
+			var md toml.MetaData
+			md, err = toml.Decode(string(data), c)
+			if err == nil && len(md.Undecoded()) > 0 {
+				err = fmt.Errorf("unknown keys %v", md.Undecoded())
+			}
+		}
+	default:
+		{
+			err = fmt.Errorf("unsupported format %q, it should be .yaml, .yml or .toml", filepath.Ext(path))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %v", path, err)
+	}
+	return nil
+}
+
+func (l *Loader) loadEnv(c *Config) error {
+	for _, f := range fields(reflect.TypeOf(Config{}), nil, nil) {
+		name := f.env()
+		value, ok := l.lookupEnv(name)
+
+		if f.secret {
+			path, fok := l.lookupEnv(name + fileSuffix)
+			if fok && ok {
+				return fmt.Errorf("env %s: only one of %s & %s should be set", name, name, name+fileSuffix)
+			}
+			if fok {
+				data, err := ioutil.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("env %s: %v", name+fileSuffix, err)
+				}
+				// files usually end with a newline, which is not part of the secret
+				value, ok = strings.TrimRight(string(data), "\r\n"), true
+			}
+		}
+
+		if !ok {
+			continue
+		}
+
+		err := set(c, f, value)
+		if err != nil {
+			return fmt.Errorf("env %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func format(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Redacted returns a copy of the configuration with the non-empty secrets redacted
+func (c *Config) Redacted() *Config {
+	rc := *c
+	for _, f := range fields(reflect.TypeOf(Config{}), nil, nil) {
+		v := reflect.ValueOf(&rc).Elem().FieldByIndex(f.index)
+		if f.secret && v.String() != "" {
+			v.SetString(redacted)
+		}
+	}
+	return &rc
+}
+
+// Print writes the configuration as YAML, with the secrets redacted
+func Print(w io.Writer, c *Config) error {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,455 @@
+// Package invites handles inviting collaborators, by email, to an organisation or an application.
+// Invitees accept or decline using the signed token delivered to their email, and an account is
+// created on accept if the email is not registered yet
+package invites
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/orgs"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/notifier"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// Status is the state of an invitation
+type Status string
+
+const (
+	StatusPending  = Status("pending")
+	StatusAccepted = Status("accepted")
+	StatusDeclined = Status("declined")
+	StatusRevoked  = Status("revoked")
+
+	// InvitePath is the path of the link sent to the invitee, it's followed by the invite token
+	InvitePath = "/invites/"
+
+	issuer = "padlock.dev/invites"
+)
+
+var (
+	ErrInvalidEmail     = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no email provided")
+	ErrInvalidTarget    = apperr.New(apperr.CodeInvalidInput, "Sorry, either an organisation or an application should be provided")
+	ErrInvalidRole      = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid role provided")
+	ErrInvalidToken     = apperr.New(apperr.CodeNotFound, "Sorry, invalid invitation")
+	ErrExpired          = apperr.New(apperr.CodeExpired, "Sorry, the invitation has expired")
+	ErrNotPending       = apperr.New(apperr.CodeConflict, "Sorry, the invitation was already accepted, declined or revoked")
+	ErrNotFound         = apperr.New(apperr.CodeNotFound, "Sorry, invitation not found")
+	ErrPasswordRequired = apperr.New(apperr.CodeInvalidInput, "Sorry, a password is required to create your account")
+	ErrUnexpected       = apperr.New(apperr.CodeInternal, "Sorry, an unexpected error occurred")
+)
+
+// Invitation is an invite sent to an email, to join an organisation or an application with a role
+type Invitation struct {
+	ID    int64  `json:"id,omitempty"`
+	Email string `json:"email,omitempty"`
+	// OrgID is set for invitations to an organisation, AppID otherwise
+	OrgID int64 `json:"orgId,omitempty"`
+	AppID int64 `json:"appId,omitempty"`
+	// Role is an orgs.Role for organisation invitations, and rbac.Role for application invitations
+	Role        string     `json:"role,omitempty"`
+	InvitedBy   int64      `json:"invitedBy,omitempty"`
+	Status      Status     `json:"status,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt   *time.Time `json:"createdAt,omitempty"`
+	RespondedAt *time.Time `json:"respondedAt,omitempty"`
+}
+
+// Validate checks if the target & role of the invitation are valid
+func (inv *Invitation) Validate() error {
+	if (inv.OrgID > 0) == (inv.AppID > 0) {
+		return ErrInvalidTarget
+	}
+
+	if inv.OrgID > 0 {
+		switch orgs.Role(inv.Role) {
+		case orgs.RoleOwner, orgs.RoleAdmin, orgs.RoleMember:
+			{
+				return nil
+			}
+		}
+		return ErrInvalidRole
+	}
+
+	switch rbac.Role(inv.Role) {
+	case rbac.RoleOwner, rbac.RoleDeveloper, rbac.RoleViewer:
+		{
+			return nil
+		}
+	}
+	return ErrInvalidRole
+}
+
+// Config has all the configurations of invitations
+type Config struct {
+	// Secret is used to sign the invite tokens, it should not change across restarts for the
+	// tokens already sent to remain valid
+	Secret []byte
+	// Validity is the duration for which an invitation can be accepted
+	Validity time.Duration
+}
+
+// DefaultConfig has the default configuration for invitations, Secret should still be provided
+var DefaultConfig = Config{
+	Validity: time.Hour * 24 * 7,
+}
+
+// Users is used to find or create the account of the invitee
+type Users interface {
+	ReadByEmail(ctx context.Context, email string) (*users.User, error)
+	Create(ctx context.Context, u users.User, password string) (*users.User, error)
+}
+
+// Orgs is used to authorize invitations to an organisation, and add the invitee as a member
+type Orgs interface {
+	Authorize(ctx context.Context, orgID int64, min orgs.Role) error
+	SetMember(ctx context.Context, m orgs.Member) (*orgs.Member, error)
+}
+
+// RBAC is used to authorize invitations to an application, and assign the role to the invitee
+type RBAC interface {
+	Authorize(ctx context.Context, perm rbac.Permission, appID int64) error
+	Assign(ctx context.Context, a rbac.Assignment) (*rbac.Assignment, error)
+}
+
+// Invites handles all the service methods made available by this package
+type Invites struct {
+	appCtx   *appcontext.AppContext
+	cfg      Config
+	store    store
+	users    Users
+	orgs     Orgs
+	rbac     RBAC
+	notifier notifier.Notifier
+}
+
+func (in *Invites) unexpected(err error) error {
+	if in.appCtx.Logging {
+		in.appCtx.Logger.Error(err)
+	}
+	return ErrUnexpected
+}
+
+// storeErr returns the errors known to this package as is, and ErrUnexpected for the rest
+func (in *Invites) storeErr(err error) error {
+	switch err {
+	case ErrNotFound, ErrNotPending:
+		{
+			return err
+		}
+	}
+	return in.unexpected(err)
+}
+
+// authorize checks if the user in the context can manage the invitations of the target. Owner
+// invitations require the inviter to be an owner
+func (in *Invites) authorize(ctx context.Context, inv Invitation) error {
+	if inv.OrgID > 0 {
+		min := orgs.RoleAdmin
+		if orgs.Role(inv.Role) == orgs.RoleOwner {
+			min = orgs.RoleOwner
+		}
+		return in.orgs.Authorize(ctx, inv.OrgID, min)
+	}
+	return in.rbac.Authorize(ctx, rbac.PermAppMembers, inv.AppID)
+}
+
+// token returns the signed token of the invitation
+func (in *Invites) token(inv *Invitation) (string, error) {
+	claims := jwt.StandardClaims{
+		Id:        strconv.FormatInt(inv.ID, 10),
+		Subject:   inv.Email,
+		Issuer:    issuer,
+		IssuedAt:  inv.CreatedAt.Unix(),
+		ExpiresAt: inv.ExpiresAt.Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(in.cfg.Secret)
+}
+
+// fromToken verifies the token & returns the pending invitation it was issued for
+func (in *Invites) fromToken(ctx context.Context, token string) (*Invitation, error) {
+	claims := jwt.StandardClaims{}
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
+		}
+		return in.cfg.Secret, nil
+	})
+	if err != nil {
+		verr, ok := err.(*jwt.ValidationError)
+		if ok && verr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, ErrExpired
+		}
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Issuer != issuer {
+		return nil, ErrInvalidToken
+	}
+
+	id, err := strconv.ParseInt(claims.Id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	inv, err := in.store.Read(ctx, id)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrInvalidToken
+		}
+		return nil, in.unexpected(err)
+	}
+
+	if !strings.EqualFold(inv.Email, claims.Subject) {
+		return nil, ErrInvalidToken
+	}
+
+	if inv.Status != StatusPending {
+		return nil, ErrNotPending
+	}
+
+	return inv, nil
+}
+
+// Invite creates an invitation & emails the invite link to the invitee
+func (in *Invites) Invite(ctx context.Context, inv Invitation) (*Invitation, error) {
+	inv.Email = strings.ToLower(strings.TrimSpace(inv.Email))
+	if inv.Email == "" || !strings.Contains(inv.Email, "@") {
+		return nil, ErrInvalidEmail
+	}
+
+	err := inv.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	err = in.authorize(ctx, inv)
+	if err != nil {
+		return nil, err
+	}
+
+	inviter := users.FromContext(ctx)
+	if inviter != nil {
+		inv.InvitedBy = inviter.ID
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(in.cfg.Validity)
+	inv.ID = 0
+	inv.Status = StatusPending
+	inv.CreatedAt = &now
+	inv.ExpiresAt = &expiresAt
+	inv.RespondedAt = nil
+
+	created, err := in.store.Create(ctx, inv)
+	if err != nil {
+		return nil, in.unexpected(err)
+	}
+
+	token, err := in.token(created)
+	if err != nil {
+		return nil, in.unexpected(err)
+	}
+
+	target := fmt.Sprintf("organisation #%d", created.OrgID)
+	if created.AppID > 0 {
+		target = fmt.Sprintf("application #%d", created.AppID)
+	}
+
+	err = in.notifier.Notify(ctx, notifier.Message{
+		Channel: notifier.ChannelEmail,
+		To:      created.Email,
+		Subject: "You have been invited to collaborate on Padlock",
+		Body: fmt.Sprintf(
+			"You have been invited to join %s as %s. Accept the invitation before %s: %s%s%s",
+			target,
+			created.Role,
+			expiresAt.Format(time.RFC1123),
+			in.appCtx.BaseURL,
+			InvitePath,
+			token,
+		),
+	})
+	if err != nil {
+		return nil, in.unexpected(err)
+	}
+
+	return created, nil
+}
+
+// Details returns the invitation the token was issued for, so the invitee can review it
+// before accepting
+func (in *Invites) Details(ctx context.Context, token string) (*Invitation, error) {
+	return in.fromToken(ctx, token)
+}
+
+// Accept accepts the invitation. If the email is not registered yet, an account is created with
+// the given name & password. The account of the invitee is returned
+func (in *Invites) Accept(ctx context.Context, token, name, password string) (*users.User, error) {
+	inv, err := in.fromToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := in.users.ReadByEmail(ctx, inv.Email)
+	if err != nil {
+		if !apperr.Is(err, users.ErrNotFound) {
+			return nil, err
+		}
+
+		if password == "" {
+			return nil, ErrPasswordRequired
+		}
+
+		u, err = in.users.Create(ctx, users.User{Name: strings.TrimSpace(name), Email: inv.Email}, password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// access is granted before the invitation is marked accepted, so that the invitation can
+	// be accepted again if granting fails. Granting is repeatable, in case marking it fails
+	err = in.grant(ctx, inv, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = in.respond(ctx, inv, StatusAccepted)
+	if err == ErrNotPending {
+		// accepted concurrently, by another request with the same token
+		current, rerr := in.store.Read(ctx, inv.ID)
+		if rerr != nil {
+			return nil, in.storeErr(rerr)
+		}
+		if current.Status == StatusAccepted {
+			return u, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// grant grants the role of the invitation to the user. The invitation was authorized when it
+// was created, so access is granted on behalf of the system rather than the invitee
+func (in *Invites) grant(ctx context.Context, inv *Invitation, userID int64) error {
+	sysCtx := rbac.SystemContext(ctx)
+	if inv.OrgID > 0 {
+		_, err := in.orgs.SetMember(sysCtx, orgs.Member{OrgID: inv.OrgID, UserID: userID, Role: orgs.Role(inv.Role)})
+		return err
+	}
+
+	_, err := in.rbac.Assign(sysCtx, rbac.Assignment{UserID: userID, Role: rbac.Role(inv.Role), AppID: inv.AppID})
+	if apperr.Is(err, rbac.ErrAssigned) {
+		return nil
+	}
+	return err
+}
+
+// Decline declines the invitation
+func (in *Invites) Decline(ctx context.Context, token string) error {
+	inv, err := in.fromToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return in.respond(ctx, inv, StatusDeclined)
+}
+
+// respond updates the status of a pending invitation
+func (in *Invites) respond(ctx context.Context, inv *Invitation, status Status) error {
+	err := in.store.UpdateStatus(ctx, inv.ID, status, time.Now().UTC())
+	if err != nil {
+		return in.storeErr(err)
+	}
+	return nil
+}
+
+// Revoke revokes a pending invitation, after which its token can no longer be used
+func (in *Invites) Revoke(ctx context.Context, id int64) error {
+	inv, err := in.store.Read(ctx, id)
+	if err != nil {
+		return in.storeErr(err)
+	}
+
+	err = in.authorize(ctx, *inv)
+	if err != nil {
+		return err
+	}
+
+	if inv.Status != StatusPending {
+		return ErrNotPending
+	}
+
+	return in.respond(ctx, inv, StatusRevoked)
+}
+
+// Pending lists the pending invitations of an organisation (orgID) or an application (appID)
+func (in *Invites) Pending(ctx context.Context, orgID, appID int64) ([]Invitation, error) {
+	target := Invitation{OrgID: orgID, AppID: appID}
+	if (orgID > 0) == (appID > 0) {
+		return nil, ErrInvalidTarget
+	}
+
+	err := in.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := in.store.ListPending(ctx, orgID, appID, "")
+	if err != nil {
+		return nil, in.unexpected(err)
+	}
+	return list, nil
+}
+
+// Received lists the pending invitations sent to the email of the user in the context
+func (in *Invites) Received(ctx context.Context) ([]Invitation, error) {
+	u := users.FromContext(ctx)
+	if u == nil {
+		return nil, rbac.ErrUnauthenticated
+	}
+
+	list, err := in.store.ListPending(ctx, 0, 0, strings.ToLower(u.Email))
+	if err != nil {
+		return nil, in.unexpected(err)
+	}
+	return list, nil
+}
+
+func New(
+	appCtx *appcontext.AppContext,
+	driver database.Driver,
+	cfg Config,
+	sdb *sql.DB,
+	u Users,
+	o Orgs,
+	r RBAC,
+	n notifier.Notifier,
+) *Invites {
+	if cfg.Validity <= 0 {
+		cfg.Validity = DefaultConfig.Validity
+	}
+
+	return &Invites{
+		appCtx:   appCtx,
+		cfg:      cfg,
+		store:    newStore(appCtx, driver, sdb),
+		users:    u,
+		orgs:     o,
+		rbac:     r,
+		notifier: n,
+	}
+}
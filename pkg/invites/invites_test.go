@@ -0,0 +1,235 @@
+package invites
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/orgs"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/platform/notifier"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+var tokenRegex = regexp.MustCompile(regexp.QuoteMeta(InvitePath) + `(\S+)`)
+
+// fakeUsers creates the accounts in memory
+type fakeUsers struct {
+	mu    sync.Mutex
+	users map[string]*users.User
+}
+
+func (fu *fakeUsers) ReadByEmail(ctx context.Context, email string) (*users.User, error) {
+	fu.mu.Lock()
+	defer fu.mu.Unlock()
+	u, ok := fu.users[email]
+	if !ok {
+		return nil, users.ErrNotFound
+	}
+	return u, nil
+}
+
+func (fu *fakeUsers) Create(ctx context.Context, u users.User, password string) (*users.User, error) {
+	fu.mu.Lock()
+	defer fu.mu.Unlock()
+	u.ID = int64(len(fu.users) + 1)
+	fu.users[u.Email] = &u
+	return &u, nil
+}
+
+// fakeAccess allows all the invitations, and records the roles granted. It fails to grant the
+// roles while fail is set
+type fakeAccess struct {
+	mu       sync.Mutex
+	fail     bool
+	members  []orgs.Member
+	assigned []rbac.Assignment
+}
+
+func (fa *fakeAccess) Authorize(ctx context.Context, perm rbac.Permission, appID int64) error {
+	return nil
+}
+
+func (fa *fakeAccess) Assign(ctx context.Context, a rbac.Assignment) (*rbac.Assignment, error) {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	if fa.fail {
+		return nil, rbac.ErrUnexpected
+	}
+	for _, existing := range fa.assigned {
+		if existing.UserID == a.UserID && existing.Role == a.Role && existing.AppID == a.AppID {
+			return nil, rbac.ErrAssigned
+		}
+	}
+	fa.assigned = append(fa.assigned, a)
+	return &a, nil
+}
+
+// fakeOrgs is the organisations side of fakeAccess
+type fakeOrgs struct {
+	*fakeAccess
+}
+
+func (fo fakeOrgs) Authorize(ctx context.Context, orgID int64, min orgs.Role) error {
+	return nil
+}
+
+func (fo fakeOrgs) SetMember(ctx context.Context, m orgs.Member) (*orgs.Member, error) {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+	if fo.fail {
+		return nil, orgs.ErrUnexpected
+	}
+	fo.members = append(fo.members, m)
+	return &m, nil
+}
+
+type invitesFixture struct {
+	in       *Invites
+	access   *fakeAccess
+	notifier *notifier.Recorder
+	orgID    int64
+	appID    int64
+}
+
+func testInvites(t *testing.T, fn func(t *testing.T, f invitesFixture)) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		f := invitesFixture{
+			access:   &fakeAccess{},
+			notifier: notifier.NewRecorder(),
+			orgID:    databasetest.Org(t, driver, db, "acme"),
+		}
+		f.appID = databasetest.App(t, driver, db, f.orgID, "billing")
+		f.in = New(
+			appcontext.New(logger.New()),
+			driver,
+			Config{Secret: []byte("secret"), Validity: time.Hour},
+			db,
+			&fakeUsers{users: map[string]*users.User{}},
+			fakeOrgs{fakeAccess: f.access},
+			f.access,
+			f.notifier,
+		)
+		fn(t, f)
+	})
+}
+
+// invite invites the email & returns the token sent
+func (f invitesFixture) invite(t *testing.T, inv Invitation) string {
+	t.Helper()
+
+	_, err := f.in.Invite(context.Background(), inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := f.notifier.Messages()
+	m := tokenRegex.FindStringSubmatch(msgs[len(msgs)-1].Body)
+	if m == nil {
+		t.Fatalf("no token in %q", msgs[len(msgs)-1].Body)
+	}
+	return m[1]
+}
+
+func TestAcceptGrantFails(t *testing.T) {
+	testInvites(t, func(t *testing.T, f invitesFixture) {
+		ctx := context.Background()
+		token := f.invite(t, Invitation{Email: "jane@example.com", AppID: f.appID, Role: string(rbac.RoleViewer)})
+
+		f.access.fail = true
+		_, err := f.in.Accept(ctx, token, "Jane", "password")
+		if err == nil {
+			t.Fatal("expected the grant to fail")
+		}
+
+		// the invitation is not used up by the failure, so it can be accepted again
+		f.access.fail = false
+		inv, err := f.in.Details(ctx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inv.Status != StatusPending {
+			t.Fatalf("expected the invitation to be pending, got %+v", inv)
+		}
+
+		u, err := f.in.Accept(ctx, token, "Jane", "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.access.assigned) != 1 || f.access.assigned[0].UserID != u.ID {
+			t.Fatalf("expected the role to be assigned, got %+v", f.access.assigned)
+		}
+
+		_, err = f.in.Accept(ctx, token, "Jane", "password")
+		if !errors.Is(err, ErrNotPending) {
+			t.Fatalf("expected ErrNotPending, got %v", err)
+		}
+	})
+}
+
+func TestAcceptRepeated(t *testing.T) {
+	testInvites(t, func(t *testing.T, f invitesFixture) {
+		ctx := context.Background()
+		token := f.invite(t, Invitation{Email: "jane@example.com", AppID: f.appID, Role: string(rbac.RoleViewer)})
+		inv, err := f.in.Details(ctx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the role was granted by an earlier attempt, which failed to mark the invitation accepted
+		u, err := f.in.users.Create(ctx, users.User{Email: inv.Email}, "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = f.in.grant(ctx, inv, u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = f.in.Accept(ctx, token, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.access.assigned) != 1 {
+			t.Fatalf("expected the role to be assigned once, got %+v", f.access.assigned)
+		}
+
+		inv, err = f.in.store.Read(ctx, inv.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inv.Status != StatusAccepted {
+			t.Fatalf("expected the invitation to be accepted, got %+v", inv)
+		}
+	})
+}
+
+func TestAcceptOrg(t *testing.T) {
+	testInvites(t, func(t *testing.T, f invitesFixture) {
+		ctx := context.Background()
+		token := f.invite(t, Invitation{Email: "jane@example.com", OrgID: f.orgID, Role: string(orgs.RoleMember)})
+
+		f.access.fail = true
+		_, err := f.in.Accept(ctx, token, "Jane", "password")
+		if err == nil {
+			t.Fatal("expected the grant to fail")
+		}
+
+		f.access.fail = false
+		u, err := f.in.Accept(ctx, token, "Jane", "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.access.members) != 1 || f.access.members[0].UserID != u.ID || f.access.members[0].OrgID != f.orgID {
+			t.Fatalf("expected the user to be a member, got %+v", f.access.members)
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package invites
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+)
+
+const (
+	invitesTable = "invitations"
+
+	inviteColumns = "id,email,orgid,appid,role,invitedby,status,expiresat,createdat,respondedat"
+)
+
+type store interface {
+	Create(ctx context.Context, inv Invitation) (*Invitation, error)
+	Read(ctx context.Context, id int64) (*Invitation, error)
+	// ListPending lists the pending & unexpired invitations of the organisation, application or
+	// email; whichever is provided
+	ListPending(ctx context.Context, orgID, appID int64, email string) ([]Invitation, error)
+	// UpdateStatus updates the status of the invitation, only if it's still pending
+	UpdateStatus(ctx context.Context, id int64, status Status, respondedAt time.Time) error
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type dbStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// newStore returns the store of the driver
+func newStore(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB) store {
+	if driver == database.SQLite {
+		return &sqliteStore{
+			appCtx: appCtx,
+			db:     sdb,
+		}
+	}
+	return &dbStore{
+		appCtx: appCtx,
+		db:     sdb,
+	}
+}
+
+func (dbs *dbStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, fmt.Sprintf("$%d", i+1))
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+// nullID converts the ID 0 to NULL
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id > 0}
+}
+
+func scan(row scanner) (*Invitation, error) {
+	inv := Invitation{}
+	orgID := sql.NullInt64{}
+	appID := sql.NullInt64{}
+	invitedBy := sql.NullInt64{}
+	status := ""
+	expiresAt := pq.NullTime{}
+	createdAt := pq.NullTime{}
+	respondedAt := pq.NullTime{}
+
+	err := row.Scan(
+		&inv.ID,
+		&inv.Email,
+		&orgID,
+		&appID,
+		&inv.Role,
+		&invitedBy,
+		&status,
+		&expiresAt,
+		&createdAt,
+		&respondedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	inv.OrgID = orgID.Int64
+	inv.AppID = appID.Int64
+	inv.InvitedBy = invitedBy.Int64
+	inv.Status = Status(status)
+	if expiresAt.Valid {
+		inv.ExpiresAt = &expiresAt.Time
+	}
+	if createdAt.Valid {
+		inv.CreatedAt = &createdAt.Time
+	}
+	if respondedAt.Valid {
+		inv.RespondedAt = &respondedAt.Time
+	}
+
+	return &inv, nil
+}
+
+func (dbs *dbStore) Create(ctx context.Context, inv Invitation) (*Invitation, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s RETURNING id",
+		invitesTable,
+		dbs.prepColVals("email", "orgid", "appid", "role", "invitedby", "status", "expiresat", "createdat"),
+	)
+
+	err := dbs.db.QueryRowContext(
+		ctx,
+		stmt,
+		inv.Email,
+		nullID(inv.OrgID),
+		nullID(inv.AppID),
+		inv.Role,
+		nullID(inv.InvitedBy),
+		inv.Status,
+		inv.ExpiresAt,
+		inv.CreatedAt,
+	).Scan(&inv.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+func (dbs *dbStore) Read(ctx context.Context, id int64) (*Invitation, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=$1", inviteColumns, invitesTable)
+	return scan(dbs.db.QueryRowContext(ctx, stmt, id))
+}
+
+func (dbs *dbStore) ListPending(ctx context.Context, orgID, appID int64, email string) ([]Invitation, error) {
+	where := []string{"status=$1", "expiresat > $2"}
+	args := []interface{}{StatusPending, time.Now().UTC()}
+
+	switch {
+	case orgID > 0:
+		{
+			args = append(args, orgID)
+			where = append(where, fmt.Sprintf("orgid=$%d", len(args)))
+		}
+	case appID > 0:
+		{
+			args = append(args, appID)
+			where = append(where, fmt.Sprintf("appid=$%d", len(args)))
+		}
+	default:
+		{
+			args = append(args, email)
+			where = append(where, fmt.Sprintf("email=$%d", len(args)))
+		}
+	}
+
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s ORDER BY id",
+		inviteColumns,
+		invitesTable,
+		strings.Join(where, " AND "),
+	)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Invitation, 0)
+	for rows.Next() {
+		inv, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *inv)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) UpdateStatus(ctx context.Context, id int64, status Status, respondedAt time.Time) error {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET status=$1, respondedat=$2 WHERE id=$3 AND status=$4",
+		invitesTable,
+	)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, status, respondedAt, id, StatusPending)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotPending
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package invites
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+)
+
+// sqliteStore is the store of SQLite, it behaves exactly like dbStore
+type sqliteStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// sqliteTime returns the time in UTC truncated to seconds, as stored by Postgres. SQLite stores
+// the times as text, which are compared correctly only if they're all in the same time zone
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Truncate(time.Second)
+}
+
+func (ss *sqliteStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, "?")
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func (ss *sqliteStore) Create(ctx context.Context, inv Invitation) (*Invitation, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		invitesTable,
+		ss.prepColVals("email", "orgid", "appid", "role", "invitedby", "status", "expiresat", "createdat"),
+	)
+
+	result, err := ss.db.ExecContext(
+		ctx,
+		stmt,
+		inv.Email,
+		nullID(inv.OrgID),
+		nullID(inv.AppID),
+		inv.Role,
+		nullID(inv.InvitedBy),
+		inv.Status,
+		sqliteTime(inv.ExpiresAt),
+		sqliteTime(inv.CreatedAt),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	inv.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+func (ss *sqliteStore) Read(ctx context.Context, id int64) (*Invitation, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=?", inviteColumns, invitesTable)
+	return scan(ss.db.QueryRowContext(ctx, stmt, id))
+}
+
+func (ss *sqliteStore) ListPending(ctx context.Context, orgID, appID int64, email string) ([]Invitation, error) {
+	now := time.Now()
+	where := []string{"status=?", "expiresat > ?"}
+	args := []interface{}{StatusPending, sqliteTime(&now)}
+
+	switch {
+	case orgID > 0:
+		{
+			args = append(args, orgID)
+			where = append(where, "orgid=?")
+		}
+	case appID > 0:
+		{
+			args = append(args, appID)
+			where = append(where, "appid=?")
+		}
+	default:
+		{
+			args = append(args, email)
+			where = append(where, "email=?")
+		}
+	}
+
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s ORDER BY id",
+		inviteColumns,
+		invitesTable,
+		strings.Join(where, " AND "),
+	)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Invitation, 0)
+	for rows.Next() {
+		inv, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *inv)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) UpdateStatus(ctx context.Context, id int64, status Status, respondedAt time.Time) error {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET status=?, respondedat=? WHERE id=? AND status=?",
+		invitesTable,
+	)
+
+	result, err := ss.db.ExecContext(ctx, stmt, status, sqliteTime(&respondedAt), id, StatusPending)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotPending
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package invites
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+func TestStoreInvitations(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		st := newStore(appcontext.New(logger.New()), driver, db)
+		inviter := databasetest.User(t, driver, db, "owner@example.com")
+		orgID := databasetest.Org(t, driver, db, "acme")
+		appID := databasetest.App(t, driver, db, orgID, "billing")
+
+		now := time.Now()
+		expiresAt := now.Add(time.Hour)
+		expiredAt := now.Add(-time.Hour)
+		create := func(email string, orgID, appID int64, expiresAt time.Time) *Invitation {
+			t.Helper()
+
+			inv, err := st.Create(ctx, Invitation{
+				Email:     email,
+				OrgID:     orgID,
+				AppID:     appID,
+				Role:      "member",
+				InvitedBy: inviter,
+				Status:    StatusPending,
+				ExpiresAt: &expiresAt,
+				CreatedAt: &now,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return inv
+		}
+
+		toOrg := create("jane@example.com", orgID, 0, expiresAt)
+		toApp := create("jane@example.com", 0, appID, expiresAt)
+		create("john@example.com", orgID, 0, expiredAt)
+
+		inv, err := st.Read(ctx, toApp.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inv.AppID != appID || inv.OrgID != 0 || inv.InvitedBy != inviter || inv.Status != StatusPending {
+			t.Fatalf("expected %+v, got %+v", toApp, inv)
+		}
+
+		_, err = st.Read(ctx, toApp.ID+10)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		tests := []struct {
+			name  string
+			orgID int64
+			appID int64
+			email string
+			ids   []int64
+		}{
+			{name: "organisation", orgID: orgID, ids: []int64{toOrg.ID}},
+			{name: "application", appID: appID, ids: []int64{toApp.ID}},
+			{name: "email", email: "jane@example.com", ids: []int64{toOrg.ID, toApp.ID}},
+			{name: "only expired", email: "john@example.com", ids: []int64{}},
+		}
+
+		for _, tt := range tests {
+			list, err := st.ListPending(ctx, tt.orgID, tt.appID, tt.email)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if len(list) != len(tt.ids) {
+				t.Fatalf("%s: expected %v, got %+v", tt.name, tt.ids, list)
+			}
+			for i, inv := range list {
+				if inv.ID != tt.ids[i] {
+					t.Fatalf("%s: expected %v, got %+v", tt.name, tt.ids, list)
+				}
+			}
+		}
+
+		err = st.UpdateStatus(ctx, toOrg.ID, StatusAccepted, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// only a pending invitation can be responded to
+		err = st.UpdateStatus(ctx, toOrg.ID, StatusDeclined, now)
+		if !errors.Is(err, ErrNotPending) {
+			t.Fatalf("expected ErrNotPending, got %v", err)
+		}
+
+		inv, err = st.Read(ctx, toOrg.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inv.Status != StatusAccepted || inv.RespondedAt == nil {
+			t.Fatalf("expected the invitation to be accepted, got %+v", inv)
+		}
+
+		list, err := st.ListPending(ctx, orgID, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 0 {
+			t.Fatalf("expected no pending invitations, got %+v", list)
+		}
+	})
+}
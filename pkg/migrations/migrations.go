@@ -0,0 +1,315 @@
+// Package migrations has the versioned schema migrations of Padlock, and applies them. The
+// applied migrations are recorded in the schema_migrations table along with their checksum, so
+// that a migration modified after it was applied is detected
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const table = "schema_migrations"
+
+var (
+	// ErrIrreversible is returned when rolling back a migration which has no down script
+	ErrIrreversible = errors.New("migrations: the migration cannot be rolled back")
+	// ErrInvalidTarget is returned when the target version of Up is not a known migration
+	ErrInvalidTarget = errors.New("migrations: unknown target version")
+)
+
+// Migration is a single, versioned change of the schema
+type Migration struct {
+	// Version orders the migrations, it should never be changed once released
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	// Up applies the change, Down reverts it. Both can have multiple statements
+	Up   string `json:"-"`
+	Down string `json:"-"`
+}
+
+// Checksum returns the checksum of the up script
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// Dialect has everything specific to a database, required to apply the migrations
+type Dialect struct {
+	Name       string
+	Migrations []Migration
+	// createTable creates the table of the applied migrations, if it does not exist
+	createTable string
+	// tableExists returns a single row, which is true if the table of migrations exists
+	tableExists string
+	// placeholder returns the placeholder of the nth (1 based) argument of a statement
+	placeholder func(n int) string
+	// lock prevents the other instances from migrating concurrently, till unlock is called.
+	// Both are called with the same connection
+	lock   func(ctx context.Context, conn *sql.Conn) error
+	unlock func(ctx context.Context, conn *sql.Conn) error
+}
+
+// Status is the status of a migration
+type Status struct {
+	Migration
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// Step is a migration applied (or to be applied in dry runs), in either direction
+type Step struct {
+	Migration
+	// Direction is either up or down
+	Direction string `json:"direction"`
+	// SQL is the script which was (or would be) run
+	SQL string `json:"sql,omitempty"`
+}
+
+type applied struct {
+	version   int
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrator applies the migrations of a dialect on the database
+type Migrator struct {
+	db      *sql.DB
+	dialect *Dialect
+}
+
+// migrations returns the migrations of the dialect ordered by version
+func (m *Migrator) migrations() []Migration {
+	list := append([]Migration{}, m.dialect.Migrations...)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list
+}
+
+// applied returns the applied migrations keyed by version. The migrations are verified
+// against the known migrations, an error is returned if any of them was modified or is unknown
+func (m *Migrator) applied(ctx context.Context, q queryer) (map[int]applied, error) {
+	exists := false
+	err := q.QueryRowContext(ctx, m.dialect.tableExists).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	list := map[int]applied{}
+	if !exists {
+		return list, nil
+	}
+
+	rows, err := q.QueryContext(ctx, fmt.Sprintf("SELECT version, checksum, appliedat FROM %s", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a := applied{}
+		err = rows.Scan(&a.version, &a.checksum, &a.appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		list[a.version] = a
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[int]Migration{}
+	for _, mg := range m.dialect.Migrations {
+		known[mg.Version] = mg
+	}
+	for version, a := range list {
+		mg, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("migrations: version %d is applied but unknown, the database is newer than this build", version)
+		}
+		if mg.Checksum() != a.checksum {
+			return nil, fmt.Errorf("migrations: %04d_%s was modified after it was applied (checksum mismatch)", mg.Version, mg.Name)
+		}
+	}
+
+	return list, nil
+}
+
+// queryer is implemented by *sql.DB, *sql.Conn & *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Status returns the status of all the migrations, ordered by version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	done, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []Status{}
+	for _, mg := range m.migrations() {
+		s := Status{Migration: mg}
+		if a, ok := done[mg.Version]; ok {
+			at := a.appliedAt
+			s.Applied, s.AppliedAt = true, &at
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// plan returns the steps to be run to reach the target. For up, the target is the version
+// to migrate to (0 is the latest). For down, it's the number of migrations to roll back
+func (m *Migrator) plan(done map[int]applied, up bool, target int) ([]Step, error) {
+	list := m.migrations()
+	steps := []Step{}
+
+	if up {
+		if target != 0 {
+			found := false
+			for _, mg := range list {
+				found = found || mg.Version == target
+			}
+			if !found {
+				return nil, ErrInvalidTarget
+			}
+		}
+
+		for _, mg := range list {
+			if target != 0 && mg.Version > target {
+				break
+			}
+			if _, ok := done[mg.Version]; !ok {
+				steps = append(steps, Step{Migration: mg, Direction: "up", SQL: mg.Up})
+			}
+		}
+		return steps, nil
+	}
+
+	for i := len(list) - 1; i >= 0 && len(steps) < target; i-- {
+		mg := list[i]
+		if _, ok := done[mg.Version]; !ok {
+			continue
+		}
+		if mg.Down == "" {
+			return nil, fmt.Errorf("%w: %04d_%s", ErrIrreversible, mg.Version, mg.Name)
+		}
+		steps = append(steps, Step{Migration: mg, Direction: "down", SQL: mg.Down})
+	}
+	return steps, nil
+}
+
+// Up applies the pending migrations till the target version (0 is the latest), in order.
+// Only one instance migrates at a time, the others wait for it to complete & then find nothing
+// pending. With dryRun, the steps are returned without being applied
+func (m *Migrator) Up(ctx context.Context, target int, dryRun bool) ([]Step, error) {
+	return m.run(ctx, true, target, dryRun)
+}
+
+// Down rolls back the last 'count' applied migrations, in the reverse order. With dryRun, the
+// steps are returned without being applied
+func (m *Migrator) Down(ctx context.Context, count int, dryRun bool) ([]Step, error) {
+	return m.run(ctx, false, count, dryRun)
+}
+
+func (m *Migrator) run(ctx context.Context, up bool, target int, dryRun bool) ([]Step, error) {
+	if dryRun {
+		done, err := m.applied(ctx, m.db)
+		if err != nil {
+			return nil, err
+		}
+		return m.plan(done, up, target)
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = m.dialect.lock(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// the lock is released with the connection anyway, if unlock fails
+		_ = m.dialect.unlock(context.Background(), conn)
+	}()
+
+	_, err = conn.ExecContext(ctx, m.dialect.createTable)
+	if err != nil {
+		return nil, err
+	}
+
+	// the state is read only after locking, since another instance might have migrated
+	done, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := m.plan(done, up, target)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, s := range steps {
+		err = m.apply(ctx, conn, s)
+		if err != nil {
+			return steps[:i], fmt.Errorf("migrations: %04d_%s %s: %v", s.Version, s.Name, s.Direction, err)
+		}
+	}
+	return steps, nil
+}
+
+// apply runs the step & records it in a single transaction, so that a failed step leaves no
+// trace
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, s Step) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, s.SQL)
+	if err != nil {
+		return err
+	}
+
+	p := m.dialect.placeholder
+	if s.Direction == "up" {
+		_, err = tx.ExecContext(
+			ctx,
+			fmt.Sprintf(
+				"INSERT INTO %s (version, name, checksum, appliedat) VALUES(%s, %s, %s, %s)",
+				table, p(1), p(2), p(3), p(4),
+			),
+			s.Version,
+			s.Name,
+			s.Checksum(),
+			time.Now().UTC(),
+		)
+	} else {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE version=%s", table, p(1)), s.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func New(db *sql.DB, d *Dialect) *Migrator {
+	return &Migrator{
+		db:      db,
+		dialect: d,
+	}
+}
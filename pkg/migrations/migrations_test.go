@@ -0,0 +1,260 @@
+package migrations_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/bnkamalesh/padlock/pkg/migrations"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+)
+
+func dialect(driver database.Driver) *migrations.Dialect {
+	if driver == database.SQLite {
+		return migrations.SQLite
+	}
+	return migrations.Postgres
+}
+
+func forEachDriver(t *testing.T, fn func(t *testing.T, driver database.Driver, db *sql.DB)) {
+	for _, driver := range databasetest.Drivers() {
+		driver := driver
+		t.Run(string(driver), func(t *testing.T) {
+			fn(t, driver, databasetest.Open(t, driver))
+		})
+	}
+}
+
+func TestDialects(t *testing.T) {
+	pg, lite := migrations.Postgres.Migrations, migrations.SQLite.Migrations
+	if len(pg) != len(lite) {
+		t.Fatalf("expected the same number of migrations, got %d (postgres) & %d (sqlite)", len(pg), len(lite))
+	}
+
+	for i := range pg {
+		if pg[i].Version != i+1 || lite[i].Version != i+1 {
+			t.Errorf("expected version %d, got %d (postgres) & %d (sqlite)", i+1, pg[i].Version, lite[i].Version)
+		}
+		if pg[i].Name != lite[i].Name {
+			t.Errorf("version %d: expected the same names, got %q (postgres) & %q (sqlite)", i+1, pg[i].Name, lite[i].Name)
+		}
+	}
+}
+
+func TestUp(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		m := migrations.New(db, dialect(driver))
+
+		steps, err := m.Up(ctx, 3, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(steps) != 3 {
+			t.Fatalf("expected 3 steps, got %d", len(steps))
+		}
+
+		steps, err = m.Up(ctx, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := len(dialect(driver).Migrations)
+		if len(steps) != total-3 || steps[0].Version != 4 {
+			t.Fatalf("expected %d steps from version 4, got %+v", total-3, steps)
+		}
+
+		steps, err = m.Up(ctx, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(steps) != 0 {
+			t.Fatalf("expected no pending steps, got %d", len(steps))
+		}
+
+		status, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range status {
+			if !s.Applied || s.AppliedAt == nil {
+				t.Errorf("expected %04d_%s to be applied", s.Version, s.Name)
+			}
+		}
+
+		_, err = m.Up(ctx, 1000, false)
+		if err != migrations.ErrInvalidTarget {
+			t.Fatalf("expected ErrInvalidTarget, got %v", err)
+		}
+	})
+}
+
+func TestDryRun(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		m := migrations.New(db, dialect(driver))
+
+		steps, err := m.Up(ctx, 0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(steps) != len(dialect(driver).Migrations) {
+			t.Fatalf("expected all the migrations to be planned, got %d", len(steps))
+		}
+		for _, s := range steps {
+			if s.Direction != "up" || s.SQL != s.Up {
+				t.Fatalf("expected the up script of %04d_%s, got %q", s.Version, s.Name, s.SQL)
+			}
+		}
+
+		status, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range status {
+			if s.Applied {
+				t.Fatalf("expected %04d_%s not to be applied by a dry run", s.Version, s.Name)
+			}
+		}
+	})
+}
+
+func TestDown(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		m := migrations.New(db, dialect(driver))
+
+		_, err := m.Up(ctx, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		steps, err := m.Down(ctx, 4, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(steps) != 4 || steps[0].Version != 12 || steps[3].Version != 9 {
+			t.Fatalf("expected versions 12 to 9 to be rolled back, got %+v", steps)
+		}
+
+		// the tables dropped are created again
+		_, err = m.Up(ctx, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = m.Down(ctx, len(dialect(driver).Migrations), false)
+		if driver == database.SQLite {
+			// the columns added by 0006 cannot be dropped by SQLite
+			if !errors.Is(err, migrations.ErrIrreversible) {
+				t.Fatalf("expected ErrIrreversible, got %v", err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range status {
+			if s.Applied {
+				t.Fatalf("expected %04d_%s to be rolled back", s.Version, s.Name)
+			}
+		}
+	})
+}
+
+func TestModified(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		m := migrations.New(db, dialect(driver))
+
+		_, err := m.Up(ctx, 1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = db.Exec("UPDATE schema_migrations SET checksum='modified' WHERE version=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = m.Status(ctx)
+		if err == nil {
+			t.Fatal("expected the modified migration to be detected")
+		}
+
+		_, err = m.Up(ctx, 0, false)
+		if err == nil {
+			t.Fatal("expected the migrations not to be applied after a modified migration")
+		}
+	})
+}
+
+// TestAdopt verifies that a database created before the migrations, from the released
+// schema, is brought up to date along with its data
+func TestAdopt(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		d := dialect(driver)
+
+		stmts := []string{
+			d.Migrations[0].Up,
+			"INSERT INTO users (name, email, password, salt, createdat) VALUES ('Jane', 'jane@example.com', 'x', 'y', CURRENT_TIMESTAMP)",
+			"INSERT INTO applications (name, createdat) VALUES ('legacy', CURRENT_TIMESTAMP)",
+			"INSERT INTO applicationOwners (appID, userID, createdat) VALUES (1, 1, CURRENT_TIMESTAMP)",
+			"INSERT INTO applicationOwners (appID, userID, createdat) VALUES (1, 1, CURRENT_TIMESTAMP)",
+		}
+		for _, stmt := range stmts {
+			_, err := db.Exec(stmt)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err := migrations.New(db, d).Up(ctx, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		version := 0
+		err = db.QueryRow("SELECT version FROM users WHERE id=1").Scan(&version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != 1 {
+			t.Errorf("expected the version of the existing user to be 1, got %d", version)
+		}
+
+		owners := 0
+		err = db.QueryRow("SELECT COUNT(*) FROM roleAssignments WHERE userID=1 AND appID=1 AND role='owner'").Scan(&owners)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owners != 1 {
+			t.Errorf("expected the owner to be moved to the role assignments once, got %d", owners)
+		}
+
+		org := ""
+		err = db.QueryRow("SELECT o.name FROM applications a INNER JOIN organisations o ON o.id=a.orgID WHERE a.id=1").Scan(&org)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if org != "default" {
+			t.Errorf("expected the app to be moved to the default organisation, got %q", org)
+		}
+
+		role := ""
+		err = db.QueryRow("SELECT role FROM organisationMembers WHERE userID=1").Scan(&role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if role != "member" {
+			t.Errorf("expected the owner to be a member of the default organisation, got %q", role)
+		}
+	})
+}
@@ -0,0 +1,361 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// lockKey is the key of the Postgres advisory lock held while migrating
+const lockKey = 7291736401
+
+// Postgres is the dialect of PostgreSQL, the migrations hold a session level advisory lock, so
+// that only one replica migrates at a time
+var Postgres = &Dialect{
+	Name:       "postgres",
+	Migrations: postgres,
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations(
+version INTEGER PRIMARY KEY,
+name VARCHAR(255) NOT NULL,
+checksum VARCHAR(64) NOT NULL,
+appliedat timestamp(0) with time zone NOT NULL
+)`,
+	tableExists: "SELECT to_regclass('schema_migrations') IS NOT NULL",
+	placeholder: func(n int) string {
+		return fmt.Sprintf("$%d", n)
+	},
+	lock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey)
+		return err
+	},
+	unlock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+		return err
+	},
+}
+
+// postgres are the migrations of PostgreSQL. A released migration should never be modified, a
+// new one should be added instead.
+//
+// The first migration is the schema of sql/padlock-postgres.sql, as released before the
+// migrations were introduced. Its tables are created only if they do not exist, so that the
+// databases created from that file adopt it as is, and the later migrations bring them up to
+// date. The later migrations are idempotent as well (IF NOT EXISTS), for the same reason
+var postgres = []Migration{
+	{
+		Version: 1,
+		Name:    "initial",
+		Up: `CREATE TABLE IF NOT EXISTS applications(
+id SERIAL PRIMARY KEY,
+name VARCHAR(255) UNIQUE NOT NULL,
+description VARCHAR(2048),
+totp JSON,
+createdat timestamp(0) with time zone,
+updatedat timestamp(0) with time zone
+);
+
+CREATE TABLE IF NOT EXISTS users(
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255),
+	email VARCHAR(510) UNIQUE NOT NULL,
+	phone VARCHAR(30),
+	password VARCHAR(255),
+	salt VARCHAR(64),
+	createdat timestamp(0) with time zone,
+	updatedat timestamp(0) with time zone
+);
+
+CREATE TABLE IF NOT EXISTS applicationOwners(
+id SERIAL PRIMARY KEY,
+appID INTEGER,
+userID INTEGER,
+createdat timestamp(0) with time zone,
+FOREIGN KEY (appID) REFERENCES applications (id),
+FOREIGN KEY (userID) REFERENCES users (id)
+);`,
+		Down: `DROP TABLE IF EXISTS applicationOwners;
+DROP TABLE IF EXISTS applications;
+DROP TABLE IF EXISTS users;`,
+	},
+	{
+		Version: 2,
+		Name:    "users_soft_delete_version",
+		Up: `ALTER TABLE users ADD COLUMN IF NOT EXISTS deletedat timestamp(0) with time zone;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;`,
+		Down: `ALTER TABLE users DROP COLUMN IF EXISTS version;
+ALTER TABLE users DROP COLUMN IF EXISTS deletedat;`,
+	},
+	{
+		Version: 3,
+		Name:    "webauthn_credentials",
+		Up: `CREATE TABLE IF NOT EXISTS webauthnCredentials(
+id SERIAL PRIMARY KEY,
+userID INTEGER NOT NULL,
+name VARCHAR(255),
+credentialID BYTEA UNIQUE NOT NULL,
+publicKey BYTEA NOT NULL,
+algorithm INTEGER NOT NULL,
+signCount BIGINT NOT NULL DEFAULT 0,
+aaguid BYTEA,
+attestation VARCHAR(32),
+userVerified BOOLEAN NOT NULL DEFAULT FALSE,
+createdat timestamp(0) with time zone,
+lastusedat timestamp(0) with time zone,
+FOREIGN KEY (userID) REFERENCES users (id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE IF EXISTS webauthnCredentials;`,
+	},
+	{
+		Version: 4,
+		Name:    "push_devices",
+		Up: `CREATE TABLE IF NOT EXISTS pushDevices(
+id SERIAL PRIMARY KEY,
+userID INTEGER NOT NULL,
+name VARCHAR(255),
+publicKey BYTEA NOT NULL,
+pushToken VARCHAR(4096) NOT NULL,
+createdat timestamp(0) with time zone,
+lastusedat timestamp(0) with time zone,
+FOREIGN KEY (userID) REFERENCES users (id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE IF EXISTS pushDevices;`,
+	},
+	{
+		// the application owners become role assignments, with the owner role of the app
+		Version: 5,
+		Name:    "role_assignments",
+		Up: `CREATE TABLE IF NOT EXISTS roleAssignments(
+id SERIAL PRIMARY KEY,
+userID INTEGER NOT NULL,
+role VARCHAR(32) NOT NULL,
+appID INTEGER,
+createdat timestamp(0) with time zone,
+UNIQUE (userID, role, appID),
+FOREIGN KEY (userID) REFERENCES users (id) ON DELETE CASCADE,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE
+);
+
+INSERT INTO roleAssignments (userID, role, appID, createdat)
+SELECT userID, 'owner', appID, MIN(createdat) FROM applicationOwners
+WHERE appID IS NOT NULL AND userID IS NOT NULL
+GROUP BY userID, appID
+ON CONFLICT (userID, role, appID) DO NOTHING;
+
+DROP TABLE applicationOwners;`,
+		Down: `CREATE TABLE applicationOwners(
+id SERIAL PRIMARY KEY,
+appID INTEGER,
+userID INTEGER,
+createdat timestamp(0) with time zone,
+FOREIGN KEY (appID) REFERENCES applications (id),
+FOREIGN KEY (userID) REFERENCES users (id)
+);
+
+INSERT INTO applicationOwners (appID, userID, createdat)
+SELECT appID, userID, createdat FROM roleAssignments WHERE role='owner' AND appID IS NOT NULL ORDER BY id;
+
+DROP TABLE roleAssignments;`,
+	},
+	{
+		// every application belongs to an organisation, the existing ones are moved to the
+		// 'default' organisation, and their owners become its members
+		Version: 6,
+		Name:    "organisations",
+		Up: `CREATE TABLE IF NOT EXISTS organisations(
+id SERIAL PRIMARY KEY,
+name VARCHAR(255) UNIQUE NOT NULL,
+createdat timestamp(0) with time zone,
+updatedat timestamp(0) with time zone
+);
+
+ALTER TABLE applications ADD COLUMN IF NOT EXISTS orgID INTEGER REFERENCES organisations (id) ON DELETE CASCADE;
+
+INSERT INTO organisations (name, createdat, updatedat)
+SELECT 'default', now(), now() WHERE EXISTS (SELECT 1 FROM applications WHERE orgID IS NULL)
+ON CONFLICT (name) DO NOTHING;
+
+UPDATE applications SET orgID=(SELECT id FROM organisations WHERE name='default') WHERE orgID IS NULL;
+
+ALTER TABLE applications ALTER COLUMN orgID SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS organisationMembers(
+id SERIAL PRIMARY KEY,
+orgID INTEGER NOT NULL,
+userID INTEGER NOT NULL,
+role VARCHAR(32) NOT NULL,
+createdat timestamp(0) with time zone,
+UNIQUE (orgID, userID),
+FOREIGN KEY (orgID) REFERENCES organisations (id) ON DELETE CASCADE,
+FOREIGN KEY (userID) REFERENCES users (id) ON DELETE CASCADE
+);
+
+INSERT INTO organisationMembers (orgID, userID, role, createdat)
+SELECT DISTINCT a.orgID, r.userID, 'member', now() FROM roleAssignments r
+INNER JOIN applications a ON a.id=r.appID
+ON CONFLICT (orgID, userID) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS teams(
+id SERIAL PRIMARY KEY,
+orgID INTEGER NOT NULL,
+name VARCHAR(255) NOT NULL,
+createdat timestamp(0) with time zone,
+updatedat timestamp(0) with time zone,
+UNIQUE (orgID, name),
+FOREIGN KEY (orgID) REFERENCES organisations (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS teamMembers(
+id SERIAL PRIMARY KEY,
+teamID INTEGER NOT NULL,
+userID INTEGER NOT NULL,
+createdat timestamp(0) with time zone,
+UNIQUE (teamID, userID),
+FOREIGN KEY (teamID) REFERENCES teams (id) ON DELETE CASCADE,
+FOREIGN KEY (userID) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS teamApplications(
+id SERIAL PRIMARY KEY,
+teamID INTEGER NOT NULL,
+appID INTEGER NOT NULL,
+role VARCHAR(32) NOT NULL,
+createdat timestamp(0) with time zone,
+UNIQUE (teamID, appID),
+FOREIGN KEY (teamID) REFERENCES teams (id) ON DELETE CASCADE,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE IF EXISTS teamApplications;
+DROP TABLE IF EXISTS teamMembers;
+DROP TABLE IF EXISTS teams;
+DROP TABLE IF EXISTS organisationMembers;
+ALTER TABLE applications DROP COLUMN IF EXISTS orgID;
+DROP TABLE IF EXISTS organisations;`,
+	},
+	{
+		Version: 7,
+		Name:    "invitations",
+		Up: `CREATE TABLE IF NOT EXISTS invitations(
+id SERIAL PRIMARY KEY,
+email VARCHAR(510) NOT NULL,
+orgID INTEGER,
+appID INTEGER,
+role VARCHAR(32) NOT NULL,
+invitedBy INTEGER,
+status VARCHAR(16) NOT NULL,
+expiresat timestamp(0) with time zone,
+createdat timestamp(0) with time zone,
+respondedat timestamp(0) with time zone,
+CHECK ((orgID IS NULL) <> (appID IS NULL)),
+FOREIGN KEY (orgID) REFERENCES organisations (id) ON DELETE CASCADE,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE,
+FOREIGN KEY (invitedBy) REFERENCES users (id) ON DELETE SET NULL
+);`,
+		Down: `DROP TABLE IF EXISTS invitations;`,
+	},
+	{
+		Version: 8,
+		Name:    "api_keys",
+		Up: `CREATE TABLE IF NOT EXISTS apiKeys(
+id SERIAL PRIMARY KEY,
+appID INTEGER NOT NULL,
+name VARCHAR(255) NOT NULL,
+prefix VARCHAR(32) UNIQUE NOT NULL,
+hash VARCHAR(64) NOT NULL,
+scopes VARCHAR(255) NOT NULL,
+createdBy INTEGER,
+expiresat timestamp(0) with time zone,
+lastusedat timestamp(0) with time zone,
+revokedat timestamp(0) with time zone,
+createdat timestamp(0) with time zone,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE,
+FOREIGN KEY (createdBy) REFERENCES users (id) ON DELETE SET NULL
+);`,
+		Down: `DROP TABLE IF EXISTS apiKeys;`,
+	},
+	{
+		Version: 9,
+		Name:    "subjects",
+		Up: `CREATE TABLE IF NOT EXISTS subjects(
+id SERIAL PRIMARY KEY,
+appID INTEGER NOT NULL,
+externalID VARCHAR(255) NOT NULL,
+secret VARCHAR(64) NOT NULL,
+confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+lastCounter BIGINT NOT NULL DEFAULT 0,
+failedAttempts INTEGER NOT NULL DEFAULT 0,
+lockeduntil timestamp(0) with time zone,
+createdat timestamp(0) with time zone,
+updatedat timestamp(0) with time zone,
+UNIQUE (appID, externalID),
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE IF EXISTS subjects;`,
+	},
+	{
+		Version: 10,
+		Name:    "webhooks",
+		Up: `CREATE TABLE IF NOT EXISTS webhookEndpoints(
+id SERIAL PRIMARY KEY,
+appID INTEGER NOT NULL,
+url TEXT NOT NULL,
+secret VARCHAR(64) NOT NULL,
+events VARCHAR(255) NOT NULL,
+active BOOLEAN NOT NULL DEFAULT TRUE,
+createdat timestamp(0) with time zone,
+updatedat timestamp(0) with time zone,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS webhookDeliveries(
+id SERIAL PRIMARY KEY,
+endpointID INTEGER NOT NULL,
+appID INTEGER NOT NULL,
+eventID VARCHAR(32) NOT NULL,
+event VARCHAR(64) NOT NULL,
+payload TEXT NOT NULL,
+status VARCHAR(16) NOT NULL,
+attempts INTEGER NOT NULL DEFAULT 0,
+nextattemptat timestamp(0) with time zone,
+createdat timestamp(0) with time zone,
+deliveredat timestamp(0) with time zone,
+FOREIGN KEY (endpointID) REFERENCES webhookEndpoints (id) ON DELETE CASCADE,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhookDeliveriesDue ON webhookDeliveries (status, nextattemptat);
+
+CREATE TABLE IF NOT EXISTS webhookAttempts(
+id SERIAL PRIMARY KEY,
+deliveryID INTEGER NOT NULL,
+statusCode INTEGER NOT NULL DEFAULT 0,
+response TEXT NOT NULL DEFAULT '',
+error TEXT NOT NULL DEFAULT '',
+duration INTEGER NOT NULL DEFAULT 0,
+createdat timestamp(0) with time zone,
+FOREIGN KEY (deliveryID) REFERENCES webhookDeliveries (id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE IF EXISTS webhookAttempts;
+DROP TABLE IF EXISTS webhookDeliveries;
+DROP TABLE IF EXISTS webhookEndpoints;`,
+	},
+	{
+		Version: 11,
+		Name:    "subjectRecoveryCodes",
+		Up: `CREATE TABLE IF NOT EXISTS subjectRecoveryCodes(
+id SERIAL PRIMARY KEY,
+subjectID INTEGER NOT NULL,
+hash VARCHAR(64) NOT NULL,
+usedat timestamp(0) with time zone,
+createdat timestamp(0) with time zone,
+UNIQUE (subjectID, hash),
+FOREIGN KEY (subjectID) REFERENCES subjects (id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE IF EXISTS subjectRecoveryCodes;`,
+	},
+	{
+		Version: 12,
+		Name:    "usersEmailLower",
+		Up:      `CREATE UNIQUE INDEX IF NOT EXISTS usersEmailLower ON users (lower(email));`,
+		Down:    `DROP INDEX IF EXISTS usersEmailLower;`,
+	},
+}
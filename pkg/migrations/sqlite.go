@@ -0,0 +1,340 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SQLite is the dialect of SQLite, which is meant for single node deployments. The migrations
+// are not locked, since SQLite serializes the writes anyway, and a concurrent attempt to apply
+// the same migration fails while recording it
+var SQLite = &Dialect{
+	Name:       "sqlite",
+	Migrations: sqlite,
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations(
+version INTEGER PRIMARY KEY,
+name VARCHAR(255) NOT NULL,
+checksum VARCHAR(64) NOT NULL,
+appliedat TIMESTAMP NOT NULL
+)`,
+	tableExists: "SELECT COUNT(*) > 0 FROM sqlite_master WHERE type='table' AND name='schema_migrations'",
+	placeholder: func(n int) string {
+		return "?"
+	},
+	lock: func(ctx context.Context, conn *sql.Conn) error {
+		return nil
+	},
+	unlock: func(ctx context.Context, conn *sql.Conn) error {
+		return nil
+	},
+}
+
+// sqlite are the migrations of SQLite, every migration of Postgres should have its equivalent
+// here with the same version. SQLite (as bundled) cannot drop columns, so the migrations adding
+// columns cannot be rolled back
+var sqlite = []Migration{
+	{
+		Version: 1,
+		Name:    "initial",
+		Up: `CREATE TABLE IF NOT EXISTS applications(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+name VARCHAR(255) UNIQUE NOT NULL,
+description VARCHAR(2048),
+totp TEXT,
+createdat TIMESTAMP,
+updatedat TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS users(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255),
+	email VARCHAR(510) UNIQUE NOT NULL,
+	phone VARCHAR(30),
+	password VARCHAR(255),
+	salt VARCHAR(64),
+	createdat TIMESTAMP,
+	updatedat TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS applicationOwners(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+appID INTEGER,
+userID INTEGER,
+createdat TIMESTAMP,
+FOREIGN KEY (appID) REFERENCES applications (id),
+FOREIGN KEY (userID) REFERENCES users (id)
+);`,
+		Down: `DROP TABLE IF EXISTS applicationOwners;
+DROP TABLE IF EXISTS applications;
+DROP TABLE IF EXISTS users;`,
+	},
+	{
+		Version: 2,
+		Name:    "users_soft_delete_version",
+		Up: `ALTER TABLE users ADD COLUMN deletedat TIMESTAMP;
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+	},
+	{
+		Version: 3,
+		Name:    "webauthn_credentials",
+		Up: `CREATE TABLE IF NOT EXISTS webauthnCredentials(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+userID INTEGER NOT NULL,
+name VARCHAR(255),
+credentialID BLOB UNIQUE NOT NULL,
+publicKey BLOB NOT NULL,
+algorithm INTEGER NOT NULL,
+signCount BIGINT NOT NULL DEFAULT 0,
+aaguid BLOB,
+attestation VARCHAR(32),
+userVerified BOOLEAN NOT NULL DEFAULT FALSE,
+createdat TIMESTAMP,
+lastusedat TIMESTAMP,
+FOREIGN KEY (userID) REFERENCES users (id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE IF EXISTS webauthnCredentials;`,
+	},
+	{
+		Version: 4,
+		Name:    "push_devices",
+		Up: `CREATE TABLE IF NOT EXISTS pushDevices(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+userID INTEGER NOT NULL,
+name VARCHAR(255),
+publicKey BLOB NOT NULL,
+pushToken VARCHAR(4096) NOT NULL,
+createdat TIMESTAMP,
+lastusedat TIMESTAMP,
+FOREIGN KEY (userID) REFERENCES users (id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE IF EXISTS pushDevices;`,
+	},
+	{
+		// the application owners become role assignments, with the owner role of the app
+		Version: 5,
+		Name:    "role_assignments",
+		Up: `CREATE TABLE IF NOT EXISTS roleAssignments(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+userID INTEGER NOT NULL,
+role VARCHAR(32) NOT NULL,
+appID INTEGER,
+createdat TIMESTAMP,
+UNIQUE (userID, role, appID),
+FOREIGN KEY (userID) REFERENCES users (id) ON DELETE CASCADE,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE
+);
+
+INSERT OR IGNORE INTO roleAssignments (userID, role, appID, createdat)
+SELECT userID, 'owner', appID, MIN(createdat) FROM applicationOwners
+WHERE appID IS NOT NULL AND userID IS NOT NULL
+GROUP BY userID, appID;
+
+DROP TABLE applicationOwners;`,
+		Down: `CREATE TABLE applicationOwners(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+appID INTEGER,
+userID INTEGER,
+createdat TIMESTAMP,
+FOREIGN KEY (appID) REFERENCES applications (id),
+FOREIGN KEY (userID) REFERENCES users (id)
+);
+
+INSERT INTO applicationOwners (appID, userID, createdat)
+SELECT appID, userID, createdat FROM roleAssignments WHERE role='owner' AND appID IS NOT NULL ORDER BY id;
+
+DROP TABLE roleAssignments;`,
+	},
+	{
+		// every application belongs to an organisation, the existing ones are moved to the
+		// 'default' organisation, and their owners become its members. Columns cannot be made
+		// NOT NULL once added, so the stores ensure that orgID is always set
+		Version: 6,
+		Name:    "organisations",
+		Up: `CREATE TABLE IF NOT EXISTS organisations(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+name VARCHAR(255) UNIQUE NOT NULL,
+createdat TIMESTAMP,
+updatedat TIMESTAMP
+);
+
+ALTER TABLE applications ADD COLUMN orgID INTEGER REFERENCES organisations (id) ON DELETE CASCADE;
+
+INSERT OR IGNORE INTO organisations (name, createdat, updatedat)
+SELECT 'default', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP WHERE EXISTS (SELECT 1 FROM applications WHERE orgID IS NULL);
+
+UPDATE applications SET orgID=(SELECT id FROM organisations WHERE name='default') WHERE orgID IS NULL;
+
+CREATE TABLE IF NOT EXISTS organisationMembers(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+orgID INTEGER NOT NULL,
+userID INTEGER NOT NULL,
+role VARCHAR(32) NOT NULL,
+createdat TIMESTAMP,
+UNIQUE (orgID, userID),
+FOREIGN KEY (orgID) REFERENCES organisations (id) ON DELETE CASCADE,
+FOREIGN KEY (userID) REFERENCES users (id) ON DELETE CASCADE
+);
+
+INSERT OR IGNORE INTO organisationMembers (orgID, userID, role, createdat)
+SELECT DISTINCT a.orgID, r.userID, 'member', CURRENT_TIMESTAMP FROM roleAssignments r
+INNER JOIN applications a ON a.id=r.appID;
+
+CREATE TABLE IF NOT EXISTS teams(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+orgID INTEGER NOT NULL,
+name VARCHAR(255) NOT NULL,
+createdat TIMESTAMP,
+updatedat TIMESTAMP,
+UNIQUE (orgID, name),
+FOREIGN KEY (orgID) REFERENCES organisations (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS teamMembers(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+teamID INTEGER NOT NULL,
+userID INTEGER NOT NULL,
+createdat TIMESTAMP,
+UNIQUE (teamID, userID),
+FOREIGN KEY (teamID) REFERENCES teams (id) ON DELETE CASCADE,
+FOREIGN KEY (userID) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS teamApplications(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+teamID INTEGER NOT NULL,
+appID INTEGER NOT NULL,
+role VARCHAR(32) NOT NULL,
+createdat TIMESTAMP,
+UNIQUE (teamID, appID),
+FOREIGN KEY (teamID) REFERENCES teams (id) ON DELETE CASCADE,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE
+);`,
+	},
+	{
+		Version: 7,
+		Name:    "invitations",
+		Up: `CREATE TABLE IF NOT EXISTS invitations(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+email VARCHAR(510) NOT NULL,
+orgID INTEGER,
+appID INTEGER,
+role VARCHAR(32) NOT NULL,
+invitedBy INTEGER,
+status VARCHAR(16) NOT NULL,
+expiresat TIMESTAMP,
+createdat TIMESTAMP,
+respondedat TIMESTAMP,
+CHECK ((orgID IS NULL) <> (appID IS NULL)),
+FOREIGN KEY (orgID) REFERENCES organisations (id) ON DELETE CASCADE,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE,
+FOREIGN KEY (invitedBy) REFERENCES users (id) ON DELETE SET NULL
+);`,
+		Down: `DROP TABLE IF EXISTS invitations;`,
+	},
+	{
+		Version: 8,
+		Name:    "api_keys",
+		Up: `CREATE TABLE IF NOT EXISTS apiKeys(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+appID INTEGER NOT NULL,
+name VARCHAR(255) NOT NULL,
+prefix VARCHAR(32) UNIQUE NOT NULL,
+hash VARCHAR(64) NOT NULL,
+scopes VARCHAR(255) NOT NULL,
+createdBy INTEGER,
+expiresat TIMESTAMP,
+lastusedat TIMESTAMP,
+revokedat TIMESTAMP,
+createdat TIMESTAMP,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE,
+FOREIGN KEY (createdBy) REFERENCES users (id) ON DELETE SET NULL
+);`,
+		Down: `DROP TABLE IF EXISTS apiKeys;`,
+	},
+	{
+		Version: 9,
+		Name:    "subjects",
+		Up: `CREATE TABLE IF NOT EXISTS subjects(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+appID INTEGER NOT NULL,
+externalID VARCHAR(255) NOT NULL,
+secret VARCHAR(64) NOT NULL,
+confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+lastCounter BIGINT NOT NULL DEFAULT 0,
+failedAttempts INTEGER NOT NULL DEFAULT 0,
+lockeduntil TIMESTAMP,
+createdat TIMESTAMP,
+updatedat TIMESTAMP,
+UNIQUE (appID, externalID),
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE IF EXISTS subjects;`,
+	},
+	{
+		Version: 10,
+		Name:    "webhooks",
+		Up: `CREATE TABLE IF NOT EXISTS webhookEndpoints(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+appID INTEGER NOT NULL,
+url TEXT NOT NULL,
+secret VARCHAR(64) NOT NULL,
+events VARCHAR(255) NOT NULL,
+active BOOLEAN NOT NULL DEFAULT TRUE,
+createdat TIMESTAMP,
+updatedat TIMESTAMP,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS webhookDeliveries(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+endpointID INTEGER NOT NULL,
+appID INTEGER NOT NULL,
+eventID VARCHAR(32) NOT NULL,
+event VARCHAR(64) NOT NULL,
+payload TEXT NOT NULL,
+status VARCHAR(16) NOT NULL,
+attempts INTEGER NOT NULL DEFAULT 0,
+nextattemptat TIMESTAMP,
+createdat TIMESTAMP,
+deliveredat TIMESTAMP,
+FOREIGN KEY (endpointID) REFERENCES webhookEndpoints (id) ON DELETE CASCADE,
+FOREIGN KEY (appID) REFERENCES applications (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhookDeliveriesDue ON webhookDeliveries (status, nextattemptat);
+
+CREATE TABLE IF NOT EXISTS webhookAttempts(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+deliveryID INTEGER NOT NULL,
+statusCode INTEGER NOT NULL DEFAULT 0,
+response TEXT NOT NULL DEFAULT '',
+error TEXT NOT NULL DEFAULT '',
+duration INTEGER NOT NULL DEFAULT 0,
+createdat TIMESTAMP,
+FOREIGN KEY (deliveryID) REFERENCES webhookDeliveries (id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE IF EXISTS webhookAttempts;
+DROP TABLE IF EXISTS webhookDeliveries;
+DROP TABLE IF EXISTS webhookEndpoints;`,
+	},
+	{
+		Version: 11,
+		Name:    "subjectRecoveryCodes",
+		Up: `CREATE TABLE IF NOT EXISTS subjectRecoveryCodes(
+id INTEGER PRIMARY KEY AUTOINCREMENT,
+subjectID INTEGER NOT NULL,
+hash VARCHAR(64) NOT NULL,
+usedat TIMESTAMP,
+createdat TIMESTAMP,
+UNIQUE (subjectID, hash),
+FOREIGN KEY (subjectID) REFERENCES subjects (id) ON DELETE CASCADE
+);`,
+		Down: `DROP TABLE IF EXISTS subjectRecoveryCodes;`,
+	},
+	{
+		Version: 12,
+		Name:    "usersEmailLower",
+		Up:      `CREATE UNIQUE INDEX IF NOT EXISTS usersEmailLower ON users (lower(email));`,
+		Down:    `DROP INDEX IF EXISTS usersEmailLower;`,
+	},
+}
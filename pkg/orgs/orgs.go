@@ -0,0 +1,558 @@
+// Package orgs handles organisations, their members & teams. Applications are owned by an
+// organisation, and members get access to the applications through their teams
+package orgs
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// Role is the role of a member within an organisation
+type Role string
+
+const (
+	// RoleOwner can do everything an admin can, and also manage other owners & delete the
+	// organisation
+	RoleOwner = Role("owner")
+	// RoleAdmin can manage members, teams and all the applications of the organisation
+	RoleAdmin = Role("admin")
+	// RoleMember can only access the applications granted to their teams
+	RoleMember = Role("member")
+)
+
+var (
+	ErrInvalidName     = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no name provided")
+	ErrInvalidID       = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no organisation ID provided")
+	ErrInvalidRole     = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid role provided")
+	ErrInvalidApp      = apperr.New(apperr.CodeInvalidInput, "Sorry, the application does not belong to the organisation")
+	ErrNameExists      = apperr.New(apperr.CodeConflict, "Sorry, the name is already taken")
+	ErrNotFound        = apperr.New(apperr.CodeNotFound, "Sorry, organisation not found")
+	ErrTeamNotFound    = apperr.New(apperr.CodeNotFound, "Sorry, team not found")
+	ErrMemberNotFound  = apperr.New(apperr.CodeNotFound, "Sorry, member not found")
+	ErrNotMember       = apperr.New(apperr.CodeInvalidInput, "Sorry, the user should be a member of the organisation")
+	ErrLastOwner       = apperr.New(apperr.CodeConflict, "Sorry, an organisation should have at least one owner")
+	ErrAlreadyMember   = apperr.New(apperr.CodeConflict, "Sorry, the user is already a member")
+	ErrInvalidTeamRole = apperr.New(apperr.CodeInvalidInput, "Sorry, teams can only be granted owner, developer or viewer roles")
+	ErrUnexpected      = apperr.New(apperr.CodeInternal, "Sorry, an unexpected error occurred")
+
+	roleRank = map[Role]int{
+		RoleMember: 1,
+		RoleAdmin:  2,
+		RoleOwner:  3,
+	}
+)
+
+// Organisation is a group of users, which owns applications
+type Organisation struct {
+	ID   int64  `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Role is the role of the authenticated user within the organisation, it is only set while
+	// listing the organisations of a user
+	Role      Role       `json:"role,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// Member is a user who is a member of an organisation
+type Member struct {
+	OrgID     int64      `json:"orgId,omitempty"`
+	UserID    int64      `json:"userId,omitempty"`
+	Role      Role       `json:"role,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// Team is a group of members within an organisation
+type Team struct {
+	ID        int64      `json:"id,omitempty"`
+	OrgID     int64      `json:"orgId,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// TeamMember is a member of a team
+type TeamMember struct {
+	TeamID    int64      `json:"teamId,omitempty"`
+	UserID    int64      `json:"userId,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// TeamApp grants all the members of a team a role on an application
+type TeamApp struct {
+	TeamID    int64      `json:"teamId,omitempty"`
+	AppID     int64      `json:"appId,omitempty"`
+	Role      rbac.Role  `json:"role,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+func validRole(r Role) bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// Authorizer checks if the caller, identified by the context, is allowed a platform wide
+// permission
+type Authorizer interface {
+	Authorize(ctx context.Context, perm rbac.Permission, appID int64) error
+}
+
+// Orgs handles all the service methods made available by this package
+type Orgs struct {
+	appCtx *appcontext.AppContext
+	store  store
+	auth   Authorizer
+}
+
+func (o *Orgs) unexpected(err error) error {
+	if o.appCtx.Logging {
+		o.appCtx.Logger.Error(err)
+	}
+	return ErrUnexpected
+}
+
+// storeErr returns the errors known to this package as is, and ErrUnexpected for the rest
+func (o *Orgs) storeErr(err error) error {
+	switch err {
+	case ErrNotFound, ErrTeamNotFound, ErrMemberNotFound, ErrNameExists, ErrInvalidApp, ErrAlreadyMember:
+		{
+			return err
+		}
+	}
+	return o.unexpected(err)
+}
+
+// Authorize checks if the user in the context has at least the given role in the organisation.
+// Platform admins (rbac.PermOrgs) and system contexts are allowed everything
+func (o *Orgs) Authorize(ctx context.Context, orgID int64, min Role) error {
+	err := o.auth.Authorize(ctx, rbac.PermOrgs, 0)
+	if err != rbac.ErrForbidden {
+		return err
+	}
+
+	u := users.FromContext(ctx)
+	m, err := o.store.Member(ctx, orgID, u.ID)
+	if err != nil {
+		if err == ErrMemberNotFound {
+			return rbac.ErrForbidden
+		}
+		return o.unexpected(err)
+	}
+
+	if roleRank[m.Role] < roleRank[min] {
+		return rbac.ErrForbidden
+	}
+	return nil
+}
+
+// AuthorizeApps returns nil if the user in the context can create & manage all the applications
+// of the organisation
+func (o *Orgs) AuthorizeApps(ctx context.Context, orgID int64) error {
+	if orgID < 1 {
+		return ErrInvalidID
+	}
+	return o.Authorize(ctx, orgID, RoleAdmin)
+}
+
+// Create creates a new organisation, with the given user as its owner. Users can only create
+// organisations owned by themselves, unless the caller is a platform admin
+func (o *Orgs) Create(ctx context.Context, org Organisation, ownerID int64) (*Organisation, error) {
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return nil, ErrInvalidName
+	}
+
+	u := users.FromContext(ctx)
+	if u == nil || u.ID != ownerID {
+		err := o.auth.Authorize(ctx, rbac.PermOrgs, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	org.ID = 0
+	org.CreatedAt = &now
+	org.UpdatedAt = &now
+
+	created, err := o.store.Create(ctx, org, Member{UserID: ownerID, Role: RoleOwner, CreatedAt: &now})
+	if err != nil {
+		return nil, o.storeErr(err)
+	}
+	created.Role = RoleOwner
+	return created, nil
+}
+
+// Read returns the organisation, only members are allowed to read it
+func (o *Orgs) Read(ctx context.Context, id int64) (*Organisation, error) {
+	err := o.Authorize(ctx, id, RoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := o.store.Read(ctx, id)
+	if err != nil {
+		return nil, o.storeErr(err)
+	}
+	return org, nil
+}
+
+// List lists all the organisations the user in the context is a member of
+func (o *Orgs) List(ctx context.Context) ([]Organisation, error) {
+	u := users.FromContext(ctx)
+	if u == nil {
+		return nil, rbac.ErrUnauthenticated
+	}
+
+	list, err := o.store.ListByUser(ctx, u.ID)
+	if err != nil {
+		return nil, o.unexpected(err)
+	}
+	return list, nil
+}
+
+// Update updates the name of the organisation
+func (o *Orgs) Update(ctx context.Context, org Organisation) (*Organisation, error) {
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return nil, ErrInvalidName
+	}
+
+	err := o.Authorize(ctx, org.ID, RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	org.UpdatedAt = &now
+	updated, err := o.store.Update(ctx, org)
+	if err != nil {
+		return nil, o.storeErr(err)
+	}
+	return updated, nil
+}
+
+// Delete deletes the organisation, along with all its teams & applications
+func (o *Orgs) Delete(ctx context.Context, id int64) error {
+	err := o.Authorize(ctx, id, RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	err = o.store.Delete(ctx, id)
+	if err != nil {
+		return o.storeErr(err)
+	}
+	return nil
+}
+
+// Members lists all the members of the organisation
+func (o *Orgs) Members(ctx context.Context, orgID int64) ([]Member, error) {
+	err := o.Authorize(ctx, orgID, RoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := o.store.Members(ctx, orgID)
+	if err != nil {
+		return nil, o.unexpected(err)
+	}
+	return list, nil
+}
+
+// SetMember adds the user to the organisation, or updates the role if already a member. Only
+// owners can make other users owners, or change the role of an owner
+func (o *Orgs) SetMember(ctx context.Context, m Member) (*Member, error) {
+	if m.UserID < 1 || !validRole(m.Role) {
+		return nil, ErrInvalidRole
+	}
+
+	existing, err := o.store.Member(ctx, m.OrgID, m.UserID)
+	if err != nil && err != ErrMemberNotFound {
+		return nil, o.unexpected(err)
+	}
+
+	min := RoleAdmin
+	if m.Role == RoleOwner || (existing != nil && existing.Role == RoleOwner) {
+		min = RoleOwner
+	}
+
+	err = o.Authorize(ctx, m.OrgID, min)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil && existing.Role == RoleOwner && m.Role != RoleOwner {
+		err = o.ensureOwner(ctx, m.OrgID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	m.CreatedAt = &now
+	updated, err := o.store.SetMember(ctx, m)
+	if err != nil {
+		return nil, o.storeErr(err)
+	}
+	return updated, nil
+}
+
+// ensureOwner returns ErrLastOwner if the organisation has only one owner left
+func (o *Orgs) ensureOwner(ctx context.Context, orgID int64) error {
+	list, err := o.store.Members(ctx, orgID)
+	if err != nil {
+		return o.unexpected(err)
+	}
+
+	owners := 0
+	for _, m := range list {
+		if m.Role == RoleOwner {
+			owners++
+		}
+	}
+
+	if owners < 2 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// RemoveMember removes the user from the organisation & all its teams. Members can remove
+// themselves, i.e. leave the organisation
+func (o *Orgs) RemoveMember(ctx context.Context, orgID, userID int64) error {
+	existing, err := o.store.Member(ctx, orgID, userID)
+	if err != nil {
+		if err == ErrMemberNotFound {
+			// not revealing membership details to non-members
+			err = o.Authorize(ctx, orgID, RoleAdmin)
+			if err != nil {
+				return err
+			}
+			return ErrMemberNotFound
+		}
+		return o.unexpected(err)
+	}
+
+	u := users.FromContext(ctx)
+	if u == nil || u.ID != userID {
+		min := RoleAdmin
+		if existing.Role == RoleOwner {
+			min = RoleOwner
+		}
+
+		err = o.Authorize(ctx, orgID, min)
+		if err != nil {
+			return err
+		}
+	}
+
+	if existing.Role == RoleOwner {
+		err = o.ensureOwner(ctx, orgID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = o.store.RemoveMember(ctx, orgID, userID)
+	if err != nil {
+		return o.storeErr(err)
+	}
+	return nil
+}
+
+// CreateTeam creates a new team within the organisation
+func (o *Orgs) CreateTeam(ctx context.Context, t Team) (*Team, error) {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return nil, ErrInvalidName
+	}
+
+	err := o.Authorize(ctx, t.OrgID, RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	t.ID = 0
+	t.CreatedAt = &now
+	t.UpdatedAt = &now
+	created, err := o.store.CreateTeam(ctx, t)
+	if err != nil {
+		return nil, o.storeErr(err)
+	}
+	return created, nil
+}
+
+// Teams lists all the teams of the organisation
+func (o *Orgs) Teams(ctx context.Context, orgID int64) ([]Team, error) {
+	err := o.Authorize(ctx, orgID, RoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := o.store.Teams(ctx, orgID)
+	if err != nil {
+		return nil, o.unexpected(err)
+	}
+	return list, nil
+}
+
+// DeleteTeam deletes the team, its members lose access to the applications granted to the team
+func (o *Orgs) DeleteTeam(ctx context.Context, orgID, teamID int64) error {
+	err := o.Authorize(ctx, orgID, RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	err = o.store.DeleteTeam(ctx, orgID, teamID)
+	if err != nil {
+		return o.storeErr(err)
+	}
+	return nil
+}
+
+// TeamMembers lists all the members of the team
+func (o *Orgs) TeamMembers(ctx context.Context, orgID, teamID int64) ([]TeamMember, error) {
+	err := o.Authorize(ctx, orgID, RoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = o.store.Team(ctx, orgID, teamID)
+	if err != nil {
+		return nil, o.storeErr(err)
+	}
+
+	list, err := o.store.TeamMembers(ctx, teamID)
+	if err != nil {
+		return nil, o.unexpected(err)
+	}
+	return list, nil
+}
+
+// AddTeamMember adds a member of the organisation to the team
+func (o *Orgs) AddTeamMember(ctx context.Context, orgID, teamID, userID int64) (*TeamMember, error) {
+	err := o.Authorize(ctx, orgID, RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = o.store.Team(ctx, orgID, teamID)
+	if err != nil {
+		return nil, o.storeErr(err)
+	}
+
+	_, err = o.store.Member(ctx, orgID, userID)
+	if err != nil {
+		if err == ErrMemberNotFound {
+			return nil, ErrNotMember
+		}
+		return nil, o.unexpected(err)
+	}
+
+	now := time.Now().UTC()
+	tm, err := o.store.AddTeamMember(ctx, TeamMember{TeamID: teamID, UserID: userID, CreatedAt: &now})
+	if err != nil {
+		return nil, o.storeErr(err)
+	}
+	return tm, nil
+}
+
+// RemoveTeamMember removes the user from the team
+func (o *Orgs) RemoveTeamMember(ctx context.Context, orgID, teamID, userID int64) error {
+	err := o.Authorize(ctx, orgID, RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.store.Team(ctx, orgID, teamID)
+	if err != nil {
+		return o.storeErr(err)
+	}
+
+	err = o.store.RemoveTeamMember(ctx, teamID, userID)
+	if err != nil {
+		return o.storeErr(err)
+	}
+	return nil
+}
+
+// GrantTeamApp grants the members of the team a role on an application of the organisation.
+// If the team already has a role on the application, it is replaced
+func (o *Orgs) GrantTeamApp(ctx context.Context, orgID int64, ta TeamApp) (*TeamApp, error) {
+	if ta.Role != rbac.RoleOwner && ta.Role != rbac.RoleDeveloper && ta.Role != rbac.RoleViewer {
+		return nil, ErrInvalidTeamRole
+	}
+
+	err := o.Authorize(ctx, orgID, RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = o.store.Team(ctx, orgID, ta.TeamID)
+	if err != nil {
+		return nil, o.storeErr(err)
+	}
+
+	now := time.Now().UTC()
+	ta.CreatedAt = &now
+	granted, err := o.store.SetTeamApp(ctx, orgID, ta)
+	if err != nil {
+		return nil, o.storeErr(err)
+	}
+	return granted, nil
+}
+
+// RevokeTeamApp revokes the access of the team to the application
+func (o *Orgs) RevokeTeamApp(ctx context.Context, orgID, teamID, appID int64) error {
+	err := o.Authorize(ctx, orgID, RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.store.Team(ctx, orgID, teamID)
+	if err != nil {
+		return o.storeErr(err)
+	}
+
+	err = o.store.RemoveTeamApp(ctx, teamID, appID)
+	if err != nil {
+		return o.storeErr(err)
+	}
+	return nil
+}
+
+// TeamApps lists all the applications granted to the team
+func (o *Orgs) TeamApps(ctx context.Context, orgID, teamID int64) ([]TeamApp, error) {
+	err := o.Authorize(ctx, orgID, RoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = o.store.Team(ctx, orgID, teamID)
+	if err != nil {
+		return nil, o.storeErr(err)
+	}
+
+	list, err := o.store.TeamApps(ctx, teamID)
+	if err != nil {
+		return nil, o.unexpected(err)
+	}
+	return list, nil
+}
+
+func New(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB, auth Authorizer) *Orgs {
+	return &Orgs{
+		appCtx: appCtx,
+		store:  newStore(appCtx, driver, sdb),
+		auth:   auth,
+	}
+}
@@ -0,0 +1,512 @@
+package orgs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+)
+
+const (
+	orgsTable        = "organisations"
+	membersTable     = "organisationMembers"
+	teamsTable       = "teams"
+	teamMembersTable = "teamMembers"
+	teamAppsTable    = "teamApplications"
+	appTable         = "applications"
+
+	pqUniqueViolation = "23505"
+)
+
+type store interface {
+	Create(ctx context.Context, org Organisation, owner Member) (*Organisation, error)
+	Read(ctx context.Context, id int64) (*Organisation, error)
+	ListByUser(ctx context.Context, userID int64) ([]Organisation, error)
+	Update(ctx context.Context, org Organisation) (*Organisation, error)
+	Delete(ctx context.Context, id int64) error
+
+	Member(ctx context.Context, orgID, userID int64) (*Member, error)
+	Members(ctx context.Context, orgID int64) ([]Member, error)
+	SetMember(ctx context.Context, m Member) (*Member, error)
+	RemoveMember(ctx context.Context, orgID, userID int64) error
+
+	CreateTeam(ctx context.Context, t Team) (*Team, error)
+	Team(ctx context.Context, orgID, teamID int64) (*Team, error)
+	Teams(ctx context.Context, orgID int64) ([]Team, error)
+	DeleteTeam(ctx context.Context, orgID, teamID int64) error
+
+	TeamMembers(ctx context.Context, teamID int64) ([]TeamMember, error)
+	AddTeamMember(ctx context.Context, tm TeamMember) (*TeamMember, error)
+	RemoveTeamMember(ctx context.Context, teamID, userID int64) error
+
+	TeamApps(ctx context.Context, teamID int64) ([]TeamApp, error)
+	SetTeamApp(ctx context.Context, orgID int64, ta TeamApp) (*TeamApp, error)
+	RemoveTeamApp(ctx context.Context, teamID, appID int64) error
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type dbStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// newStore returns the store of the driver
+func newStore(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB) store {
+	if driver == database.SQLite {
+		return &sqliteStore{
+			appCtx: appCtx,
+			db:     sdb,
+		}
+	}
+	return &dbStore{
+		appCtx: appCtx,
+		db:     sdb,
+	}
+}
+
+func (dbs *dbStore) rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && err != sql.ErrTxDone {
+		dbs.appCtx.Logger.Error(err)
+	}
+}
+
+func (dbs *dbStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, fmt.Sprintf("$%d", i+1))
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == pqUniqueViolation
+}
+
+// affected returns notFound if the result did not affect any rows
+func affected(result sql.Result, notFound error) error {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func nullTime(t pq.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func scanOrg(row scanner, extra ...interface{}) (*Organisation, error) {
+	org := Organisation{}
+	createdAt := pq.NullTime{}
+	updatedAt := pq.NullTime{}
+
+	dest := append([]interface{}{&org.ID, &org.Name, &createdAt, &updatedAt}, extra...)
+	err := row.Scan(dest...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	org.CreatedAt = nullTime(createdAt)
+	org.UpdatedAt = nullTime(updatedAt)
+	return &org, nil
+}
+
+func (dbs *dbStore) Create(ctx context.Context, org Organisation, owner Member) (*Organisation, error) {
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer dbs.rollback(tx)
+
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s RETURNING id",
+		orgsTable,
+		dbs.prepColVals("name", "createdat", "updatedat"),
+	)
+	err = tx.QueryRowContext(ctx, stmt, org.Name, org.CreatedAt, org.UpdatedAt).Scan(&org.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrNameExists
+		}
+		return nil, err
+	}
+
+	stmt = fmt.Sprintf(
+		"INSERT INTO %s %s",
+		membersTable,
+		dbs.prepColVals("orgid", "userid", "role", "createdat"),
+	)
+	_, err = tx.ExecContext(ctx, stmt, org.ID, owner.UserID, owner.Role, owner.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+func (dbs *dbStore) Read(ctx context.Context, id int64) (*Organisation, error) {
+	stmt := fmt.Sprintf("SELECT id,name,createdat,updatedat FROM %s WHERE id=$1", orgsTable)
+	return scanOrg(dbs.db.QueryRowContext(ctx, stmt, id))
+}
+
+func (dbs *dbStore) ListByUser(ctx context.Context, userID int64) ([]Organisation, error) {
+	stmt := fmt.Sprintf(
+		"SELECT o.id,o.name,o.createdat,o.updatedat,m.role FROM %s o INNER JOIN %s m ON m.orgid=o.id WHERE m.userid=$1 ORDER BY o.name",
+		orgsTable,
+		membersTable,
+	)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Organisation, 0)
+	for rows.Next() {
+		role := ""
+		org, err := scanOrg(rows, &role)
+		if err != nil {
+			return nil, err
+		}
+		org.Role = Role(role)
+		list = append(list, *org)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) Update(ctx context.Context, org Organisation) (*Organisation, error) {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET name=$1, updatedat=$2 WHERE id=$3 RETURNING id,name,createdat,updatedat",
+		orgsTable,
+	)
+
+	updated, err := scanOrg(dbs.db.QueryRowContext(ctx, stmt, org.Name, org.UpdatedAt, org.ID))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrNameExists
+		}
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (dbs *dbStore) Delete(ctx context.Context, id int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=$1", orgsTable)
+	result, err := dbs.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrNotFound)
+}
+
+func scanMember(row scanner) (*Member, error) {
+	m := Member{}
+	role := ""
+	createdAt := pq.NullTime{}
+
+	err := row.Scan(&m.OrgID, &m.UserID, &role, &createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMemberNotFound
+		}
+		return nil, err
+	}
+
+	m.Role = Role(role)
+	m.CreatedAt = nullTime(createdAt)
+	return &m, nil
+}
+
+func (dbs *dbStore) Member(ctx context.Context, orgID, userID int64) (*Member, error) {
+	stmt := fmt.Sprintf(
+		"SELECT orgid,userid,role,createdat FROM %s WHERE orgid=$1 AND userid=$2",
+		membersTable,
+	)
+	return scanMember(dbs.db.QueryRowContext(ctx, stmt, orgID, userID))
+}
+
+func (dbs *dbStore) Members(ctx context.Context, orgID int64) ([]Member, error) {
+	stmt := fmt.Sprintf(
+		"SELECT orgid,userid,role,createdat FROM %s WHERE orgid=$1 ORDER BY id",
+		membersTable,
+	)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Member, 0)
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *m)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) SetMember(ctx context.Context, m Member) (*Member, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s ON CONFLICT (orgid, userid) DO UPDATE SET role=EXCLUDED.role RETURNING orgid,userid,role,createdat",
+		membersTable,
+		dbs.prepColVals("orgid", "userid", "role", "createdat"),
+	)
+	return scanMember(dbs.db.QueryRowContext(ctx, stmt, m.OrgID, m.UserID, m.Role, m.CreatedAt))
+}
+
+func (dbs *dbStore) RemoveMember(ctx context.Context, orgID, userID int64) error {
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer dbs.rollback(tx)
+
+	stmt := fmt.Sprintf(
+		"DELETE FROM %s WHERE userid=$1 AND teamid IN (SELECT id FROM %s WHERE orgid=$2)",
+		teamMembersTable,
+		teamsTable,
+	)
+	_, err = tx.ExecContext(ctx, stmt, userID, orgID)
+	if err != nil {
+		return err
+	}
+
+	stmt = fmt.Sprintf("DELETE FROM %s WHERE orgid=$1 AND userid=$2", membersTable)
+	result, err := tx.ExecContext(ctx, stmt, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = affected(result, ErrMemberNotFound)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func scanTeam(row scanner) (*Team, error) {
+	t := Team{}
+	createdAt := pq.NullTime{}
+	updatedAt := pq.NullTime{}
+
+	err := row.Scan(&t.ID, &t.OrgID, &t.Name, &createdAt, &updatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTeamNotFound
+		}
+		return nil, err
+	}
+
+	t.CreatedAt = nullTime(createdAt)
+	t.UpdatedAt = nullTime(updatedAt)
+	return &t, nil
+}
+
+func (dbs *dbStore) CreateTeam(ctx context.Context, t Team) (*Team, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s RETURNING id",
+		teamsTable,
+		dbs.prepColVals("orgid", "name", "createdat", "updatedat"),
+	)
+
+	err := dbs.db.QueryRowContext(ctx, stmt, t.OrgID, t.Name, t.CreatedAt, t.UpdatedAt).Scan(&t.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrNameExists
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (dbs *dbStore) Team(ctx context.Context, orgID, teamID int64) (*Team, error) {
+	stmt := fmt.Sprintf(
+		"SELECT id,orgid,name,createdat,updatedat FROM %s WHERE id=$1 AND orgid=$2",
+		teamsTable,
+	)
+	return scanTeam(dbs.db.QueryRowContext(ctx, stmt, teamID, orgID))
+}
+
+func (dbs *dbStore) Teams(ctx context.Context, orgID int64) ([]Team, error) {
+	stmt := fmt.Sprintf(
+		"SELECT id,orgid,name,createdat,updatedat FROM %s WHERE orgid=$1 ORDER BY name",
+		teamsTable,
+	)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Team, 0)
+	for rows.Next() {
+		t, err := scanTeam(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) DeleteTeam(ctx context.Context, orgID, teamID int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=$1 AND orgid=$2", teamsTable)
+	result, err := dbs.db.ExecContext(ctx, stmt, teamID, orgID)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrTeamNotFound)
+}
+
+func (dbs *dbStore) TeamMembers(ctx context.Context, teamID int64) ([]TeamMember, error) {
+	stmt := fmt.Sprintf(
+		"SELECT teamid,userid,createdat FROM %s WHERE teamid=$1 ORDER BY id",
+		teamMembersTable,
+	)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]TeamMember, 0)
+	for rows.Next() {
+		tm := TeamMember{}
+		createdAt := pq.NullTime{}
+		err := rows.Scan(&tm.TeamID, &tm.UserID, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		tm.CreatedAt = nullTime(createdAt)
+		list = append(list, tm)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) AddTeamMember(ctx context.Context, tm TeamMember) (*TeamMember, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		teamMembersTable,
+		dbs.prepColVals("teamid", "userid", "createdat"),
+	)
+
+	_, err := dbs.db.ExecContext(ctx, stmt, tm.TeamID, tm.UserID, tm.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrAlreadyMember
+		}
+		return nil, err
+	}
+	return &tm, nil
+}
+
+func (dbs *dbStore) RemoveTeamMember(ctx context.Context, teamID, userID int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE teamid=$1 AND userid=$2", teamMembersTable)
+	result, err := dbs.db.ExecContext(ctx, stmt, teamID, userID)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrMemberNotFound)
+}
+
+func (dbs *dbStore) TeamApps(ctx context.Context, teamID int64) ([]TeamApp, error) {
+	stmt := fmt.Sprintf(
+		"SELECT teamid,appid,role,createdat FROM %s WHERE teamid=$1 ORDER BY id",
+		teamAppsTable,
+	)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]TeamApp, 0)
+	for rows.Next() {
+		ta := TeamApp{}
+		createdAt := pq.NullTime{}
+		err := rows.Scan(&ta.TeamID, &ta.AppID, &ta.Role, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		ta.CreatedAt = nullTime(createdAt)
+		list = append(list, ta)
+	}
+
+	return list, rows.Err()
+}
+
+// SetTeamApp grants the role on the app to the team, only if the app belongs to the organisation
+func (dbs *dbStore) SetTeamApp(ctx context.Context, orgID int64, ta TeamApp) (*TeamApp, error) {
+	stmt := fmt.Sprintf(
+		`INSERT INTO %s (teamid, appid, role, createdat)
+		SELECT $1, id, $2, $3 FROM %s WHERE id=$4 AND orgid=$5
+		ON CONFLICT (teamid, appid) DO UPDATE SET role=EXCLUDED.role`,
+		teamAppsTable,
+		appTable,
+	)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, ta.TeamID, ta.Role, ta.CreatedAt, ta.AppID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = affected(result, ErrInvalidApp)
+	if err != nil {
+		return nil, err
+	}
+	return &ta, nil
+}
+
+func (dbs *dbStore) RemoveTeamApp(ctx context.Context, teamID, appID int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE teamid=$1 AND appid=$2", teamAppsTable)
+	result, err := dbs.db.ExecContext(ctx, stmt, teamID, appID)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrInvalidApp)
+}
@@ -0,0 +1,447 @@
+package orgs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+)
+
+// sqliteStore is the store of SQLite, it behaves exactly like dbStore. SQLite (as bundled) does
+// not support RETURNING, so the rows are read again after writing them
+type sqliteStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func isSQLiteUniqueViolation(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// sqliteTime returns the time in UTC truncated to seconds, as stored by Postgres. SQLite stores
+// the times as text, which are compared correctly only if they're all in the same time zone
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Truncate(time.Second)
+}
+
+func (ss *sqliteStore) rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && err != sql.ErrTxDone {
+		ss.appCtx.Logger.Error(err)
+	}
+}
+
+func (ss *sqliteStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, "?")
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func (ss *sqliteStore) Create(ctx context.Context, org Organisation, owner Member) (*Organisation, error) {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ss.rollback(tx)
+
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		orgsTable,
+		ss.prepColVals("name", "createdat", "updatedat"),
+	)
+	result, err := tx.ExecContext(ctx, stmt, org.Name, sqliteTime(org.CreatedAt), sqliteTime(org.UpdatedAt))
+	if err != nil {
+		if isSQLiteUniqueViolation(err) {
+			return nil, ErrNameExists
+		}
+		return nil, err
+	}
+
+	org.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt = fmt.Sprintf(
+		"INSERT INTO %s %s",
+		membersTable,
+		ss.prepColVals("orgid", "userid", "role", "createdat"),
+	)
+	_, err = tx.ExecContext(ctx, stmt, org.ID, owner.UserID, owner.Role, sqliteTime(owner.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+func (ss *sqliteStore) read(ctx context.Context, q queryer, id int64) (*Organisation, error) {
+	stmt := fmt.Sprintf("SELECT id,name,createdat,updatedat FROM %s WHERE id=?", orgsTable)
+	return scanOrg(q.QueryRowContext(ctx, stmt, id))
+}
+
+func (ss *sqliteStore) Read(ctx context.Context, id int64) (*Organisation, error) {
+	return ss.read(ctx, ss.db, id)
+}
+
+func (ss *sqliteStore) ListByUser(ctx context.Context, userID int64) ([]Organisation, error) {
+	stmt := fmt.Sprintf(
+		"SELECT o.id,o.name,o.createdat,o.updatedat,m.role FROM %s o INNER JOIN %s m ON m.orgid=o.id WHERE m.userid=? ORDER BY o.name",
+		orgsTable,
+		membersTable,
+	)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Organisation, 0)
+	for rows.Next() {
+		role := ""
+		org, err := scanOrg(rows, &role)
+		if err != nil {
+			return nil, err
+		}
+		org.Role = Role(role)
+		list = append(list, *org)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) Update(ctx context.Context, org Organisation) (*Organisation, error) {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ss.rollback(tx)
+
+	stmt := fmt.Sprintf("UPDATE %s SET name=?, updatedat=? WHERE id=?", orgsTable)
+	result, err := tx.ExecContext(ctx, stmt, org.Name, sqliteTime(org.UpdatedAt), org.ID)
+	if err != nil {
+		if isSQLiteUniqueViolation(err) {
+			return nil, ErrNameExists
+		}
+		return nil, err
+	}
+
+	err = affected(result, ErrNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := ss.read(ctx, tx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, tx.Commit()
+}
+
+func (ss *sqliteStore) Delete(ctx context.Context, id int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=?", orgsTable)
+	result, err := ss.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrNotFound)
+}
+
+func (ss *sqliteStore) member(ctx context.Context, q queryer, orgID, userID int64) (*Member, error) {
+	stmt := fmt.Sprintf(
+		"SELECT orgid,userid,role,createdat FROM %s WHERE orgid=? AND userid=?",
+		membersTable,
+	)
+	return scanMember(q.QueryRowContext(ctx, stmt, orgID, userID))
+}
+
+func (ss *sqliteStore) Member(ctx context.Context, orgID, userID int64) (*Member, error) {
+	return ss.member(ctx, ss.db, orgID, userID)
+}
+
+func (ss *sqliteStore) Members(ctx context.Context, orgID int64) ([]Member, error) {
+	stmt := fmt.Sprintf(
+		"SELECT orgid,userid,role,createdat FROM %s WHERE orgid=? ORDER BY id",
+		membersTable,
+	)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Member, 0)
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *m)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) SetMember(ctx context.Context, m Member) (*Member, error) {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ss.rollback(tx)
+
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s ON CONFLICT (orgid, userid) DO UPDATE SET role=excluded.role",
+		membersTable,
+		ss.prepColVals("orgid", "userid", "role", "createdat"),
+	)
+	_, err = tx.ExecContext(ctx, stmt, m.OrgID, m.UserID, m.Role, sqliteTime(m.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := ss.member(ctx, tx, m.OrgID, m.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return member, tx.Commit()
+}
+
+func (ss *sqliteStore) RemoveMember(ctx context.Context, orgID, userID int64) error {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer ss.rollback(tx)
+
+	stmt := fmt.Sprintf(
+		"DELETE FROM %s WHERE userid=? AND teamid IN (SELECT id FROM %s WHERE orgid=?)",
+		teamMembersTable,
+		teamsTable,
+	)
+	_, err = tx.ExecContext(ctx, stmt, userID, orgID)
+	if err != nil {
+		return err
+	}
+
+	stmt = fmt.Sprintf("DELETE FROM %s WHERE orgid=? AND userid=?", membersTable)
+	result, err := tx.ExecContext(ctx, stmt, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = affected(result, ErrMemberNotFound)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ss *sqliteStore) CreateTeam(ctx context.Context, t Team) (*Team, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		teamsTable,
+		ss.prepColVals("orgid", "name", "createdat", "updatedat"),
+	)
+
+	result, err := ss.db.ExecContext(ctx, stmt, t.OrgID, t.Name, sqliteTime(t.CreatedAt), sqliteTime(t.UpdatedAt))
+	if err != nil {
+		if isSQLiteUniqueViolation(err) {
+			return nil, ErrNameExists
+		}
+		return nil, err
+	}
+
+	t.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (ss *sqliteStore) Team(ctx context.Context, orgID, teamID int64) (*Team, error) {
+	stmt := fmt.Sprintf(
+		"SELECT id,orgid,name,createdat,updatedat FROM %s WHERE id=? AND orgid=?",
+		teamsTable,
+	)
+	return scanTeam(ss.db.QueryRowContext(ctx, stmt, teamID, orgID))
+}
+
+func (ss *sqliteStore) Teams(ctx context.Context, orgID int64) ([]Team, error) {
+	stmt := fmt.Sprintf(
+		"SELECT id,orgid,name,createdat,updatedat FROM %s WHERE orgid=? ORDER BY name",
+		teamsTable,
+	)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Team, 0)
+	for rows.Next() {
+		t, err := scanTeam(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) DeleteTeam(ctx context.Context, orgID, teamID int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=? AND orgid=?", teamsTable)
+	result, err := ss.db.ExecContext(ctx, stmt, teamID, orgID)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrTeamNotFound)
+}
+
+func (ss *sqliteStore) TeamMembers(ctx context.Context, teamID int64) ([]TeamMember, error) {
+	stmt := fmt.Sprintf(
+		"SELECT teamid,userid,createdat FROM %s WHERE teamid=? ORDER BY id",
+		teamMembersTable,
+	)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]TeamMember, 0)
+	for rows.Next() {
+		tm := TeamMember{}
+		createdAt := sql.NullTime{}
+		err := rows.Scan(&tm.TeamID, &tm.UserID, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		if createdAt.Valid {
+			tm.CreatedAt = &createdAt.Time
+		}
+		list = append(list, tm)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) AddTeamMember(ctx context.Context, tm TeamMember) (*TeamMember, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		teamMembersTable,
+		ss.prepColVals("teamid", "userid", "createdat"),
+	)
+
+	_, err := ss.db.ExecContext(ctx, stmt, tm.TeamID, tm.UserID, sqliteTime(tm.CreatedAt))
+	if err != nil {
+		if isSQLiteUniqueViolation(err) {
+			return nil, ErrAlreadyMember
+		}
+		return nil, err
+	}
+	return &tm, nil
+}
+
+func (ss *sqliteStore) RemoveTeamMember(ctx context.Context, teamID, userID int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE teamid=? AND userid=?", teamMembersTable)
+	result, err := ss.db.ExecContext(ctx, stmt, teamID, userID)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrMemberNotFound)
+}
+
+func (ss *sqliteStore) TeamApps(ctx context.Context, teamID int64) ([]TeamApp, error) {
+	stmt := fmt.Sprintf(
+		"SELECT teamid,appid,role,createdat FROM %s WHERE teamid=? ORDER BY id",
+		teamAppsTable,
+	)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]TeamApp, 0)
+	for rows.Next() {
+		ta := TeamApp{}
+		createdAt := sql.NullTime{}
+		err := rows.Scan(&ta.TeamID, &ta.AppID, &ta.Role, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		if createdAt.Valid {
+			ta.CreatedAt = &createdAt.Time
+		}
+		list = append(list, ta)
+	}
+
+	return list, rows.Err()
+}
+
+// SetTeamApp grants the role on the app to the team, only if the app belongs to the organisation
+func (ss *sqliteStore) SetTeamApp(ctx context.Context, orgID int64, ta TeamApp) (*TeamApp, error) {
+	stmt := fmt.Sprintf(
+		`INSERT INTO %s (teamid, appid, role, createdat)
+		SELECT ?, id, ?, ? FROM %s WHERE id=? AND orgid=?
+		ON CONFLICT (teamid, appid) DO UPDATE SET role=excluded.role`,
+		teamAppsTable,
+		appTable,
+	)
+
+	result, err := ss.db.ExecContext(ctx, stmt, ta.TeamID, ta.Role, sqliteTime(ta.CreatedAt), ta.AppID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = affected(result, ErrInvalidApp)
+	if err != nil {
+		return nil, err
+	}
+	return &ta, nil
+}
+
+func (ss *sqliteStore) RemoveTeamApp(ctx context.Context, teamID, appID int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE teamid=? AND appid=?", teamAppsTable)
+	result, err := ss.db.ExecContext(ctx, stmt, teamID, appID)
+	if err != nil {
+		return err
+	}
+	return affected(result, ErrInvalidApp)
+}
@@ -0,0 +1,290 @@
+package orgs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+)
+
+type fixture struct {
+	st     store
+	driver database.Driver
+	db     *sql.DB
+	// owner is the user who creates the organisations
+	owner int64
+}
+
+func testStore(t *testing.T, fn func(t *testing.T, f fixture)) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		fn(t, fixture{
+			st:     newStore(appcontext.New(logger.New()), driver, db),
+			driver: driver,
+			db:     db,
+			owner:  databasetest.User(t, driver, db, "owner@example.com"),
+		})
+	})
+}
+
+func (f fixture) create(t *testing.T, name string) *Organisation {
+	t.Helper()
+
+	now := time.Now()
+	org, err := f.st.Create(
+		context.Background(),
+		Organisation{Name: name, CreatedAt: &now, UpdatedAt: &now},
+		Member{UserID: f.owner, Role: RoleOwner, CreatedAt: &now},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return org
+}
+
+func (f fixture) team(t *testing.T, orgID int64, name string) *Team {
+	t.Helper()
+
+	now := time.Now()
+	team, err := f.st.CreateTeam(context.Background(), Team{OrgID: orgID, Name: name, CreatedAt: &now, UpdatedAt: &now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return team
+}
+
+func TestStoreOrganisations(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		acme := f.create(t, "acme")
+		f.create(t, "umbrella")
+
+		_, err := f.st.Create(ctx, Organisation{Name: "acme"}, Member{UserID: f.owner, Role: RoleOwner})
+		if !errors.Is(err, ErrNameExists) {
+			t.Fatalf("expected ErrNameExists, got %v", err)
+		}
+
+		owner, err := f.st.Member(ctx, acme.ID, f.owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owner.Role != RoleOwner {
+			t.Fatalf("expected the creator to be the owner, got %s", owner.Role)
+		}
+
+		list, err := f.st.ListByUser(ctx, f.owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].Name != "acme" || list[1].Name != "umbrella" || list[0].Role != RoleOwner {
+			t.Fatalf("expected acme & umbrella with the owner role, got %+v", list)
+		}
+
+		acme.Name = "acme corp"
+		updated, err := f.st.Update(ctx, *acme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Name != "acme corp" {
+			t.Fatalf("expected the name to be updated, got %s", updated.Name)
+		}
+
+		acme.Name = "umbrella"
+		_, err = f.st.Update(ctx, *acme)
+		if !errors.Is(err, ErrNameExists) {
+			t.Fatalf("expected ErrNameExists, got %v", err)
+		}
+
+		err = f.st.Delete(ctx, acme.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = f.st.Read(ctx, acme.ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		err = f.st.Delete(ctx, acme.ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStoreMembers(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		org := f.create(t, "acme")
+		userID := databasetest.User(t, f.driver, f.db, "jane@example.com")
+
+		_, err := f.st.Member(ctx, org.ID, userID)
+		if !errors.Is(err, ErrMemberNotFound) {
+			t.Fatalf("expected ErrMemberNotFound, got %v", err)
+		}
+
+		now := time.Now()
+		for _, role := range []Role{RoleMember, RoleAdmin} {
+			// setting an existing member updates the role
+			m, err := f.st.SetMember(ctx, Member{OrgID: org.ID, UserID: userID, Role: role, CreatedAt: &now})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Role != role {
+				t.Fatalf("expected role %s, got %s", role, m.Role)
+			}
+		}
+
+		members, err := f.st.Members(ctx, org.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(members) != 2 || members[1].UserID != userID || members[1].Role != RoleAdmin {
+			t.Fatalf("expected the owner & the admin, got %+v", members)
+		}
+
+		// removing a member removes them from the teams of the organisation as well
+		team := f.team(t, org.ID, "platform")
+		_, err = f.st.AddTeamMember(ctx, TeamMember{TeamID: team.ID, UserID: userID, CreatedAt: &now})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.st.RemoveMember(ctx, org.ID, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		teamMembers, err := f.st.TeamMembers(ctx, team.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(teamMembers) != 0 {
+			t.Fatalf("expected no team members, got %+v", teamMembers)
+		}
+
+		err = f.st.RemoveMember(ctx, org.ID, userID)
+		if !errors.Is(err, ErrMemberNotFound) {
+			t.Fatalf("expected ErrMemberNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStoreTeams(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		org := f.create(t, "acme")
+		other := f.create(t, "umbrella")
+		platform := f.team(t, org.ID, "platform")
+		f.team(t, org.ID, "billing")
+		// the names are unique only within an organisation
+		f.team(t, other.ID, "platform")
+
+		_, err := f.st.CreateTeam(ctx, Team{OrgID: org.ID, Name: "platform"})
+		if !errors.Is(err, ErrNameExists) {
+			t.Fatalf("expected ErrNameExists, got %v", err)
+		}
+
+		teams, err := f.st.Teams(ctx, org.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(teams) != 2 || teams[0].Name != "billing" || teams[1].Name != "platform" {
+			t.Fatalf("expected billing & platform, got %+v", teams)
+		}
+
+		_, err = f.st.Team(ctx, other.ID, platform.ID)
+		if !errors.Is(err, ErrTeamNotFound) {
+			t.Fatalf("expected the team of another organisation to be not found, got %v", err)
+		}
+
+		now := time.Now()
+		tm := TeamMember{TeamID: platform.ID, UserID: f.owner, CreatedAt: &now}
+		_, err = f.st.AddTeamMember(ctx, tm)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = f.st.AddTeamMember(ctx, tm)
+		if !errors.Is(err, ErrAlreadyMember) {
+			t.Fatalf("expected ErrAlreadyMember, got %v", err)
+		}
+
+		members, err := f.st.TeamMembers(ctx, platform.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(members) != 1 || members[0].UserID != f.owner || members[0].CreatedAt == nil {
+			t.Fatalf("expected the owner as the only member, got %+v", members)
+		}
+
+		err = f.st.RemoveTeamMember(ctx, platform.ID, f.owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.st.RemoveTeamMember(ctx, platform.ID, f.owner)
+		if !errors.Is(err, ErrMemberNotFound) {
+			t.Fatalf("expected ErrMemberNotFound, got %v", err)
+		}
+
+		err = f.st.DeleteTeam(ctx, other.ID, platform.ID)
+		if !errors.Is(err, ErrTeamNotFound) {
+			t.Fatalf("expected ErrTeamNotFound, got %v", err)
+		}
+
+		err = f.st.DeleteTeam(ctx, org.ID, platform.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestStoreTeamApps(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		org := f.create(t, "acme")
+		other := f.create(t, "umbrella")
+		team := f.team(t, org.ID, "platform")
+		appID := databasetest.App(t, f.driver, f.db, org.ID, "billing")
+		otherAppID := databasetest.App(t, f.driver, f.db, other.ID, "shipping")
+
+		now := time.Now()
+		for _, role := range []rbac.Role{rbac.RoleViewer, rbac.RoleDeveloper} {
+			// granting an existing app again updates the role
+			_, err := f.st.SetTeamApp(ctx, org.ID, TeamApp{TeamID: team.ID, AppID: appID, Role: role, CreatedAt: &now})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err := f.st.SetTeamApp(ctx, org.ID, TeamApp{TeamID: team.ID, AppID: otherAppID, Role: rbac.RoleViewer, CreatedAt: &now})
+		if !errors.Is(err, ErrInvalidApp) {
+			t.Fatalf("expected the app of another organisation to be rejected, got %v", err)
+		}
+
+		list, err := f.st.TeamApps(ctx, team.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].AppID != appID || list[0].Role != rbac.RoleDeveloper {
+			t.Fatalf("expected the developer role on the app, got %+v", list)
+		}
+
+		err = f.st.RemoveTeamApp(ctx, team.ID, appID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.st.RemoveTeamApp(ctx, team.ID, appID)
+		if !errors.Is(err, ErrInvalidApp) {
+			t.Fatalf("expected ErrInvalidApp, got %v", err)
+		}
+	})
+}
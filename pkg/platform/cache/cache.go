@@ -25,11 +25,27 @@ var ErrCacheNoHn = errors.New("No cache handler initialized")
 type Cache interface {
 	Set(key string, value interface{}, expiry time.Duration) error
 	Get(key string, result interface{}) error
+	// SetNX sets the value only if the key does not exist, and returns true if it was set
+	SetNX(key string, value interface{}, expiry time.Duration) (bool, error)
+	// Incr increments the counter with the given key atomically & returns the incremented
+	// value. A new counter starts at 1 and expires after the expiry
+	Incr(key string, expiry time.Duration) (int64, error)
+	// SAdd adds the members to the set with the given key atomically, and resets the expiry of
+	// the set to the given expiry
+	SAdd(key string, expiry time.Duration, members ...string) error
+	// SMembers returns the members of the set with the given key, a set which does not exist
+	// has no members
+	SMembers(key string) ([]string, error)
+	// SRem removes the members from the set with the given key atomically
+	SRem(key string, members ...string) error
 	// HSet(string, string, interface{}, time.Duration, bool) error
 	// HGet(string, string, interface{}) (error)
-	// Delete(...string) error
+	Delete(keys ...string) error
 	// HDelete(string, ...string) error
 	Ping() error
+	// Close closes the connections to the cache, it should be called only after all the
+	// other methods have returned
+	Close() error
 }
 
 type Config struct {
@@ -58,10 +74,39 @@ func (h *Handler) Get(key string, result interface{}) error {
 	return err
 }
 
+func (h *Handler) SetNX(key string, value interface{}, expiry time.Duration) (bool, error) {
+	return h.client.SetNX(key, value, expiry)
+}
+
+func (h *Handler) Incr(key string, expiry time.Duration) (int64, error) {
+	return h.client.Incr(key, expiry)
+}
+
+func (h *Handler) SAdd(key string, expiry time.Duration, members ...string) error {
+	return h.client.SAdd(key, expiry, members...)
+}
+
+func (h *Handler) SMembers(key string) ([]string, error) {
+	return h.client.SMembers(key)
+}
+
+func (h *Handler) SRem(key string, members ...string) error {
+	return h.client.SRem(key, members...)
+}
+
+// Delete deletes all the given keys, keys which do not exist are ignored
+func (h *Handler) Delete(keys ...string) error {
+	return h.client.Delete(keys...)
+}
+
 func (h *Handler) Ping() error {
 	return h.client.Ping()
 }
 
+func (h *Handler) Close() error {
+	return h.client.Close()
+}
+
 func New(c Config) (Cache, error) {
 	h := &Handler{}
 	db, _ := strconv.Atoi(c.Name)
@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	msgpack "gopkg.in/vmihailenco/msgpack.v2"
+)
+
+type memoryItem struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (mi *memoryItem) expired(now time.Time) bool {
+	return !mi.expiresAt.IsZero() && !now.Before(mi.expiresAt)
+}
+
+// Memory is an in-memory cache, meant for tests & single node setups. The values are encoded
+// the same way as in Redis, so that the cached values are never shared with the callers
+type Memory struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+}
+
+// get returns the item of the key if it exists & has not expired. It should be called only
+// while holding the lock
+func (m *Memory) get(key string) (memoryItem, bool) {
+	item, ok := m.items[key]
+	if !ok {
+		return item, false
+	}
+
+	if item.expired(time.Now()) {
+		delete(m.items, key)
+		return item, false
+	}
+	return item, true
+}
+
+func (m *Memory) set(key string, value interface{}, expiry time.Duration) error {
+	b, err := msgpack.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	item := memoryItem{value: b}
+	if expiry > 0 {
+		item.expiresAt = time.Now().Add(expiry)
+	}
+	m.items[key] = item
+	return nil
+}
+
+func (m *Memory) Set(key string, value interface{}, expiry time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.set(key, value, expiry)
+}
+
+func (m *Memory) Get(key string, result interface{}) error {
+	m.mu.Lock()
+	item, ok := m.get(key)
+	m.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+
+	return msgpack.Unmarshal(item.value, result)
+}
+
+func (m *Memory) SetNX(key string, value interface{}, expiry time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.get(key)
+	if ok {
+		return false, nil
+	}
+
+	return true, m.set(key, value, expiry)
+}
+
+func (m *Memory) Incr(key string, expiry time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.get(key)
+	if !ok {
+		return 1, m.set(key, int64(1), expiry)
+	}
+
+	count := int64(0)
+	err := msgpack.Unmarshal(item.value, &count)
+	if err != nil {
+		return 0, err
+	}
+	count++
+
+	b, err := msgpack.Marshal(count)
+	if err != nil {
+		return 0, err
+	}
+	// the expiry is not extended by the subsequent increments
+	item.value = b
+	m.items[key] = item
+
+	return count, nil
+}
+
+// members returns the members of the set with the given key. It should be called only while
+// holding the lock
+func (m *Memory) members(key string) (memoryItem, []string, error) {
+	item, ok := m.get(key)
+	if !ok {
+		return item, []string{}, nil
+	}
+
+	members := []string{}
+	err := msgpack.Unmarshal(item.value, &members)
+	if err != nil {
+		return item, nil, err
+	}
+	return item, members, nil
+}
+
+func (m *Memory) SAdd(key string, expiry time.Duration, members ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, existing, err := m.members(key)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if !contains(existing, member) {
+			existing = append(existing, member)
+		}
+	}
+	return m.set(key, existing, expiry)
+}
+
+func (m *Memory) SMembers(key string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, members, err := m.members(key)
+	return members, err
+}
+
+func (m *Memory) SRem(key string, members ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, existing, err := m.members(key)
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]string, 0, len(existing))
+	for _, member := range existing {
+		if !contains(members, member) {
+			remaining = append(remaining, member)
+		}
+	}
+	// like Redis, an empty set does not exist
+	if len(remaining) == 0 {
+		delete(m.items, key)
+		return nil
+	}
+
+	b, err := msgpack.Marshal(remaining)
+	if err != nil {
+		return err
+	}
+	// the expiry is not changed by removing members
+	item.value = b
+	m.items[key] = item
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete deletes all the given keys, keys which do not exist are ignored
+func (m *Memory) Delete(keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.items, key)
+	}
+	return nil
+}
+
+func (m *Memory) Ping() error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		items: make(map[string]memoryItem),
+	}
+}
@@ -56,6 +56,76 @@ func (h *Handler) Get(key string, result interface{}) error {
 	return h.codec.Get(key, result)
 }
 
+// SetNX saves the value only if the key does not exist, and returns true if it was saved
+func (h *Handler) SetNX(key string, value interface{}, expiry time.Duration) (bool, error) {
+	b, err := h.codec.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return h.ring.SetNX(key, b, expiry).Result()
+}
+
+// incrScript increments the counter, and sets its expiry only when it's created so that the
+// expiry is not extended by the subsequent increments
+var incrScript = redis.NewScript(`local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count`)
+
+// Incr increments the counter with the given key & returns the incremented value. A new counter
+// starts at 1 and expires after the expiry
+func (h *Handler) Incr(key string, expiry time.Duration) (int64, error) {
+	return incrScript.Run(h.ring, []string{key}, expiry.Milliseconds()).Int64()
+}
+
+// saddScript adds the members to the set and resets its expiry, in one step so that the set
+// is never left without an expiry
+var saddScript = redis.NewScript(`redis.call("SADD", KEYS[1], unpack(ARGV, 2))
+redis.call("PEXPIRE", KEYS[1], ARGV[1])
+return 1`)
+
+// SAdd adds the members to the set with the given key, and resets the expiry of the set
+func (h *Handler) SAdd(key string, expiry time.Duration, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(members)+1)
+	args = append(args, expiry.Milliseconds())
+	for _, m := range members {
+		args = append(args, m)
+	}
+	return saddScript.Run(h.ring, []string{key}, args...).Err()
+}
+
+// SMembers returns the members of the set with the given key
+func (h *Handler) SMembers(key string) ([]string, error) {
+	return h.ring.SMembers(key).Result()
+}
+
+// SRem removes the members from the set with the given key
+func (h *Handler) SRem(key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(members))
+	for _, m := range members {
+		args = append(args, m)
+	}
+	return h.ring.SRem(key, args...).Err()
+}
+
+// Delete deletes all the given keys from Redis, keys which do not exist are ignored
+func (h *Handler) Delete(keys ...string) error {
+	for _, key := range keys {
+		err := h.codec.Delete(key)
+		if err != nil && err != cache.ErrCacheMiss {
+			return err
+		}
+	}
+	return nil
+}
+
 // Ping pings the redis server
 func (h *Handler) Ping() error {
 	result := h.ring.Ping()
@@ -66,6 +136,11 @@ func (h *Handler) Ping() error {
 	return nil
 }
 
+// Close closes all the connections to the redis server(s)
+func (h *Handler) Close() error {
+	return h.ring.Close()
+}
+
 // New returns a handler instance with all the required attributes initialized
 func New(c Config) (*Handler, error) {
 	if len(c.Hosts) == 0 {
@@ -0,0 +1,25 @@
+// Package database has the databases supported by the stores of Padlock. A store uses the SQL
+// dialect of the driver it's initialized with
+package database
+
+import "strings"
+
+// Driver is the name of a database supported by the stores
+type Driver string
+
+const (
+	// Postgres is supported by all the stores, and is recommended for production
+	Postgres Driver = "postgres"
+	// SQLite is supported by all the stores as well, it's meant for single node deployments &
+	// tests
+	SQLite Driver = "sqlite"
+)
+
+// likeEscaper escapes the wildcards of LIKE, and the escape character itself
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Contains returns the LIKE pattern matching the values which contain s. The wildcards in s
+// are matched literally, so the condition should use ESCAPE '\'
+func Contains(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
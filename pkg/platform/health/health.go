@@ -0,0 +1,178 @@
+// Package health checks the dependencies of the service, to determine if it's ready to serve
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+// Status is the status of a dependency, or of the service
+type Status string
+
+const (
+	StatusUp   = Status("up")
+	StatusDown = Status("down")
+)
+
+const (
+	reasonTimeout = "timed out"
+	reasonFailed  = "check failed"
+)
+
+// Check checks a dependency, it should return an error if the dependency is not usable
+type Check func(ctx context.Context) error
+
+// WithoutContext converts a check which does not accept a context (e.g. cache.Cache.Ping) to
+// a Check, which returns when the context is done even if the check has not returned
+func WithoutContext(check func() error) Check {
+	return func(ctx context.Context) error {
+		result := make(chan error, 1)
+		go func() {
+			result <- check()
+		}()
+
+		select {
+		case err := <-result:
+			{
+				return err
+			}
+		case <-ctx.Done():
+			{
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// Config has all the configurations of the checker
+type Config struct {
+	// Timeout is the maximum duration for a check, post which the dependency is down
+	Timeout time.Duration
+	// TTL is the duration for which a report is reused, so that frequent probes do not hammer
+	// the dependencies
+	TTL time.Duration
+}
+
+// DefaultConfig is the default configuration of the checker
+var DefaultConfig = Config{
+	Timeout: time.Second * 2,
+	TTL:     time.Second * 2,
+}
+
+// Dependency is the result of the check of a single dependency
+type Dependency struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	// Latency is the duration of the check in milliseconds
+	Latency float64 `json:"latencyMs"`
+	// Error is the reason the dependency is down. The actual error is only logged, since it
+	// might reveal the internals (e.g. host names)
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the result of checking all the dependencies
+type Report struct {
+	// Status is up only if all the dependencies are up
+	Status       Status       `json:"status"`
+	Dependencies []Dependency `json:"dependencies"`
+	CheckedAt    time.Time    `json:"checkedAt"`
+}
+
+// Up returns true if all the dependencies are up
+func (r *Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker checks all the added dependencies, its reports are transport agnostic so that they
+// can be served over HTTP as well as the gRPC health service
+type Checker struct {
+	cfg    Config
+	logger logger.Logger
+	checks []namedCheck
+
+	// mu is held while checking, so that concurrent calls wait for & reuse the same report
+	mu     sync.Mutex
+	report *Report
+}
+
+// Add adds a dependency to be checked. It should be called before the checker is used
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Check checks all the dependencies concurrently, and returns the report. The last report is
+// returned if it's not older than the TTL
+func (c *Checker) Check() Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.report != nil && time.Since(c.report.CheckedAt) < c.cfg.TTL {
+		return *c.report
+	}
+
+	// the checks are not tied to the context of the caller, since the report is shared
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+
+	report := &Report{
+		Status:       StatusUp,
+		Dependencies: make([]Dependency, len(c.checks)),
+		CheckedAt:    time.Now(),
+	}
+
+	wg := &sync.WaitGroup{}
+	for i, nc := range c.checks {
+		wg.Add(1)
+		go func(i int, nc namedCheck) {
+			defer wg.Done()
+			report.Dependencies[i] = c.check(ctx, nc)
+		}(i, nc)
+	}
+	wg.Wait()
+
+	for _, d := range report.Dependencies {
+		if d.Status != StatusUp {
+			report.Status = StatusDown
+			break
+		}
+	}
+
+	c.report = report
+	return *report
+}
+
+func (c *Checker) check(ctx context.Context, nc namedCheck) Dependency {
+	start := time.Now()
+	err := nc.check(ctx)
+	d := Dependency{
+		Name:    nc.name,
+		Status:  StatusUp,
+		Latency: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err == nil {
+		return d
+	}
+
+	c.logger.Error("health:", nc.name, err)
+	d.Status = StatusDown
+	d.Error = reasonFailed
+	if ctx.Err() == context.DeadlineExceeded {
+		d.Error = reasonTimeout
+	}
+	return d
+}
+
+func New(cfg Config, l logger.Logger) *Checker {
+	return &Checker{
+		cfg:    cfg,
+		logger: l,
+	}
+}
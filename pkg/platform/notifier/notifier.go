@@ -0,0 +1,84 @@
+// Package notifier delivers messages to users, over email or phone
+package notifier
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+type channel string
+
+const (
+	// ChannelEmail is used for messages sent to an email address
+	ChannelEmail = channel("email")
+	// ChannelSMS is used for messages sent as text messages to a phone number
+	ChannelSMS = channel("sms")
+)
+
+var (
+	// ErrInvalidChannel is returned when the notifier does not support the message's channel
+	ErrInvalidChannel = errors.New("Unsupported notification channel")
+)
+
+// Message is a single message to be delivered
+type Message struct {
+	Channel channel `json:"channel,omitempty"`
+	// To is the email address or phone number of the recipient, based on the channel
+	To      string `json:"to,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body,omitempty"`
+}
+
+// Notifier should be implemented by all the notification providers
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// Log is a notifier which only logs the messages, useful for local development
+type Log struct {
+	logger logger.Logger
+}
+
+// Notify logs the message
+func (l *Log) Notify(ctx context.Context, m Message) error {
+	l.logger.Info("notification", m.Channel, m.To, m.Subject, m.Body)
+	return nil
+}
+
+// NewLog returns a notifier which logs all the messages using the given logger
+func NewLog(l logger.Logger) *Log {
+	return &Log{
+		logger: l,
+	}
+}
+
+// Recorder is a notifier which records all the messages in memory, useful for tests
+type Recorder struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Notify records the message
+func (r *Recorder) Notify(ctx context.Context, m Message) error {
+	r.mu.Lock()
+	r.messages = append(r.messages, m)
+	r.mu.Unlock()
+	return nil
+}
+
+// Messages returns all the messages recorded so far
+func (r *Recorder) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Message, len(r.messages))
+	copy(list, r.messages)
+	return list
+}
+
+// NewRecorder returns a notifier which records all the messages in memory
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
@@ -0,0 +1,120 @@
+// Package sms delivers text messages & voice calls to phone numbers
+package sms
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+type kind string
+
+const (
+	// KindSMS is a text message
+	KindSMS = kind("sms")
+	// KindVoice is a voice call, where the message is read out
+	KindVoice = kind("voice")
+)
+
+var (
+	e164Regex = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	// separators are the characters commonly used while writing phone numbers, which are dropped
+	separators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "/", "")
+
+	// ErrInvalidNumber is returned when the phone number cannot be converted to E.164 format
+	ErrInvalidNumber = errors.New("Invalid phone number")
+)
+
+// Sender should be implemented by all the SMS/voice providers
+type Sender interface {
+	// SendSMS sends the message as a text message to the phone number, in E.164 format
+	SendSMS(ctx context.Context, to, message string) error
+	// Call calls the phone number, in E.164 format, and reads out the message
+	Call(ctx context.Context, to, message string) error
+}
+
+// Normalize converts a free form phone number to E.164 format. e.g. "+91-98765 43210" is converted
+// to "+919876543210". Numbers without a country code are prefixed with defaultCountryCode
+// (e.g. "+91", can be empty), after dropping the leading trunk prefix "0"
+func Normalize(phone, defaultCountryCode string) (string, error) {
+	phone = separators.Replace(strings.TrimSpace(phone))
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(phone, "00"):
+		{
+			phone = "+" + phone[2:]
+		}
+	case defaultCountryCode != "":
+		{
+			phone = defaultCountryCode + strings.TrimPrefix(phone, "0")
+		}
+	default:
+		{
+			return "", ErrInvalidNumber
+		}
+	}
+
+	if !e164Regex.MatchString(phone) {
+		return "", ErrInvalidNumber
+	}
+
+	return phone, nil
+}
+
+// Message is a single message sent using Fake
+type Message struct {
+	Kind    kind   `json:"kind,omitempty"`
+	To      string `json:"to,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Fake is a sender which does not deliver any messages, instead records them in memory.
+// It is useful for tests & local development
+type Fake struct {
+	logger   logger.Logger
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (f *Fake) record(m Message) {
+	f.mu.Lock()
+	f.messages = append(f.messages, m)
+	f.mu.Unlock()
+
+	if f.logger != nil {
+		f.logger.Info(m.Kind, m.To, m.Message)
+	}
+}
+
+// SendSMS records the text message
+func (f *Fake) SendSMS(ctx context.Context, to, message string) error {
+	f.record(Message{Kind: KindSMS, To: to, Message: message})
+	return nil
+}
+
+// Call records the voice message
+func (f *Fake) Call(ctx context.Context, to, message string) error {
+	f.record(Message{Kind: KindVoice, To: to, Message: message})
+	return nil
+}
+
+// Messages returns all the messages recorded so far
+func (f *Fake) Messages() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	list := make([]Message, len(f.messages))
+	copy(list, f.messages)
+	return list
+}
+
+// NewFake returns a fake sender. If a logger is provided, all the messages are logged as well
+func NewFake(l logger.Logger) *Fake {
+	return &Fake{
+		logger: l,
+	}
+}
@@ -0,0 +1,126 @@
+// Package twilio implements sms.Sender using the Twilio REST API
+package twilio
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL = "https://api.twilio.com/2010-04-01"
+)
+
+var (
+	// ErrInvalidConfig is returned when the account SID, auth token or sender number is missing
+	ErrInvalidConfig = errors.New("Invalid Twilio configuration")
+)
+
+// Config has all the configurations required for Twilio
+type Config struct {
+	AccountSID string
+	AuthToken  string
+	// From is the Twilio phone number, in E.164 format, used for sending messages & calling
+	From string
+	// BaseURL is the base URL of the Twilio API, it's overridden only for testing
+	BaseURL string
+	Timeout time.Duration
+}
+
+// apiError is the error response returned by Twilio
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+}
+
+func (ae *apiError) Error() string {
+	return fmt.Sprintf("twilio: %d %s (status %d)", ae.Code, ae.Message, ae.Status)
+}
+
+// Handler sends text messages & makes voice calls using Twilio
+type Handler struct {
+	cfg    Config
+	client *http.Client
+}
+
+func (h *Handler) post(ctx context.Context, resource string, form url.Values) error {
+	endpoint := fmt.Sprintf("%s/Accounts/%s/%s.json", h.cfg.BaseURL, h.cfg.AccountSID, resource)
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(h.cfg.AccountSID, h.cfg.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	ae := &apiError{Status: resp.StatusCode}
+	err = json.NewDecoder(resp.Body).Decode(ae)
+	if err != nil {
+		return fmt.Errorf("twilio: unexpected response status %d", resp.StatusCode)
+	}
+	return ae
+}
+
+// SendSMS sends the message as a text message
+func (h *Handler) SendSMS(ctx context.Context, to, message string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", h.cfg.From)
+	form.Set("Body", message)
+	return h.post(ctx, "Messages", form)
+}
+
+// Call calls the phone number and reads out the message
+func (h *Handler) Call(ctx context.Context, to, message string) error {
+	buf := strings.Builder{}
+	err := xml.EscapeText(&buf, []byte(message))
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", h.cfg.From)
+	form.Set("Twiml", fmt.Sprintf("<Response><Say>%s</Say></Response>", buf.String()))
+	return h.post(ctx, "Calls", form)
+}
+
+// New returns a handler instance with all the required attributes initialized
+func New(cfg Config) (*Handler, error) {
+	if cfg.AccountSID == "" || cfg.AuthToken == "" || cfg.From == "" {
+		return nil, ErrInvalidConfig
+	}
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURL
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second * 10
+	}
+
+	return &Handler{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}, nil
+}
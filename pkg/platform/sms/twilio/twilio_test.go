@@ -0,0 +1,149 @@
+package twilio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// request is a request received by the fake Twilio API
+type request struct {
+	method      string
+	path        string
+	user        string
+	password    string
+	contentType string
+	form        url.Values
+}
+
+// testTwilio returns a handler using a fake Twilio API, which responds with the status & body. The
+// requests received are sent to the channel returned
+func testTwilio(t *testing.T, status int, body string) (*Handler, chan request) {
+	t.Helper()
+
+	received := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		err := r.ParseForm()
+		if err != nil {
+			t.Error(err)
+		}
+		received <- request{
+			method:      r.Method,
+			path:        r.URL.Path,
+			user:        user,
+			password:    password,
+			contentType: r.Header.Get("Content-Type"),
+			form:        r.PostForm,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+
+	h, err := New(Config{AccountSID: "AC123", AuthToken: "token", From: "+15005550006", BaseURL: srv.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, received
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []Config{
+		{AuthToken: "token", From: "+15005550006"},
+		{AccountSID: "AC123", From: "+15005550006"},
+		{AccountSID: "AC123", AuthToken: "token"},
+	} {
+		_, err := New(cfg)
+		if err != ErrInvalidConfig {
+			t.Errorf("%+v: expected ErrInvalidConfig, got %v", cfg, err)
+		}
+	}
+
+	h, err := New(Config{AccountSID: "AC123", AuthToken: "token", From: "+15005550006"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.cfg.BaseURL != defaultBaseURL || h.client.Timeout != time.Second*10 {
+		t.Fatalf("expected the defaults, got %+v", h.cfg)
+	}
+}
+
+func TestSendSMS(t *testing.T) {
+	h, received := testTwilio(t, http.StatusCreated, `{"sid":"SM123","status":"queued"}`)
+
+	err := h.SendSMS(context.Background(), "+15555550100", "Your code is 123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-received
+	if r.method != http.MethodPost || r.path != "/Accounts/AC123/Messages.json" {
+		t.Fatalf("expected a POST to the messages of the account, got %s %s", r.method, r.path)
+	}
+	if r.user != "AC123" || r.password != "token" {
+		t.Fatalf("expected the account SID & auth token, got %q & %q", r.user, r.password)
+	}
+	if r.contentType != "application/x-www-form-urlencoded" {
+		t.Fatalf("expected a form, got %q", r.contentType)
+	}
+	if r.form.Get("To") != "+15555550100" || r.form.Get("From") != "+15005550006" || r.form.Get("Body") != "Your code is 123456" {
+		t.Fatalf("unexpected form %v", r.form)
+	}
+}
+
+func TestCall(t *testing.T) {
+	h, received := testTwilio(t, http.StatusCreated, `{"sid":"CA123","status":"queued"}`)
+
+	err := h.Call(context.Background(), "+15555550100", "Your code is 1, 2 & <3>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-received
+	if r.path != "/Accounts/AC123/Calls.json" {
+		t.Fatalf("expected the calls of the account, got %s", r.path)
+	}
+	expected := "<Response><Say>Your code is 1, 2 &amp; &lt;3&gt;</Say></Response>"
+	if r.form.Get("To") != "+15555550100" || r.form.Get("From") != "+15005550006" || r.form.Get("Twiml") != expected {
+		t.Fatalf("unexpected form %v", r.form)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	h, _ := testTwilio(
+		t,
+		http.StatusBadRequest,
+		`{"code":21211,"message":"The 'To' number is not a valid phone number.","status":400}`,
+	)
+	err := h.SendSMS(context.Background(), "+1555", "hello")
+	ae := &apiError{}
+	if !errors.As(err, &ae) {
+		t.Fatalf("expected *apiError, got %v", err)
+	}
+	if ae.Code != 21211 || ae.Status != http.StatusBadRequest || !strings.Contains(ae.Message, "not a valid phone number") {
+		t.Fatalf("expected the error of the response, got %+v", ae)
+	}
+
+	h, _ = testTwilio(t, http.StatusBadGateway, "<html>Bad Gateway</html>")
+	err = h.Call(context.Background(), "+15555550100", "hello")
+	if err == nil || errors.As(err, &ae) || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("expected the unexpected status, got %v", err)
+	}
+
+	h, _ = testTwilio(t, http.StatusCreated, "{}")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = h.SendSMS(ctx, "+15555550100", "hello")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
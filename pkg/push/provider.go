@@ -0,0 +1,59 @@
+package push
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Notification is the payload pushed to the device, for the user to approve or deny a sign in
+type Notification struct {
+	ChallengeID string `json:"challengeId"`
+	// Nonce should be included in the signed response of the device
+	Nonce string `json:"nonce"`
+	// Digits is the number of digits of the number displayed in the browser
+	Digits int `json:"digits"`
+	// Choices are the numbers shown on the device, the user should pick the one displayed in
+	// the browser. If there are no choices, the user should type the number instead
+	Choices []int `json:"choices,omitempty"`
+	// Source identifies the client which requested the sign in
+	Source    string    `json:"source,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PushProvider should be implemented by all the push notification services (APNs, FCM etc.)
+type PushProvider interface {
+	Push(ctx context.Context, d Device, n Notification) error
+}
+
+// Loopback is a push provider which does not deliver notifications to real devices, instead
+// keeps them in memory to be read by a local/simulated device. It is useful for tests & local
+// development
+type Loopback struct {
+	mu            sync.Mutex
+	notifications map[string][]Notification
+}
+
+// Push stores the notification against the device's push token
+func (l *Loopback) Push(ctx context.Context, d Device, n Notification) error {
+	l.mu.Lock()
+	l.notifications[d.PushToken] = append(l.notifications[d.PushToken], n)
+	l.mu.Unlock()
+	return nil
+}
+
+// Receive returns & clears all the notifications pushed to the given push token
+func (l *Loopback) Receive(pushToken string) []Notification {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	list := l.notifications[pushToken]
+	delete(l.notifications, pushToken)
+	return list
+}
+
+// NewLoopback returns a loopback push provider
+func NewLoopback() *Loopback {
+	return &Loopback{
+		notifications: make(map[string][]Notification),
+	}
+}
@@ -0,0 +1,504 @@
+// Package push implements push notification based approval of sign ins. The browser displays a
+// number, and the user approves the sign in on an enrolled device by picking (or typing) the same
+// number, which prevents approving sign ins blindly (push fatigue attacks)
+package push
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusDenied   = "denied"
+	StatusExpired  = "expired"
+
+	challengeValidity = time.Minute * 2
+	// resultRetention is the duration for which the outcome of a challenge is available after it expires
+	resultRetention = time.Minute
+	// MaxWait is the maximum duration for which a status request would wait for the outcome
+	MaxWait      = time.Second * 30
+	pollInterval = time.Millisecond * 500
+
+	// MinDigits & MaxDigits are the number of digits allowed for the number to be matched
+	MinDigits = 3
+	MaxDigits = 6
+	// MaxChoices is the maximum number of choices shown on the device
+	MaxChoices = 10
+)
+
+var (
+	ErrUnexpected         = apperr.New(apperr.CodeInternal, "Sorry, an unexpected error occurred")
+	ErrInvalidPublicKey   = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid public key, should be base64 encoded PKIX P-256 or Ed25519 key")
+	ErrInvalidPushToken   = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no push token provided")
+	ErrNoDevices          = apperr.New(apperr.CodeInvalidInput, "Sorry, no devices enrolled for push approval")
+	ErrDeviceNotFound     = apperr.New(apperr.CodeNotFound, "Sorry, device not found")
+	ErrChallengeNotFound  = apperr.New(apperr.CodeNotFound, "Sorry, the sign in request does not exist or has expired")
+	ErrChallengeResolved  = apperr.New(apperr.CodeConflict, "Sorry, the sign in request was already approved/denied")
+	ErrInvalidSignature   = apperr.New(apperr.CodePermissionDenied, "Sorry, the response signature could not be verified")
+	ErrNumberMismatch     = apperr.New(apperr.CodePermissionDenied, "Sorry, the number selected did not match, the sign in was denied")
+	ErrPushDeliveryFailed = apperr.New(apperr.CodeUpstream, "Sorry, the approval request could not be delivered to any device")
+)
+
+// Device is a device enrolled by a user for approving sign ins
+type Device struct {
+	ID     int64  `json:"id,omitempty"`
+	UserID int64  `json:"userId,omitempty"`
+	Name   string `json:"name,omitempty"`
+	// PublicKey is the DER encoded PKIX public key of the device, used to verify its responses
+	PublicKey []byte `json:"-"`
+	// PushToken is the provider specific token to push notifications to the device
+	PushToken  string     `json:"-"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// Challenge is a sign in pending approval
+type Challenge struct {
+	ID     string `json:"id"`
+	UserID int64  `json:"-"`
+	Nonce  string `json:"-"`
+	// Number is displayed in the browser, and should be picked on the device while approving
+	Number  int    `json:"number"`
+	Choices []int  `json:"-"`
+	Status  string `json:"status"`
+	// DeviceID is the device which approved/denied the sign in
+	DeviceID  int64     `json:"deviceId,omitempty"`
+	Source    string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Response is the response of a device to a challenge
+type Response struct {
+	DeviceID int64 `json:"deviceId"`
+	Approve  bool  `json:"approve"`
+	// Number is the number picked by the user on the device
+	Number int `json:"number"`
+	// Signature is the signature of SignedMessage, generated using the device's private key
+	Signature []byte `json:"signature"`
+}
+
+// Config has all the configurations for the number matching
+type Config struct {
+	// Digits is the number of digits of the number to be matched, between MinDigits & MaxDigits
+	Digits int
+	// Choices is the number of choices shown on the device, including the correct one. If it's
+	// 0, no choices are shown and the user should type the number instead
+	Choices int
+}
+
+// DefaultConfig is the default configuration, the user picks the number out of 3 choices
+var DefaultConfig = Config{
+	Digits:  3,
+	Choices: 3,
+}
+
+// Push handles all the service methods made available by this package
+type Push struct {
+	appCtx   *appcontext.AppContext
+	cfg      Config
+	store    store
+	cache    cache.Cache
+	provider PushProvider
+}
+
+func challengeKey(id string) string {
+	return "push:challenge:" + id
+}
+
+// resolvedKey is the key set by the first response to the challenge, so that concurrent
+// responses cannot overwrite each other's outcome
+func resolvedKey(id string) string {
+	return "push:challenge:resolved:" + id
+}
+
+// SignedMessage returns the message to be signed by the device while responding to a challenge
+func SignedMessage(challengeID, nonce string, approve bool, number int) []byte {
+	decision := StatusDenied
+	if approve {
+		decision = StatusApproved
+	}
+	return []byte(fmt.Sprintf("%s|%s|%s|%d", challengeID, nonce, decision, number))
+}
+
+func randomInt(min, max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min+1)))
+	if err != nil {
+		return 0, err
+	}
+	return min + int(n.Int64()), nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// numbers returns the number to be matched, and the shuffled choices to be shown on the device.
+// There are no choices if the user should type the number
+func (cfg Config) numbers() (int, []int, error) {
+	min := 1
+	for i := 1; i < cfg.Digits; i++ {
+		min *= 10
+	}
+	max := min*10 - 1
+
+	if cfg.Choices == 0 {
+		n, err := randomInt(min, max)
+		return n, nil, err
+	}
+
+	choices := make([]int, 0, cfg.Choices)
+	for len(choices) < cfg.Choices {
+		n, err := randomInt(min, max)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		duplicate := false
+		for _, c := range choices {
+			duplicate = duplicate || c == n
+		}
+		if !duplicate {
+			choices = append(choices, n)
+		}
+	}
+
+	// the choices are already random, so picking any index as the correct one is unbiased
+	idx, err := randomInt(0, cfg.Choices-1)
+	if err != nil {
+		return 0, nil, err
+	}
+	return choices[idx], choices, nil
+}
+
+func verify(der, msg, sig []byte) error {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return err
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		{
+			digest := sha256.Sum256(msg)
+			if !ecdsa.VerifyASN1(k, digest[:], sig) {
+				return ErrInvalidSignature
+			}
+			return nil
+		}
+	case ed25519.PublicKey:
+		{
+			if !ed25519.Verify(k, msg, sig) {
+				return ErrInvalidSignature
+			}
+			return nil
+		}
+	}
+	return ErrInvalidPublicKey
+}
+
+func (p *Push) unexpected(err error) error {
+	if p.appCtx.Logging {
+		p.appCtx.Logger.Error(err)
+	}
+	return ErrUnexpected
+}
+
+func (p *Push) save(ch *Challenge) error {
+	return p.cache.Set(challengeKey(ch.ID), ch, time.Until(ch.ExpiresAt)+resultRetention)
+}
+
+func (p *Push) read(id string) (*Challenge, error) {
+	ch := &Challenge{}
+	err := p.cache.Get(challengeKey(id), ch)
+	if err != nil {
+		if err == cache.ErrNotFound {
+			return nil, ErrChallengeNotFound
+		}
+		return nil, p.unexpected(err)
+	}
+
+	if ch.Status == StatusPending && time.Now().After(ch.ExpiresAt) {
+		ch.Status = StatusExpired
+	}
+	return ch, nil
+}
+
+// EnrollDevice enrolls a device of the user for approving sign ins. publicKey is the base64
+// encoded PKIX public key (ECDSA P-256 or Ed25519) of the device
+func (p *Push) EnrollDevice(ctx context.Context, u *users.User, name, publicKey, pushToken string) (*Device, error) {
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		{
+			if k.Curve != elliptic.P256() {
+				return nil, ErrInvalidPublicKey
+			}
+		}
+	case ed25519.PublicKey:
+	default:
+		{
+			return nil, ErrInvalidPublicKey
+		}
+	}
+
+	pushToken = strings.TrimSpace(pushToken)
+	if pushToken == "" {
+		return nil, ErrInvalidPushToken
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Mobile device"
+	}
+
+	now := time.Now().UTC()
+	d, err := p.store.Create(ctx, Device{
+		UserID:    u.ID,
+		Name:      name,
+		PublicKey: der,
+		PushToken: pushToken,
+		CreatedAt: &now,
+	})
+	if err != nil {
+		return nil, p.unexpected(err)
+	}
+	return d, nil
+}
+
+// Devices lists all the devices enrolled by the user
+func (p *Push) Devices(ctx context.Context, u *users.User) ([]Device, error) {
+	list, err := p.store.List(ctx, u.ID)
+	if err != nil {
+		return nil, p.unexpected(err)
+	}
+	return list, nil
+}
+
+// RemoveDevice removes an enrolled device of the user
+func (p *Push) RemoveDevice(ctx context.Context, u *users.User, id int64) error {
+	err := p.store.Delete(ctx, u.ID, id)
+	if err != nil {
+		if err == ErrDeviceNotFound {
+			return err
+		}
+		return p.unexpected(err)
+	}
+	return nil
+}
+
+// Challenge creates a sign in request pending approval, and pushes it to all the enrolled
+// devices of the user. The number in the returned challenge should be displayed to the user
+func (p *Push) Challenge(ctx context.Context, u *users.User, source string) (*Challenge, error) {
+	devices, err := p.store.List(ctx, u.ID)
+	if err != nil {
+		return nil, p.unexpected(err)
+	}
+	if len(devices) == 0 {
+		return nil, ErrNoDevices
+	}
+
+	id, err := randomString(18)
+	if err != nil {
+		return nil, p.unexpected(err)
+	}
+	nonce, err := randomString(18)
+	if err != nil {
+		return nil, p.unexpected(err)
+	}
+	number, choices, err := p.cfg.numbers()
+	if err != nil {
+		return nil, p.unexpected(err)
+	}
+
+	now := time.Now().UTC()
+	ch := &Challenge{
+		ID:        id,
+		UserID:    u.ID,
+		Nonce:     nonce,
+		Number:    number,
+		Choices:   choices,
+		Status:    StatusPending,
+		Source:    source,
+		CreatedAt: now,
+		ExpiresAt: now.Add(challengeValidity),
+	}
+	err = p.save(ch)
+	if err != nil {
+		return nil, p.unexpected(err)
+	}
+
+	n := Notification{
+		ChallengeID: ch.ID,
+		Nonce:       ch.Nonce,
+		Digits:      p.cfg.Digits,
+		Choices:     ch.Choices,
+		Source:      ch.Source,
+		ExpiresAt:   ch.ExpiresAt,
+	}
+	delivered := 0
+	for _, d := range devices {
+		err = p.provider.Push(ctx, d, n)
+		if err != nil {
+			if p.appCtx.Logging {
+				p.appCtx.Logger.Error(err)
+			}
+			continue
+		}
+		delivered++
+	}
+
+	if delivered == 0 {
+		return nil, ErrPushDeliveryFailed
+	}
+
+	return ch, nil
+}
+
+// Respond records the approval/denial of a challenge by a device. Approving with a number
+// other than the one displayed in the browser denies the sign in
+func (p *Push) Respond(ctx context.Context, challengeID string, r Response) (*Challenge, error) {
+	ch, err := p.read(challengeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ch.Status == StatusExpired {
+		return nil, ErrChallengeNotFound
+	}
+	if ch.Status != StatusPending {
+		return nil, ErrChallengeResolved
+	}
+
+	d, err := p.store.Read(ctx, r.DeviceID)
+	if err != nil {
+		if err == ErrDeviceNotFound {
+			return nil, err
+		}
+		return nil, p.unexpected(err)
+	}
+
+	if d.UserID != ch.UserID {
+		return nil, ErrDeviceNotFound
+	}
+
+	err = verify(d.PublicKey, SignedMessage(ch.ID, ch.Nonce, r.Approve, r.Number), r.Signature)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	// only the first of concurrent responses is recorded
+	ok, err := p.cache.SetNX(resolvedKey(ch.ID), d.ID, time.Until(ch.ExpiresAt)+resultRetention)
+	if err != nil {
+		return nil, p.unexpected(err)
+	}
+	if !ok {
+		return nil, ErrChallengeResolved
+	}
+
+	ch.DeviceID = d.ID
+	ch.Status = StatusDenied
+	if r.Approve && r.Number == ch.Number {
+		ch.Status = StatusApproved
+	}
+
+	err = p.save(ch)
+	if err != nil {
+		return nil, p.unexpected(err)
+	}
+
+	err = p.store.UpdateUsage(ctx, d.ID, time.Now().UTC())
+	if err != nil {
+		return nil, p.unexpected(err)
+	}
+
+	if r.Approve && ch.Status == StatusDenied {
+		return ch, ErrNumberMismatch
+	}
+	return ch, nil
+}
+
+// Status returns the challenge of the user. If wait is non zero, it waits (long polls) for at
+// most wait duration (capped at MaxWait) for the challenge to be approved/denied
+func (p *Push) Status(ctx context.Context, u *users.User, challengeID string, wait time.Duration) (*Challenge, error) {
+	if wait > MaxWait {
+		wait = MaxWait
+	}
+	deadline := time.Now().Add(wait)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		ch, err := p.read(challengeID)
+		if err != nil {
+			return nil, err
+		}
+
+		if ch.UserID != u.ID {
+			return nil, ErrChallengeNotFound
+		}
+
+		if ch.Status != StatusPending || !time.Now().Before(deadline) {
+			return ch, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			{
+				return ch, nil
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+func New(appCtx *appcontext.AppContext, driver database.Driver, cfg Config, sdb *sql.DB, c cache.Cache, provider PushProvider) *Push {
+	if cfg.Digits < MinDigits || cfg.Digits > MaxDigits {
+		cfg.Digits = DefaultConfig.Digits
+	}
+	// a single choice would let the user approve without matching
+	if cfg.Choices < 0 || cfg.Choices == 1 || cfg.Choices > MaxChoices {
+		cfg.Choices = DefaultConfig.Choices
+	}
+
+	return &Push{
+		appCtx:   appCtx,
+		cfg:      cfg,
+		store:    newStore(appCtx, driver, sdb),
+		cache:    c,
+		provider: provider,
+	}
+}
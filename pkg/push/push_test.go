@@ -0,0 +1,409 @@
+package push
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// testDevice is a simulated device, which receives the notifications using the loopback provider
+type testDevice struct {
+	*Device
+	key crypto.Signer
+}
+
+func (td *testDevice) sign(t *testing.T, msg []byte) []byte {
+	t.Helper()
+
+	var (
+		sig []byte
+		err error
+	)
+	if _, ok := td.key.(ed25519.PrivateKey); ok {
+		sig, err = td.key.Sign(rand.Reader, msg, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(msg)
+		sig, err = td.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// respond responds to the notification, picking the given number
+func (td *testDevice) respond(t *testing.T, n Notification, approve bool, number int) Response {
+	return Response{
+		DeviceID:  td.ID,
+		Approve:   approve,
+		Number:    number,
+		Signature: td.sign(t, SignedMessage(n.ChallengeID, n.Nonce, approve, number)),
+	}
+}
+
+type pushFixture struct {
+	p        *Push
+	loopback *Loopback
+	user     *users.User
+}
+
+func testPush(t *testing.T, fn func(t *testing.T, f pushFixture)) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		f := pushFixture{
+			loopback: NewLoopback(),
+			user:     &users.User{ID: databasetest.User(t, driver, db, "jane@example.com")},
+		}
+		f.p = New(appcontext.New(logger.New()), driver, DefaultConfig, db, cache.NewMemory(), f.loopback)
+		fn(t, f)
+	})
+}
+
+func (f pushFixture) enroll(t *testing.T, name string, key crypto.Signer) *testDevice {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := f.p.EnrollDevice(
+		context.Background(),
+		f.user,
+		name,
+		base64.StdEncoding.EncodeToString(der),
+		"token of "+name,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testDevice{Device: d, key: key}
+}
+
+func (f pushFixture) enrollECDSA(t *testing.T, name string) *testDevice {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.enroll(t, name, key)
+}
+
+// challenge creates a challenge, and returns it along with the notification received by the device
+func (f pushFixture) challenge(t *testing.T, td *testDevice) (*Challenge, Notification) {
+	t.Helper()
+
+	ch, err := f.p.Challenge(context.Background(), f.user, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := f.loopback.Receive(td.PushToken)
+	if len(received) != 1 || received[0].ChallengeID != ch.ID {
+		t.Fatalf("expected the notification of challenge %s, got %+v", ch.ID, received)
+	}
+	return ch, received[0]
+}
+
+// otherChoice returns a choice other than the number displayed in the browser
+func otherChoice(ch *Challenge, n Notification) int {
+	for _, c := range n.Choices {
+		if c != ch.Number {
+			return c
+		}
+	}
+	return 0
+}
+
+func TestEnrollDevice(t *testing.T) {
+	testPush(t, func(t *testing.T, f pushFixture) {
+		ctx := context.Background()
+		_, err := f.p.Challenge(ctx, f.user, "web")
+		if !errors.Is(err, ErrNoDevices) {
+			t.Fatalf("expected ErrNoDevices, got %v", err)
+		}
+
+		_, err = f.p.EnrollDevice(ctx, f.user, "phone", "invalid", "token")
+		if !errors.Is(err, ErrInvalidPublicKey) {
+			t.Fatalf("expected ErrInvalidPublicKey, got %v", err)
+		}
+
+		p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(p384.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.p.EnrollDevice(ctx, f.user, "phone", base64.StdEncoding.EncodeToString(der), "token")
+		if !errors.Is(err, ErrInvalidPublicKey) {
+			t.Fatalf("expected ErrInvalidPublicKey for a P-384 key, got %v", err)
+		}
+
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := f.enroll(t, "phone", edKey)
+
+		list, err := f.p.Devices(ctx, f.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].ID != d.ID || list[0].Name != "phone" {
+			t.Fatalf("expected the enrolled device, got %+v", list)
+		}
+	})
+}
+
+func TestRespond(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// ed25519 enrolls an Ed25519 device instead of ECDSA P-256
+		ed25519 bool
+		approve bool
+		// wrongNumber picks a number other than the one displayed
+		wrongNumber bool
+		err         error
+		status      string
+	}{
+		{name: "approve", approve: true, status: StatusApproved},
+		{name: "approve ed25519", ed25519: true, approve: true, status: StatusApproved},
+		{name: "deny", approve: false, status: StatusDenied},
+		{name: "number mismatch", approve: true, wrongNumber: true, err: ErrNumberMismatch, status: StatusDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testPush(t, func(t *testing.T, f pushFixture) {
+				ctx := context.Background()
+				td := (*testDevice)(nil)
+				if tt.ed25519 {
+					td = f.enroll(t, "phone", edKey)
+				} else {
+					td = f.enrollECDSA(t, "phone")
+				}
+
+				ch, n := f.challenge(t, td)
+				number := ch.Number
+				if tt.wrongNumber {
+					number = otherChoice(ch, n)
+				}
+
+				got, err := f.p.Respond(ctx, ch.ID, td.respond(t, n, tt.approve, number))
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				if got.Status != tt.status || got.DeviceID != td.ID {
+					t.Fatalf("expected %s by device %d, got %+v", tt.status, td.ID, got)
+				}
+
+				status, err := f.p.Status(ctx, f.user, ch.ID, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if status.Status != tt.status {
+					t.Fatalf("expected status %s, got %s", tt.status, status.Status)
+				}
+
+				// the outcome cannot be changed once recorded
+				_, err = f.p.Respond(ctx, ch.ID, td.respond(t, n, true, ch.Number))
+				if !errors.Is(err, ErrChallengeResolved) {
+					t.Fatalf("expected ErrChallengeResolved, got %v", err)
+				}
+			})
+		})
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	for _, cfg := range []Config{{Digits: 3, Choices: 3}, {Digits: 6, Choices: 10}, {Digits: 4}} {
+		number, choices, err := cfg.numbers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(strconv.Itoa(number)) != cfg.Digits {
+			t.Fatalf("%+v: expected a number of %d digits, got %d", cfg, cfg.Digits, number)
+		}
+		if len(choices) != cfg.Choices {
+			t.Fatalf("%+v: expected %d choices, got %v", cfg, cfg.Choices, choices)
+		}
+
+		seen := make(map[int]bool, len(choices))
+		for _, c := range choices {
+			if seen[c] || len(strconv.Itoa(c)) != cfg.Digits {
+				t.Fatalf("%+v: expected distinct choices of %d digits, got %v", cfg, cfg.Digits, choices)
+			}
+			seen[c] = true
+		}
+		if cfg.Choices > 0 && !seen[number] {
+			t.Fatalf("%+v: expected %d to be one of the choices %v", cfg, number, choices)
+		}
+	}
+}
+
+func TestRespondTyped(t *testing.T) {
+	testPush(t, func(t *testing.T, f pushFixture) {
+		f.p.cfg = Config{Digits: 6}
+		td := f.enrollECDSA(t, "phone")
+
+		// the user types the number, so the device is not given the choices
+		ch, n := f.challenge(t, td)
+		if len(n.Choices) != 0 || n.Digits != 6 || len(strconv.Itoa(ch.Number)) != 6 {
+			t.Fatalf("expected a number of 6 digits without choices, got %d & %+v", ch.Number, n)
+		}
+
+		got, err := f.p.Respond(context.Background(), ch.ID, td.respond(t, n, true, ch.Number))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != StatusApproved {
+			t.Fatalf("expected the sign in to be approved, got %s", got.Status)
+		}
+	})
+}
+
+func TestRespondInvalid(t *testing.T) {
+	testPush(t, func(t *testing.T, f pushFixture) {
+		ctx := context.Background()
+		td := f.enrollECDSA(t, "phone")
+		ch, n := f.challenge(t, td)
+
+		// signed for a different number than the one in the response
+		r := td.respond(t, n, true, otherChoice(ch, n))
+		r.Number = ch.Number
+		_, err := f.p.Respond(ctx, ch.ID, r)
+		if !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("expected ErrInvalidSignature, got %v", err)
+		}
+
+		r = td.respond(t, n, true, ch.Number)
+		r.DeviceID = td.ID + 1
+		_, err = f.p.Respond(ctx, ch.ID, r)
+		if !errors.Is(err, ErrDeviceNotFound) {
+			t.Fatalf("expected ErrDeviceNotFound, got %v", err)
+		}
+
+		_, err = f.p.Respond(ctx, "invalid", td.respond(t, n, true, ch.Number))
+		if !errors.Is(err, ErrChallengeNotFound) {
+			t.Fatalf("expected ErrChallengeNotFound, got %v", err)
+		}
+
+		// the invalid responses do not resolve the challenge
+		_, err = f.p.Respond(ctx, ch.ID, td.respond(t, n, true, ch.Number))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestRespondExpired(t *testing.T) {
+	testPush(t, func(t *testing.T, f pushFixture) {
+		ctx := context.Background()
+		td := f.enrollECDSA(t, "phone")
+		ch, n := f.challenge(t, td)
+
+		expired, err := f.p.read(ch.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expired.ExpiresAt = time.Now().Add(-time.Second)
+		err = f.p.cache.Set(challengeKey(ch.ID), expired, resultRetention)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status, err := f.p.Status(ctx, f.user, ch.ID, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Status != StatusExpired {
+			t.Fatalf("expected status %s, got %s", StatusExpired, status.Status)
+		}
+
+		_, err = f.p.Respond(ctx, ch.ID, td.respond(t, n, true, ch.Number))
+		if !errors.Is(err, ErrChallengeNotFound) {
+			t.Fatalf("expected ErrChallengeNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRespondConcurrent(t *testing.T) {
+	testPush(t, func(t *testing.T, f pushFixture) {
+		ctx := context.Background()
+		phone := f.enrollECDSA(t, "phone")
+		tablet := f.enrollECDSA(t, "tablet")
+
+		ch, n := f.challenge(t, phone)
+		f.loopback.Receive(tablet.PushToken)
+
+		responses := make([]Response, 0, 10)
+		for i := 0; i < 5; i++ {
+			responses = append(
+				responses,
+				phone.respond(t, n, true, ch.Number),
+				tablet.respond(t, n, false, ch.Number),
+			)
+		}
+
+		var (
+			wg       sync.WaitGroup
+			mu       sync.Mutex
+			recorded []*Challenge
+		)
+		for _, r := range responses {
+			wg.Add(1)
+			go func(r Response) {
+				defer wg.Done()
+				got, err := f.p.Respond(ctx, ch.ID, r)
+				if err != nil {
+					if !errors.Is(err, ErrChallengeResolved) {
+						t.Error(err)
+					}
+					return
+				}
+				mu.Lock()
+				recorded = append(recorded, got)
+				mu.Unlock()
+			}(r)
+		}
+		wg.Wait()
+
+		if len(recorded) != 1 {
+			t.Fatalf("expected exactly 1 response to be recorded, got %d", len(recorded))
+		}
+
+		status, err := f.p.Status(ctx, f.user, ch.ID, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Status != recorded[0].Status || status.DeviceID != recorded[0].DeviceID {
+			t.Fatalf("expected the recorded outcome %+v, got %+v", recorded[0], status)
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package push
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+)
+
+const (
+	devicesTable = "pushDevices"
+
+	deviceColumns = "id,userid,name,publickey,pushtoken,createdat,lastusedat"
+)
+
+type store interface {
+	Create(ctx context.Context, d Device) (*Device, error)
+	Read(ctx context.Context, id int64) (*Device, error)
+	List(ctx context.Context, userID int64) ([]Device, error)
+	UpdateUsage(ctx context.Context, id int64, usedAt time.Time) error
+	Delete(ctx context.Context, userID, id int64) error
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type dbStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// newStore returns the store of the driver
+func newStore(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB) store {
+	if driver == database.SQLite {
+		return &sqliteStore{
+			appCtx: appCtx,
+			db:     sdb,
+		}
+	}
+	return &dbStore{
+		appCtx: appCtx,
+		db:     sdb,
+	}
+}
+
+func (dbs *dbStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, fmt.Sprintf("$%d", i+1))
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func scan(row scanner) (*Device, error) {
+	d := Device{}
+	publicKey := []byte{}
+	createdAt := pq.NullTime{}
+	lastUsedAt := pq.NullTime{}
+
+	err := row.Scan(
+		&d.ID,
+		&d.UserID,
+		&d.Name,
+		&publicKey,
+		&d.PushToken,
+		&createdAt,
+		&lastUsedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+
+	d.PublicKey = publicKey
+	if createdAt.Valid {
+		d.CreatedAt = &createdAt.Time
+	}
+	if lastUsedAt.Valid {
+		d.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return &d, nil
+}
+
+func (dbs *dbStore) Create(ctx context.Context, d Device) (*Device, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s RETURNING id",
+		devicesTable,
+		dbs.prepColVals("userid", "name", "publickey", "pushtoken", "createdat"),
+	)
+
+	err := dbs.db.QueryRowContext(
+		ctx,
+		stmt,
+		d.UserID,
+		d.Name,
+		d.PublicKey,
+		d.PushToken,
+		d.CreatedAt,
+	).Scan(&d.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func (dbs *dbStore) Read(ctx context.Context, id int64) (*Device, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=$1", deviceColumns, devicesTable)
+	return scan(dbs.db.QueryRowContext(ctx, stmt, id))
+}
+
+func (dbs *dbStore) List(ctx context.Context, userID int64) ([]Device, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE userid=$1 ORDER BY id",
+		deviceColumns,
+		devicesTable,
+	)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Device, 0)
+	for rows.Next() {
+		d, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *d)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) UpdateUsage(ctx context.Context, id int64, usedAt time.Time) error {
+	stmt := fmt.Sprintf("UPDATE %s SET lastusedat=$1 WHERE id=$2", devicesTable)
+	_, err := dbs.db.ExecContext(ctx, stmt, usedAt, id)
+	return err
+}
+
+func (dbs *dbStore) Delete(ctx context.Context, userID, id int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=$1 AND userid=$2", devicesTable)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
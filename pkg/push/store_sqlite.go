@@ -0,0 +1,123 @@
+package push
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+)
+
+// sqliteStore is the store of SQLite, it behaves exactly like dbStore
+type sqliteStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// sqliteTime returns the time in UTC truncated to seconds, as stored by Postgres. SQLite stores
+// the times as text, which are compared correctly only if they're all in the same time zone
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Truncate(time.Second)
+}
+
+func (ss *sqliteStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, "?")
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func (ss *sqliteStore) Create(ctx context.Context, d Device) (*Device, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		devicesTable,
+		ss.prepColVals("userid", "name", "publickey", "pushtoken", "createdat"),
+	)
+
+	result, err := ss.db.ExecContext(
+		ctx,
+		stmt,
+		d.UserID,
+		d.Name,
+		d.PublicKey,
+		d.PushToken,
+		sqliteTime(d.CreatedAt),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	d.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func (ss *sqliteStore) Read(ctx context.Context, id int64) (*Device, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=?", deviceColumns, devicesTable)
+	return scan(ss.db.QueryRowContext(ctx, stmt, id))
+}
+
+func (ss *sqliteStore) List(ctx context.Context, userID int64) ([]Device, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE userid=? ORDER BY id",
+		deviceColumns,
+		devicesTable,
+	)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Device, 0)
+	for rows.Next() {
+		d, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *d)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) UpdateUsage(ctx context.Context, id int64, usedAt time.Time) error {
+	stmt := fmt.Sprintf("UPDATE %s SET lastusedat=? WHERE id=?", devicesTable)
+	_, err := ss.db.ExecContext(ctx, stmt, sqliteTime(&usedAt), id)
+	return err
+}
+
+func (ss *sqliteStore) Delete(ctx context.Context, userID, id int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=? AND userid=?", devicesTable)
+
+	result, err := ss.db.ExecContext(ctx, stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
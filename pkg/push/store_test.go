@@ -0,0 +1,89 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+func TestStoreDevices(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		st := newStore(appcontext.New(logger.New()), driver, db)
+		userID := databasetest.User(t, driver, db, "jane@example.com")
+		otherUserID := databasetest.User(t, driver, db, "john@example.com")
+
+		now := time.Now()
+		create := func(userID int64, name string) *Device {
+			t.Helper()
+
+			d, err := st.Create(ctx, Device{
+				UserID:    userID,
+				Name:      name,
+				PublicKey: []byte("public key of " + name),
+				PushToken: "token of " + name,
+				CreatedAt: &now,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return d
+		}
+
+		phone := create(userID, "phone")
+		tablet := create(userID, "tablet")
+		create(otherUserID, "other")
+
+		d, err := st.Read(ctx, phone.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.UserID != userID || d.Name != "phone" || !bytes.Equal(d.PublicKey, phone.PublicKey) || d.PushToken != phone.PushToken {
+			t.Fatalf("expected %+v, got %+v", phone, d)
+		}
+
+		list, err := st.List(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != phone.ID || list[1].ID != tablet.ID {
+			t.Fatalf("expected the devices of the user, got %+v", list)
+		}
+
+		err = st.UpdateUsage(ctx, phone.ID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err = st.Read(ctx, phone.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.LastUsedAt == nil {
+			t.Fatalf("expected the usage to be updated, got %+v", d)
+		}
+
+		err = st.Delete(ctx, otherUserID, phone.ID)
+		if !errors.Is(err, ErrDeviceNotFound) {
+			t.Fatalf("expected the device of another user to be not found, got %v", err)
+		}
+
+		err = st.Delete(ctx, userID, phone.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = st.Read(ctx, phone.ID)
+		if !errors.Is(err, ErrDeviceNotFound) {
+			t.Fatalf("expected ErrDeviceNotFound, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,315 @@
+// Package rbac implements role based access control. Roles are either platform wide (admin) or
+// scoped to an application (owner, developer, viewer)
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+// Role is a named set of permissions
+type Role string
+
+// Permission is an action which can be performed on the platform or on an application
+type Permission string
+
+type ctxKey string
+
+const (
+	// RoleAdmin is a platform admin, allowed to perform every action on every application
+	RoleAdmin = Role("admin")
+	// RoleOwner owns an application, and can manage who else has access to it
+	RoleOwner = Role("owner")
+	// RoleDeveloper can read & update an application
+	RoleDeveloper = Role("developer")
+	// RoleViewer can only read an application
+	RoleViewer = Role("viewer")
+
+	PermAppRead    = Permission("apps.read")
+	PermAppUpdate  = Permission("apps.update")
+	PermAppDelete  = Permission("apps.delete")
+	PermAppMembers = Permission("apps.members")
+	PermAppKeys    = Permission("apps.keys")
+	PermAppHooks   = Permission("apps.webhooks")
+	PermUsers      = Permission("users.manage")
+	PermRoles      = Permission("roles.manage")
+	PermOrgs       = Permission("orgs.manage")
+
+	ctxSystemKey = ctxKey("system")
+)
+
+var (
+	ErrUnauthenticated = apperr.New(apperr.CodeUnauthenticated, "Sorry, you should be signed in to perform this action")
+	ErrForbidden       = apperr.New(apperr.CodePermissionDenied, "Sorry, you are not allowed to perform this action")
+	ErrInvalidRole     = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid role/application provided")
+	ErrNotFound        = apperr.New(apperr.CodeNotFound, "Sorry, role assignment not found")
+	ErrAssigned        = apperr.New(apperr.CodeConflict, "Sorry, the role is already assigned")
+	ErrUnexpected      = apperr.New(apperr.CodeInternal, "Sorry, an unexpected error occurred")
+
+	rolePermissions = map[Role][]Permission{
+		RoleAdmin: []Permission{
+			PermAppRead,
+			PermAppUpdate,
+			PermAppDelete,
+			PermAppMembers,
+			PermAppKeys,
+			PermAppHooks,
+			PermUsers,
+			PermRoles,
+			PermOrgs,
+		},
+		RoleOwner: []Permission{
+			PermAppRead,
+			PermAppUpdate,
+			PermAppDelete,
+			PermAppMembers,
+			PermAppKeys,
+			PermAppHooks,
+		},
+		RoleDeveloper: []Permission{
+			PermAppRead,
+			PermAppUpdate,
+		},
+		RoleViewer: []Permission{
+			PermAppRead,
+		},
+	}
+)
+
+// Assignment assigns a role to a user. AppID is 0 for platform wide roles
+type Assignment struct {
+	ID        int64      `json:"id,omitempty"`
+	UserID    int64      `json:"userId,omitempty"`
+	Role      Role       `json:"role,omitempty"`
+	AppID     int64      `json:"appId,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// Validate checks if the role is valid for the scope of the assignment
+func (a *Assignment) Validate() error {
+	if a.UserID < 1 {
+		return ErrInvalidRole
+	}
+
+	switch a.Role {
+	case RoleAdmin:
+		{
+			if a.AppID != 0 {
+				return ErrInvalidRole
+			}
+		}
+	case RoleOwner, RoleDeveloper, RoleViewer:
+		{
+			if a.AppID < 1 {
+				return ErrInvalidRole
+			}
+		}
+	default:
+		{
+			return ErrInvalidRole
+		}
+	}
+	return nil
+}
+
+func hasPermission(list []Permission, perm Permission) bool {
+	for _, p := range list {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows checks if the assignment allows the permission on the given application
+func (a *Assignment) Allows(perm Permission, appID int64) bool {
+	if a.Role == RoleAdmin {
+		return hasPermission(rolePermissions[RoleAdmin], perm)
+	}
+	return a.AppID == appID && appID > 0 && hasPermission(rolePermissions[a.Role], perm)
+}
+
+// SystemContext marks the context as being used by the system itself (e.g. CLI), rather than on
+// behalf of a user. All permissions are allowed for a system context
+func SystemContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, ctxSystemKey, true)
+}
+
+func isSystem(ctx context.Context) bool {
+	system, _ := ctx.Value(ctxSystemKey).(bool)
+	return system
+}
+
+// RBAC handles all the service methods made available by this package
+type RBAC struct {
+	appCtx *appcontext.AppContext
+	store  store
+}
+
+// unexpected logs the error, and wraps it with ErrUnexpected
+func (r *RBAC) unexpected(err error) error {
+	if r.appCtx.Logging {
+		r.appCtx.Logger.Error(err)
+	}
+	return apperr.Wrap(ErrUnexpected, err)
+}
+
+// Can checks if the user is allowed the permission on the application. appID should be 0 for
+// platform wide permissions. Roles derived from organisation & team memberships are included
+func (r *RBAC) Can(ctx context.Context, u *users.User, perm Permission, appID int64) (bool, error) {
+	list, err := r.store.ListEffective(ctx, u.ID)
+	if err != nil {
+		return false, r.unexpected(err)
+	}
+
+	for _, a := range list {
+		if a.Allows(perm, appID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Authorize returns nil if the user in the context is allowed the permission on the application,
+// else returns ErrUnauthenticated or ErrForbidden
+func (r *RBAC) Authorize(ctx context.Context, perm Permission, appID int64) error {
+	if isSystem(ctx) {
+		return nil
+	}
+
+	u := users.FromContext(ctx)
+	if u == nil {
+		return ErrUnauthenticated
+	}
+
+	ok, err := r.Can(ctx, u, perm, appID)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// AppIDs returns the IDs of all the applications on which the user in the context is allowed
+// the permission. all is true if the permission is allowed on every application
+func (r *RBAC) AppIDs(ctx context.Context, perm Permission) (ids []int64, all bool, err error) {
+	if isSystem(ctx) {
+		return nil, true, nil
+	}
+
+	u := users.FromContext(ctx)
+	if u == nil {
+		return nil, false, ErrUnauthenticated
+	}
+
+	list, err := r.store.ListEffective(ctx, u.ID)
+	if err != nil {
+		return nil, false, r.unexpected(err)
+	}
+
+	ids = make([]int64, 0, len(list))
+	for _, a := range list {
+		if a.Role == RoleAdmin && a.Allows(perm, 0) {
+			return nil, true, nil
+		}
+		if a.Allows(perm, a.AppID) {
+			ids = append(ids, a.AppID)
+		}
+	}
+	return ids, false, nil
+}
+
+// authorizeAssignment checks if the user in the context can manage the assignment. Platform
+// roles require PermRoles, and application roles require PermAppMembers on the application
+func (r *RBAC) authorizeAssignment(ctx context.Context, a Assignment) error {
+	if a.AppID == 0 {
+		return r.Authorize(ctx, PermRoles, 0)
+	}
+	return r.Authorize(ctx, PermAppMembers, a.AppID)
+}
+
+// Assign assigns the role to the user
+func (r *RBAC) Assign(ctx context.Context, a Assignment) (*Assignment, error) {
+	err := a.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.authorizeAssignment(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	a.CreatedAt = &now
+	assigned, err := r.store.Create(ctx, a)
+	if err != nil {
+		if apperr.Is(err, ErrAssigned) {
+			return nil, err
+		}
+		return nil, r.unexpected(err)
+	}
+	return assigned, nil
+}
+
+// Revoke removes the role assignment
+func (r *RBAC) Revoke(ctx context.Context, id int64) error {
+	a, err := r.store.Read(ctx, id)
+	if err != nil {
+		if apperr.Is(err, ErrNotFound) {
+			return err
+		}
+		return r.unexpected(err)
+	}
+
+	err = r.authorizeAssignment(ctx, *a)
+	if err != nil {
+		return err
+	}
+
+	err = r.store.Delete(ctx, id)
+	if err != nil {
+		if apperr.Is(err, ErrNotFound) {
+			return err
+		}
+		return r.unexpected(err)
+	}
+	return nil
+}
+
+// Assignments lists the roles assigned to the user. Users can list their own roles, else
+// PermRoles is required
+func (r *RBAC) Assignments(ctx context.Context, userID int64) ([]Assignment, error) {
+	u := users.FromContext(ctx)
+	if u == nil || u.ID != userID {
+		err := r.Authorize(ctx, PermRoles, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	list, err := r.store.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, r.unexpected(err)
+	}
+	return list, nil
+}
+
+func New(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB) *RBAC {
+	return &RBAC{
+		appCtx: appCtx,
+		store:  newStore(appCtx, driver, sdb),
+	}
+}
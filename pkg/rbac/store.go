@@ -0,0 +1,207 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+)
+
+const (
+	// AssignmentsTable is the table storing role assignments, it's exported for the other
+	// packages which assign roles within their own transactions
+	AssignmentsTable = "roleAssignments"
+
+	assignmentColumns = "id,userid,role,appid,createdat"
+
+	// tables of the orgs package, through which roles are derived
+	orgMembersTable  = "organisationMembers"
+	teamMembersTable = "teamMembers"
+	teamAppsTable    = "teamApplications"
+	appTable         = "applications"
+
+	// pqUniqueViolation is the Postgres error code for unique constraint violation
+	pqUniqueViolation = "23505"
+)
+
+type store interface {
+	// Create returns ErrAssigned if the role is already assigned to the user
+	Create(ctx context.Context, a Assignment) (*Assignment, error)
+	Read(ctx context.Context, id int64) (*Assignment, error)
+	ListByUser(ctx context.Context, userID int64) ([]Assignment, error)
+	// ListEffective lists the roles assigned to the user directly, as well as the ones derived
+	// from organisation & team memberships
+	ListEffective(ctx context.Context, userID int64) ([]Assignment, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type dbStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// newStore returns the store of the driver
+func newStore(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB) store {
+	if driver == database.SQLite {
+		return &sqliteStore{
+			appCtx: appCtx,
+			db:     sdb,
+		}
+	}
+	return &dbStore{
+		appCtx: appCtx,
+		db:     sdb,
+	}
+}
+
+func (dbs *dbStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, fmt.Sprintf("$%d", i+1))
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func scan(row scanner) (*Assignment, error) {
+	a := Assignment{}
+	appID := sql.NullInt64{}
+	createdAt := pq.NullTime{}
+
+	err := row.Scan(&a.ID, &a.UserID, &a.Role, &appID, &createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	a.AppID = appID.Int64
+	if createdAt.Valid {
+		a.CreatedAt = &createdAt.Time
+	}
+	return &a, nil
+}
+
+// nullAppID converts the platform wide app ID 0 to NULL
+func nullAppID(appID int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: appID, Valid: appID > 0}
+}
+
+func (dbs *dbStore) Create(ctx context.Context, a Assignment) (*Assignment, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s RETURNING id",
+		AssignmentsTable,
+		dbs.prepColVals("userid", "role", "appid", "createdat"),
+	)
+
+	err := dbs.db.QueryRowContext(
+		ctx,
+		stmt,
+		a.UserID,
+		a.Role,
+		nullAppID(a.AppID),
+		a.CreatedAt,
+	).Scan(&a.ID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+			return nil, ErrAssigned
+		}
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+func (dbs *dbStore) Read(ctx context.Context, id int64) (*Assignment, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=$1", assignmentColumns, AssignmentsTable)
+	return scan(dbs.db.QueryRowContext(ctx, stmt, id))
+}
+
+func (dbs *dbStore) ListByUser(ctx context.Context, userID int64) ([]Assignment, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE userid=$1 ORDER BY id",
+		assignmentColumns,
+		AssignmentsTable,
+	)
+	return dbs.list(ctx, stmt, userID)
+}
+
+// ListEffective lists the direct assignments of the user, along with the following derived ones
+// (with ID 0):
+// 1. owner of every application of the organisations where the user is an owner or admin
+// 2. the role granted to a team, on the team's applications, for every member of the team
+func (dbs *dbStore) ListEffective(ctx context.Context, userID int64) ([]Assignment, error) {
+	stmt := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE userid=$1
+		UNION ALL
+		SELECT 0, m.userid, '%s', a.id, m.createdat FROM %s m
+		INNER JOIN %s a ON a.orgid=m.orgid
+		WHERE m.userid=$1 AND m.role IN ('owner', 'admin')
+		UNION ALL
+		SELECT 0, tm.userid, ta.role, ta.appid, tm.createdat FROM %s tm
+		INNER JOIN %s ta ON ta.teamid=tm.teamid
+		WHERE tm.userid=$1`,
+		assignmentColumns,
+		AssignmentsTable,
+		RoleOwner,
+		orgMembersTable,
+		appTable,
+		teamMembersTable,
+		teamAppsTable,
+	)
+	return dbs.list(ctx, stmt, userID)
+}
+
+func (dbs *dbStore) list(ctx context.Context, stmt string, args ...interface{}) ([]Assignment, error) {
+	rows, err := dbs.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Assignment, 0)
+	for rows.Next() {
+		a, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) Delete(ctx context.Context, id int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=$1", AssignmentsTable)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
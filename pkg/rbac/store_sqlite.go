@@ -0,0 +1,153 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+)
+
+// sqliteStore is the store of SQLite, it behaves exactly like dbStore
+type sqliteStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+func isSQLiteUniqueViolation(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// sqliteTime returns the time in UTC truncated to seconds, as stored by Postgres. SQLite stores
+// the times as text, which are compared correctly only if they're all in the same time zone
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Truncate(time.Second)
+}
+
+func (ss *sqliteStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, "?")
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func (ss *sqliteStore) Create(ctx context.Context, a Assignment) (*Assignment, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		AssignmentsTable,
+		ss.prepColVals("userid", "role", "appid", "createdat"),
+	)
+
+	result, err := ss.db.ExecContext(
+		ctx,
+		stmt,
+		a.UserID,
+		a.Role,
+		nullAppID(a.AppID),
+		sqliteTime(a.CreatedAt),
+	)
+	if err != nil {
+		if isSQLiteUniqueViolation(err) {
+			return nil, ErrAssigned
+		}
+		return nil, err
+	}
+
+	a.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+func (ss *sqliteStore) Read(ctx context.Context, id int64) (*Assignment, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=?", assignmentColumns, AssignmentsTable)
+	return scan(ss.db.QueryRowContext(ctx, stmt, id))
+}
+
+func (ss *sqliteStore) ListByUser(ctx context.Context, userID int64) ([]Assignment, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE userid=? ORDER BY id",
+		assignmentColumns,
+		AssignmentsTable,
+	)
+	return ss.list(ctx, stmt, userID)
+}
+
+// ListEffective lists the direct assignments of the user, along with the derived ones, refer
+// dbStore.ListEffective
+func (ss *sqliteStore) ListEffective(ctx context.Context, userID int64) ([]Assignment, error) {
+	stmt := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE userid=?
+		UNION ALL
+		SELECT 0, m.userid, '%s', a.id, m.createdat FROM %s m
+		INNER JOIN %s a ON a.orgid=m.orgid
+		WHERE m.userid=? AND m.role IN ('owner', 'admin')
+		UNION ALL
+		SELECT 0, tm.userid, ta.role, ta.appid, tm.createdat FROM %s tm
+		INNER JOIN %s ta ON ta.teamid=tm.teamid
+		WHERE tm.userid=?`,
+		assignmentColumns,
+		AssignmentsTable,
+		RoleOwner,
+		orgMembersTable,
+		appTable,
+		teamMembersTable,
+		teamAppsTable,
+	)
+	return ss.list(ctx, stmt, userID, userID, userID)
+}
+
+func (ss *sqliteStore) list(ctx context.Context, stmt string, args ...interface{}) ([]Assignment, error) {
+	rows, err := ss.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Assignment, 0)
+	for rows.Next() {
+		a, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) Delete(ctx context.Context, id int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=?", AssignmentsTable)
+
+	result, err := ss.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+func TestStoreAssignments(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		st := newStore(appcontext.New(logger.New()), driver, db)
+		userID := databasetest.User(t, driver, db, "jane@example.com")
+		orgID := databasetest.Org(t, driver, db, "acme")
+		appID := databasetest.App(t, driver, db, orgID, "billing")
+
+		now := time.Now()
+		admin, err := st.Create(ctx, Assignment{UserID: userID, Role: RoleAdmin, CreatedAt: &now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		viewer, err := st.Create(ctx, Assignment{UserID: userID, Role: RoleViewer, AppID: appID, CreatedAt: &now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = st.Create(ctx, Assignment{UserID: userID, Role: RoleViewer, AppID: appID, CreatedAt: &now})
+		if !errors.Is(err, ErrAssigned) {
+			t.Fatalf("expected ErrAssigned, got %v", err)
+		}
+
+		a, err := st.Read(ctx, admin.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Role != RoleAdmin || a.AppID != 0 || a.UserID != userID {
+			t.Fatalf("expected the platform wide admin role, got %+v", a)
+		}
+
+		list, err := st.ListByUser(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != admin.ID || list[1].ID != viewer.ID || list[1].AppID != appID {
+			t.Fatalf("expected the admin & viewer roles, got %+v", list)
+		}
+
+		err = st.Delete(ctx, admin.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = st.Read(ctx, admin.ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		err = st.Delete(ctx, admin.ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStoreListEffective(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		st := newStore(appcontext.New(logger.New()), driver, db)
+		now := time.Now().UTC()
+
+		admin := databasetest.User(t, driver, db, "admin@example.com")
+		member := databasetest.User(t, driver, db, "member@example.com")
+		orgID := databasetest.Org(t, driver, db, "acme")
+		billing := databasetest.App(t, driver, db, orgID, "billing")
+		shipping := databasetest.App(t, driver, db, orgID, "shipping")
+		otherApp := databasetest.App(t, driver, db, databasetest.Org(t, driver, db, "umbrella"), "other")
+
+		memberCols := []string{"orgid", "userid", "role", "createdat"}
+		databasetest.Insert(t, driver, db, orgMembersTable, memberCols, orgID, admin, "admin", now)
+		databasetest.Insert(t, driver, db, orgMembersTable, memberCols, orgID, member, "member", now)
+		teamID := databasetest.Insert(t, driver, db, "teams", []string{"orgid", "name", "createdat"}, orgID, "platform", now)
+		databasetest.Insert(t, driver, db, teamMembersTable, []string{"teamid", "userid", "createdat"}, teamID, member, now)
+		databasetest.Insert(t, driver, db, teamAppsTable, []string{"teamid", "appid", "role", "createdat"}, teamID, shipping, RoleDeveloper, now)
+
+		_, err := st.Create(ctx, Assignment{UserID: member, Role: RoleViewer, AppID: otherApp, CreatedAt: &now})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name   string
+			userID int64
+			// roles are the expected roles keyed by app ID
+			roles map[int64]Role
+		}{
+			{
+				// the admins of an organisation own all its apps
+				name:   "org admin",
+				userID: admin,
+				roles:  map[int64]Role{billing: RoleOwner, shipping: RoleOwner},
+			},
+			{
+				// the members get the roles granted to their teams, besides their own
+				name:   "org member",
+				userID: member,
+				roles:  map[int64]Role{shipping: RoleDeveloper, otherApp: RoleViewer},
+			},
+		}
+
+		for _, tt := range tests {
+			list, err := st.ListEffective(ctx, tt.userID)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+
+			roles := map[int64]Role{}
+			for _, a := range list {
+				if a.UserID != tt.userID {
+					t.Fatalf("%s: expected assignments of user %d, got %+v", tt.name, tt.userID, a)
+				}
+				roles[a.AppID] = a.Role
+			}
+
+			if len(roles) != len(tt.roles) || len(list) != len(tt.roles) {
+				t.Fatalf("%s: expected %v, got %+v", tt.name, tt.roles, list)
+			}
+			for appID, role := range tt.roles {
+				if roles[appID] != role {
+					t.Fatalf("%s: expected %s on app %d, got %s", tt.name, role, appID, roles[appID])
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,280 @@
+package subjects
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+)
+
+const (
+	subjectsTable      = "subjects"
+	recoveryCodesTable = "subjectRecoveryCodes"
+
+	subjectColumns = "id,appid,externalid,secret,confirmed,lastcounter,failedattempts,lockeduntil,createdat,updatedat"
+)
+
+// errLockedOut is returned by the store if the subject is locked out
+var errLockedOut = errors.New("subjects: locked out")
+
+// the failed attempts after the current failure, they start over if the previous lockout (if
+// any) has elapsed
+const nextAttempts = "CASE WHEN lockeduntil IS NULL THEN failedattempts + 1 ELSE 1 END"
+
+type store interface {
+	Read(ctx context.Context, appID int64, externalID string) (*Subject, error)
+	// Upsert creates the subject, or resets the secret & state of an existing subject. The
+	// recovery codes (hashes) replace the existing ones
+	Upsert(ctx context.Context, s Subject, recoveryCodes []string) error
+	// RecordSuccess records the counter of a verified code & resets the failed attempts. It's
+	// recorded only if the counter is greater than the last one & the subject is not locked out,
+	// else false is returned (e.g. the code was used by a concurrent verification)
+	RecordSuccess(ctx context.Context, id, counter int64, at time.Time) (bool, error)
+	// Confirm confirms the enrollment, and returns false if it was already confirmed
+	Confirm(ctx context.Context, id int64, at time.Time) (bool, error)
+	// RecordFailure increments the failed attempts, and locks out the subject till lockUntil once
+	// they reach maxAttempts. The attempts are reset if the previous lockout has elapsed. It returns
+	// the updated subject, or errLockedOut if the subject is still locked out
+	RecordFailure(ctx context.Context, id int64, maxAttempts int, lockUntil, at time.Time) (*Subject, error)
+	// UseRecoveryCode marks the recovery code (hash) as used & resets the failed attempts. It
+	// returns false if the code does not exist, was already used or the subject is locked out
+	UseRecoveryCode(ctx context.Context, id int64, recoveryCode string, at time.Time) (bool, error)
+	Delete(ctx context.Context, appID int64, externalID string) error
+}
+
+type dbStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// newStore returns the store of the driver
+func newStore(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB) store {
+	if driver == database.SQLite {
+		return &sqliteStore{
+			appCtx: appCtx,
+			db:     sdb,
+		}
+	}
+	return &dbStore{
+		appCtx: appCtx,
+		db:     sdb,
+	}
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row scanner) (*Subject, error) {
+	s := Subject{}
+	lockedUntil := pq.NullTime{}
+	createdAt := pq.NullTime{}
+	updatedAt := pq.NullTime{}
+
+	err := row.Scan(
+		&s.ID,
+		&s.AppID,
+		&s.ExternalID,
+		&s.secret,
+		&s.Confirmed,
+		&s.lastCounter,
+		&s.FailedAttempts,
+		&lockedUntil,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotEnrolled
+		}
+		return nil, err
+	}
+
+	if lockedUntil.Valid {
+		s.LockedUntil = &lockedUntil.Time
+	}
+	if createdAt.Valid {
+		s.CreatedAt = &createdAt.Time
+	}
+	if updatedAt.Valid {
+		s.UpdatedAt = &updatedAt.Time
+	}
+
+	return &s, nil
+}
+
+func (dbs *dbStore) Read(ctx context.Context, appID int64, externalID string) (*Subject, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE appid=$1 AND externalid=$2",
+		subjectColumns,
+		subjectsTable,
+	)
+
+	return scan(dbs.db.QueryRowContext(ctx, stmt, appID, externalID))
+}
+
+func (dbs *dbStore) rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && err != sql.ErrTxDone && dbs.appCtx.Logging {
+		dbs.appCtx.Logger.Error(err)
+	}
+}
+
+func (dbs *dbStore) Upsert(ctx context.Context, s Subject, recoveryCodes []string) error {
+	stmt := fmt.Sprintf(
+		`INSERT INTO %s (appid, externalid, secret, confirmed, lastcounter, failedattempts, createdat, updatedat)
+		VALUES($1, $2, $3, FALSE, 0, 0, $4, $5)
+		ON CONFLICT (appid, externalid) DO UPDATE SET
+		secret=EXCLUDED.secret, confirmed=FALSE, lastcounter=0, failedattempts=0, lockeduntil=NULL,
+		updatedat=EXCLUDED.updatedat RETURNING id`,
+		subjectsTable,
+	)
+
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer dbs.rollback(tx)
+
+	id := int64(0)
+	err = tx.QueryRowContext(ctx, stmt, s.AppID, s.ExternalID, s.secret, s.CreatedAt, s.UpdatedAt).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE subjectid=$1", recoveryCodesTable), id)
+	if err != nil {
+		return err
+	}
+
+	stmt = fmt.Sprintf("INSERT INTO %s (subjectid, hash, createdat) VALUES($1, $2, $3)", recoveryCodesTable)
+	for _, code := range recoveryCodes {
+		_, err = tx.ExecContext(ctx, stmt, id, code, s.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (dbs *dbStore) RecordSuccess(ctx context.Context, id, counter int64, at time.Time) (bool, error) {
+	stmt := fmt.Sprintf(
+		`UPDATE %s SET lastcounter=$1, failedattempts=0, lockeduntil=NULL, updatedat=$2
+		WHERE id=$3 AND lastcounter < $1 AND (lockeduntil IS NULL OR lockeduntil <= $2)`,
+		subjectsTable,
+	)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, counter, at, id)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (dbs *dbStore) Confirm(ctx context.Context, id int64, at time.Time) (bool, error) {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET confirmed=TRUE, updatedat=$1 WHERE id=$2 AND confirmed=FALSE",
+		subjectsTable,
+	)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, at, id)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (dbs *dbStore) RecordFailure(ctx context.Context, id int64, maxAttempts int, lockUntil, at time.Time) (*Subject, error) {
+	stmt := fmt.Sprintf(
+		`UPDATE %s SET failedattempts=%s,
+		lockeduntil=CASE WHEN %s >= $1 THEN $2::timestamptz ELSE NULL END, updatedat=$3
+		WHERE id=$4 AND (lockeduntil IS NULL OR lockeduntil <= $3) RETURNING %s`,
+		subjectsTable,
+		nextAttempts,
+		nextAttempts,
+		subjectColumns,
+	)
+
+	sub, err := scan(dbs.db.QueryRowContext(ctx, stmt, maxAttempts, lockUntil, at, id))
+	if err == ErrNotEnrolled {
+		// the subject exists (it was read before verifying), so it's locked out
+		return nil, errLockedOut
+	}
+	return sub, err
+}
+
+func (dbs *dbStore) UseRecoveryCode(ctx context.Context, id int64, recoveryCode string, at time.Time) (bool, error) {
+	stmt := fmt.Sprintf(
+		`UPDATE %s SET usedat=$1 WHERE subjectid=$2 AND hash=$3 AND usedat IS NULL
+		AND NOT EXISTS (SELECT 1 FROM %s WHERE id=$2 AND lockeduntil > $1)`,
+		recoveryCodesTable,
+		subjectsTable,
+	)
+
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer dbs.rollback(tx)
+
+	result, err := tx.ExecContext(ctx, stmt, at, id, recoveryCode)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if count == 0 {
+		return false, nil
+	}
+
+	stmt = fmt.Sprintf(
+		"UPDATE %s SET failedattempts=0, lockeduntil=NULL, updatedat=$1 WHERE id=$2",
+		subjectsTable,
+	)
+	_, err = tx.ExecContext(ctx, stmt, at, id)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (dbs *dbStore) Delete(ctx context.Context, appID int64, externalID string) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE appid=$1 AND externalid=$2", subjectsTable)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, appID, externalID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotEnrolled
+	}
+	return nil
+}
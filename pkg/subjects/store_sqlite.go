@@ -0,0 +1,239 @@
+package subjects
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+)
+
+// sqliteStore is the store of SQLite, it behaves exactly like dbStore
+type sqliteStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// sqliteTime returns the time in UTC truncated to seconds, as stored by Postgres. SQLite stores
+// the times as text, which are compared correctly only if they're all in the same time zone
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Truncate(time.Second)
+}
+
+func (ss *sqliteStore) Read(ctx context.Context, appID int64, externalID string) (*Subject, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE appid=? AND externalid=?",
+		subjectColumns,
+		subjectsTable,
+	)
+
+	return scan(ss.db.QueryRowContext(ctx, stmt, appID, externalID))
+}
+
+func (ss *sqliteStore) rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && err != sql.ErrTxDone && ss.appCtx.Logging {
+		ss.appCtx.Logger.Error(err)
+	}
+}
+
+func (ss *sqliteStore) Upsert(ctx context.Context, s Subject, recoveryCodes []string) error {
+	stmt := fmt.Sprintf(
+		`INSERT INTO %s (appid, externalid, secret, confirmed, lastcounter, failedattempts, createdat, updatedat)
+		VALUES(?, ?, ?, FALSE, 0, 0, ?, ?)
+		ON CONFLICT (appid, externalid) DO UPDATE SET
+		secret=excluded.secret, confirmed=FALSE, lastcounter=0, failedattempts=0, lockeduntil=NULL,
+		updatedat=excluded.updatedat`,
+		subjectsTable,
+	)
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer ss.rollback(tx)
+
+	_, err = tx.ExecContext(
+		ctx,
+		stmt,
+		s.AppID,
+		s.ExternalID,
+		s.secret,
+		sqliteTime(s.CreatedAt),
+		sqliteTime(s.UpdatedAt),
+	)
+	if err != nil {
+		return err
+	}
+
+	// the last insert ID is not set when the subject is updated
+	id := int64(0)
+	stmt = fmt.Sprintf("SELECT id FROM %s WHERE appid=? AND externalid=?", subjectsTable)
+	err = tx.QueryRowContext(ctx, stmt, s.AppID, s.ExternalID).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE subjectid=?", recoveryCodesTable), id)
+	if err != nil {
+		return err
+	}
+
+	stmt = fmt.Sprintf("INSERT INTO %s (subjectid, hash, createdat) VALUES(?, ?, ?)", recoveryCodesTable)
+	for _, code := range recoveryCodes {
+		_, err = tx.ExecContext(ctx, stmt, id, code, sqliteTime(s.UpdatedAt))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (ss *sqliteStore) RecordSuccess(ctx context.Context, id, counter int64, at time.Time) (bool, error) {
+	stmt := fmt.Sprintf(
+		`UPDATE %s SET lastcounter=?, failedattempts=0, lockeduntil=NULL, updatedat=?
+		WHERE id=? AND lastcounter < ? AND (lockeduntil IS NULL OR lockeduntil <= ?)`,
+		subjectsTable,
+	)
+
+	result, err := ss.db.ExecContext(ctx, stmt, counter, sqliteTime(&at), id, counter, sqliteTime(&at))
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (ss *sqliteStore) Confirm(ctx context.Context, id int64, at time.Time) (bool, error) {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET confirmed=TRUE, updatedat=? WHERE id=? AND confirmed=FALSE",
+		subjectsTable,
+	)
+
+	result, err := ss.db.ExecContext(ctx, stmt, sqliteTime(&at), id)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (ss *sqliteStore) RecordFailure(ctx context.Context, id int64, maxAttempts int, lockUntil, at time.Time) (*Subject, error) {
+	stmt := fmt.Sprintf(
+		`UPDATE %s SET failedattempts=%s,
+		lockeduntil=CASE WHEN %s >= ? THEN ? ELSE NULL END, updatedat=?
+		WHERE id=? AND (lockeduntil IS NULL OR lockeduntil <= ?)`,
+		subjectsTable,
+		nextAttempts,
+		nextAttempts,
+	)
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ss.rollback(tx)
+
+	result, err := tx.ExecContext(
+		ctx,
+		stmt,
+		maxAttempts,
+		sqliteTime(&lockUntil),
+		sqliteTime(&at),
+		id,
+		sqliteTime(&at),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errLockedOut
+	}
+
+	sub, err := scan(tx.QueryRowContext(
+		ctx,
+		fmt.Sprintf("SELECT %s FROM %s WHERE id=?", subjectColumns, subjectsTable),
+		id,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, tx.Commit()
+}
+
+func (ss *sqliteStore) UseRecoveryCode(ctx context.Context, id int64, recoveryCode string, at time.Time) (bool, error) {
+	stmt := fmt.Sprintf(
+		`UPDATE %s SET usedat=? WHERE subjectid=? AND hash=? AND usedat IS NULL
+		AND NOT EXISTS (SELECT 1 FROM %s WHERE id=? AND lockeduntil > ?)`,
+		recoveryCodesTable,
+		subjectsTable,
+	)
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer ss.rollback(tx)
+
+	result, err := tx.ExecContext(ctx, stmt, sqliteTime(&at), id, recoveryCode, id, sqliteTime(&at))
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if count == 0 {
+		return false, nil
+	}
+
+	stmt = fmt.Sprintf(
+		"UPDATE %s SET failedattempts=0, lockeduntil=NULL, updatedat=? WHERE id=?",
+		subjectsTable,
+	)
+	_, err = tx.ExecContext(ctx, stmt, sqliteTime(&at), id)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (ss *sqliteStore) Delete(ctx context.Context, appID int64, externalID string) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE appid=? AND externalid=?", subjectsTable)
+
+	result, err := ss.db.ExecContext(ctx, stmt, appID, externalID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotEnrolled
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package subjects
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+func TestStoreSubjects(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		st := newStore(appcontext.New(logger.New()), driver, db)
+		orgID := databasetest.Org(t, driver, db, "acme")
+		appID := databasetest.App(t, driver, db, orgID, "billing")
+
+		_, err := st.Read(ctx, appID, "jane")
+		if !errors.Is(err, ErrNotEnrolled) {
+			t.Fatalf("expected ErrNotEnrolled, got %v", err)
+		}
+
+		now := time.Now()
+		err = st.Upsert(ctx, Subject{AppID: appID, ExternalID: "jane", CreatedAt: &now, UpdatedAt: &now, secret: "first"}, []string{"a", "b", "d"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sub, err := st.Read(ctx, appID, "jane")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sub.secret != "first" || sub.Confirmed || sub.FailedAttempts != 0 || sub.lastCounter != 0 {
+			t.Fatalf("expected a new unconfirmed subject, got %+v", sub)
+		}
+
+		confirmed, err := st.Confirm(ctx, sub.ID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !confirmed {
+			t.Fatal("expected the subject to be confirmed")
+		}
+
+		confirmed, err = st.Confirm(ctx, sub.ID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if confirmed {
+			t.Fatal("expected the subject to be confirmed only once")
+		}
+
+		recorded, err := st.RecordSuccess(ctx, sub.ID, 42, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !recorded {
+			t.Fatal("expected the counter to be recorded")
+		}
+
+		for _, counter := range []int64{42, 41} {
+			recorded, err = st.RecordSuccess(ctx, sub.ID, counter, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recorded {
+				t.Fatalf("expected counter %d not to be recorded after 42", counter)
+			}
+		}
+
+		lockUntil := now.Add(time.Minute)
+		for i := 1; i <= 3; i++ {
+			sub, err = st.RecordFailure(ctx, sub.ID, 3, lockUntil, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sub.FailedAttempts != i {
+				t.Fatalf("expected %d failed attempts, got %d", i, sub.FailedAttempts)
+			}
+			if (sub.LockedUntil != nil) != (i == 3) {
+				t.Fatalf("expected the subject to be locked out only after 3 failures, got %v after %d", sub.LockedUntil, i)
+			}
+		}
+
+		_, err = st.RecordFailure(ctx, sub.ID, 3, lockUntil, now)
+		if err != errLockedOut {
+			t.Fatalf("expected errLockedOut, got %v", err)
+		}
+
+		recorded, err = st.RecordSuccess(ctx, sub.ID, 43, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recorded {
+			t.Fatal("expected the counter not to be recorded while locked out")
+		}
+
+		used, err := st.UseRecoveryCode(ctx, sub.ID, "a", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used {
+			t.Fatal("expected the recovery code not to be used while locked out")
+		}
+
+		sub, err = st.Read(ctx, appID, "jane")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sub.Confirmed || sub.lastCounter != 42 || sub.FailedAttempts != 3 || sub.LockedUntil == nil {
+			t.Fatalf("expected the state to be updated, got %+v", sub)
+		}
+
+		// the attempts start over once the lockout elapses
+		later := lockUntil.Add(time.Second)
+		elapsed, err := st.RecordFailure(ctx, sub.ID, 3, later.Add(time.Minute), later)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed.FailedAttempts != 1 || elapsed.LockedUntil != nil {
+			t.Fatalf("expected the attempts to start over, got %+v", elapsed)
+		}
+
+		recorded, err = st.RecordSuccess(ctx, sub.ID, 43, later)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !recorded {
+			t.Fatal("expected the counter to be recorded")
+		}
+
+		sub, err = st.Read(ctx, appID, "jane")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sub.lastCounter != 43 || sub.FailedAttempts != 0 || sub.LockedUntil != nil {
+			t.Fatalf("expected the failed attempts to be reset, got %+v", sub)
+		}
+
+		_, err = st.RecordFailure(ctx, sub.ID, 3, later.Add(time.Minute), later)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, code := range []struct {
+			hash string
+			used bool
+		}{{"x", false}, {"a", true}, {"a", false}, {"b", true}} {
+			used, err = st.UseRecoveryCode(ctx, sub.ID, code.hash, later)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if used != code.used {
+				t.Fatalf("expected recovery code %q to be used: %v, got %v", code.hash, code.used, used)
+			}
+		}
+
+		sub, err = st.Read(ctx, appID, "jane")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sub.FailedAttempts != 0 {
+			t.Fatalf("expected the recovery code to reset the failed attempts, got %+v", sub)
+		}
+
+		// enrolling again resets the secret & the state
+		err = st.Upsert(ctx, Subject{AppID: appID, ExternalID: "jane", CreatedAt: &now, UpdatedAt: &now, secret: "second"}, []string{"c"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reset, err := st.Read(ctx, appID, "jane")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reset.ID != sub.ID || reset.secret != "second" || reset.Confirmed || reset.lastCounter != 0 ||
+			reset.FailedAttempts != 0 || reset.LockedUntil != nil {
+			t.Fatalf("expected the subject to be reset, got %+v", reset)
+		}
+
+		// the recovery codes are replaced as well
+		for _, code := range []struct {
+			hash string
+			used bool
+		}{{"d", false}, {"c", true}} {
+			used, err = st.UseRecoveryCode(ctx, sub.ID, code.hash, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if used != code.used {
+				t.Fatalf("expected recovery code %q to be used: %v, got %v", code.hash, code.used, used)
+			}
+		}
+
+		err = st.Delete(ctx, appID, "jane")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = st.Delete(ctx, appID, "jane")
+		if !errors.Is(err, ErrNotEnrolled) {
+			t.Fatalf("expected ErrNotEnrolled, got %v", err)
+		}
+	})
+}
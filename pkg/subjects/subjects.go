@@ -0,0 +1,406 @@
+// Package subjects handles the end users of registered applications, identified by the
+// application's own user ID, who are enrolled for TOTP verification through Padlock
+package subjects
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/totp"
+	"github.com/bnkamalesh/padlock/pkg/webhooks"
+)
+
+// Status is the result of a verification
+type Status string
+
+const (
+	// StatusValid is returned when the code is valid
+	StatusValid = Status("valid")
+	// StatusInvalid is returned when the code is invalid
+	StatusInvalid = Status("invalid")
+	// StatusReplayed is returned when the code was valid, but was already used
+	StatusReplayed = Status("replayed")
+	// StatusLockedOut is returned when the subject is locked out for too many failed attempts
+	StatusLockedOut = Status("locked_out")
+
+	// maxExternalIDLength is the maximum length of the application's user identifier
+	maxExternalIDLength = 255
+
+	// recoveryCodeCount is the number of recovery codes generated while enrolling
+	recoveryCodeCount = 10
+	// recoveryCodeLength is the number of characters in a recovery code (excluding the hyphen),
+	// it's longer than the TOTP codes so that the 2 cannot be mistaken for each other
+	recoveryCodeLength = 10
+)
+
+var (
+	ErrInvalidExternalID = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no subject ID provided")
+	ErrAlreadyEnrolled   = apperr.New(apperr.CodeConflict, "Sorry, the subject is already enrolled")
+	ErrNotEnrolled       = apperr.New(apperr.CodeNotFound, "Sorry, the subject is not enrolled")
+	ErrUnexpected        = apperr.New(apperr.CodeInternal, "Sorry, an unexpected error occurred")
+)
+
+// Subject is an end user of a registered application
+type Subject struct {
+	ID    int64 `json:"-"`
+	AppID int64 `json:"appId,omitempty"`
+	// ExternalID is the identifier of the user, as used by the application
+	ExternalID string `json:"externalId,omitempty"`
+	// Confirmed is true once the subject verifies a code post enrolling
+	Confirmed bool `json:"confirmed"`
+	// FailedAttempts is the number of consecutive failed verifications
+	FailedAttempts int        `json:"failedAttempts,omitempty"`
+	LockedUntil    *time.Time `json:"lockedUntil,omitempty"`
+	CreatedAt      *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt      *time.Time `json:"updatedAt,omitempty"`
+
+	secret string
+	// lastCounter is the TOTP counter of the last successfully verified code
+	lastCounter int64
+}
+
+// Enrollment has the details required by the subject to set up an authenticator app
+type Enrollment struct {
+	ExternalID string `json:"externalId"`
+	// Secret is the TOTP secret, base32 encoded. It is only available while enrolling
+	Secret string `json:"secret"`
+	// URI is the otpauth URI, to be shown as a QR code
+	URI string `json:"uri"`
+	// RecoveryCodes are single use codes, which can be verified instead of the TOTP code (e.g. if
+	// the authenticator is lost). They're only available while enrolling
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// Result is the result of a verification
+type Result struct {
+	Status Status `json:"status"`
+	// RecoveryCode is true if a recovery code was verified, rather than a TOTP code
+	RecoveryCode bool `json:"recoveryCode,omitempty"`
+	// RemainingAttempts is the number of failed attempts left before the subject is locked out
+	RemainingAttempts int        `json:"remainingAttempts"`
+	LockedUntil       *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// Config has all the configurations for verification
+type Config struct {
+	// MaxAttempts is the number of consecutive failed verifications, post which the subject is
+	// locked out
+	MaxAttempts int
+	// LockoutDuration is the duration for which a subject is locked out
+	LockoutDuration time.Duration
+}
+
+// DefaultConfig is the default configuration for verification
+var DefaultConfig = Config{
+	MaxAttempts:     5,
+	LockoutDuration: time.Minute * 15,
+}
+
+// Publisher publishes the events of subjects to the application, e.g. as webhooks
+type Publisher interface {
+	Publish(ctx context.Context, appID int64, event webhooks.Event, data interface{}) error
+}
+
+// Subjects handles all the service methods made available by this package
+type Subjects struct {
+	appCtx    *appcontext.AppContext
+	cfg       Config
+	store     store
+	publisher Publisher
+}
+
+func (s *Subjects) unexpected(err error) error {
+	if s.appCtx.Logging {
+		s.appCtx.Logger.Error(err)
+	}
+	return ErrUnexpected
+}
+
+// publish publishes the event of the subject. The event is secondary to the action which
+// triggered it, so a failure to publish is only logged
+func (s *Subjects) publish(ctx context.Context, event webhooks.Event, sub Subject) {
+	err := s.publisher.Publish(ctx, sub.AppID, event, sub)
+	if err != nil && s.appCtx.Logging {
+		s.appCtx.Logger.Error(err)
+	}
+}
+
+// appTOTP returns the TOTP settings of the app, or the defaults if not configured
+func appTOTP(app *apps.App) *totp.TOTP {
+	if app.TOTP == nil {
+		return totp.New(app.Name, 6, 30, totp.AlgoSHA1)
+	}
+
+	if app.TOTP.Issuer == "" {
+		// the issuer is shown by the authenticator apps, to tell apart the accounts
+		t := *app.TOTP
+		t.Issuer = app.Name
+		return &t
+	}
+	return app.TOTP
+}
+
+// recoveryEncoding is lower case, so that the codes are easier to read out & type
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns the recovery codes, formatted as 'xxxxx-xxxxx', along with their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		// every character encodes 5 bits
+		b := make([]byte, (recoveryCodeLength*5+7)/8)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := recoveryEncoding.EncodeToString(b)[:recoveryCodeLength]
+		half := recoveryCodeLength / 2
+		codes = append(codes, code[:half]+"-"+code[half:])
+		hashes = append(hashes, recoveryCodeHash(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode removes the formatting of the code
+func normalizeRecoveryCode(code string) string {
+	code = strings.Replace(strings.TrimSpace(code), "-", "", -1)
+	return strings.ToLower(strings.Replace(code, " ", "", -1))
+}
+
+// recoveryCodeHash returns the hash of the (normalized) code as stored. The codes are random,
+// so unlike passwords, a plain hash is enough
+func recoveryCodeHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func sanitizeExternalID(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	if id == "" || len(id) > maxExternalIDLength {
+		return "", ErrInvalidExternalID
+	}
+	return id, nil
+}
+
+// Enroll generates a new TOTP secret for the subject. The enrollment is confirmed when the
+// subject verifies a code for the first time, till then it can be enrolled again (e.g. if the
+// QR code was lost)
+func (s *Subjects) Enroll(ctx context.Context, app *apps.App, externalID string) (*Enrollment, error) {
+	externalID, err := sanitizeExternalID(externalID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.store.Read(ctx, app.ID, externalID)
+	if err != nil && err != ErrNotEnrolled {
+		return nil, s.unexpected(err)
+	}
+	if existing != nil && existing.Confirmed {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, s.unexpected(err)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, s.unexpected(err)
+	}
+
+	now := time.Now().UTC()
+	sub := Subject{
+		AppID:      app.ID,
+		ExternalID: externalID,
+		CreatedAt:  &now,
+		UpdatedAt:  &now,
+		secret:     secret,
+	}
+
+	err = s.store.Upsert(ctx, sub, hashes)
+	if err != nil {
+		return nil, s.unexpected(err)
+	}
+
+	return &Enrollment{
+		ExternalID:    externalID,
+		Secret:        secret,
+		URI:           appTOTP(app).URI(externalID, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// Unenroll removes the TOTP secret of the subject
+func (s *Subjects) Unenroll(ctx context.Context, app *apps.App, externalID string) error {
+	externalID, err := sanitizeExternalID(externalID)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.Delete(ctx, app.ID, externalID)
+	if err != nil {
+		if err == ErrNotEnrolled {
+			return err
+		}
+		return s.unexpected(err)
+	}
+
+	s.publish(ctx, webhooks.EventSubjectUnenrolled, Subject{AppID: app.ID, ExternalID: externalID})
+	return nil
+}
+
+// Verify verifies the TOTP code, or one of the recovery codes of the subject. Codes cannot be
+// reused, and the subject is locked out for Config.LockoutDuration after Config.MaxAttempts
+// consecutive failures. The state is updated atomically, so concurrent verifications cannot
+// reuse a code or exceed the attempts
+func (s *Subjects) Verify(ctx context.Context, app *apps.App, externalID, code string) (*Result, error) {
+	externalID, err := sanitizeExternalID(externalID)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := s.store.Read(ctx, app.ID, externalID)
+	if err != nil {
+		if err == ErrNotEnrolled {
+			return nil, err
+		}
+		return nil, s.unexpected(err)
+	}
+
+	now := time.Now().UTC()
+	if sub.LockedUntil != nil && sub.LockedUntil.After(now) {
+		return &Result{Status: StatusLockedOut, LockedUntil: sub.LockedUntil}, nil
+	}
+
+	if rc := normalizeRecoveryCode(code); len(rc) == recoveryCodeLength {
+		return s.recover(ctx, sub, rc, now)
+	}
+
+	counter, ok, err := appTOTP(app).Verify(sub.secret, code, now)
+	if err != nil {
+		return nil, s.unexpected(err)
+	}
+
+	if ok {
+		return s.recordSuccess(ctx, sub, counter, now)
+	}
+	return s.recordFailure(ctx, sub, now)
+}
+
+func (s *Subjects) remainingAttempts(failed int) int {
+	remaining := s.cfg.MaxAttempts - failed
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// current returns the result as per the current state of the subject, when the verification
+// could not be recorded due to a concurrent verification
+func (s *Subjects) current(ctx context.Context, sub *Subject, status Status, now time.Time) (*Result, error) {
+	latest, err := s.store.Read(ctx, sub.AppID, sub.ExternalID)
+	if err != nil {
+		if err == ErrNotEnrolled {
+			return nil, err
+		}
+		return nil, s.unexpected(err)
+	}
+
+	if latest.LockedUntil != nil && latest.LockedUntil.After(now) {
+		return &Result{Status: StatusLockedOut, LockedUntil: latest.LockedUntil}, nil
+	}
+
+	return &Result{Status: status, RemainingAttempts: s.remainingAttempts(latest.FailedAttempts)}, nil
+}
+
+func (s *Subjects) recordSuccess(ctx context.Context, sub *Subject, counter int64, now time.Time) (*Result, error) {
+	recorded, err := s.store.RecordSuccess(ctx, sub.ID, counter, now)
+	if err != nil {
+		return nil, s.unexpected(err)
+	}
+	if !recorded {
+		// the code (or a later one) was already used, or the subject was locked out meanwhile
+		return s.current(ctx, sub, StatusReplayed, now)
+	}
+
+	confirmed, err := s.store.Confirm(ctx, sub.ID, now)
+	if err != nil {
+		return nil, s.unexpected(err)
+	}
+
+	sub.Confirmed = true
+	sub.FailedAttempts = 0
+	sub.LockedUntil = nil
+	sub.UpdatedAt = &now
+	sub.lastCounter = counter
+	if confirmed {
+		s.publish(ctx, webhooks.EventSubjectEnrolled, *sub)
+	}
+
+	return &Result{Status: StatusValid, RemainingAttempts: s.cfg.MaxAttempts}, nil
+}
+
+// recover verifies the recovery code, which can be used only once
+func (s *Subjects) recover(ctx context.Context, sub *Subject, code string, now time.Time) (*Result, error) {
+	used, err := s.store.UseRecoveryCode(ctx, sub.ID, recoveryCodeHash(code), now)
+	if err != nil {
+		return nil, s.unexpected(err)
+	}
+	if !used {
+		return s.recordFailure(ctx, sub, now)
+	}
+
+	sub.FailedAttempts = 0
+	sub.LockedUntil = nil
+	sub.UpdatedAt = &now
+	s.publish(ctx, webhooks.EventSubjectRecoveryCodeUsed, *sub)
+
+	return &Result{Status: StatusValid, RecoveryCode: true, RemainingAttempts: s.cfg.MaxAttempts}, nil
+}
+
+func (s *Subjects) recordFailure(ctx context.Context, sub *Subject, now time.Time) (*Result, error) {
+	updated, err := s.store.RecordFailure(ctx, sub.ID, s.cfg.MaxAttempts, now.Add(s.cfg.LockoutDuration), now)
+	if err != nil {
+		if err == errLockedOut {
+			return s.current(ctx, sub, StatusInvalid, now)
+		}
+		return nil, s.unexpected(err)
+	}
+
+	if updated.LockedUntil != nil {
+		s.publish(ctx, webhooks.EventSubjectLockedOut, *updated)
+		return &Result{Status: StatusLockedOut, LockedUntil: updated.LockedUntil}, nil
+	}
+
+	return &Result{Status: StatusInvalid, RemainingAttempts: s.remainingAttempts(updated.FailedAttempts)}, nil
+}
+
+func New(appCtx *appcontext.AppContext, driver database.Driver, cfg Config, sdb *sql.DB, p Publisher) *Subjects {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = DefaultConfig.MaxAttempts
+	}
+
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = DefaultConfig.LockoutDuration
+	}
+
+	return &Subjects{
+		appCtx:    appCtx,
+		cfg:       cfg,
+		store:     newStore(appCtx, driver, sdb),
+		publisher: p,
+	}
+}
@@ -0,0 +1,294 @@
+package subjects
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apps"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/totp"
+	"github.com/bnkamalesh/padlock/pkg/webhooks"
+)
+
+// recorder records the published events
+type recorder struct {
+	mu     sync.Mutex
+	events []webhooks.Event
+}
+
+func (r *recorder) Publish(ctx context.Context, appID int64, event webhooks.Event, data interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recorder) count(event webhooks.Event) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, e := range r.events {
+		if e == event {
+			count++
+		}
+	}
+	return count
+}
+
+type subjectsFixture struct {
+	subjects  *Subjects
+	publisher *recorder
+	app       *apps.App
+}
+
+func testSubjects(t *testing.T, driver database.Driver, db *sql.DB) *subjectsFixture {
+	t.Helper()
+	orgID := databasetest.Org(t, driver, db, "acme")
+	app := &apps.App{ID: databasetest.App(t, driver, db, orgID, "billing"), Name: "billing"}
+	p := &recorder{}
+	cfg := Config{MaxAttempts: 3, LockoutDuration: time.Minute}
+	return &subjectsFixture{
+		subjects:  New(appcontext.New(logger.New()), driver, cfg, db, p),
+		publisher: p,
+		app:       app,
+	}
+}
+
+// codes returns a valid code of the secret, and a code which is invalid within the drift
+func codes(t *testing.T, app *apps.App, secret string) (string, string) {
+	t.Helper()
+	now := time.Now()
+	tt := appTOTP(app)
+	valid := make(map[string]bool, 3)
+	for i := -1; i <= 1; i++ {
+		code, err := tt.Code(secret, now.Add(time.Duration(i)*time.Second*30))
+		if err != nil {
+			t.Fatal(err)
+		}
+		valid[code] = true
+	}
+
+	code, err := tt.Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; ; i++ {
+		invalid := fmt.Sprintf("%06d", i)
+		if !valid[invalid] {
+			return code, invalid
+		}
+	}
+}
+
+func TestEnrollURI(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		f := testSubjects(t, driver, db)
+
+		// the settings not configured by the application are the defaults
+		f.app.TOTP = &totp.TOTP{}
+		enrollment, err := f.subjects.Enroll(ctx, f.app, "jane")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := "otpauth://totp/billing:jane?algorithm=SHA1&digits=6&issuer=billing&period=30&secret=" + enrollment.Secret
+		if enrollment.URI != expected {
+			t.Fatalf("expected %s, got %s", expected, enrollment.URI)
+		}
+		if f.app.TOTP.Issuer != "" {
+			t.Fatalf("expected the application not to be modified, got %+v", f.app.TOTP)
+		}
+	})
+}
+
+func TestVerify(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		f := testSubjects(t, driver, db)
+
+		enrollment, err := f.subjects.Enroll(ctx, f.app, "jane")
+		if err != nil {
+			t.Fatal(err)
+		}
+		valid, invalid := codes(t, f.app, enrollment.Secret)
+
+		result, err := f.subjects.Verify(ctx, f.app, "jane", invalid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != StatusInvalid || result.RemainingAttempts != 2 {
+			t.Fatalf("expected invalid with 2 attempts remaining, got %+v", result)
+		}
+
+		result, err = f.subjects.Verify(ctx, f.app, "jane", valid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != StatusValid || result.RemainingAttempts != 3 {
+			t.Fatalf("expected valid with the attempts reset, got %+v", result)
+		}
+		if f.publisher.count(webhooks.EventSubjectEnrolled) != 1 {
+			t.Fatalf("expected the enrollment to be published once, got %v", f.publisher.events)
+		}
+
+		result, err = f.subjects.Verify(ctx, f.app, "jane", valid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != StatusReplayed {
+			t.Fatalf("expected the code to be replayed, got %+v", result)
+		}
+
+		for i := 0; i < 3; i++ {
+			result, err = f.subjects.Verify(ctx, f.app, "jane", invalid)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if result.Status != StatusLockedOut || result.LockedUntil == nil {
+			t.Fatalf("expected the subject to be locked out, got %+v", result)
+		}
+		if f.publisher.count(webhooks.EventSubjectLockedOut) != 1 {
+			t.Fatalf("expected the lockout to be published once, got %v", f.publisher.events)
+		}
+
+		for _, code := range []string{invalid, valid} {
+			result, err = f.subjects.Verify(ctx, f.app, "jane", code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Status != StatusLockedOut {
+				t.Fatalf("expected %q to be rejected while locked out, got %+v", code, result)
+			}
+		}
+		if f.publisher.count(webhooks.EventSubjectEnrolled) != 1 || f.publisher.count(webhooks.EventSubjectLockedOut) != 1 {
+			t.Fatalf("expected no more events while locked out, got %v", f.publisher.events)
+		}
+
+		_, err = f.subjects.Verify(ctx, f.app, "john", valid)
+		if err != ErrNotEnrolled {
+			t.Fatalf("expected ErrNotEnrolled, got %v", err)
+		}
+	})
+}
+
+func TestVerifyRecoveryCode(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		f := testSubjects(t, driver, db)
+
+		enrollment, err := f.subjects.Enroll(ctx, f.app, "jane")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(enrollment.RecoveryCodes) != recoveryCodeCount {
+			t.Fatalf("expected %d recovery codes, got %v", recoveryCodeCount, enrollment.RecoveryCodes)
+		}
+		_, invalid := codes(t, f.app, enrollment.Secret)
+
+		result, err := f.subjects.Verify(ctx, f.app, "jane", invalid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != StatusInvalid {
+			t.Fatalf("expected invalid, got %+v", result)
+		}
+
+		// the codes are accepted irrespective of the case & formatting
+		code := strings.ToUpper(strings.Replace(enrollment.RecoveryCodes[0], "-", " ", -1))
+		result, err = f.subjects.Verify(ctx, f.app, "jane", code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != StatusValid || !result.RecoveryCode || result.RemainingAttempts != 3 {
+			t.Fatalf("expected the recovery code to be valid, got %+v", result)
+		}
+		if f.publisher.count(webhooks.EventSubjectRecoveryCodeUsed) != 1 {
+			t.Fatalf("expected the recovery code usage to be published, got %v", f.publisher.events)
+		}
+
+		for _, code := range []string{enrollment.RecoveryCodes[0], "aaaaa-aaaaa"} {
+			result, err = f.subjects.Verify(ctx, f.app, "jane", code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Status != StatusInvalid || result.RecoveryCode {
+				t.Fatalf("expected %q to be invalid, got %+v", code, result)
+			}
+		}
+
+		// enrolling again replaces the recovery codes
+		_, err = f.subjects.Enroll(ctx, f.app, "jane")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err = f.subjects.Verify(ctx, f.app, "jane", enrollment.RecoveryCodes[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != StatusInvalid {
+			t.Fatalf("expected the old recovery code to be invalid, got %+v", result)
+		}
+		if f.publisher.count(webhooks.EventSubjectRecoveryCodeUsed) != 1 {
+			t.Fatalf("expected no more recovery code usages, got %v", f.publisher.events)
+		}
+	})
+}
+
+func TestVerifyConcurrent(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		f := testSubjects(t, driver, db)
+
+		enrollment, err := f.subjects.Enroll(ctx, f.app, "jane")
+		if err != nil {
+			t.Fatal(err)
+		}
+		valid, invalid := codes(t, f.app, enrollment.Secret)
+
+		verify := func(code string, n int) map[Status]int {
+			wg := sync.WaitGroup{}
+			mu := sync.Mutex{}
+			statuses := make(map[Status]int, 4)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					result, err := f.subjects.Verify(ctx, f.app, "jane", code)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					statuses[result.Status]++
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+			return statuses
+		}
+
+		statuses := verify(valid, 10)
+		if statuses[StatusValid] != 1 || statuses[StatusReplayed] != 9 {
+			t.Fatalf("expected the code to be accepted only once, got %v", statuses)
+		}
+
+		statuses = verify(invalid, 10)
+		if statuses[StatusInvalid] != 2 || statuses[StatusLockedOut] != 8 {
+			t.Fatalf("expected only 3 attempts before the lockout, got %v", statuses)
+		}
+		if f.publisher.count(webhooks.EventSubjectLockedOut) != 1 {
+			t.Fatalf("expected the lockout to be published once, got %v", f.publisher.events)
+		}
+	})
+}
@@ -4,7 +4,17 @@
 package totp
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash"
 	"net/url"
 	"strings"
 	"time"
@@ -12,15 +22,38 @@ import (
 	"github.com/bnkamalesh/padlock/pkg/appcontext"
 )
 
-type algo string
+// Algo is the hashing algorithm used to generate the TOTP
+type Algo string
 
 const (
 	StatusActive   = "active"
 	StatusInactive = "inactive"
 
-	AlgoSHA1   = algo("SHA1")
-	AlgoSHA256 = algo("SHA256")
-	AlgoSHA512 = algo("SHA512")
+	AlgoSHA1   = Algo("SHA1")
+	AlgoSHA256 = Algo("SHA256")
+	AlgoSHA512 = Algo("SHA512")
+
+	defaultDigits      = 6
+	defaultPeriod      = 30
+	defaultDriftChecks = 1
+	// secretLength is the number of random bytes in a secret, RFC 4226 recommends 160 bits
+	secretLength = 20
+
+	// MinDigits & MaxDigits are the number of digits allowed, RFC 4226 (section 5.3) requires at
+	// least 6, and the 31 bit truncated value has less than 10 digits
+	MinDigits = 6
+	MaxDigits = 8
+)
+
+var (
+	// ErrInvalidSecret is returned if the secret is not a valid base32 string
+	ErrInvalidSecret = errors.New("Invalid TOTP secret")
+	// ErrInvalidDigits is returned if the number of digits is not between MinDigits & MaxDigits
+	ErrInvalidDigits = errors.New("Invalid TOTP digits, should be between 6 & 8")
+	// ErrInvalidAlgorithm is returned if the algorithm is not one of SHA1, SHA256 or SHA512
+	ErrInvalidAlgorithm = errors.New("Invalid TOTP algorithm, should be SHA1, SHA256 or SHA512")
+
+	b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
 )
 
 type TOTP struct {
@@ -28,7 +61,7 @@ type TOTP struct {
 	// Issuer is the URI encoded name of the service for which TOTP is generated
 	Issuer string `json:"issuer,omitempty"`
 	// Algorithm is the hashing algorithm used to generate the secret
-	Algorithm algo `json:"algorithm,omitempty"`
+	Algorithm Algo `json:"algorithm,omitempty"`
 	// Digits is the number of digits to be generated for the TOTP
 	Digits int `json:"digits,omitempty"`
 	// Period is the number of seconds TOTP is valid for
@@ -39,42 +72,181 @@ type TOTP struct {
 	DriftChecks int `json:"driftChecks,omitempty"`
 }
 
-// checkDrift will back track the time counter twice, by 30 seconds.
-// The OTP will be verified for each backtracking. This is done taking into account the possible
-// clock drift between server and client.
-func (t *TOTP) checkDrift() {
-
-}
-
 // Secret generates a new secret for TOTP for the given identifiers
 func Secret(parts ...string) string {
 	return strings.Join(parts, "")
 }
 
+// NewSecret generates a new random secret, base32 encoded (without padding) as expected by
+// authenticator apps
+func NewSecret() (string, error) {
+	b := make([]byte, secretLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+// Validate checks the settings, the zero values are replaced by the defaults
+func (t *TOTP) Validate() error {
+	if t.Digits != 0 && (t.Digits < MinDigits || t.Digits > MaxDigits) {
+		return ErrInvalidDigits
+	}
+
+	switch t.Algorithm {
+	case "", AlgoSHA1, AlgoSHA256, AlgoSHA512:
+		{
+			return nil
+		}
+	}
+	return ErrInvalidAlgorithm
+}
+
 func (t *TOTP) label(userID string) string {
+	if t.Issuer == "" {
+		return userID
+	}
 	return fmt.Sprintf("%s:%s", url.QueryEscape(t.Issuer), userID)
 }
 
-// check checks if the given otp is valid
-func (t *TOTP) check(otp string) bool {
-	return false
+func (t *TOTP) algorithm() Algo {
+	if t.Algorithm == "" {
+		return AlgoSHA1
+	}
+	return t.Algorithm
+}
+
+func (t *TOTP) period() int64 {
+	if t.Period < 1 {
+		return defaultPeriod
+	}
+	return int64(t.Period)
+}
+
+func (t *TOTP) digits() int {
+	if t.Digits < 1 {
+		return defaultDigits
+	}
+	return t.Digits
+}
+
+func (t *TOTP) hasher() func() hash.Hash {
+	switch t.Algorithm {
+	case AlgoSHA256:
+		{
+			return sha256.New
+		}
+	case AlgoSHA512:
+		{
+			return sha512.New
+		}
+	}
+	return sha1.New
+}
+
+// Counter returns the time counter (the number of periods since Unix epoch) for the given time
+func (t *TOTP) Counter(at time.Time) int64 {
+	return at.Unix() / t.period()
+}
+
+// hotp generates the HOTP for the given counter, as per RFC 4226
+func (t *TOTP) hotp(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(t.hasher(), key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := uint64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+
+	digits := t.digits()
+	mod := uint64(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(strings.TrimSpace(secret), " ", "", -1))
+	key, err := b32.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+// Code generates the OTP for the secret, at the given time
+func (t *TOTP) Code(secret string, at time.Time) (string, error) {
+	err := t.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return t.hotp(key, t.Counter(at)), nil
+}
+
+// Verify checks if the otp is valid at the given time, and returns the counter it matched. To
+// allow for clock drift between server & client, the counters up to 'DriftChecks' periods
+// before & after the current one are also checked
+func (t *TOTP) Verify(secret, otp string, at time.Time) (int64, bool, error) {
+	err := t.Validate()
+	if err != nil {
+		return 0, false, err
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+
+	otp = strings.TrimSpace(otp)
+	if len(otp) != t.digits() {
+		return 0, false, nil
+	}
+
+	drift := int64(t.DriftChecks)
+	if drift < 1 {
+		drift = defaultDriftChecks
+	}
+
+	current := t.Counter(at)
+	for i := -drift; i <= drift; i++ {
+		counter := current + i
+		if subtle.ConstantTimeCompare([]byte(t.hotp(key, counter)), []byte(otp)) == 1 {
+			return counter, true, nil
+		}
+	}
+
+	return 0, false, nil
 }
 
 // Check checks if the given otp is valid or not. It will also perform a drift check.
 // The drift would only be allowed 'DriftChecks' times, post which the client has fix its clock
 func (t *TOTP) Check(secret, otp string) bool {
-	return false
+	_, ok, _ := t.Verify(secret, otp, time.Now())
+	return ok
 }
 
 // URI generates the URI representing all the required details to be consumed by authenticator
-// apps, while scanning the QR code
-func (t *TOTP) URI(userID string) string {
+// apps, while scanning the QR code. The zero values are replaced by the defaults, as done while
+// generating the codes
+func (t *TOTP) URI(userID, secret string) string {
 	params := url.Values{}
-	params.Add("issuer", t.Issuer)
-	params.Add("secret", "none")
-	params.Add("algorithm", string(t.Algorithm))
-	params.Add("digits", fmt.Sprintf("%d", t.Digits))
-	params.Add("period", fmt.Sprintf("%d", t.Period))
+	if t.Issuer != "" {
+		params.Add("issuer", t.Issuer)
+	}
+	params.Add("secret", secret)
+	params.Add("algorithm", string(t.algorithm()))
+	params.Add("digits", fmt.Sprintf("%d", t.digits()))
+	params.Add("period", fmt.Sprintf("%d", t.period()))
 	return fmt.Sprintf("otpauth://totp/%s?%s", t.label(userID), params.Encode())
 }
 
@@ -83,7 +255,7 @@ func (t *TOTP) QR() {
 
 }
 
-func New(issuer string, digits, period int, alg algo) *TOTP {
+func New(issuer string, digits, period int, alg Algo) *TOTP {
 
 	t := &TOTP{
 		Issuer:    issuer,
@@ -0,0 +1,204 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+func secretOf(key string) string {
+	return b32.EncodeToString([]byte(key))
+}
+
+// TestHOTP uses the test values of RFC 4226, appendix D
+func TestHOTP(t *testing.T) {
+	key := []byte("12345678901234567890")
+	expected := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	tt := New("padlock", 6, 30, AlgoSHA1)
+	for counter, code := range expected {
+		got := tt.hotp(key, int64(counter))
+		if got != code {
+			t.Errorf("counter %d: expected %s, got %s", counter, code, got)
+		}
+	}
+}
+
+// TestCode uses the test values of RFC 6238, appendix B
+func TestCode(t *testing.T) {
+	secrets := map[Algo]string{
+		AlgoSHA1:   secretOf("12345678901234567890"),
+		AlgoSHA256: secretOf("12345678901234567890123456789012"),
+		AlgoSHA512: secretOf("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+
+	tests := []struct {
+		at    int64
+		codes map[Algo]string
+	}{
+		{59, map[Algo]string{AlgoSHA1: "94287082", AlgoSHA256: "46119246", AlgoSHA512: "90693936"}},
+		{1111111109, map[Algo]string{AlgoSHA1: "07081804", AlgoSHA256: "68084774", AlgoSHA512: "25091201"}},
+		{1111111111, map[Algo]string{AlgoSHA1: "14050471", AlgoSHA256: "67062674", AlgoSHA512: "99943326"}},
+		{1234567890, map[Algo]string{AlgoSHA1: "89005924", AlgoSHA256: "91819424", AlgoSHA512: "93441116"}},
+		{2000000000, map[Algo]string{AlgoSHA1: "69279037", AlgoSHA256: "90698825", AlgoSHA512: "38618901"}},
+		{20000000000, map[Algo]string{AlgoSHA1: "65353130", AlgoSHA256: "77737706", AlgoSHA512: "47863826"}},
+	}
+
+	for _, tc := range tests {
+		for alg, code := range tc.codes {
+			tt := New("padlock", 8, 30, alg)
+			at := time.Unix(tc.at, 0)
+
+			got, err := tt.Code(secrets[alg], at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != code {
+				t.Errorf("%s at %d: expected %s, got %s", alg, tc.at, code, got)
+			}
+
+			counter, ok, err := tt.Verify(secrets[alg], code, at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok || counter != tt.Counter(at) {
+				t.Errorf("%s at %d: expected %s to be verified at counter %d, got %d (%v)", alg, tc.at, code, tt.Counter(at), counter, ok)
+			}
+		}
+	}
+}
+
+func TestVerifyDrift(t *testing.T) {
+	secret := secretOf("12345678901234567890")
+	tt := New("padlock", 6, 30, AlgoSHA1)
+	at := time.Unix(1234567890, 0)
+
+	tests := []struct {
+		name  string
+		shift time.Duration
+		ok    bool
+	}{
+		{"current", 0, true},
+		{"previous", -time.Second * 30, true},
+		{"next", time.Second * 30, true},
+		{"too old", -time.Minute, false},
+		{"too new", time.Minute, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			code, err := tt.Code(secret, at.Add(tc.shift))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			counter, ok, err := tt.Verify(secret, code, at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tc.ok {
+				t.Fatalf("expected %v, got %v", tc.ok, ok)
+			}
+			if ok && counter != tt.Counter(at.Add(tc.shift)) {
+				t.Fatalf("expected counter %d, got %d", tt.Counter(at.Add(tc.shift)), counter)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		digits int
+		alg    Algo
+		err    error
+	}{
+		{0, "", nil},
+		{6, AlgoSHA1, nil},
+		{7, AlgoSHA256, nil},
+		{8, AlgoSHA512, nil},
+		{5, AlgoSHA1, ErrInvalidDigits},
+		{9, AlgoSHA1, ErrInvalidDigits},
+		{10, AlgoSHA1, ErrInvalidDigits},
+		{-1, AlgoSHA1, ErrInvalidDigits},
+		{6, Algo("MD5"), ErrInvalidAlgorithm},
+	}
+
+	secret := secretOf("12345678901234567890")
+	for _, tc := range tests {
+		tt := New("padlock", tc.digits, 30, tc.alg)
+		err := tt.Validate()
+		if err != tc.err {
+			t.Errorf("%d digits & %q: expected %v, got %v", tc.digits, tc.alg, tc.err, err)
+		}
+
+		_, err = tt.Code(secret, time.Now())
+		if err != tc.err {
+			t.Errorf("%d digits & %q: expected %v while generating, got %v", tc.digits, tc.alg, tc.err, err)
+		}
+
+		_, _, err = tt.Verify(secret, "123456", time.Now())
+		if err != tc.err {
+			t.Errorf("%d digits & %q: expected %v while verifying, got %v", tc.digits, tc.alg, tc.err, err)
+		}
+	}
+}
+
+func TestDecodeSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != secretLength {
+		t.Fatalf("expected %d bytes, got %d", secretLength, len(key))
+	}
+
+	// authenticator apps show the secret in lower case, grouped & padded
+	padded := base32.StdEncoding.EncodeToString(key)
+	grouped := strings.ToLower(padded[:4] + " " + padded[4:])
+	_, err = decodeSecret(grouped)
+	if err != nil {
+		t.Fatalf("expected %q to be decoded, got %v", grouped, err)
+	}
+
+	for _, invalid := range []string{"", "1!", "===="} {
+		_, err = decodeSecret(invalid)
+		if err != ErrInvalidSecret {
+			t.Errorf("%q: expected ErrInvalidSecret, got %v", invalid, err)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	tests := []struct {
+		name     string
+		totp     *TOTP
+		expected string
+	}{
+		{
+			"defaults",
+			&TOTP{},
+			"otpauth://totp/jane?algorithm=SHA1&digits=6&period=30&secret=ABC",
+		},
+		{
+			"configured",
+			New("Acme Inc", 8, 60, AlgoSHA256),
+			"otpauth://totp/Acme+Inc:jane?algorithm=SHA256&digits=8&issuer=Acme+Inc&period=60&secret=ABC",
+		},
+	}
+
+	for _, tc := range tests {
+		got := tc.totp.URI("jane", "ABC")
+		if got != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, got)
+		}
+	}
+}
@@ -2,13 +2,14 @@ package users
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"math/rand"
 	"strings"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 
+	"github.com/bnkamalesh/padlock/pkg/apperr"
 	"github.com/bnkamalesh/padlock/pkg/platform/cache"
 )
 
@@ -21,15 +22,17 @@ const (
 	letterIdxMask   = 1<<letterIdxBits - 1 // All 1-bits, as many as letterIdxBits
 	letterIdxMax    = 63 / letterIdxBits   // # of letter indices fitting in 63 bits
 
-	app        = "padlock.dev"
-	ctxUserKey = ctxKey("user")
+	app             = "padlock.dev"
+	ctxUserKey      = ctxKey("user")
+	ctxSessionKey   = ctxKey("session")
+	sessionValidity = time.Hour * 12
 )
 
 var (
 	src                 = rand.NewSource(time.Now().UnixNano())
 	signKey             = []byte(rdmStr(64))
-	ErrSessionID        = errors.New("Invalid session ID received")
-	ErrSessionIDExpired = errors.New("Session ID expired")
+	ErrSessionID        = apperr.New(apperr.CodeUnauthenticated, "Sorry, invalid session, please sign in again")
+	ErrSessionIDExpired = apperr.New(apperr.CodeUnauthenticated, "Sorry, the session has expired, please sign in again")
 )
 
 func tokenKeyFunc(token *jwt.Token) (interface{}, error) {
@@ -65,7 +68,7 @@ type TokenClaims struct {
 func sessionID(source string, u *User) (string, *TokenClaims) {
 	id := rdmStr(48)
 	now := time.Now()
-	expire := now.Add(time.Hour * 12)
+	expire := now.Add(sessionValidity)
 	tc := TokenClaims{
 		source,
 		jwt.StandardClaims{
@@ -89,13 +92,10 @@ func sessionDetails(tokenStr string) (*TokenClaims, error) {
 	c := &TokenClaims{}
 	token, err := jwt.ParseWithClaims(tokenStr, c, tokenKeyFunc)
 	if err != nil {
-		switch err.Error() {
-		case jwt.ErrInvalidKey.Error(), jwt.ErrSignatureInvalid.Error():
-			{
-				return nil, ErrSessionID
-			}
+		if vErr, ok := err.(*jwt.ValidationError); ok && vErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, apperr.Wrap(ErrSessionIDExpired, err)
 		}
-		return nil, err
+		return nil, apperr.Wrap(ErrSessionID, err)
 	}
 	if !token.Valid {
 		return nil, ErrSessionID
@@ -103,7 +103,7 @@ func sessionDetails(tokenStr string) (*TokenClaims, error) {
 
 	err = token.Claims.Valid()
 	if err != nil {
-		return nil, err
+		return nil, apperr.Wrap(ErrSessionIDExpired, err)
 	}
 	return c, nil
 }
@@ -117,10 +117,13 @@ func (us *Users) Login(ctx context.Context, source, email, password string) (*Us
 
 	u, err := us.store.ReadByEmail(ctx, email)
 	if err != nil {
+		if apperr.Is(err, ErrNotFound) {
+			return nil, "", ErrInvalidLogin
+		}
 		if us.appCtx.Logging {
 			us.appCtx.Logger.Error(err)
 		}
-		return nil, "", ErrUnexpected
+		return nil, "", apperr.Wrap(ErrUnexpected, err)
 	}
 
 	pwd := hash(u.Salt, password)
@@ -128,17 +131,97 @@ func (us *Users) Login(ctx context.Context, source, email, password string) (*Us
 		return nil, "", ErrInvalidLogin
 	}
 
+	token, err := us.startSession(source, u)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return u, token, nil
+}
+
+// startSession creates a new session for the user, for the given source and returns the session token
+func (us *Users) startSession(source string, u *User) (string, error) {
 	token, claims := sessionID(source, u)
 	expiry := time.Until(time.Unix(claims.ExpiresAt, 0))
-	err = us.cache.Set(claims.Id, u, expiry)
+	err := us.cache.Set(claims.Id, u, expiry)
 	if err != nil {
 		if us.appCtx.Logging {
 			us.appCtx.Logger.Error(err)
 		}
-		return nil, "", ErrUnexpected
+		return "", apperr.Wrap(ErrUnexpected, err)
 	}
 
-	return u, token, nil
+	err = us.trackSession(u.ID, claims.Id)
+	if err != nil {
+		if us.appCtx.Logging {
+			us.appCtx.Logger.Error(err)
+		}
+		return "", apperr.Wrap(ErrUnexpected, err)
+	}
+
+	return token, nil
+}
+
+func sessionsKey(userID int64) string {
+	return fmt.Sprintf("sessions:%d", userID)
+}
+
+// trackSession maintains the set of all session IDs of a user, so that they can be revoked. The
+// ID is added atomically, so that concurrent logins do not lose each other's sessions
+func (us *Users) trackSession(userID int64, id string) error {
+	return us.cache.SAdd(sessionsKey(userID), sessionValidity, id)
+}
+
+// revokeSessions revokes all the sessions of the user, except the one with session ID 'except'
+func (us *Users) revokeSessions(userID int64, except string) error {
+	ids, err := us.cache.SMembers(sessionsKey(userID))
+	if err != nil {
+		return err
+	}
+
+	revoke := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != except {
+			revoke = append(revoke, id)
+		}
+	}
+	if len(revoke) == 0 {
+		return nil
+	}
+
+	err = us.cache.Delete(revoke...)
+	if err != nil {
+		return err
+	}
+
+	// only the revoked IDs are removed, so that the sessions started meanwhile are still tracked
+	return us.cache.SRem(sessionsKey(userID), revoke...)
+}
+
+// RevokeSessions revokes all the sessions of the user, signing them out everywhere
+func (us *Users) RevokeSessions(ctx context.Context, userID int64) error {
+	if userID < 1 {
+		return ErrInvalidID
+	}
+
+	err := us.revokeSessions(userID, "")
+	if err != nil {
+		if us.appCtx.Logging {
+			us.appCtx.Logger.Error(err)
+		}
+		return apperr.Wrap(ErrUnexpected, err)
+	}
+	return nil
+}
+
+// refreshSession updates the user details cached against the session, without changing its expiry
+func (us *Users) refreshSession(token string, u *User) error {
+	claims, err := sessionDetails(token)
+	if err != nil {
+		return err
+	}
+
+	return us.cache.Set(claims.Id, u, time.Until(time.Unix(claims.ExpiresAt, 0)))
 }
 
 func (us *Users) AuthUser(ctx context.Context, source, token string) (*User, error) {
@@ -160,7 +243,7 @@ func (us *Users) AuthUser(ctx context.Context, source, token string) (*User, err
 		if us.appCtx.Logging {
 			us.appCtx.Logger.Error(err)
 		}
-		return nil, err
+		return nil, apperr.Wrap(ErrUnexpected, err)
 	}
 
 	return u, nil
@@ -188,3 +271,20 @@ func FromContext(ctx context.Context) *User {
 	}
 	return u
 }
+
+// SetSessionContext sets the session token of the authenticated user in the context
+func SetSessionContext(ctx context.Context, token string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, ctxSessionKey, token)
+}
+
+// SessionFromContext returns the session token set in the context, if any
+func SessionFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	token, _ := ctx.Value(ctxSessionKey).(string)
+	return token
+}
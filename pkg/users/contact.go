@@ -0,0 +1,410 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/notifier"
+)
+
+type contactField string
+
+const (
+	// ContactEmail is the email address of the user
+	ContactEmail = contactField("email")
+	// ContactPhone is the phone number of the user
+	ContactPhone = contactField("phone")
+
+	contactChangeValidity    = time.Minute * 15
+	contactRevertValidity    = time.Hour * 24 * 7
+	contactChangeMaxAttempts = 5
+	verificationCodeLength   = 6
+
+	// ContactRevertPath is the path prefix of the revert link sent to the old email/phone
+	ContactRevertPath = "/users/contact/revert/"
+)
+
+var (
+	ErrInvalidPhone     = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no phone number provided, it should include the country code (e.g. +15555550100)")
+	ErrInvalidContact   = apperr.New(apperr.CodeInvalidInput, "Sorry, only email or phone can be changed")
+	ErrContactUnchanged = apperr.New(apperr.CodeInvalidInput, "Sorry, the new contact is the same as the existing one")
+	ErrEmailExists      = apperr.New(apperr.CodeConflict, "Sorry, the email is already registered")
+	ErrChangeNotFound   = apperr.New(apperr.CodeNotFound, "Sorry, the change request does not exist or has expired")
+	ErrInvalidCode      = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid verification code")
+	ErrTooManyAttempts  = apperr.New(apperr.CodeRateLimited, "Sorry, too many invalid attempts")
+	// ErrRevertConflict is returned when a change cannot be reverted, because the old email has
+	// since been registered by another account
+	ErrRevertConflict = apperr.New(apperr.CodeConflict, "Sorry, the old email is now registered to another account, please contact support to recover it")
+	// ErrContactNotEditable is returned when the email/phone is updated directly, instead of
+	// verifying it using RequestContactChange
+	ErrContactNotEditable = apperr.New(apperr.CodeInvalidInput, "Sorry, the email/phone can only be changed by verifying it")
+)
+
+// ContactChange is a pending change of the email or phone of a user. The change is applied only
+// after it's confirmed using the code sent to the new email/phone
+type ContactChange struct {
+	ID     string       `json:"id,omitempty"`
+	UserID int64        `json:"userId,omitempty"`
+	Field  contactField `json:"field,omitempty"`
+	// Old is the email/phone at the time of requesting the change
+	Old string `json:"-"`
+	// New is the email/phone which would replace Old
+	New string `json:"new,omitempty"`
+	// Code is the hash of the verification code sent to New
+	Code        string    `json:"-"`
+	RevertToken string    `json:"-"`
+	ExpiresAt   time.Time `json:"expiresAt,omitempty"`
+}
+
+// contactRevert has the details required to revert/cancel a contact change, using the link sent
+// to the old email/phone
+type contactRevert struct {
+	ChangeID string
+	UserID   int64
+	Field    contactField
+	Old      string
+	New      string
+	Applied  bool
+}
+
+func contactChangeKey(id string) string {
+	return "contactchange:" + id
+}
+
+// contactAttemptsKey is the key of the number of attempts made to confirm the change
+func contactAttemptsKey(id string) string {
+	return "contactchange:attempts:" + id
+}
+
+func contactRevertKey(token string) string {
+	return "contactrevert:" + token
+}
+
+// secureToken returns a URL safe random string generated from n random bytes
+func secureToken(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// secureCode returns a random numeric code of the given length
+func secureCode(length int) (string, error) {
+	max := big.NewInt(10)
+	sb := strings.Builder{}
+	sb.Grow(length)
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(byte('0' + n.Int64()))
+	}
+	return sb.String(), nil
+}
+
+// codeHash returns the hash of the code, salted with the given ID
+func codeHash(id, code string) string {
+	sum := sha256.Sum256([]byte(id + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+func codeMatches(hashed, id, code string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashed), []byte(codeHash(id, code))) == 1
+}
+
+// normalizeContact validates & normalizes the given email/phone
+func (us *Users) normalizeContact(field contactField, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch field {
+	case ContactEmail:
+		{
+			if !emailRegex.MatchString(value) {
+				return "", ErrInvalidEmail
+			}
+			return strings.ToLower(value), nil
+		}
+	case ContactPhone:
+		{
+			return us.normalizePhone(value)
+		}
+	}
+	return "", ErrInvalidContact
+}
+
+func (cc *ContactChange) channel() notifier.Message {
+	if cc.Field == ContactPhone {
+		return notifier.Message{Channel: notifier.ChannelSMS}
+	}
+	return notifier.Message{Channel: notifier.ChannelEmail}
+}
+
+// RequestContactChange initiates the change of email or phone of the user. A verification code
+// is sent to the new email/phone, and a notification with a link to revert the change is sent
+// to the existing one
+func (us *Users) RequestContactChange(ctx context.Context, u *User, field contactField, value string) (*ContactChange, error) {
+	value, err := us.normalizeContact(field, value)
+	if err != nil {
+		return nil, err
+	}
+
+	usr, err := us.Read(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	old := usr.Email
+	if field == ContactPhone {
+		old = usr.Phone
+	}
+	if strings.EqualFold(old, value) {
+		return nil, ErrContactUnchanged
+	}
+
+	if field == ContactEmail {
+		_, err = us.store.ReadByEmail(ctx, value)
+		if err == nil {
+			return nil, ErrEmailExists
+		}
+		if !apperr.Is(err, ErrNotFound) {
+			return nil, us.storeErr(err)
+		}
+	}
+
+	id, err := secureToken(18)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+	code, err := secureCode(verificationCodeLength)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+	revertToken, err := secureToken(32)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	cc := &ContactChange{
+		ID:          id,
+		UserID:      usr.ID,
+		Field:       field,
+		Old:         old,
+		New:         value,
+		Code:        codeHash(id, code),
+		RevertToken: revertToken,
+		ExpiresAt:   time.Now().UTC().Add(contactChangeValidity),
+	}
+
+	err = us.cache.Set(contactChangeKey(id), cc, contactChangeValidity)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	err = us.cache.Set(
+		contactRevertKey(revertToken),
+		contactRevert{
+			ChangeID: id,
+			UserID:   usr.ID,
+			Field:    field,
+			Old:      old,
+			New:      value,
+		},
+		contactRevertValidity,
+	)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	msg := cc.channel()
+	msg.To = value
+	msg.Subject = "Verify your new " + string(field)
+	msg.Body = fmt.Sprintf(
+		"Your verification code is %s. It is valid for %d minutes.",
+		code,
+		int(contactChangeValidity.Minutes()),
+	)
+	err = us.notifier.Notify(ctx, msg)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	// the old email/phone could be empty, e.g. if phone was never set
+	if old != "" {
+		msg = cc.channel()
+		msg.To = old
+		msg.Subject = fmt.Sprintf("Your %s is being changed", field)
+		msg.Body = fmt.Sprintf(
+			"A request was made to change your %s. If this was not you, revert it using %s%s%s",
+			field,
+			us.appCtx.BaseURL,
+			ContactRevertPath,
+			revertToken,
+		)
+		err = us.notifier.Notify(ctx, msg)
+		if err != nil {
+			return nil, us.storeErr(err)
+		}
+	}
+
+	return cc, nil
+}
+
+// ConfirmContactChange applies the change, if the code matches the one sent to the new
+// email/phone. Once applied, all the sessions of the user except the one identified by
+// sessionToken are revoked
+func (us *Users) ConfirmContactChange(ctx context.Context, sessionToken string, u *User, changeID, code string) (*User, error) {
+	cc := &ContactChange{}
+	err := us.cache.Get(contactChangeKey(changeID), cc)
+	if err != nil {
+		if err == cache.ErrNotFound {
+			return nil, ErrChangeNotFound
+		}
+		return nil, us.storeErr(err)
+	}
+
+	if cc.UserID != u.ID {
+		return nil, ErrChangeNotFound
+	}
+
+	// the attempts are counted atomically, so concurrent guesses cannot exceed the limit
+	attempts, err := us.cache.Incr(contactAttemptsKey(cc.ID), time.Until(cc.ExpiresAt))
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	if attempts > contactChangeMaxAttempts {
+		return nil, ErrTooManyAttempts
+	}
+
+	if !codeMatches(cc.Code, cc.ID, strings.TrimSpace(code)) {
+		return nil, ErrInvalidCode
+	}
+
+	err = us.cache.Delete(contactChangeKey(cc.ID), contactAttemptsKey(cc.ID))
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	usr, err := us.setContact(ctx, cc.UserID, cc.Field, cc.New)
+	if err != nil {
+		return nil, err
+	}
+
+	err = us.markApplied(cc.RevertToken)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	claims, err := sessionDetails(sessionToken)
+	if err != nil {
+		return nil, err
+	}
+
+	err = us.revokeSessions(usr.ID, claims.Id)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	err = us.refreshSession(sessionToken, usr)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	return usr, nil
+}
+
+// ReadContactRevert returns the field of the change which would be reverted using the token,
+// without reverting it
+func (us *Users) ReadContactRevert(ctx context.Context, token string) (contactField, error) {
+	cr := &contactRevert{}
+	err := us.cache.Get(contactRevertKey(token), cr)
+	if err != nil {
+		if err == cache.ErrNotFound {
+			return "", ErrChangeNotFound
+		}
+		return "", us.storeErr(err)
+	}
+
+	return cr.Field, nil
+}
+
+// RevertContactChange cancels a pending contact change, or reverts it to the old email/phone if
+// it was already applied. Since a reverted change implies the account might be compromised, all
+// the sessions of the user are revoked, even if the old email cannot be restored
+func (us *Users) RevertContactChange(ctx context.Context, token string) error {
+	cr := &contactRevert{}
+	err := us.cache.Get(contactRevertKey(token), cr)
+	if err != nil {
+		if err == cache.ErrNotFound {
+			return ErrChangeNotFound
+		}
+		return us.storeErr(err)
+	}
+
+	err = us.cache.Delete(contactChangeKey(cr.ChangeID))
+	if err != nil {
+		return us.storeErr(err)
+	}
+
+	if cr.Applied {
+		_, err = us.setContact(ctx, cr.UserID, cr.Field, cr.Old)
+		if apperr.Is(err, ErrEmailExists) {
+			// the token is kept, so that the change can be reverted once the conflict is resolved
+			rerr := us.revokeSessions(cr.UserID, "")
+			if rerr != nil {
+				return us.storeErr(rerr)
+			}
+			return apperr.Wrap(ErrRevertConflict, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err = us.cache.Delete(contactRevertKey(token))
+	if err != nil {
+		return us.storeErr(err)
+	}
+
+	err = us.revokeSessions(cr.UserID, "")
+	if err != nil {
+		return us.storeErr(err)
+	}
+
+	return nil
+}
+
+// markApplied records that the change of the revert record was applied, so that reverting it
+// restores the old email/phone
+func (us *Users) markApplied(token string) error {
+	cr := &contactRevert{}
+	err := us.cache.Get(contactRevertKey(token), cr)
+	if err != nil {
+		return err
+	}
+
+	cr.Applied = true
+	return us.cache.Set(contactRevertKey(token), cr, contactRevertValidity)
+}
+
+// setContact updates the email/phone of the user with the given ID
+func (us *Users) setContact(ctx context.Context, userID int64, field contactField, value string) (*User, error) {
+	usr, err := us.store.UpdateContact(ctx, userID, field, value, time.Now().UTC())
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	return usr, nil
+}
@@ -0,0 +1,246 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/platform/notifier"
+	"github.com/bnkamalesh/padlock/pkg/platform/sms"
+)
+
+var (
+	codeRegex   = regexp.MustCompile(`code is (\d+)`)
+	revertRegex = regexp.MustCompile(regexp.QuoteMeta(ContactRevertPath) + `(\S+)`)
+)
+
+type usersFixture struct {
+	us       *Users
+	notifier *notifier.Recorder
+	sms      *sms.Fake
+}
+
+func testUsers(t *testing.T, fn func(t *testing.T, f usersFixture)) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		f := usersFixture{
+			notifier: notifier.NewRecorder(),
+			sms:      sms.NewFake(nil),
+		}
+		f.us = New(appcontext.New(logger.New()), driver, db, cache.NewMemory(), f.notifier, f.sms)
+		fn(t, f)
+	})
+}
+
+// lastMatch returns the first submatch of the last message sent to the recipient
+func (f usersFixture) lastMatch(t *testing.T, to string, re *regexp.Regexp) string {
+	t.Helper()
+
+	msgs := f.notifier.Messages()
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].To != to {
+			continue
+		}
+		m := re.FindStringSubmatch(msgs[i].Body)
+		if m == nil {
+			t.Fatalf("no match in %q", msgs[i].Body)
+		}
+		return m[1]
+	}
+	t.Fatalf("no message sent to %s", to)
+	return ""
+}
+
+// login creates a user & signs in, returning the user & session token
+func (f usersFixture) login(t *testing.T, u User) (*User, string) {
+	t.Helper()
+
+	ctx := context.Background()
+	_, err := f.us.Create(ctx, u, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usr, token, err := f.us.Login(ctx, "test", u.Email, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return usr, token
+}
+
+func TestUpdateRejectsContact(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		usr, _ := f.login(t, User{Email: "jane@example.com"})
+
+		for _, upd := range []User{
+			{ID: usr.ID, Version: usr.Version, Email: "john@example.com"},
+			{ID: usr.ID, Version: usr.Version, Phone: "+15555550100"},
+		} {
+			_, err := f.us.Update(context.Background(), upd)
+			if !errors.Is(err, ErrContactNotEditable) {
+				t.Fatalf("expected ErrContactNotEditable, got %v", err)
+			}
+		}
+	})
+}
+
+func TestConfirmContactChange(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		usr, token := f.login(t, User{Email: "jane@example.com"})
+
+		cc, err := f.us.RequestContactChange(ctx, usr, ContactEmail, "jane@example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := f.lastMatch(t, "jane@example.org", codeRegex)
+
+		for i := 0; i < contactChangeMaxAttempts; i++ {
+			_, err = f.us.ConfirmContactChange(ctx, token, usr, cc.ID, "invalid")
+			if !errors.Is(err, ErrInvalidCode) {
+				t.Fatalf("expected ErrInvalidCode, got %v", err)
+			}
+		}
+
+		// the valid code is rejected as well, once the attempts are exhausted
+		_, err = f.us.ConfirmContactChange(ctx, token, usr, cc.ID, code)
+		if !errors.Is(err, ErrTooManyAttempts) {
+			t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+		}
+
+		cc, err = f.us.RequestContactChange(ctx, usr, ContactEmail, "jane@example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		code = f.lastMatch(t, "jane@example.org", codeRegex)
+
+		updated, err := f.us.ConfirmContactChange(ctx, token, usr, cc.ID, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Email != "jane@example.org" || updated.Version != usr.Version+1 {
+			t.Fatalf("expected the new email & version %d, got %+v", usr.Version+1, updated)
+		}
+
+		_, err = f.us.ConfirmContactChange(ctx, token, usr, cc.ID, code)
+		if !errors.Is(err, ErrChangeNotFound) {
+			t.Fatalf("expected ErrChangeNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRevertContactChange(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		usr, token := f.login(t, User{Email: "jane@example.com", Phone: "+15555550100"})
+
+		cc, err := f.us.RequestContactChange(ctx, usr, ContactPhone, "+15555550101")
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := f.lastMatch(t, "+15555550101", codeRegex)
+		revertToken := f.lastMatch(t, "+15555550100", revertRegex)
+
+		_, err = f.us.ConfirmContactChange(ctx, token, usr, cc.ID, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		field, err := f.us.ReadContactRevert(ctx, revertToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if field != ContactPhone {
+			t.Fatalf("expected %s, got %s", ContactPhone, field)
+		}
+
+		err = f.us.RevertContactChange(ctx, revertToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := f.us.Read(ctx, usr.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Phone != "+15555550100" {
+			t.Fatalf("expected the old phone, got %q", got.Phone)
+		}
+
+		_, err = f.us.AuthUser(ctx, "test", token)
+		if !errors.Is(err, ErrSessionIDExpired) {
+			t.Fatalf("expected the session to be revoked, got %v", err)
+		}
+
+		_, err = f.us.ReadContactRevert(ctx, revertToken)
+		if !errors.Is(err, ErrChangeNotFound) {
+			t.Fatalf("expected ErrChangeNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRevertContactChangeConflict(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		usr, token := f.login(t, User{Email: "jane@example.com"})
+
+		cc, err := f.us.RequestContactChange(ctx, usr, ContactEmail, "jane@example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := f.lastMatch(t, "jane@example.org", codeRegex)
+		revertToken := f.lastMatch(t, "jane@example.com", revertRegex)
+
+		_, err = f.us.ConfirmContactChange(ctx, token, usr, cc.ID, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the old email is claimed by another account before the change is reverted
+		other, err := f.us.Create(ctx, User{Email: "jane@example.com"}, "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.us.RevertContactChange(ctx, revertToken)
+		if !errors.Is(err, ErrRevertConflict) {
+			t.Fatalf("expected ErrRevertConflict, got %v", err)
+		}
+
+		got, err := f.us.Read(ctx, usr.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Email != "jane@example.org" {
+			t.Fatalf("expected the new email to be kept, got %q", got.Email)
+		}
+
+		_, err = f.us.AuthUser(ctx, "test", token)
+		if !errors.Is(err, ErrSessionIDExpired) {
+			t.Fatalf("expected the session to be revoked, got %v", err)
+		}
+
+		// once the old email is released, the change can be reverted using the same token
+		_, err = f.us.setContact(ctx, other.ID, ContactEmail, "john@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = f.us.RevertContactChange(ctx, revertToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = f.us.Read(ctx, usr.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Email != "jane@example.com" {
+			t.Fatalf("expected the old email, got %q", got.Email)
+		}
+	})
+}
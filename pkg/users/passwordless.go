@@ -0,0 +1,329 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/notifier"
+)
+
+type passwordlessKind string
+
+const (
+	// PasswordlessCode sends a one time code by email, to be entered on the sign in page
+	PasswordlessCode = passwordlessKind("code")
+	// PasswordlessLink sends a single use sign in link by email
+	PasswordlessLink = passwordlessKind("link")
+
+	// MagicLinkPath is the path prefix of the sign in link sent by email
+	MagicLinkPath = "/login/link/"
+)
+
+// PasswordlessKind converts the string to a passwordless sign in type
+func PasswordlessKind(kind string) passwordlessKind {
+	return passwordlessKind(kind)
+}
+
+var (
+	ErrInvalidPasswordless = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid passwordless sign in type, should be code or link")
+	ErrInvalidMagicLink    = apperr.New(apperr.CodeUnauthenticated, "Sorry, the sign in link is invalid or has expired")
+	ErrPasswordlessLimited = apperr.New(apperr.CodeRateLimited, "Sorry, too many sign in emails requested, please try again later")
+)
+
+// PasswordlessConfig has all the configurations for passwordless sign in
+type PasswordlessConfig struct {
+	// CodeValidity is the duration for which an email code can be used
+	CodeValidity time.Duration
+	// LinkValidity is the duration for which a magic link can be used
+	LinkValidity time.Duration
+	// MaxAttempts is the number of invalid codes allowed per email within the SendWindow, post
+	// which the code is invalidated. The attempts are not reset by requesting another code
+	MaxAttempts int
+	// SendLimit is the number of codes/links which can be sent to an email within the SendWindow
+	SendLimit int
+	// SendWindow is the window of the SendLimit & the MaxAttempts
+	SendWindow time.Duration
+}
+
+// DefaultPasswordlessConfig is the passwordless configuration used unless overridden
+var DefaultPasswordlessConfig = PasswordlessConfig{
+	CodeValidity: time.Minute * 10,
+	LinkValidity: time.Minute * 15,
+	MaxAttempts:  5,
+	SendLimit:    5,
+	SendWindow:   time.Hour,
+}
+
+// passwordlessLogin is a pending passwordless sign in
+type passwordlessLogin struct {
+	// ID identifies the code/link, to claim it when used
+	ID     string
+	UserID int64
+	// Source is the source ID of the client which requested the sign in. The sign in can only
+	// be completed from the same source
+	Source    string
+	Code      string
+	ExpiresAt time.Time
+}
+
+func emailCodeKey(email string) string {
+	return "emaillogin:" + strings.ToLower(email)
+}
+
+// emailCodeAttemptsKey is the key of the number of attempts made to sign in using a code sent
+// to the email, in the current window
+func emailCodeAttemptsKey(email string) string {
+	return "emaillogin:attempts:" + strings.ToLower(email)
+}
+
+// passwordlessSendsKey is the key of the number of codes/links sent to the email, in the current
+// window
+func passwordlessSendsKey(email string) string {
+	return "emaillogin:sends:" + strings.ToLower(email)
+}
+
+func magicLinkKey(token string) string {
+	return "magiclink:" + token
+}
+
+// claimPasswordless marks the code/link identified by id as used, and returns true only for the
+// first caller. The claim outlives the code/link, so that it cannot be used again
+func (us *Users) claimPasswordless(id string, expiresAt time.Time) (bool, error) {
+	return us.cache.SetNX("passwordless:used:"+id, true, time.Until(expiresAt)+time.Minute)
+}
+
+// ConfigurePasswordless overrides the default passwordless sign in configuration. Zero values
+// in cfg are ignored
+func (us *Users) ConfigurePasswordless(cfg PasswordlessConfig) {
+	if cfg.CodeValidity > 0 {
+		us.passwordless.CodeValidity = cfg.CodeValidity
+	}
+	if cfg.LinkValidity > 0 {
+		us.passwordless.LinkValidity = cfg.LinkValidity
+	}
+	if cfg.MaxAttempts > 0 {
+		us.passwordless.MaxAttempts = cfg.MaxAttempts
+	}
+	if cfg.SendLimit > 0 {
+		us.passwordless.SendLimit = cfg.SendLimit
+	}
+	if cfg.SendWindow > 0 {
+		us.passwordless.SendWindow = cfg.SendWindow
+	}
+}
+
+// RequestPasswordless sends a one time code or a single use sign in link, based on kind, to the
+// given email. The sign in can only be completed from the same source. No error is returned if
+// the email is not registered, so as to not reveal which emails are registered. At most
+// SendLimit codes/links are sent to an email within the SendWindow
+func (us *Users) RequestPasswordless(ctx context.Context, source, email string, kind passwordlessKind) error {
+	email = strings.TrimSpace(email)
+	if !emailRegex.MatchString(email) {
+		return ErrInvalidEmail
+	}
+
+	if kind != PasswordlessCode && kind != PasswordlessLink {
+		return ErrInvalidPasswordless
+	}
+
+	// the sends are counted for the unregistered emails as well, so that the limit does not
+	// reveal which emails are registered
+	sends, err := us.cache.Incr(passwordlessSendsKey(email), us.passwordless.SendWindow)
+	if err != nil {
+		return us.storeErr(err)
+	}
+	if sends > int64(us.passwordless.SendLimit) {
+		return ErrPasswordlessLimited
+	}
+
+	u, err := us.store.ReadByEmail(ctx, email)
+	if err != nil {
+		if apperr.Is(err, ErrNotFound) {
+			return nil
+		}
+		return us.storeErr(err)
+	}
+
+	now := time.Now().UTC()
+	pl := &passwordlessLogin{
+		UserID: u.ID,
+		Source: source,
+	}
+	msg := notifier.Message{
+		Channel: notifier.ChannelEmail,
+		To:      u.Email,
+		Subject: "Sign in to " + app,
+	}
+
+	if kind == PasswordlessCode {
+		code, err := secureCode(verificationCodeLength)
+		if err != nil {
+			return us.storeErr(err)
+		}
+
+		pl.ID, err = secureToken(16)
+		if err != nil {
+			return us.storeErr(err)
+		}
+
+		key := emailCodeKey(u.Email)
+		pl.Code = codeHash(key, code)
+		pl.ExpiresAt = now.Add(us.passwordless.CodeValidity)
+		// the attempts made on the previous codes are retained, so that requesting another code
+		// does not allow more guesses
+		err = us.cache.Set(key, pl, us.passwordless.CodeValidity)
+		if err != nil {
+			return us.storeErr(err)
+		}
+
+		msg.Body = fmt.Sprintf(
+			"Your sign in code is %s. It is valid for %d minutes.",
+			code,
+			int(us.passwordless.CodeValidity.Minutes()),
+		)
+	} else {
+		token, err := secureToken(32)
+		if err != nil {
+			return us.storeErr(err)
+		}
+
+		pl.ID = token
+		pl.ExpiresAt = now.Add(us.passwordless.LinkValidity)
+		err = us.cache.Set(magicLinkKey(token), pl, us.passwordless.LinkValidity)
+		if err != nil {
+			return us.storeErr(err)
+		}
+
+		msg.Body = fmt.Sprintf(
+			"Sign in using %s%s%s. The link is valid for %d minutes, and only on the device it was requested from.",
+			us.appCtx.BaseURL,
+			MagicLinkPath,
+			token,
+			int(us.passwordless.LinkValidity.Minutes()),
+		)
+	}
+
+	err = us.notifier.Notify(ctx, msg)
+	if err != nil {
+		return us.storeErr(err)
+	}
+
+	return nil
+}
+
+// LoginWithEmailCode signs in the user, if the code matches the one sent to the email
+func (us *Users) LoginWithEmailCode(ctx context.Context, source, email, code string) (*User, string, error) {
+	email = strings.TrimSpace(email)
+	if !emailRegex.MatchString(email) {
+		return nil, "", ErrInvalidEmail
+	}
+
+	key := emailCodeKey(email)
+	pl := &passwordlessLogin{}
+	err := us.cache.Get(key, pl)
+	if err != nil {
+		if err == cache.ErrNotFound {
+			return nil, "", ErrOTPNotRequested
+		}
+		return nil, "", us.storeErr(err)
+	}
+
+	if pl.Source != source {
+		return nil, "", ErrOTPNotRequested
+	}
+
+	// the attempts are counted atomically before verifying, so concurrent guesses cannot exceed
+	// the limit. They are counted per email, across all the codes sent within the window
+	attemptsKey := emailCodeAttemptsKey(email)
+	attempts, err := us.cache.Incr(attemptsKey, us.passwordless.SendWindow)
+	if err != nil {
+		return nil, "", us.storeErr(err)
+	}
+
+	if attempts > int64(us.passwordless.MaxAttempts) {
+		return nil, "", ErrTooManyAttempts
+	}
+
+	if !codeMatches(pl.Code, key, strings.TrimSpace(code)) {
+		if attempts == int64(us.passwordless.MaxAttempts) {
+			// the attempts are retained, so that the codes requested later in the window are
+			// rejected as well
+			err = us.cache.Delete(key)
+			if err != nil {
+				return nil, "", us.storeErr(err)
+			}
+			return nil, "", ErrTooManyAttempts
+		}
+		return nil, "", ErrInvalidCode
+	}
+
+	// concurrent requests with the same code get past the checks above, only the first one to
+	// claim it signs in
+	claimed, err := us.claimPasswordless(pl.ID, pl.ExpiresAt)
+	if err != nil {
+		return nil, "", us.storeErr(err)
+	}
+	if !claimed {
+		return nil, "", ErrOTPNotRequested
+	}
+
+	err = us.cache.Delete(key, attemptsKey)
+	if err != nil {
+		return nil, "", us.storeErr(err)
+	}
+
+	return us.passwordlessSession(ctx, source, pl.UserID)
+}
+
+// LoginWithMagicLink signs in the user using the token of the link sent by email. The link can
+// be used only once, and only from the source which requested it
+func (us *Users) LoginWithMagicLink(ctx context.Context, source, token string) (*User, string, error) {
+	key := magicLinkKey(token)
+	pl := &passwordlessLogin{}
+	err := us.cache.Get(key, pl)
+	if err != nil {
+		if err == cache.ErrNotFound {
+			return nil, "", ErrInvalidMagicLink
+		}
+		return nil, "", us.storeErr(err)
+	}
+
+	// the link is not consumed when used from a different source, so that a forwarded link
+	// does not stop the requester from signing in
+	if pl.Source != source {
+		return nil, "", ErrInvalidMagicLink
+	}
+
+	claimed, err := us.claimPasswordless(pl.ID, pl.ExpiresAt)
+	if err != nil {
+		return nil, "", us.storeErr(err)
+	}
+	if !claimed {
+		return nil, "", ErrInvalidMagicLink
+	}
+
+	err = us.cache.Delete(key)
+	if err != nil {
+		return nil, "", us.storeErr(err)
+	}
+
+	return us.passwordlessSession(ctx, source, pl.UserID)
+}
+
+func (us *Users) passwordlessSession(ctx context.Context, source string, userID int64) (*User, string, error) {
+	u, err := us.Read(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := us.startSession(source, u)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return u, token, nil
+}
@@ -0,0 +1,257 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+)
+
+var magicLinkRegex = regexp.MustCompile(regexp.QuoteMeta(MagicLinkPath) + `([A-Za-z0-9_-]+)`)
+
+func TestRequestPasswordless(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+
+		err := f.us.RequestPasswordless(ctx, "web", "jane@example.com", PasswordlessKind("sms"))
+		if !errors.Is(err, ErrInvalidPasswordless) {
+			t.Fatalf("expected ErrInvalidPasswordless, got %v", err)
+		}
+
+		// unregistered emails are not revealed
+		err = f.us.RequestPasswordless(ctx, "web", "jane@example.com", PasswordlessCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.notifier.Messages()) != 0 {
+			t.Fatalf("expected no messages, got %+v", f.notifier.Messages())
+		}
+	})
+}
+
+func TestLoginWithEmailCode(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		usr, _ := f.login(t, User{Email: "jane@example.com"})
+
+		err := f.us.RequestPasswordless(ctx, "web", usr.Email, PasswordlessCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := f.lastMatch(t, usr.Email, codeRegex)
+
+		// the code can only be used from the source which requested it
+		_, _, err = f.us.LoginWithEmailCode(ctx, "mobile", usr.Email, code)
+		if !errors.Is(err, ErrOTPNotRequested) {
+			t.Fatalf("expected ErrOTPNotRequested, got %v", err)
+		}
+
+		_, _, err = f.us.LoginWithEmailCode(ctx, "web", usr.Email, "invalid")
+		if !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("expected ErrInvalidCode, got %v", err)
+		}
+
+		u, token, err := f.us.LoginWithEmailCode(ctx, "web", usr.Email, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.ID != usr.ID {
+			t.Fatalf("expected user %d, got %d", usr.ID, u.ID)
+		}
+
+		_, err = f.us.AuthUser(ctx, "web", token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = f.us.LoginWithEmailCode(ctx, "web", usr.Email, code)
+		if !errors.Is(err, ErrOTPNotRequested) {
+			t.Fatalf("expected ErrOTPNotRequested, got %v", err)
+		}
+	})
+}
+
+func TestLoginWithEmailCodeAttempts(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		f.us.ConfigurePasswordless(PasswordlessConfig{MaxAttempts: 3})
+		usr, _ := f.login(t, User{Email: "jane@example.com"})
+
+		err := f.us.RequestPasswordless(ctx, "web", usr.Email, PasswordlessCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := f.lastMatch(t, usr.Email, codeRegex)
+
+		for i := 1; i <= 3; i++ {
+			expected := ErrInvalidCode
+			if i == 3 {
+				expected = ErrTooManyAttempts
+			}
+
+			_, _, err = f.us.LoginWithEmailCode(ctx, "web", usr.Email, "invalid")
+			if !errors.Is(err, expected) {
+				t.Fatalf("attempt %d: expected %v, got %v", i, expected, err)
+			}
+		}
+
+		_, _, err = f.us.LoginWithEmailCode(ctx, "web", usr.Email, code)
+		if !errors.Is(err, ErrOTPNotRequested) {
+			t.Fatalf("expected ErrOTPNotRequested, got %v", err)
+		}
+
+		// a new code does not get a fresh set of attempts within the window
+		err = f.us.RequestPasswordless(ctx, "web", usr.Email, PasswordlessCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code = f.lastMatch(t, usr.Email, codeRegex)
+
+		_, _, err = f.us.LoginWithEmailCode(ctx, "web", usr.Email, code)
+		if !errors.Is(err, ErrTooManyAttempts) {
+			t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+		}
+	})
+}
+
+func TestRequestPasswordlessLimit(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		f.us.ConfigurePasswordless(PasswordlessConfig{SendLimit: 2})
+		usr, _ := f.login(t, User{Email: "jane@example.com"})
+
+		for i := 0; i < 2; i++ {
+			kind := PasswordlessCode
+			if i == 1 {
+				kind = PasswordlessLink
+			}
+			err := f.us.RequestPasswordless(ctx, "web", usr.Email, kind)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		sent := len(f.notifier.Messages())
+
+		// the limit is per email, irrespective of the case & the source
+		err := f.us.RequestPasswordless(ctx, "mobile", "JANE@example.com", PasswordlessCode)
+		if !errors.Is(err, ErrPasswordlessLimited) {
+			t.Fatalf("expected ErrPasswordlessLimited, got %v", err)
+		}
+		if len(f.notifier.Messages()) != sent {
+			t.Fatalf("expected no more messages, got %+v", f.notifier.Messages())
+		}
+
+		// unregistered emails are limited the same way, so as to not reveal them
+		for i := 0; i < 2; i++ {
+			err = f.us.RequestPasswordless(ctx, "web", "john@example.com", PasswordlessCode)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = f.us.RequestPasswordless(ctx, "web", "john@example.com", PasswordlessCode)
+		if !errors.Is(err, ErrPasswordlessLimited) {
+			t.Fatalf("expected ErrPasswordlessLimited, got %v", err)
+		}
+	})
+}
+
+func TestLoginWithMagicLink(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		usr, _ := f.login(t, User{Email: "jane@example.com"})
+
+		err := f.us.RequestPasswordless(ctx, "web", usr.Email, PasswordlessLink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := f.lastMatch(t, usr.Email, magicLinkRegex)
+
+		// a forwarded link does not work, nor is it consumed
+		_, _, err = f.us.LoginWithMagicLink(ctx, "mobile", token)
+		if !errors.Is(err, ErrInvalidMagicLink) {
+			t.Fatalf("expected ErrInvalidMagicLink, got %v", err)
+		}
+
+		u, _, err := f.us.LoginWithMagicLink(ctx, "web", token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.ID != usr.ID {
+			t.Fatalf("expected user %d, got %d", usr.ID, u.ID)
+		}
+
+		_, _, err = f.us.LoginWithMagicLink(ctx, "web", token)
+		if !errors.Is(err, ErrInvalidMagicLink) {
+			t.Fatalf("expected ErrInvalidMagicLink, got %v", err)
+		}
+	})
+}
+
+// slowCache delays the reads, so that the concurrent requests all read before any of them writes
+type slowCache struct {
+	cache.Cache
+}
+
+func (sc slowCache) Get(key string, result interface{}) error {
+	err := sc.Cache.Get(key, result)
+	time.Sleep(time.Millisecond * 20)
+	return err
+}
+
+func TestPasswordlessConcurrent(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		usr, _ := f.login(t, User{Email: "jane@example.com"})
+		f.us.cache = slowCache{Cache: f.us.cache}
+
+		// signIn signs in concurrently with the same code/link, & returns the number of sessions
+		signIn := func(login func() error) int {
+			wg := sync.WaitGroup{}
+			mu := sync.Mutex{}
+			sessions := 0
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if login() == nil {
+						mu.Lock()
+						sessions++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			return sessions
+		}
+
+		err := f.us.RequestPasswordless(ctx, "web", usr.Email, PasswordlessLink)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := f.lastMatch(t, usr.Email, magicLinkRegex)
+		sessions := signIn(func() error {
+			_, _, err := f.us.LoginWithMagicLink(ctx, "web", token)
+			return err
+		})
+		if sessions != 1 {
+			t.Fatalf("expected the link to be used only once, got %d sessions", sessions)
+		}
+
+		err = f.us.RequestPasswordless(ctx, "web", usr.Email, PasswordlessCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := f.lastMatch(t, usr.Email, codeRegex)
+		sessions = signIn(func() error {
+			_, _, err := f.us.LoginWithEmailCode(ctx, "web", usr.Email, code)
+			return err
+		})
+		if sessions != 1 {
+			t.Fatalf("expected the code to be used only once, got %d sessions", sessions)
+		}
+	})
+}
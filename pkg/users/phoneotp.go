@@ -0,0 +1,213 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/sms"
+)
+
+const (
+	phoneOTPValidity    = time.Minute * 5
+	phoneOTPMaxAttempts = 5
+
+	// phoneOTPRateWindow is the window in which at most phoneOTPRateLimit codes can be sent to a number
+	phoneOTPRateWindow = time.Hour
+	phoneOTPRateLimit  = 5
+	// phoneOTPResendAfter is the minimum duration between 2 codes sent to a number
+	phoneOTPResendAfter = time.Second * 30
+)
+
+var (
+	ErrNoPhone         = apperr.New(apperr.CodeInvalidInput, "Sorry, no phone number registered")
+	ErrInvalidChannel  = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid OTP channel, should be sms or voice")
+	ErrOTPRateLimited  = apperr.New(apperr.CodeRateLimited, "Sorry, too many codes requested, please try again later")
+	ErrOTPNotRequested = apperr.New(apperr.CodeInvalidInput, "Sorry, the code has expired or was never requested")
+)
+
+// PhoneOTP is a one time code sent to the phone number of a user
+type PhoneOTP struct {
+	// Phone is the E.164 formatted number to which the code was sent
+	Phone   string    `json:"phone,omitempty"`
+	Channel string    `json:"channel,omitempty"`
+	Code    string    `json:"-"`
+	SentAt  time.Time `json:"sentAt,omitempty"`
+	// ExpiresAt is the time after which the code can no longer be used
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+func phoneOTPKey(userID int64) string {
+	return fmt.Sprintf("phoneotp:%d", userID)
+}
+
+// phoneOTPAttemptsKey is the key of the number of attempts made to verify the code
+func phoneOTPAttemptsKey(userID int64) string {
+	return fmt.Sprintf("phoneotp:attempts:%d", userID)
+}
+
+// phoneOTPRateKey is the key of the number of codes sent to the number in the current window
+func phoneOTPRateKey(phone string) string {
+	return "phoneotp:rate:" + phone
+}
+
+// phoneOTPCooldownKey exists until a code can be sent to the number again
+func phoneOTPCooldownKey(phone string) string {
+	return "phoneotp:cooldown:" + phone
+}
+
+// SetDefaultCountryCode sets the country code (e.g. "+91") of the phone numbers provided without
+// one. Unless it's set, the phone numbers should be in E.164 format (e.g. "+919876543210")
+func (us *Users) SetDefaultCountryCode(code string) {
+	us.defaultCountryCode = code
+}
+
+// normalizePhone converts the user provided phone number to E.164 format
+func (us *Users) normalizePhone(phone string) (string, error) {
+	p, err := sms.Normalize(phone, us.defaultCountryCode)
+	if err != nil {
+		return "", ErrInvalidPhone
+	}
+	return p, nil
+}
+
+// spoken separates the digits of the code, so that they are read out one by one in a voice call
+func spoken(code string) string {
+	return strings.Join(strings.Split(code, ""), ", ")
+}
+
+// allowPhoneOTP checks & updates the rate limit of the phone number. Both the checks are atomic,
+// so that the concurrent requests cannot exceed the limit
+func (us *Users) allowPhoneOTP(phone string) error {
+	// the cooldown is claimed first, so that the sends rejected by it are not counted
+	ok, err := us.cache.SetNX(phoneOTPCooldownKey(phone), true, phoneOTPResendAfter)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrOTPRateLimited
+	}
+
+	count, err := us.cache.Incr(phoneOTPRateKey(phone), phoneOTPRateWindow)
+	if err != nil {
+		return err
+	}
+	if count > phoneOTPRateLimit {
+		return ErrOTPRateLimited
+	}
+	return nil
+}
+
+// SendPhoneOTP sends a one time code to the registered phone number of the user, as a text
+// message or a voice call based on the channel ("sms" or "voice")
+func (us *Users) SendPhoneOTP(ctx context.Context, u *User, channel string) (*PhoneOTP, error) {
+	if channel != string(sms.KindSMS) && channel != string(sms.KindVoice) {
+		return nil, ErrInvalidChannel
+	}
+
+	usr, err := us.Read(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if usr.Phone == "" {
+		return nil, ErrNoPhone
+	}
+
+	phone, err := us.normalizePhone(usr.Phone)
+	if err != nil {
+		return nil, err
+	}
+
+	err = us.allowPhoneOTP(phone)
+	if err != nil {
+		if err == ErrOTPRateLimited {
+			return nil, err
+		}
+		return nil, us.storeErr(err)
+	}
+
+	code, err := secureCode(verificationCodeLength)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	now := time.Now().UTC()
+	key := phoneOTPKey(usr.ID)
+	otp := &PhoneOTP{
+		Phone:     phone,
+		Channel:   channel,
+		Code:      codeHash(key, code),
+		SentAt:    now,
+		ExpiresAt: now.Add(phoneOTPValidity),
+	}
+
+	err = us.cache.Set(key, otp, phoneOTPValidity)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	// the new code gets a fresh set of attempts
+	err = us.cache.Delete(phoneOTPAttemptsKey(usr.ID))
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	if channel == string(sms.KindVoice) {
+		err = us.sms.Call(ctx, phone, fmt.Sprintf("Your %s verification code is %s", app, spoken(code)))
+	} else {
+		err = us.sms.SendSMS(ctx, phone, fmt.Sprintf("Your %s verification code is %s", app, code))
+	}
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	return otp, nil
+}
+
+// VerifyPhoneOTP verifies the code sent to the phone number of the user. A code can be used only
+// once, and is invalidated after phoneOTPMaxAttempts invalid attempts
+func (us *Users) VerifyPhoneOTP(ctx context.Context, u *User, code string) error {
+	key := phoneOTPKey(u.ID)
+	otp := &PhoneOTP{}
+	err := us.cache.Get(key, otp)
+	if err != nil {
+		if err == cache.ErrNotFound {
+			return ErrOTPNotRequested
+		}
+		return us.storeErr(err)
+	}
+
+	// the attempts are counted atomically before verifying, so concurrent guesses cannot exceed
+	// the limit
+	attemptsKey := phoneOTPAttemptsKey(u.ID)
+	attempts, err := us.cache.Incr(attemptsKey, time.Until(otp.ExpiresAt))
+	if err != nil {
+		return us.storeErr(err)
+	}
+
+	if attempts > phoneOTPMaxAttempts {
+		return ErrTooManyAttempts
+	}
+
+	if codeMatches(otp.Code, key, strings.TrimSpace(code)) {
+		err = us.cache.Delete(key, attemptsKey)
+		if err != nil {
+			return us.storeErr(err)
+		}
+		return nil
+	}
+
+	if attempts == phoneOTPMaxAttempts {
+		err = us.cache.Delete(key, attemptsKey)
+		if err != nil {
+			return us.storeErr(err)
+		}
+		return ErrTooManyAttempts
+	}
+
+	return ErrInvalidCode
+}
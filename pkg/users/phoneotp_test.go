@@ -0,0 +1,170 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bnkamalesh/padlock/pkg/platform/sms"
+)
+
+// lastOTP returns the code of the last message sent to the phone, and the kind of the message
+func (f usersFixture) lastOTP(t *testing.T, phone string) (string, sms.Message) {
+	t.Helper()
+
+	msgs := f.sms.Messages()
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].To != phone {
+			continue
+		}
+		m := codeRegex.FindStringSubmatch(strings.ReplaceAll(msgs[i].Message, ", ", ""))
+		if m == nil {
+			t.Fatalf("no code in %q", msgs[i].Message)
+		}
+		return m[1], msgs[i]
+	}
+	t.Fatalf("no message sent to %s", phone)
+	return "", sms.Message{}
+}
+
+func TestSendPhoneOTP(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		noPhone, _ := f.login(t, User{Email: "john@example.com"})
+		_, err := f.us.SendPhoneOTP(ctx, noPhone, string(sms.KindSMS))
+		if !errors.Is(err, ErrNoPhone) {
+			t.Fatalf("expected ErrNoPhone, got %v", err)
+		}
+
+		usr, _ := f.login(t, User{Email: "jane@example.com", Phone: "+15555550100"})
+		_, err = f.us.SendPhoneOTP(ctx, usr, "email")
+		if !errors.Is(err, ErrInvalidChannel) {
+			t.Fatalf("expected ErrInvalidChannel, got %v", err)
+		}
+
+		otp, err := f.us.SendPhoneOTP(ctx, usr, string(sms.KindVoice))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if otp.Phone != "+15555550100" || otp.Channel != string(sms.KindVoice) {
+			t.Fatalf("unexpected OTP %+v", otp)
+		}
+
+		code, msg := f.lastOTP(t, otp.Phone)
+		if msg.Kind != sms.KindVoice || len(code) != verificationCodeLength {
+			t.Fatalf("expected a voice call with a %d digit code, got %+v", verificationCodeLength, msg)
+		}
+
+		// codes cannot be sent again immediately
+		_, err = f.us.SendPhoneOTP(ctx, usr, string(sms.KindSMS))
+		if !errors.Is(err, ErrOTPRateLimited) {
+			t.Fatalf("expected ErrOTPRateLimited, got %v", err)
+		}
+	})
+}
+
+func TestSendPhoneOTPLimit(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		usr, _ := f.login(t, User{Email: "jane@example.com", Phone: "+15555550100"})
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = f.us.SendPhoneOTP(ctx, usr, string(sms.KindSMS))
+			}()
+		}
+		wg.Wait()
+		if n := len(f.sms.Messages()); n != 1 {
+			t.Fatalf("expected only 1 of the concurrent codes to be sent, got %d", n)
+		}
+
+		// at most phoneOTPRateLimit codes are sent within the window, even after the cooldown
+		for i := 2; i <= phoneOTPRateLimit+1; i++ {
+			err := f.us.cache.Delete(phoneOTPCooldownKey(usr.Phone))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = f.us.SendPhoneOTP(ctx, usr, string(sms.KindSMS))
+			if i <= phoneOTPRateLimit && err != nil {
+				t.Fatalf("code %d: %v", i, err)
+			}
+			if i > phoneOTPRateLimit && !errors.Is(err, ErrOTPRateLimited) {
+				t.Fatalf("code %d: expected ErrOTPRateLimited, got %v", i, err)
+			}
+		}
+	})
+}
+
+func TestVerifyPhoneOTP(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		usr, _ := f.login(t, User{Email: "jane@example.com", Phone: "+15555550100"})
+
+		err := f.us.VerifyPhoneOTP(ctx, usr, "123456")
+		if !errors.Is(err, ErrOTPNotRequested) {
+			t.Fatalf("expected ErrOTPNotRequested, got %v", err)
+		}
+
+		_, err = f.us.SendPhoneOTP(ctx, usr, string(sms.KindSMS))
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, msg := f.lastOTP(t, "+15555550100")
+		if msg.Kind != sms.KindSMS {
+			t.Fatalf("expected a text message, got %s", msg.Kind)
+		}
+
+		err = f.us.VerifyPhoneOTP(ctx, usr, "invalid")
+		if !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("expected ErrInvalidCode, got %v", err)
+		}
+
+		err = f.us.VerifyPhoneOTP(ctx, usr, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the code can be used only once
+		err = f.us.VerifyPhoneOTP(ctx, usr, code)
+		if !errors.Is(err, ErrOTPNotRequested) {
+			t.Fatalf("expected ErrOTPNotRequested, got %v", err)
+		}
+	})
+}
+
+func TestVerifyPhoneOTPAttempts(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		usr, _ := f.login(t, User{Email: "jane@example.com", Phone: "+15555550100"})
+
+		_, err := f.us.SendPhoneOTP(ctx, usr, string(sms.KindSMS))
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, _ := f.lastOTP(t, "+15555550100")
+
+		for i := 1; i <= phoneOTPMaxAttempts; i++ {
+			expected := ErrInvalidCode
+			if i == phoneOTPMaxAttempts {
+				expected = ErrTooManyAttempts
+			}
+
+			err = f.us.VerifyPhoneOTP(ctx, usr, "invalid")
+			if !errors.Is(err, expected) {
+				t.Fatalf("attempt %d: expected %v, got %v", i, expected, err)
+			}
+		}
+
+		// the code is invalidated once the attempts are exhausted
+		err = f.us.VerifyPhoneOTP(ctx, usr, code)
+		if !errors.Is(err, ErrOTPNotRequested) {
+			t.Fatalf("expected ErrOTPNotRequested, got %v", err)
+		}
+	})
+}
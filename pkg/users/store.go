@@ -5,20 +5,57 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 
 	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
 )
 
 const (
 	usersTable = "users"
+
+	// pqUniqueViolation is the Postgres error code for unique constraint violation
+	pqUniqueViolation = "23505"
+
+	userColumns = "id,name,email,phone,password,salt,version,createdat,updatedat"
 )
 
 type store interface {
 	Create(ctx context.Context, u User) (*User, error)
+	Read(ctx context.Context, id int64) (*User, error)
+	// ReadByEmail matches the email irrespective of the case
 	ReadByEmail(ctx context.Context, email string) (*User, error)
+	List(ctx context.Context, filter ListFilter) ([]User, int64, error)
+	// Update updates the non-empty name & password of the user
 	Update(ctx context.Context, u User) (*User, error)
+	// UpdateContact sets the email/phone of the user, even if the value is empty
+	UpdateContact(ctx context.Context, id int64, field contactField, value string, updatedAt time.Time) (*User, error)
+	Delete(ctx context.Context, id int64, deletedAt time.Time) error
+	Restore(ctx context.Context, id int64) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// contactColumn returns the column of the email/phone
+func contactColumn(field contactField) (string, error) {
+	switch field {
+	case ContactEmail:
+		{
+			return "email", nil
+		}
+	case ContactPhone:
+		{
+			return "phone", nil
+		}
+	}
+	return "", ErrInvalidContact
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
 }
 
 type dbStore struct {
@@ -26,6 +63,20 @@ type dbStore struct {
 	db     *sql.DB
 }
 
+// newStore returns the store of the driver
+func newStore(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB) store {
+	if driver == database.SQLite {
+		return &sqliteStore{
+			appCtx: appCtx,
+			db:     sdb,
+		}
+	}
+	return &dbStore{
+		appCtx: appCtx,
+		db:     sdb,
+	}
+}
+
 func (dbs *dbStore) rollback(tx *sql.Tx) {
 	err := tx.Rollback()
 	if err != nil && err != sql.ErrTxDone && dbs.appCtx.Logging {
@@ -47,9 +98,42 @@ func (dbs *dbStore) prepColVals(cols ...string) string {
 	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
 }
 
+func (dbs *dbStore) scan(row scanner) (*User, error) {
+	u := User{}
+
+	createdAt := pq.NullTime{}
+	updatedAt := pq.NullTime{}
+	err := row.Scan(
+		&u.ID,
+		&u.Name,
+		&u.Email,
+		&u.Phone,
+		&u.Password,
+		&u.Salt,
+		&u.Version,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.Wrap(ErrNotFound, err)
+		}
+		return nil, err
+	}
+
+	if createdAt.Valid {
+		u.CreatedAt = &createdAt.Time
+	}
+	if updatedAt.Valid {
+		u.UpdatedAt = &updatedAt.Time
+	}
+
+	return &u, nil
+}
+
 func (dbs *dbStore) Create(ctx context.Context, u User) (*User, error) {
 	stmt := fmt.Sprintf(
-		"INSERT INTO %s %s RETURNING id",
+		"INSERT INTO %s %s RETURNING id,version",
 		usersTable,
 		dbs.prepColVals(
 			"name",
@@ -61,7 +145,8 @@ func (dbs *dbStore) Create(ctx context.Context, u User) (*User, error) {
 		),
 	)
 
-	result := dbs.db.QueryRow(
+	result := dbs.db.QueryRowContext(
+		ctx,
 		stmt,
 		u.Name,
 		u.Email,
@@ -70,67 +155,237 @@ func (dbs *dbStore) Create(ctx context.Context, u User) (*User, error) {
 		u.Salt,
 		u.CreatedAt,
 	)
-	err := result.Scan(&u.ID)
+	err := result.Scan(&u.ID, &u.Version)
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+			return nil, apperr.Wrap(ErrEmailExists, err)
+		}
 		return nil, err
 	}
 
 	return &u, nil
 }
 
+func (dbs *dbStore) Read(ctx context.Context, id int64) (*User, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE id=$1 AND deletedat IS NULL",
+		userColumns,
+		usersTable,
+	)
+
+	return dbs.scan(dbs.db.QueryRowContext(ctx, stmt, id))
+}
+
 func (dbs *dbStore) ReadByEmail(ctx context.Context, email string) (*User, error) {
 	stmt := fmt.Sprintf(
-		"SELECT id,name,email,phone,password,salt,createdat,updatedat FROM %s WHERE email=$1",
+		"SELECT %s FROM %s WHERE lower(email)=lower($1) AND deletedat IS NULL",
+		userColumns,
 		usersTable,
 	)
 
-	result := dbs.db.QueryRow(stmt, email)
-	u := User{}
+	return dbs.scan(dbs.db.QueryRowContext(ctx, stmt, email))
+}
 
-	createdAt := pq.NullTime{}
-	updatedAt := pq.NullTime{}
-	err := result.Scan(
-		&u.ID,
-		&u.Name,
-		&u.Email,
-		&u.Phone,
-		&u.Password,
-		&u.Salt,
-		&createdAt,
-		&updatedAt,
+// listConditions prepares the WHERE clause & its arguments, for the given filter
+func (dbs *dbStore) listConditions(filter ListFilter) (string, []interface{}) {
+	conds := []string{"deletedat IS NULL"}
+	args := make([]interface{}, 0, 4)
+
+	if filter.Email != "" {
+		args = append(args, database.Contains(filter.Email))
+		conds = append(conds, fmt.Sprintf(`email ILIKE $%d ESCAPE '\'`, len(args)))
+	}
+
+	if filter.Name != "" {
+		args = append(args, database.Contains(filter.Name))
+		conds = append(conds, fmt.Sprintf(`name ILIKE $%d ESCAPE '\'`, len(args)))
+	}
+
+	if filter.CreatedFrom != nil {
+		args = append(args, *filter.CreatedFrom)
+		conds = append(conds, fmt.Sprintf("createdat >= $%d", len(args)))
+	}
+
+	if filter.CreatedTo != nil {
+		args = append(args, *filter.CreatedTo)
+		conds = append(conds, fmt.Sprintf("createdat < $%d", len(args)))
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+func (dbs *dbStore) List(ctx context.Context, filter ListFilter) ([]User, int64, error) {
+	where, args := dbs.listConditions(filter)
+
+	total := int64(0)
+	stmt := fmt.Sprintf("SELECT COUNT(id) FROM %s WHERE %s", usersTable, where)
+	err := dbs.db.QueryRowContext(ctx, stmt, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if total == 0 {
+		return []User{}, 0, nil
+	}
+
+	stmt = fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s ORDER BY id LIMIT $%d OFFSET $%d",
+		userColumns,
+		usersTable,
+		where,
+		len(args)+1,
+		len(args)+2,
 	)
+	args = append(args, filter.Limit, filter.Offset)
 
+	rows, err := dbs.db.QueryContext(ctx, stmt, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
+	defer rows.Close()
 
-	u.CreatedAt = &createdAt.Time
-	u.UpdatedAt = &updatedAt.Time
+	list := make([]User, 0, filter.Limit)
+	for rows.Next() {
+		u, err := dbs.scan(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		list = append(list, *u)
+	}
 
-	return &u, nil
+	err = rows.Err()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return list, total, nil
 }
 
+// Update updates only the non-empty name & password of the user, provided the version of the user
+// in the store matches u.Version. On every successful update, the version is incremented
 func (dbs *dbStore) Update(ctx context.Context, u User) (*User, error) {
+	sets := make([]string, 0, 6)
+	args := make([]interface{}, 0, 8)
+	set := func(col string, val interface{}) {
+		args = append(args, val)
+		sets = append(sets, fmt.Sprintf("%s=$%d", col, len(args)))
+	}
+
+	if u.Name != "" {
+		set("name", u.Name)
+	}
+	if u.Password != "" {
+		set("password", u.Password)
+		set("salt", u.Salt)
+	}
+	set("updatedat", u.UpdatedAt)
+	sets = append(sets, "version=version+1")
+
+	args = append(args, u.ID, u.Version)
 	stmt := fmt.Sprintf(
-		"UPDATE %s SET %s WHERE id=%d",
+		"UPDATE %s SET %s WHERE id=$%d AND version=$%d AND deletedat IS NULL RETURNING %s",
 		usersTable,
-		"SET name=$1, email=$2, phone=$3, password=$4, salt=$5, createdat=$6, updatedat=$7",
-		u.ID,
+		strings.Join(sets, ", "),
+		len(args)-1,
+		len(args),
+		userColumns,
 	)
 
-	_, err := dbs.db.Exec(
-		stmt,
-		u.Name,
-		u.Email,
-		u.Phone,
-		u.Password,
-		u.Salt,
-		u.CreatedAt,
-		u.UpdatedAt,
+	usr, err := dbs.scan(dbs.db.QueryRowContext(ctx, stmt, args...))
+	if apperr.Is(err, ErrNotFound) {
+		// the user either does not exist, or was updated by someone else in the meantime
+		_, err = dbs.Read(ctx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrVersionConflict
+	}
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+			return nil, apperr.Wrap(ErrEmailExists, err)
+		}
+		return nil, err
+	}
+
+	return usr, nil
+}
+
+func (dbs *dbStore) UpdateContact(ctx context.Context, id int64, field contactField, value string, updatedAt time.Time) (*User, error) {
+	col, err := contactColumn(field)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET %s=$1, updatedat=$2, version=version+1 WHERE id=$3 AND deletedat IS NULL RETURNING %s",
+		usersTable,
+		col,
+		userColumns,
 	)
+
+	usr, err := dbs.scan(dbs.db.QueryRowContext(ctx, stmt, value, updatedAt, id))
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+			return nil, apperr.Wrap(ErrEmailExists, err)
+		}
 		return nil, err
 	}
 
-	return &u, nil
+	return usr, nil
+}
+
+// Delete soft deletes the user, by setting the deletion timestamp
+func (dbs *dbStore) Delete(ctx context.Context, id int64, deletedAt time.Time) error {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET deletedat=$1 WHERE id=$2 AND deletedat IS NULL",
+		usersTable,
+	)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, deletedAt, id)
+	if err != nil {
+		return err
+	}
+
+	return dbs.affected(result)
+}
+
+// Restore reverts a soft delete
+func (dbs *dbStore) Restore(ctx context.Context, id int64) error {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET deletedat=NULL WHERE id=$1 AND deletedat IS NOT NULL",
+		usersTable,
+	)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return dbs.affected(result)
+}
+
+// Purge permanently deletes all the users which were soft deleted before the given time, their
+// role assignments are deleted along with them by the database
+func (dbs *dbStore) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE deletedat < $1", usersTable)
+	result, err := dbs.db.ExecContext(ctx, stmt, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// affected returns ErrNotFound if the result did not affect any rows
+func (dbs *dbStore) affected(result sql.Result) error {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	return nil
 }
@@ -0,0 +1,386 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+)
+
+// sqliteStore is the store of SQLite, it behaves exactly like dbStore. SQLite (as bundled) does
+// not support RETURNING, so the rows are read again after writing them
+type sqliteStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func isSQLiteUniqueViolation(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// sqliteTime returns the time in UTC truncated to seconds, as stored by Postgres. SQLite stores
+// the times as text, which are compared correctly only if they're all in the same time zone
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Truncate(time.Second)
+}
+
+func (ss *sqliteStore) rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && err != sql.ErrTxDone && ss.appCtx.Logging {
+		ss.appCtx.Logger.Error(err)
+	}
+}
+
+func (ss *sqliteStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, "?")
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func (ss *sqliteStore) scan(row scanner) (*User, error) {
+	u := User{}
+	err := row.Scan(
+		&u.ID,
+		&u.Name,
+		&u.Email,
+		&u.Phone,
+		&u.Password,
+		&u.Salt,
+		&u.Version,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperr.Wrap(ErrNotFound, err)
+		}
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (ss *sqliteStore) Create(ctx context.Context, u User) (*User, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		usersTable,
+		ss.prepColVals(
+			"name",
+			"email",
+			"phone",
+			"password",
+			"salt",
+			"createdat",
+		),
+	)
+
+	result, err := ss.db.ExecContext(
+		ctx,
+		stmt,
+		u.Name,
+		u.Email,
+		u.Phone,
+		u.Password,
+		u.Salt,
+		sqliteTime(u.CreatedAt),
+	)
+	if err != nil {
+		if isSQLiteUniqueViolation(err) {
+			return nil, apperr.Wrap(ErrEmailExists, err)
+		}
+		return nil, err
+	}
+
+	u.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	// the default version of the table
+	u.Version = 1
+
+	return &u, nil
+}
+
+func (ss *sqliteStore) read(ctx context.Context, q queryer, id int64) (*User, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE id=? AND deletedat IS NULL",
+		userColumns,
+		usersTable,
+	)
+
+	return ss.scan(q.QueryRowContext(ctx, stmt, id))
+}
+
+func (ss *sqliteStore) Read(ctx context.Context, id int64) (*User, error) {
+	return ss.read(ctx, ss.db, id)
+}
+
+func (ss *sqliteStore) ReadByEmail(ctx context.Context, email string) (*User, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE lower(email)=lower(?) AND deletedat IS NULL",
+		userColumns,
+		usersTable,
+	)
+
+	return ss.scan(ss.db.QueryRowContext(ctx, stmt, email))
+}
+
+// listConditions prepares the WHERE clause & its arguments, for the given filter. LIKE of
+// SQLite is case insensitive (for ASCII), like ILIKE of Postgres
+func (ss *sqliteStore) listConditions(filter ListFilter) (string, []interface{}) {
+	conds := []string{"deletedat IS NULL"}
+	args := make([]interface{}, 0, 4)
+
+	if filter.Email != "" {
+		args = append(args, database.Contains(filter.Email))
+		conds = append(conds, `email LIKE ? ESCAPE '\'`)
+	}
+
+	if filter.Name != "" {
+		args = append(args, database.Contains(filter.Name))
+		conds = append(conds, `name LIKE ? ESCAPE '\'`)
+	}
+
+	if filter.CreatedFrom != nil {
+		args = append(args, sqliteTime(filter.CreatedFrom))
+		conds = append(conds, "createdat >= ?")
+	}
+
+	if filter.CreatedTo != nil {
+		args = append(args, sqliteTime(filter.CreatedTo))
+		conds = append(conds, "createdat < ?")
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+func (ss *sqliteStore) List(ctx context.Context, filter ListFilter) ([]User, int64, error) {
+	where, args := ss.listConditions(filter)
+
+	total := int64(0)
+	stmt := fmt.Sprintf("SELECT COUNT(id) FROM %s WHERE %s", usersTable, where)
+	err := ss.db.QueryRowContext(ctx, stmt, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if total == 0 {
+		return []User{}, 0, nil
+	}
+
+	stmt = fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s ORDER BY id LIMIT ? OFFSET ?",
+		userColumns,
+		usersTable,
+		where,
+	)
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	list := make([]User, 0, filter.Limit)
+	for rows.Next() {
+		u, err := ss.scan(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		list = append(list, *u)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return list, total, nil
+}
+
+// Update updates only the non-empty name & password of the user, provided the version of the user
+// in the store matches u.Version. On every successful update, the version is incremented
+func (ss *sqliteStore) Update(ctx context.Context, u User) (*User, error) {
+	sets := make([]string, 0, 6)
+	args := make([]interface{}, 0, 8)
+	set := func(col string, val interface{}) {
+		args = append(args, val)
+		sets = append(sets, col+"=?")
+	}
+
+	if u.Name != "" {
+		set("name", u.Name)
+	}
+	if u.Password != "" {
+		set("password", u.Password)
+		set("salt", u.Salt)
+	}
+	set("updatedat", sqliteTime(u.UpdatedAt))
+	sets = append(sets, "version=version+1")
+
+	args = append(args, u.ID, u.Version)
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET %s WHERE id=? AND version=? AND deletedat IS NULL",
+		usersTable,
+		strings.Join(sets, ", "),
+	)
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ss.rollback(tx)
+
+	result, err := tx.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		if isSQLiteUniqueViolation(err) {
+			return nil, apperr.Wrap(ErrEmailExists, err)
+		}
+		return nil, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		// the user either does not exist, or was updated by someone else in the meantime
+		_, err = ss.read(ctx, tx, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrVersionConflict
+	}
+
+	usr, err := ss.read(ctx, tx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return usr, tx.Commit()
+}
+
+func (ss *sqliteStore) UpdateContact(ctx context.Context, id int64, field contactField, value string, updatedAt time.Time) (*User, error) {
+	col, err := contactColumn(field)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET %s=?, updatedat=?, version=version+1 WHERE id=? AND deletedat IS NULL",
+		usersTable,
+		col,
+	)
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ss.rollback(tx)
+
+	result, err := tx.ExecContext(ctx, stmt, value, sqliteTime(&updatedAt), id)
+	if err != nil {
+		if isSQLiteUniqueViolation(err) {
+			return nil, apperr.Wrap(ErrEmailExists, err)
+		}
+		return nil, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, ErrNotFound
+	}
+
+	usr, err := ss.read(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return usr, tx.Commit()
+}
+
+// Delete soft deletes the user, by setting the deletion timestamp
+func (ss *sqliteStore) Delete(ctx context.Context, id int64, deletedAt time.Time) error {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET deletedat=? WHERE id=? AND deletedat IS NULL",
+		usersTable,
+	)
+
+	result, err := ss.db.ExecContext(ctx, stmt, sqliteTime(&deletedAt), id)
+	if err != nil {
+		return err
+	}
+
+	return ss.affected(result)
+}
+
+// Restore reverts a soft delete
+func (ss *sqliteStore) Restore(ctx context.Context, id int64) error {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET deletedat=NULL WHERE id=? AND deletedat IS NOT NULL",
+		usersTable,
+	)
+
+	result, err := ss.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return ss.affected(result)
+}
+
+// Purge permanently deletes all the users which were soft deleted before the given time, their
+// role assignments are deleted along with them by the database
+func (ss *sqliteStore) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE deletedat < ?", usersTable)
+	result, err := ss.db.ExecContext(ctx, stmt, sqliteTime(&deletedBefore))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// affected returns ErrNotFound if the result did not affect any rows
+func (ss *sqliteStore) affected(result sql.Result) error {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,307 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+func testStore(t *testing.T, fn func(t *testing.T, st store)) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		fn(t, newStore(appcontext.New(logger.New()), driver, db))
+	})
+}
+
+func createUser(t *testing.T, st store, email string) *User {
+	t.Helper()
+
+	now := time.Now()
+	u := User{Name: "Jane", Email: email, CreatedAt: &now}
+	u.setPassword("password")
+
+	usr, err := st.Create(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usr.ID < 1 || usr.Version != 1 {
+		t.Fatalf("expected an ID & version 1, got %d & %d", usr.ID, usr.Version)
+	}
+	return usr
+}
+
+func TestStoreCreateRead(t *testing.T) {
+	testStore(t, func(t *testing.T, st store) {
+		ctx := context.Background()
+		created := createUser(t, st, "jane@example.com")
+
+		u, err := st.Read(ctx, created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Email != created.Email || u.Password != created.Password || u.Salt != created.Salt {
+			t.Fatalf("expected %+v, got %+v", created, u)
+		}
+
+		u, err = st.ReadByEmail(ctx, "jane@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.ID != created.ID {
+			t.Fatalf("expected user %d, got %d", created.ID, u.ID)
+		}
+
+		_, err = st.Read(ctx, created.ID+1)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		_, err = st.Create(ctx, User{Email: "jane@example.com", CreatedAt: created.CreatedAt})
+		if !errors.Is(err, ErrEmailExists) {
+			t.Fatalf("expected ErrEmailExists, got %v", err)
+		}
+
+		// the emails are matched irrespective of the case, e.g. the ones stored before they
+		// were normalized
+		mixed := createUser(t, st, "John@Example.com")
+		u, err = st.ReadByEmail(ctx, "john@example.COM")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.ID != mixed.ID {
+			t.Fatalf("expected user %d, got %d", mixed.ID, u.ID)
+		}
+
+		_, err = st.Create(ctx, User{Email: "JOHN@example.com", CreatedAt: created.CreatedAt})
+		if !errors.Is(err, ErrEmailExists) {
+			t.Fatalf("expected ErrEmailExists, got %v", err)
+		}
+	})
+}
+
+func TestStoreList(t *testing.T) {
+	testStore(t, func(t *testing.T, st store) {
+		ctx := context.Background()
+		createUser(t, st, "jane@example.com")
+		createUser(t, st, "john@example.com")
+		deleted := createUser(t, st, "jim@example.org")
+		err := st.Delete(ctx, deleted.ID, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name   string
+			filter ListFilter
+			emails []string
+			total  int64
+		}{
+			{
+				name:   "all",
+				filter: ListFilter{Limit: 10},
+				emails: []string{"jane@example.com", "john@example.com"},
+				total:  2,
+			},
+			{
+				name:   "email, case insensitive",
+				filter: ListFilter{Email: "JOHN", Limit: 10},
+				emails: []string{"john@example.com"},
+				total:  1,
+			},
+			{
+				name:   "paginated",
+				filter: ListFilter{Offset: 1, Limit: 1},
+				emails: []string{"john@example.com"},
+				total:  2,
+			},
+			{
+				name:   "email, wildcards are literal",
+				filter: ListFilter{Email: "j_ne%", Limit: 10},
+				emails: []string{},
+				total:  0,
+			},
+			{
+				name:   "name, wildcards are literal",
+				filter: ListFilter{Name: "%", Limit: 10},
+				emails: []string{},
+				total:  0,
+			},
+			{
+				name:   "none",
+				filter: ListFilter{Name: "nobody", Limit: 10},
+				emails: []string{},
+				total:  0,
+			},
+		}
+
+		for _, tt := range tests {
+			list, total, err := st.List(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if total != tt.total || len(list) != len(tt.emails) {
+				t.Fatalf("%s: expected %d of %d users, got %d of %d", tt.name, len(tt.emails), tt.total, len(list), total)
+			}
+			for i, u := range list {
+				if u.Email != tt.emails[i] {
+					t.Fatalf("%s: expected %s, got %s", tt.name, tt.emails[i], u.Email)
+				}
+			}
+		}
+	})
+}
+
+func TestStoreUpdateVersion(t *testing.T) {
+	testStore(t, func(t *testing.T, st store) {
+		ctx := context.Background()
+		created := createUser(t, st, "jane@example.com")
+
+		now := time.Now()
+		u, err := st.Update(ctx, User{ID: created.ID, Version: created.Version, Name: "Janet", UpdatedAt: &now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Name != "Janet" || u.Email != created.Email || u.Version != created.Version+1 {
+			t.Fatalf("expected the name updated & the version incremented, got %+v", u)
+		}
+
+		// a stale version is rejected, and does not modify the user
+		_, err = st.Update(ctx, User{ID: created.ID, Version: created.Version, Name: "Jan", UpdatedAt: &now})
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("expected ErrVersionConflict, got %v", err)
+		}
+
+		u, err = st.Read(ctx, created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Name != "Janet" {
+			t.Fatalf("expected the name to be unchanged, got %s", u.Name)
+		}
+
+		_, err = st.Update(ctx, User{ID: created.ID + 1, Version: 1, Name: "Jan", UpdatedAt: &now})
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStoreUpdateContact(t *testing.T) {
+	testStore(t, func(t *testing.T, st store) {
+		ctx := context.Background()
+		created := createUser(t, st, "jane@example.com")
+		other := createUser(t, st, "john@example.com")
+
+		u, err := st.UpdateContact(ctx, created.ID, ContactPhone, "+15555550100", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Phone != "+15555550100" || u.Version != created.Version+1 {
+			t.Fatalf("expected the phone updated & the version incremented, got %+v", u)
+		}
+
+		// an empty value clears the contact, e.g. while reverting to a phone which was never set
+		u, err = st.UpdateContact(ctx, created.ID, ContactPhone, "", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Phone != "" {
+			t.Fatalf("expected the phone to be cleared, got %q", u.Phone)
+		}
+
+		_, err = st.UpdateContact(ctx, created.ID, ContactEmail, other.Email, time.Now())
+		if !errors.Is(err, ErrEmailExists) {
+			t.Fatalf("expected ErrEmailExists, got %v", err)
+		}
+
+		_, err = st.UpdateContact(ctx, other.ID+1, ContactEmail, "jan@example.com", time.Now())
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStoreSoftDelete(t *testing.T) {
+	testStore(t, func(t *testing.T, st store) {
+		ctx := context.Background()
+		u := createUser(t, st, "jane@example.com")
+
+		err := st.Delete(ctx, u.ID, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = st.Read(ctx, u.ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected a deleted user to be not found, got %v", err)
+		}
+
+		err = st.Delete(ctx, u.ID, time.Now())
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected deleting again to fail with ErrNotFound, got %v", err)
+		}
+
+		err = st.Restore(ctx, u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = st.Read(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("expected a restored user to be found, got %v", err)
+		}
+
+		err = st.Restore(ctx, u.ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected restoring an active user to fail with ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStorePurge(t *testing.T) {
+	testStore(t, func(t *testing.T, st store) {
+		ctx := context.Background()
+		now := time.Now()
+		old := createUser(t, st, "old@example.com")
+		recent := createUser(t, st, "recent@example.com")
+		active := createUser(t, st, "active@example.com")
+
+		err := st.Delete(ctx, old.ID, now.Add(-DeleteGracePeriod-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = st.Delete(ctx, recent.ID, now.Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count, err := st.Purge(ctx, now.Add(-DeleteGracePeriod))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Fatalf("expected 1 user purged, got %d", count)
+		}
+
+		err = st.Restore(ctx, old.ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected a purged user to be gone, got %v", err)
+		}
+
+		err = st.Restore(ctx, recent.ID)
+		if err != nil {
+			t.Fatalf("expected a user within the grace period to be restorable, got %v", err)
+		}
+
+		_, err = st.Read(ctx, active.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -5,7 +5,7 @@ import (
 	"crypto/sha512"
 	"database/sql"
 	"encoding/hex"
-	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 	"time"
@@ -13,7 +13,11 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
 	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/notifier"
+	"github.com/bnkamalesh/padlock/pkg/platform/sms"
 )
 
 var (
@@ -21,47 +25,132 @@ var (
 
 	emailRegex = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
-	ErrInvalidEmail = errors.New("Invalid/no email provided")
-	ErrInvalidUser  = errors.New("Invalid user")
-	ErrInvalidLogin = errors.New("Email/password did not match")
-	ErrUnexpected   = errors.New("Sorry, an unexpected error occurred")
+	ErrInvalidEmail = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no email provided")
+	ErrInvalidUser  = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid user")
+	ErrInvalidLogin = apperr.New(apperr.CodeUnauthenticated, "Sorry, the email/password did not match")
+	ErrInvalidID    = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no user ID provided")
+	ErrNotFound     = apperr.New(apperr.CodeNotFound, "Sorry, user not found")
+	ErrNoVersion    = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no user version provided")
+	// ErrVersionConflict is returned when the user was updated by someone else, after it was read
+	ErrVersionConflict = apperr.New(apperr.CodeConflict, "Sorry, the user was modified since it was read, please reload & try again")
+	ErrUnexpected      = apperr.New(apperr.CodeInternal, "Sorry, an unexpected error occurred")
 )
 
+const (
+	// DeleteGracePeriod is the duration for which a deleted user can be restored, post which
+	// it is permanently deleted by Purge
+	DeleteGracePeriod = time.Hour * 24 * 30
+	// PurgeInterval is the interval at which StartPurge purges the deleted users
+	PurgeInterval = time.Hour
+
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// ListFilter has all the filters & pagination options available while listing users
+type ListFilter struct {
+	// Email filters users whose email contains the given value (case insensitive)
+	Email string `json:"email,omitempty"`
+	// Name filters users whose name contains the given value (case insensitive)
+	Name string `json:"name,omitempty"`
+	// CreatedFrom filters users created at or after the given time
+	CreatedFrom *time.Time `json:"createdFrom,omitempty"`
+	// CreatedTo filters users created before the given time
+	CreatedTo *time.Time `json:"createdTo,omitempty"`
+	// Offset is the number of records to skip
+	Offset int `json:"offset,omitempty"`
+	// Limit is the maximum number of records to return
+	Limit int `json:"limit,omitempty"`
+}
+
+func (lf *ListFilter) sanitize() {
+	lf.Email = strings.TrimSpace(lf.Email)
+	lf.Name = strings.TrimSpace(lf.Name)
+	if lf.Offset < 0 {
+		lf.Offset = 0
+	}
+
+	if lf.Limit < 1 {
+		lf.Limit = defaultListLimit
+	} else if lf.Limit > maxListLimit {
+		lf.Limit = maxListLimit
+	}
+}
+
 type Users struct {
-	appCtx *appcontext.AppContext
-	store  store
-	cache  cache.Cache
+	appCtx   *appcontext.AppContext
+	store    store
+	cache    cache.Cache
+	notifier notifier.Notifier
+	sms      sms.Sender
+
+	passwordless PasswordlessConfig
+	// defaultCountryCode is the country code of the phone numbers provided without one
+	defaultCountryCode string
 }
 
 func (us *Users) Create(ctx context.Context, u User, password string) (*User, error) {
-	u.Email = strings.TrimSpace(u.Email)
+	// the emails are stored in lower case, since they are matched irrespective of the case
+	u.Email = strings.ToLower(strings.TrimSpace(u.Email))
 
 	if u.Email == "" || !emailRegex.Match([]byte(u.Email)) {
 		return nil, ErrInvalidEmail
 	}
 
+	if u.Phone != "" {
+		phone, err := us.normalizePhone(u.Phone)
+		if err != nil {
+			return nil, err
+		}
+		u.Phone = phone
+	}
+
 	u.setPassword(password)
 	now := time.Now()
 	u.CreatedAt = &now
 
 	usr, err := us.store.Create(ctx, u)
 	if err != nil {
-		if us.appCtx.Logging {
-			us.appCtx.Logger.Error(err)
-		}
-		return nil, ErrUnexpected
+		return nil, us.storeErr(err)
 	}
 
 	return usr, nil
 }
 
-func (us *Users) Read(ctx context.Context, id int) (*User, error) {
-	return nil, nil
+// Read reads a single user based on the given ID
+func (us *Users) Read(ctx context.Context, id int64) (*User, error) {
+	if id < 1 {
+		return nil, ErrInvalidID
+	}
+
+	u, err := us.store.Read(ctx, id)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	return u, nil
 }
 
+// Update updates only the non-empty name & password (plain text) of the user. The email &
+// phone can only be changed by verifying them, refer RequestContactChange.
+// u.Version should be the version of the user as last read, and ErrVersionConflict is returned
+// if it was updated by someone else since
 func (us *Users) Update(ctx context.Context, u User) (*User, error) {
-	if err := u.Validate(); err != nil {
-		return nil, err
+	if u.ID < 1 {
+		return nil, ErrInvalidID
+	}
+
+	if u.Version < 1 {
+		return nil, ErrNoVersion
+	}
+
+	if u.Email != "" || u.Phone != "" {
+		return nil, ErrContactNotEditable
+	}
+
+	u.Name = strings.TrimSpace(u.Name)
+	if u.Password != "" {
+		u.setPassword(u.Password)
 	}
 
 	now := time.Now()
@@ -69,38 +158,137 @@ func (us *Users) Update(ctx context.Context, u User) (*User, error) {
 
 	usr, err := us.store.Update(ctx, u)
 	if err != nil {
-		if us.appCtx.Logging {
-			us.appCtx.Logger.Error(err)
-		}
-		return nil, ErrUnexpected
+		return nil, us.storeErr(err)
 	}
 
 	return usr, nil
 }
 
+// ReadByEmail reads a single user based on the given email
 func (us *Users) ReadByEmail(ctx context.Context, email string) (*User, error) {
-	return nil, nil
+	email = strings.TrimSpace(email)
+	if !emailRegex.MatchString(email) {
+		return nil, ErrInvalidEmail
+	}
+
+	u, err := us.store.ReadByEmail(ctx, email)
+	if err != nil {
+		return nil, us.storeErr(err)
+	}
+
+	return u, nil
+}
+
+// List returns the list of users matching the filter, along with the total number of
+// matching users (ignoring offset & limit)
+func (us *Users) List(ctx context.Context, filter ListFilter) ([]User, int64, error) {
+	filter.sanitize()
+
+	list, total, err := us.store.List(ctx, filter)
+	if err != nil {
+		return nil, 0, us.storeErr(err)
+	}
+
+	return list, total, nil
+}
+
+// Delete soft deletes the user. The user can be restored within DeleteGracePeriod, post
+// which it is permanently deleted by Purge
+func (us *Users) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrInvalidID
+	}
+
+	err := us.store.Delete(ctx, id, time.Now().UTC())
+	if err != nil {
+		return us.storeErr(err)
+	}
+
+	return nil
+}
+
+// Restore restores a soft deleted user
+func (us *Users) Restore(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrInvalidID
+	}
+
+	err := us.store.Restore(ctx, id)
+	if err != nil {
+		return us.storeErr(err)
+	}
+
+	return nil
+}
+
+// Purge permanently deletes all the users whose DeleteGracePeriod has elapsed, and returns
+// the number of users deleted
+func (us *Users) Purge(ctx context.Context) (int64, error) {
+	count, err := us.store.Purge(ctx, time.Now().UTC().Add(-DeleteGracePeriod))
+	if err != nil {
+		return 0, us.storeErr(err)
+	}
+
+	return count, nil
+}
+
+// StartPurge purges the users (refer Purge) every PurgeInterval, till the context is cancelled
+func (us *Users) StartPurge(ctx context.Context) {
+	ticker := time.NewTicker(PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		// the errors are logged by Purge
+		count, err := us.Purge(ctx)
+		if err == nil && count > 0 && us.appCtx.Logging {
+			us.appCtx.Logger.Info(fmt.Sprintf("purged %d deleted users", count))
+		}
+
+		select {
+		case <-ctx.Done():
+			{
+				return
+			}
+		case <-ticker.C:
+		}
+	}
 }
 
-func New(appCtx *appcontext.AppContext, sdb *sql.DB, c cache.Cache) *Users {
-	dbs := &dbStore{
-		db: sdb,
+// storeErr returns the errors of this package as is, and logs & wraps the rest with
+// ErrUnexpected
+func (us *Users) storeErr(err error) error {
+	if _, ok := err.(*apperr.Error); ok {
+		return err
 	}
+
+	if us.appCtx.Logging {
+		us.appCtx.Logger.Error(err)
+	}
+	return apperr.Wrap(ErrUnexpected, err)
+}
+
+func New(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB, c cache.Cache, n notifier.Notifier, s sms.Sender) *Users {
 	u := &Users{
-		appCtx: appCtx,
-		cache:  c,
-		store:  dbs,
+		appCtx:   appCtx,
+		cache:    c,
+		store:    newStore(appCtx, driver, sdb),
+		notifier: n,
+		sms:      s,
+
+		passwordless: DefaultPasswordlessConfig,
 	}
 	return u
 }
 
 type User struct {
-	ID        int64      `json:"id,omitempty"`
-	Name      string     `json:"name,omitempty"`
-	Email     string     `json:"email,omitempty"`
-	Phone     string     `json:"phone,omitempty"`
-	Password  string     `json:"-"`
-	Salt      string     `json:"-"`
+	ID       int64  `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Phone    string `json:"phone,omitempty"`
+	Password string `json:"-"`
+	Salt     string `json:"-"`
+	// Version is incremented on every update, and is used to detect concurrent updates
+	Version   int64      `json:"version,omitempty"`
 	CreatedAt *time.Time `json:"createdAt,omitempty"`
 	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
 }
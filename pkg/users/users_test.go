@@ -0,0 +1,104 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestEmailCase(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		usr, _ := f.login(t, User{Email: "Jane@Example.com"})
+		if usr.Email != "jane@example.com" {
+			t.Fatalf("expected the email in lower case, got %q", usr.Email)
+		}
+
+		_, _, err := f.us.Login(ctx, "test", "JANE@example.com", "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := f.us.ReadByEmail(ctx, "jane@EXAMPLE.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.ID != usr.ID {
+			t.Fatalf("expected user %d, got %d", usr.ID, u.ID)
+		}
+
+		_, err = f.us.Create(ctx, User{Email: "JANE@example.com"}, "password")
+		if !errors.Is(err, ErrEmailExists) {
+			t.Fatalf("expected ErrEmailExists, got %v", err)
+		}
+	})
+}
+
+func TestDefaultCountryCode(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+
+		// without a default country code, the numbers should be in E.164 format
+		_, err := f.us.Create(ctx, User{Email: "jane@example.com", Phone: "098765 43210"}, "password")
+		if !errors.Is(err, ErrInvalidPhone) {
+			t.Fatalf("expected ErrInvalidPhone, got %v", err)
+		}
+
+		f.us.SetDefaultCountryCode("+91")
+		u, err := f.us.Create(ctx, User{Email: "jane@example.com", Phone: "098765 43210"}, "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Phone != "+919876543210" {
+			t.Fatalf("expected the phone in E.164 format, got %q", u.Phone)
+		}
+
+		// the numbers with a country code are not affected by the default
+		u, err = f.us.Create(ctx, User{Email: "john@example.com", Phone: "+1 555 555 0100"}, "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Phone != "+15555550100" {
+			t.Fatalf("expected the phone in E.164 format, got %q", u.Phone)
+		}
+	})
+}
+
+func TestRevokeConcurrentSessions(t *testing.T) {
+	testUsers(t, func(t *testing.T, f usersFixture) {
+		ctx := context.Background()
+		usr, first := f.login(t, User{Email: "jane@example.com"})
+		f.us.cache = slowCache{Cache: f.us.cache}
+
+		tokens := []string{first}
+		wg := sync.WaitGroup{}
+		mu := sync.Mutex{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, token, err := f.us.Login(ctx, "test", usr.Email, "password")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				tokens = append(tokens, token)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		err := f.us.RevokeSessions(ctx, usr.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, token := range tokens {
+			_, err = f.us.AuthUser(ctx, "test", token)
+			if !errors.Is(err, ErrSessionIDExpired) {
+				t.Fatalf("expected all the sessions to be revoked, got %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,275 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+const (
+	cborMaxDepth = 16
+
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+var (
+	errCBORTruncated   = errors.New("webauthn: truncated CBOR data")
+	errCBORUnsupported = errors.New("webauthn: unsupported CBOR data")
+	errCBORTooDeep     = errors.New("webauthn: CBOR data nested too deep")
+)
+
+// cborDecoder decodes the subset of CBOR (RFC 7049) used by WebAuthn. Integers are decoded as
+// int64, byte strings as []byte, text as string, arrays as []interface{} and maps as
+// map[interface{}]interface{}. Indefinite length items are not supported, since CTAP2 requires
+// canonical encoding
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// cborDecode decodes the first CBOR item in data, and returns the item along with the number of
+// bytes it occupied
+func cborDecode(data []byte) (interface{}, int, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, d.pos, nil
+}
+
+func (d *cborDecoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCBORTruncated
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// argument reads the argument of the initial byte, i.e. the value, length or count of the item
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		{
+			return uint64(info), nil
+		}
+	case info == 24:
+		{
+			b, err := d.next(1)
+			if err != nil {
+				return 0, err
+			}
+			return uint64(b[0]), nil
+		}
+	case info == 25:
+		{
+			b, err := d.next(2)
+			if err != nil {
+				return 0, err
+			}
+			return uint64(binary.BigEndian.Uint16(b)), nil
+		}
+	case info == 26:
+		{
+			b, err := d.next(4)
+			if err != nil {
+				return 0, err
+			}
+			return uint64(binary.BigEndian.Uint32(b)), nil
+		}
+	case info == 27:
+		{
+			b, err := d.next(8)
+			if err != nil {
+				return 0, err
+			}
+			return binary.BigEndian.Uint64(b), nil
+		}
+	}
+	return 0, errCBORUnsupported
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, errCBORTooDeep
+	}
+
+	ib, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	major, info := ib[0]>>5, ib[0]&0x1f
+
+	if major == cborSimple {
+		return d.simple(info)
+	}
+
+	arg, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		{
+			if arg > math.MaxInt64 {
+				return nil, errCBORUnsupported
+			}
+			return int64(arg), nil
+		}
+	case cborNegInt:
+		{
+			if arg > math.MaxInt64 {
+				return nil, errCBORUnsupported
+			}
+			return -1 - int64(arg), nil
+		}
+	case cborBytes:
+		{
+			b, err := d.next(arg)
+			if err != nil {
+				return nil, err
+			}
+			return append([]byte(nil), b...), nil
+		}
+	case cborText:
+		{
+			b, err := d.next(arg)
+			if err != nil {
+				return nil, err
+			}
+			return string(b), nil
+		}
+	case cborArray:
+		{
+			// every item takes at least 1 byte
+			if arg > uint64(len(d.data)-d.pos) {
+				return nil, errCBORTruncated
+			}
+			list := make([]interface{}, 0, arg)
+			for i := uint64(0); i < arg; i++ {
+				v, err := d.decode(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, nil
+		}
+	case cborMap:
+		{
+			if arg > uint64(len(d.data)-d.pos)/2 {
+				return nil, errCBORTruncated
+			}
+			m := make(map[interface{}]interface{}, arg)
+			for i := uint64(0); i < arg; i++ {
+				k, err := d.decode(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				switch k.(type) {
+				case int64, string:
+				default:
+					{
+						return nil, errCBORUnsupported
+					}
+				}
+
+				v, err := d.decode(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				m[k] = v
+			}
+			return m, nil
+		}
+	case cborTag:
+		{
+			// tags only add semantics to the enclosed item, which are not required by WebAuthn
+			return d.decode(depth + 1)
+		}
+	}
+
+	return nil, errCBORUnsupported
+}
+
+func (d *cborDecoder) simple(info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		{
+			return false, nil
+		}
+	case 21:
+		{
+			return true, nil
+		}
+	case 22, 23:
+		{
+			return nil, nil
+		}
+	case 25:
+		{
+			b, err := d.next(2)
+			if err != nil {
+				return nil, err
+			}
+			return halfFloat(binary.BigEndian.Uint16(b)), nil
+		}
+	case 26:
+		{
+			b, err := d.next(4)
+			if err != nil {
+				return nil, err
+			}
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		}
+	case 27:
+		{
+			b, err := d.next(8)
+			if err != nil {
+				return nil, err
+			}
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+		}
+	}
+	return nil, errCBORUnsupported
+}
+
+// halfFloat converts an IEEE 754 half precision float to float64
+func halfFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+
+	val := 0.0
+	switch exp {
+	case 0:
+		{
+			val = math.Ldexp(mant, -24)
+		}
+	case 31:
+		{
+			if mant == 0 {
+				val = math.Inf(1)
+			} else {
+				val = math.NaN()
+			}
+		}
+	default:
+		{
+			val = math.Ldexp(mant+1024, exp-25)
+		}
+	}
+
+	if h&0x8000 != 0 {
+		return -val
+	}
+	return val
+}
@@ -0,0 +1,211 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+)
+
+// COSE algorithm identifiers, https://www.iana.org/assignments/cose/cose.xhtml#algorithms
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// COSE key parameters & values used by WebAuthn, RFC 8152
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseEC2Curve = -1
+	coseEC2X     = -2
+	coseEC2Y     = -3
+	coseOKPCurve = -1
+	coseOKPX     = -2
+	coseRSAN     = -1
+	coseRSAE     = -2
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+var (
+	// ErrUnsupportedAlgorithm is returned when the credential uses an algorithm other than
+	// ES256, RS256 or EdDSA
+	ErrUnsupportedAlgorithm = apperr.New(apperr.CodeInvalidInput, "Sorry, the authenticator uses an unsupported algorithm")
+
+	errInvalidKey       = errors.New("webauthn: invalid COSE public key")
+	errInvalidSignature = errors.New("webauthn: invalid signature")
+)
+
+// supportedAlgorithms are the algorithms accepted, in the order of preference
+var supportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// publicKey is a credential public key decoded from its COSE representation
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+func mapInt(m map[interface{}]interface{}, k int64) (int64, bool) {
+	v, ok := m[k].(int64)
+	return v, ok
+}
+
+func mapBytes(m map[interface{}]interface{}, k int64) ([]byte, bool) {
+	v, ok := m[k].([]byte)
+	return v, ok && len(v) > 0
+}
+
+// parsePublicKey decodes a COSE_Key (RFC 8152, section 7)
+func parsePublicKey(b []byte) (*publicKey, error) {
+	v, _, err := cborDecode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, errInvalidKey
+	}
+
+	kty, _ := mapInt(m, coseKeyType)
+	alg, _ := mapInt(m, coseAlgorithm)
+	pk := &publicKey{alg: alg}
+
+	switch {
+	case alg == AlgES256 && kty == coseKeyTypeEC2:
+		{
+			crv, _ := mapInt(m, coseEC2Curve)
+			x, okX := mapBytes(m, coseEC2X)
+			y, okY := mapBytes(m, coseEC2Y)
+			if crv != coseCurveP256 || !okX || !okY {
+				return nil, errInvalidKey
+			}
+
+			key := &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+			if !key.Curve.IsOnCurve(key.X, key.Y) {
+				return nil, errInvalidKey
+			}
+			pk.key = key
+		}
+	case alg == AlgRS256 && kty == coseKeyTypeRSA:
+		{
+			n, okN := mapBytes(m, coseRSAN)
+			e, okE := mapBytes(m, coseRSAE)
+			if !okN || !okE || len(e) > 4 {
+				return nil, errInvalidKey
+			}
+
+			exp := int(new(big.Int).SetBytes(e).Int64())
+			key := &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: exp,
+			}
+			if key.N.BitLen() < 2048 || exp < 3 {
+				return nil, errInvalidKey
+			}
+			pk.key = key
+		}
+	case alg == AlgEdDSA && kty == coseKeyTypeOKP:
+		{
+			crv, _ := mapInt(m, coseOKPCurve)
+			x, okX := mapBytes(m, coseOKPX)
+			if crv != coseCurveEd25519 || !okX || len(x) != ed25519.PublicKeySize {
+				return nil, errInvalidKey
+			}
+			pk.key = ed25519.PublicKey(x)
+		}
+	default:
+		{
+			return nil, ErrUnsupportedAlgorithm
+		}
+	}
+
+	return pk, nil
+}
+
+func (pk *publicKey) verify(msg, sig []byte) error {
+	return verifySignature(pk.alg, pk.key, msg, sig)
+}
+
+// keyMatches returns true if the key is of the type (& curve) used by the algorithm
+func keyMatches(alg int64, key crypto.PublicKey) bool {
+	switch alg {
+	case AlgES256:
+		{
+			k, ok := key.(*ecdsa.PublicKey)
+			return ok && k.Curve == elliptic.P256()
+		}
+	case AlgRS256:
+		{
+			_, ok := key.(*rsa.PublicKey)
+			return ok
+		}
+	case AlgEdDSA:
+		{
+			_, ok := key.(ed25519.PublicKey)
+			return ok
+		}
+	}
+	return false
+}
+
+// verifySignature verifies the signature of msg, generated using the algorithm alg
+func verifySignature(alg int64, key crypto.PublicKey, msg, sig []byte) error {
+	switch alg {
+	case AlgES256:
+		{
+			k, ok := key.(*ecdsa.PublicKey)
+			if !ok {
+				return errInvalidKey
+			}
+			digest := sha256.Sum256(msg)
+			if !ecdsa.VerifyASN1(k, digest[:], sig) {
+				return errInvalidSignature
+			}
+			return nil
+		}
+	case AlgRS256:
+		{
+			k, ok := key.(*rsa.PublicKey)
+			if !ok {
+				return errInvalidKey
+			}
+			digest := sha256.Sum256(msg)
+			err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig)
+			if err != nil {
+				return errInvalidSignature
+			}
+			return nil
+		}
+	case AlgEdDSA:
+		{
+			k, ok := key.(ed25519.PublicKey)
+			if !ok {
+				return errInvalidKey
+			}
+			if !ed25519.Verify(k, msg, sig) {
+				return errInvalidSignature
+			}
+			return nil
+		}
+	}
+	return ErrUnsupportedAlgorithm
+}
@@ -0,0 +1,211 @@
+package webauthn
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+)
+
+const (
+	credentialsTable = "webauthnCredentials"
+
+	credentialColumns = "id,userid,name,credentialid,publickey,algorithm,signcount,aaguid,attestation,userverified,createdat,lastusedat"
+)
+
+type store interface {
+	Create(ctx context.Context, c Credential) (*Credential, error)
+	ReadByCredentialID(ctx context.Context, credentialID []byte) (*Credential, error)
+	List(ctx context.Context, userID int64) ([]Credential, error)
+	UpdateUsage(ctx context.Context, id int64, signCount uint32, usedAt time.Time) error
+	Delete(ctx context.Context, userID, id int64) error
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type dbStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// newStore returns the store of the driver
+func newStore(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB) store {
+	if driver == database.SQLite {
+		return &sqliteStore{
+			appCtx: appCtx,
+			db:     sdb,
+		}
+	}
+	return &dbStore{
+		appCtx: appCtx,
+		db:     sdb,
+	}
+}
+
+func (dbs *dbStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, fmt.Sprintf("$%d", i+1))
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func scan(row scanner) (*Credential, error) {
+	c := Credential{}
+	credentialID, publicKey, aaguid := []byte{}, []byte{}, []byte{}
+	signCount := int64(0)
+	createdAt := pq.NullTime{}
+	lastUsedAt := pq.NullTime{}
+
+	err := row.Scan(
+		&c.ID,
+		&c.UserID,
+		&c.Name,
+		&credentialID,
+		&publicKey,
+		&c.Algorithm,
+		&signCount,
+		&aaguid,
+		&c.Attestation,
+		&c.UserVerified,
+		&createdAt,
+		&lastUsedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCredentialNotFound
+		}
+		return nil, err
+	}
+
+	c.CredentialID = credentialID
+	c.PublicKey = publicKey
+	c.AAGUID = aaguid
+	c.SignCount = uint32(signCount)
+	if createdAt.Valid {
+		c.CreatedAt = &createdAt.Time
+	}
+	if lastUsedAt.Valid {
+		c.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return &c, nil
+}
+
+func (dbs *dbStore) Create(ctx context.Context, c Credential) (*Credential, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s RETURNING id",
+		credentialsTable,
+		dbs.prepColVals(
+			"userid",
+			"name",
+			"credentialid",
+			"publickey",
+			"algorithm",
+			"signcount",
+			"aaguid",
+			"attestation",
+			"userverified",
+			"createdat",
+		),
+	)
+
+	err := dbs.db.QueryRowContext(
+		ctx,
+		stmt,
+		c.UserID,
+		c.Name,
+		[]byte(c.CredentialID),
+		[]byte(c.PublicKey),
+		c.Algorithm,
+		int64(c.SignCount),
+		[]byte(c.AAGUID),
+		c.Attestation,
+		c.UserVerified,
+		c.CreatedAt,
+	).Scan(&c.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (dbs *dbStore) ReadByCredentialID(ctx context.Context, credentialID []byte) (*Credential, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE credentialid=$1",
+		credentialColumns,
+		credentialsTable,
+	)
+
+	return scan(dbs.db.QueryRowContext(ctx, stmt, credentialID))
+}
+
+func (dbs *dbStore) List(ctx context.Context, userID int64) ([]Credential, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE userid=$1 ORDER BY id",
+		credentialColumns,
+		credentialsTable,
+	)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Credential, 0)
+	for rows.Next() {
+		c, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *c)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) UpdateUsage(ctx context.Context, id int64, signCount uint32, usedAt time.Time) error {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET signcount=$1, lastusedat=$2 WHERE id=$3",
+		credentialsTable,
+	)
+
+	_, err := dbs.db.ExecContext(ctx, stmt, int64(signCount), usedAt, id)
+	return err
+}
+
+func (dbs *dbStore) Delete(ctx context.Context, userID, id int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=$1 AND userid=$2", credentialsTable)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrCredentialNotFound
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package webauthn
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+)
+
+// sqliteStore is the store of SQLite, it behaves exactly like dbStore
+type sqliteStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// sqliteTime returns the time in UTC truncated to seconds, as stored by Postgres. SQLite stores
+// the times as text, which are compared correctly only if they're all in the same time zone
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Truncate(time.Second)
+}
+
+func (ss *sqliteStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, "?")
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func (ss *sqliteStore) Create(ctx context.Context, c Credential) (*Credential, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		credentialsTable,
+		ss.prepColVals(
+			"userid",
+			"name",
+			"credentialid",
+			"publickey",
+			"algorithm",
+			"signcount",
+			"aaguid",
+			"attestation",
+			"userverified",
+			"createdat",
+		),
+	)
+
+	result, err := ss.db.ExecContext(
+		ctx,
+		stmt,
+		c.UserID,
+		c.Name,
+		[]byte(c.CredentialID),
+		[]byte(c.PublicKey),
+		c.Algorithm,
+		int64(c.SignCount),
+		[]byte(c.AAGUID),
+		c.Attestation,
+		c.UserVerified,
+		sqliteTime(c.CreatedAt),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (ss *sqliteStore) ReadByCredentialID(ctx context.Context, credentialID []byte) (*Credential, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE credentialid=?",
+		credentialColumns,
+		credentialsTable,
+	)
+
+	return scan(ss.db.QueryRowContext(ctx, stmt, credentialID))
+}
+
+func (ss *sqliteStore) List(ctx context.Context, userID int64) ([]Credential, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE userid=? ORDER BY id",
+		credentialColumns,
+		credentialsTable,
+	)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Credential, 0)
+	for rows.Next() {
+		c, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *c)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) UpdateUsage(ctx context.Context, id int64, signCount uint32, usedAt time.Time) error {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET signcount=?, lastusedat=? WHERE id=?",
+		credentialsTable,
+	)
+
+	_, err := ss.db.ExecContext(ctx, stmt, int64(signCount), sqliteTime(&usedAt), id)
+	return err
+}
+
+func (ss *sqliteStore) Delete(ctx context.Context, userID, id int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=? AND userid=?", credentialsTable)
+
+	result, err := ss.db.ExecContext(ctx, stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrCredentialNotFound
+	}
+	return nil
+}
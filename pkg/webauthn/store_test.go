@@ -0,0 +1,100 @@
+package webauthn
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+func TestStoreCredentials(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		st := newStore(appcontext.New(logger.New()), driver, db)
+		userID := databasetest.User(t, driver, db, "jane@example.com")
+		otherUserID := databasetest.User(t, driver, db, "john@example.com")
+
+		now := time.Now()
+		create := func(userID int64, credentialID string) *Credential {
+			t.Helper()
+
+			c, err := st.Create(ctx, Credential{
+				UserID:       userID,
+				Name:         credentialID,
+				CredentialID: Base64URL(credentialID),
+				PublicKey:    Base64URL("public key of " + credentialID),
+				Algorithm:    AlgES256,
+				SignCount:    1,
+				AAGUID:       make([]byte, 16),
+				Attestation:  "none",
+				UserVerified: true,
+				CreatedAt:    &now,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return c
+		}
+
+		first := create(userID, "first")
+		second := create(userID, "second")
+		create(otherUserID, "other")
+
+		c, err := st.ReadByCredentialID(ctx, []byte("first"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.ID != first.ID || c.UserID != userID || !bytes.Equal(c.PublicKey, first.PublicKey) ||
+			c.Algorithm != AlgES256 || c.SignCount != 1 || !c.UserVerified || c.Attestation != "none" {
+			t.Fatalf("expected %+v, got %+v", first, c)
+		}
+
+		_, err = st.ReadByCredentialID(ctx, []byte("none"))
+		if !errors.Is(err, ErrCredentialNotFound) {
+			t.Fatalf("expected ErrCredentialNotFound, got %v", err)
+		}
+
+		list, err := st.List(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID {
+			t.Fatalf("expected the credentials of the user, got %+v", list)
+		}
+
+		err = st.UpdateUsage(ctx, first.ID, 42, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, err = st.ReadByCredentialID(ctx, []byte("first"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.SignCount != 42 || c.LastUsedAt == nil {
+			t.Fatalf("expected the sign count & usage to be updated, got %+v", c)
+		}
+
+		err = st.Delete(ctx, otherUserID, first.ID)
+		if !errors.Is(err, ErrCredentialNotFound) {
+			t.Fatalf("expected the credential of another user to be not found, got %v", err)
+		}
+
+		err = st.Delete(ctx, userID, first.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = st.ReadByCredentialID(ctx, []byte("first"))
+		if !errors.Is(err, ErrCredentialNotFound) {
+			t.Fatalf("expected ErrCredentialNotFound, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,732 @@
+// Package webauthn implements WebAuthn (https://www.w3.org/TR/webauthn-2/) registration and
+// assertion ceremonies, to use passkeys & security keys as an authenticator
+package webauthn
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+const (
+	challengeLength = 32
+
+	// authenticator data flags
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+	flagExtensions   = 0x80
+
+	// UserVerificationRequired rejects authenticators which did not verify the user (PIN, biometrics)
+	UserVerificationRequired = "required"
+	// UserVerificationPreferred requests user verification, but does not require it
+	UserVerificationPreferred = "preferred"
+	// UserVerificationDiscouraged does not request user verification
+	UserVerificationDiscouraged = "discouraged"
+
+	attestationNone   = "none"
+	attestationPacked = "packed"
+)
+
+var (
+	// oidAAGUID is the certificate extension carrying the AAGUID of the authenticator
+	oidAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+	ErrUnexpected          = apperr.New(apperr.CodeInternal, "Sorry, an unexpected error occurred")
+	ErrNoChallenge         = apperr.New(apperr.CodeInvalidInput, "Sorry, the ceremony has expired or was never started")
+	ErrInvalidClientData   = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid client data received")
+	ErrInvalidAuthData     = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid authenticator data received")
+	ErrInvalidAttestation  = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/unsupported attestation received")
+	ErrInvalidSignature    = apperr.New(apperr.CodePermissionDenied, "Sorry, the signature could not be verified")
+	ErrUserNotVerified     = apperr.New(apperr.CodePermissionDenied, "Sorry, the authenticator did not verify the user")
+	ErrCredentialExists    = apperr.New(apperr.CodeConflict, "Sorry, the authenticator is already registered")
+	ErrCredentialNotFound  = apperr.New(apperr.CodeNotFound, "Sorry, the authenticator is not registered")
+	ErrSignCountRegression = apperr.New(apperr.CodePermissionDenied, "Sorry, the authenticator may have been cloned")
+)
+
+// Base64URL is a byte slice which is encoded as unpadded base64url in JSON, as used by WebAuthn
+type Base64URL []byte
+
+// MarshalJSON implements json.Marshaler
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, it accepts padded values as well
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	str := ""
+	err := json.Unmarshal(data, &str)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(str, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Config has all the configurations of the relying party, i.e. Padlock
+type Config struct {
+	// RPID is the relying party ID, the effective domain of Padlock. e.g. padlock.dev
+	RPID string
+	// RPName is the human friendly name of the relying party
+	RPName string
+	// Origins are the origins from which the ceremonies are allowed. e.g. https://padlock.dev
+	Origins []string
+	// UserVerification is one of required, preferred or discouraged
+	UserVerification string
+	// Timeout is the duration within which a ceremony should be completed
+	Timeout time.Duration
+}
+
+// Credential is a public key credential registered by a user
+type Credential struct {
+	ID     int64 `json:"id,omitempty"`
+	UserID int64 `json:"userId,omitempty"`
+	// Name is a human friendly name for the authenticator
+	Name string `json:"name,omitempty"`
+	// CredentialID is the ID generated by the authenticator
+	CredentialID Base64URL `json:"credentialId,omitempty"`
+	// PublicKey is the COSE encoded public key of the credential
+	PublicKey Base64URL `json:"-"`
+	Algorithm int64     `json:"algorithm,omitempty"`
+	// SignCount is the signature counter last reported by the authenticator
+	SignCount uint32 `json:"signCount"`
+	// AAGUID identifies the model of the authenticator
+	AAGUID Base64URL `json:"aaguid,omitempty"`
+	// Attestation is the attestation format provided while registering
+	Attestation string `json:"attestation,omitempty"`
+	// UserVerified is true if the user was verified by the authenticator while registering
+	UserVerified bool       `json:"userVerified"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+	LastUsedAt   *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+type rpEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+type credentialParam struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type credentialDescriptor struct {
+	Type string    `json:"type"`
+	ID   Base64URL `json:"id"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey,omitempty"`
+	UserVerification string `json:"userVerification,omitempty"`
+}
+
+// CreationOptions are the options to be passed to navigator.credentials.create()
+type CreationOptions struct {
+	Challenge              Base64URL              `json:"challenge"`
+	RP                     rpEntity               `json:"rp"`
+	User                   userEntity             `json:"user"`
+	PubKeyCredParams       []credentialParam      `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation,omitempty"`
+}
+
+// RequestOptions are the options to be passed to navigator.credentials.get()
+type RequestOptions struct {
+	Challenge        Base64URL              `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout,omitempty"`
+	AllowCredentials []credentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification,omitempty"`
+}
+
+// RegistrationResponse is the PublicKeyCredential returned by navigator.credentials.create()
+type RegistrationResponse struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId"`
+	Type     string    `json:"type"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON"`
+		AttestationObject Base64URL `json:"attestationObject"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the PublicKeyCredential returned by navigator.credentials.get()
+type AssertionResponse struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId"`
+	Type     string    `json:"type"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON"`
+		AuthenticatorData Base64URL `json:"authenticatorData"`
+		Signature         Base64URL `json:"signature"`
+		UserHandle        Base64URL `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// AssertionResult is the outcome of a successful assertion
+type AssertionResult struct {
+	CredentialID Base64URL `json:"credentialId"`
+	UserVerified bool      `json:"userVerified"`
+	SignCount    uint32    `json:"signCount"`
+}
+
+// clientData is the CollectedClientData, https://www.w3.org/TR/webauthn-2/#dictdef-collectedclientdata
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// authData is the parsed authenticator data, https://www.w3.org/TR/webauthn-2/#sctn-authenticator-data
+type authData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// ceremony is the state of an ongoing ceremony, stored in the cache
+type ceremony struct {
+	Challenge []byte
+	ExpiresAt time.Time
+}
+
+// WebAuthn handles all the service methods made available by this package
+type WebAuthn struct {
+	appCtx *appcontext.AppContext
+	cfg    Config
+	store  store
+	cache  cache.Cache
+}
+
+func registrationKey(userID int64) string {
+	return fmt.Sprintf("webauthn:registration:%d", userID)
+}
+
+func assertionKey(userID int64) string {
+	return fmt.Sprintf("webauthn:assertion:%d", userID)
+}
+
+// userHandle is the opaque user ID shared with the authenticator
+func userHandle(userID int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(userID))
+	return b
+}
+
+func (wa *WebAuthn) unexpected(err error) error {
+	if wa.appCtx.Logging {
+		wa.appCtx.Logger.Error(err)
+	}
+	return ErrUnexpected
+}
+
+func (wa *WebAuthn) descriptors(ctx context.Context, userID int64) ([]credentialDescriptor, error) {
+	creds, err := wa.store.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]credentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		list = append(list, credentialDescriptor{Type: "public-key", ID: c.CredentialID})
+	}
+	return list, nil
+}
+
+// begin generates & stores a new challenge for the ceremony
+func (wa *WebAuthn) begin(key string) ([]byte, error) {
+	challenge := make([]byte, challengeLength)
+	_, err := rand.Read(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	err = wa.cache.Set(
+		key,
+		ceremony{Challenge: challenge, ExpiresAt: time.Now().Add(wa.cfg.Timeout)},
+		wa.cfg.Timeout,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// challenge returns the challenge of the ongoing ceremony, and ends the ceremony. A challenge
+// can only be used once
+func (wa *WebAuthn) challenge(key string) ([]byte, error) {
+	c := &ceremony{}
+	err := wa.cache.Get(key, c)
+	if err != nil {
+		if err == cache.ErrNotFound {
+			return nil, ErrNoChallenge
+		}
+		return nil, wa.unexpected(err)
+	}
+
+	err = wa.cache.Delete(key)
+	if err != nil {
+		return nil, wa.unexpected(err)
+	}
+	return c.Challenge, nil
+}
+
+// verifyClientData verifies the type, challenge & origin of the client data
+func (wa *WebAuthn) verifyClientData(raw []byte, typ string, challenge []byte) error {
+	cd := clientData{}
+	err := json.Unmarshal(raw, &cd)
+	if err != nil {
+		return ErrInvalidClientData
+	}
+
+	if cd.Type != typ {
+		return ErrInvalidClientData
+	}
+
+	received, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(cd.Challenge, "="))
+	if err != nil || !bytes.Equal(received, challenge) {
+		return ErrInvalidClientData
+	}
+
+	for _, origin := range wa.cfg.Origins {
+		if cd.Origin == origin {
+			return nil
+		}
+	}
+	return ErrInvalidClientData
+}
+
+// verifyAuthData verifies the RP ID hash & user presence/verification flags
+func (wa *WebAuthn) verifyAuthData(ad *authData) error {
+	rpIDHash := sha256.Sum256([]byte(wa.cfg.RPID))
+	if !bytes.Equal(ad.rpIDHash, rpIDHash[:]) {
+		return ErrInvalidAuthData
+	}
+
+	if ad.flags&flagUserPresent == 0 {
+		return ErrInvalidAuthData
+	}
+
+	if wa.cfg.UserVerification == UserVerificationRequired && ad.flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+func parseAuthData(b []byte) (*authData, error) {
+	// rpIdHash (32) + flags (1) + signCount (4)
+	if len(b) < 37 {
+		return nil, ErrInvalidAuthData
+	}
+
+	ad := &authData{
+		rpIDHash:  b[:32],
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	rest := b[37:]
+
+	if ad.flags&flagAttested != 0 {
+		// aaguid (16) + credentialIdLength (2)
+		if len(rest) < 18 {
+			return nil, ErrInvalidAuthData
+		}
+		ad.aaguid = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLen {
+			return nil, ErrInvalidAuthData
+		}
+		ad.credentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		_, n, err := cborDecode(rest)
+		if err != nil {
+			return nil, ErrInvalidAuthData
+		}
+		ad.publicKey = rest[:n]
+		rest = rest[n:]
+	}
+
+	if ad.flags&flagExtensions != 0 {
+		_, n, err := cborDecode(rest)
+		if err != nil {
+			return nil, ErrInvalidAuthData
+		}
+		rest = rest[n:]
+	}
+
+	if len(rest) != 0 {
+		return nil, ErrInvalidAuthData
+	}
+
+	return ad, nil
+}
+
+// verifyPacked verifies a "packed" attestation statement, https://www.w3.org/TR/webauthn-2/#sctn-packed-attestation.
+// Attestation certificates are not verified against a trust anchor, since Padlock does not
+// restrict the authenticator models allowed
+func verifyPacked(stmt map[interface{}]interface{}, rawAuthData []byte, ad *authData, pk *publicKey, clientDataHash []byte) error {
+	alg, ok := stmt["alg"].(int64)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+	sig, ok := stmt["sig"].([]byte)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash...)
+
+	x5c, ok := stmt["x5c"].([]interface{})
+	if !ok || len(x5c) == 0 {
+		// self attestation, signed using the credential private key
+		if alg != pk.alg {
+			return ErrInvalidAttestation
+		}
+		if pk.verify(signed, sig) != nil {
+			return ErrInvalidAttestation
+		}
+		return nil
+	}
+
+	der, ok := x5c[0].([]byte)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return ErrInvalidAttestation
+	}
+
+	if cert.Version != 3 || cert.IsCA {
+		return ErrInvalidAttestation
+	}
+
+	// the algorithm is chosen by the authenticator, so it should be the one of the certificate's key
+	if !keyMatches(alg, cert.PublicKey) {
+		return ErrInvalidAttestation
+	}
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidAAGUID) {
+			continue
+		}
+		aaguid := []byte{}
+		_, err = asn1.Unmarshal(ext.Value, &aaguid)
+		if err != nil || !bytes.Equal(aaguid, ad.aaguid) {
+			return ErrInvalidAttestation
+		}
+	}
+
+	if verifySignature(alg, cert.PublicKey, signed, sig) != nil {
+		return ErrInvalidAttestation
+	}
+	return nil
+}
+
+// BeginRegistration starts the registration ceremony of a new authenticator for the user
+func (wa *WebAuthn) BeginRegistration(ctx context.Context, u *users.User) (*CreationOptions, error) {
+	exclude, err := wa.descriptors(ctx, u.ID)
+	if err != nil {
+		return nil, wa.unexpected(err)
+	}
+
+	challenge, err := wa.begin(registrationKey(u.ID))
+	if err != nil {
+		return nil, wa.unexpected(err)
+	}
+
+	params := make([]credentialParam, 0, len(supportedAlgorithms))
+	for _, alg := range supportedAlgorithms {
+		params = append(params, credentialParam{Type: "public-key", Alg: alg})
+	}
+
+	name := u.Name
+	if name == "" {
+		name = u.Email
+	}
+
+	return &CreationOptions{
+		Challenge: challenge,
+		RP: rpEntity{
+			ID:   wa.cfg.RPID,
+			Name: wa.cfg.RPName,
+		},
+		User: userEntity{
+			ID:          userHandle(u.ID),
+			Name:        u.Email,
+			DisplayName: name,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            int64(wa.cfg.Timeout / time.Millisecond),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: wa.cfg.UserVerification,
+		},
+		Attestation: "direct",
+	}, nil
+}
+
+// FinishRegistration verifies the response of the authenticator and registers the credential
+func (wa *WebAuthn) FinishRegistration(ctx context.Context, u *users.User, name string, resp RegistrationResponse) (*Credential, error) {
+	challenge, err := wa.challenge(registrationKey(u.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = wa.verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	attObj, _, err := cborDecode(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrInvalidAttestation
+	}
+	att, ok := attObj.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidAttestation
+	}
+	format, _ := att["fmt"].(string)
+	stmt, _ := att["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := att["authData"].([]byte)
+
+	ad, err := parseAuthData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = wa.verifyAuthData(ad)
+	if err != nil {
+		return nil, err
+	}
+
+	if ad.flags&flagAttested == 0 || len(ad.credentialID) == 0 {
+		return nil, ErrInvalidAuthData
+	}
+
+	pk, err := parsePublicKey(ad.publicKey)
+	if err != nil {
+		if err == ErrUnsupportedAlgorithm {
+			return nil, err
+		}
+		return nil, ErrInvalidAuthData
+	}
+
+	switch format {
+	case attestationNone:
+		{
+			if len(stmt) != 0 {
+				return nil, ErrInvalidAttestation
+			}
+		}
+	case attestationPacked:
+		{
+			clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+			err = verifyPacked(stmt, rawAuthData, ad, pk, clientDataHash[:])
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		{
+			return nil, ErrInvalidAttestation
+		}
+	}
+
+	_, err = wa.store.ReadByCredentialID(ctx, ad.credentialID)
+	if err == nil {
+		return nil, ErrCredentialExists
+	}
+	if err != ErrCredentialNotFound {
+		return nil, wa.unexpected(err)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Security key"
+	}
+
+	now := time.Now().UTC()
+	cred, err := wa.store.Create(ctx, Credential{
+		UserID:       u.ID,
+		Name:         name,
+		CredentialID: ad.credentialID,
+		PublicKey:    ad.publicKey,
+		Algorithm:    pk.alg,
+		SignCount:    ad.signCount,
+		AAGUID:       ad.aaguid,
+		Attestation:  format,
+		UserVerified: ad.flags&flagUserVerified != 0,
+		CreatedAt:    &now,
+	})
+	if err != nil {
+		return nil, wa.unexpected(err)
+	}
+
+	return cred, nil
+}
+
+// BeginAssertion starts the assertion ceremony, to verify the user using any of the registered
+// authenticators
+func (wa *WebAuthn) BeginAssertion(ctx context.Context, u *users.User) (*RequestOptions, error) {
+	allowed, err := wa.descriptors(ctx, u.ID)
+	if err != nil {
+		return nil, wa.unexpected(err)
+	}
+
+	if len(allowed) == 0 {
+		return nil, ErrCredentialNotFound
+	}
+
+	challenge, err := wa.begin(assertionKey(u.ID))
+	if err != nil {
+		return nil, wa.unexpected(err)
+	}
+
+	return &RequestOptions{
+		Challenge:        challenge,
+		RPID:             wa.cfg.RPID,
+		Timeout:          int64(wa.cfg.Timeout / time.Millisecond),
+		AllowCredentials: allowed,
+		UserVerification: wa.cfg.UserVerification,
+	}, nil
+}
+
+// FinishAssertion verifies the signature generated by the authenticator, and updates the
+// signature counter of the credential
+func (wa *WebAuthn) FinishAssertion(ctx context.Context, u *users.User, resp AssertionResponse) (*AssertionResult, error) {
+	challenge, err := wa.challenge(assertionKey(u.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := wa.store.ReadByCredentialID(ctx, resp.RawID)
+	if err != nil {
+		if err == ErrCredentialNotFound {
+			return nil, err
+		}
+		return nil, wa.unexpected(err)
+	}
+
+	if cred.UserID != u.ID {
+		return nil, ErrCredentialNotFound
+	}
+
+	if len(resp.Response.UserHandle) != 0 && !bytes.Equal(resp.Response.UserHandle, userHandle(u.ID)) {
+		return nil, ErrCredentialNotFound
+	}
+
+	err = wa.verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	ad, err := parseAuthData(resp.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = wa.verifyAuthData(ad)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := parsePublicKey(cred.PublicKey)
+	if err != nil {
+		return nil, wa.unexpected(err)
+	}
+
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	signed := append(append([]byte{}, resp.Response.AuthenticatorData...), clientDataHash[:]...)
+	if pk.verify(signed, resp.Response.Signature) != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	// authenticators which do not implement a counter always report 0
+	if (ad.signCount != 0 || cred.SignCount != 0) && ad.signCount <= cred.SignCount {
+		return nil, ErrSignCountRegression
+	}
+
+	err = wa.store.UpdateUsage(ctx, cred.ID, ad.signCount, time.Now().UTC())
+	if err != nil {
+		return nil, wa.unexpected(err)
+	}
+
+	return &AssertionResult{
+		CredentialID: cred.CredentialID,
+		UserVerified: ad.flags&flagUserVerified != 0,
+		SignCount:    ad.signCount,
+	}, nil
+}
+
+// Credentials returns all the credentials registered by the user
+func (wa *WebAuthn) Credentials(ctx context.Context, u *users.User) ([]Credential, error) {
+	creds, err := wa.store.List(ctx, u.ID)
+	if err != nil {
+		return nil, wa.unexpected(err)
+	}
+	return creds, nil
+}
+
+// DeleteCredential deletes the credential of the user
+func (wa *WebAuthn) DeleteCredential(ctx context.Context, u *users.User, id int64) error {
+	err := wa.store.Delete(ctx, u.ID, id)
+	if err != nil {
+		if err == ErrCredentialNotFound {
+			return err
+		}
+		return wa.unexpected(err)
+	}
+	return nil
+}
+
+func New(appCtx *appcontext.AppContext, driver database.Driver, cfg Config, sdb *sql.DB, c cache.Cache) *WebAuthn {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Minute * 5
+	}
+
+	if cfg.UserVerification == "" {
+		cfg.UserVerification = UserVerificationPreferred
+	}
+
+	return &WebAuthn{
+		appCtx: appCtx,
+		cfg:    cfg,
+		store:  newStore(appCtx, driver, sdb),
+		cache:  c,
+	}
+}
@@ -0,0 +1,535 @@
+package webauthn
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/cache"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+	"github.com/bnkamalesh/padlock/pkg/users"
+)
+
+const (
+	testRPID   = "padlock.dev"
+	testOrigin = "https://padlock.dev"
+)
+
+// cborPairs is a CBOR map, with the pairs encoded in the given order
+type cborPairs [][2]interface{}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		{
+			return []byte{major<<5 | byte(n)}
+		}
+	case n <= 0xff:
+		{
+			return []byte{major<<5 | 24, byte(n)}
+		}
+	case n <= 0xffff:
+		{
+			b := []byte{major<<5 | 25, 0, 0}
+			binary.BigEndian.PutUint16(b[1:], uint16(n))
+			return b
+		}
+	}
+	b := []byte{major<<5 | 26, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(n))
+	return b
+}
+
+// cborEncode encodes the subset of CBOR used by the authenticator
+func cborEncode(v interface{}) []byte {
+	switch val := v.(type) {
+	case int:
+		{
+			if val < 0 {
+				return cborHead(cborNegInt, uint64(-1-val))
+			}
+			return cborHead(cborUint, uint64(val))
+		}
+	case []byte:
+		{
+			return append(cborHead(cborBytes, uint64(len(val))), val...)
+		}
+	case string:
+		{
+			return append(cborHead(cborText, uint64(len(val))), val...)
+		}
+	case []interface{}:
+		{
+			b := cborHead(cborArray, uint64(len(val)))
+			for _, item := range val {
+				b = append(b, cborEncode(item)...)
+			}
+			return b
+		}
+	case cborPairs:
+		{
+			b := cborHead(cborMap, uint64(len(val)))
+			for _, pair := range val {
+				b = append(b, cborEncode(pair[0])...)
+				b = append(b, cborEncode(pair[1])...)
+			}
+			return b
+		}
+	}
+	panic("unsupported CBOR value")
+}
+
+// authenticator is a software ES256 authenticator
+type authenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	aaguid       []byte
+	signCount    uint32
+	// flags are the authenticator data flags other than attested credential data
+	flags  byte
+	rpID   string
+	origin string
+
+	// attCert & attKey are used for packed attestation with x5c, attAlg is the algorithm
+	// reported in the statement
+	attCert []byte
+	attKey  crypto.Signer
+	attAlg  int
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 16)
+	_, err = rand.Read(credentialID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &authenticator{
+		key:          key,
+		credentialID: credentialID,
+		aaguid:       []byte("padlock-test-key"),
+		flags:        flagUserPresent | flagUserVerified,
+		rpID:         testRPID,
+		origin:       testOrigin,
+	}
+}
+
+func (a *authenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	return cborEncode(cborPairs{
+		{coseKeyType, coseKeyTypeEC2},
+		{coseAlgorithm, AlgES256},
+		{coseEC2Curve, coseCurveP256},
+		{coseEC2X, x},
+		{coseEC2Y, y},
+	})
+}
+
+func (a *authenticator) clientData(typ string, challenge []byte) []byte {
+	b, _ := json.Marshal(clientData{
+		Type:      typ,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    a.origin,
+	})
+	return b
+}
+
+func (a *authenticator) authData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	b := append([]byte{}, rpIDHash[:]...)
+
+	flags := a.flags
+	if attested {
+		flags |= flagAttested
+	}
+	b = append(b, flags)
+
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, a.signCount)
+	b = append(b, counter...)
+
+	if attested {
+		idLen := make([]byte, 2)
+		binary.BigEndian.PutUint16(idLen, uint16(len(a.credentialID)))
+		b = append(b, a.aaguid...)
+		b = append(b, idLen...)
+		b = append(b, a.credentialID...)
+		b = append(b, a.coseKey()...)
+	}
+	return b
+}
+
+func sign(t *testing.T, key crypto.Signer, msg []byte) []byte {
+	t.Helper()
+
+	var (
+		sig []byte
+		err error
+	)
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		sig, err = key.Sign(rand.Reader, msg, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(msg)
+		sig, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// register responds to the creation options, using the attestation format (none or packed)
+func (a *authenticator) register(t *testing.T, opts *CreationOptions, format string) RegistrationResponse {
+	t.Helper()
+
+	cdj := a.clientData("webauthn.create", opts.Challenge)
+	ad := a.authData(true)
+	cdHash := sha256.Sum256(cdj)
+	signed := append(append([]byte{}, ad...), cdHash[:]...)
+
+	stmt := cborPairs{}
+	if format == attestationPacked {
+		if a.attCert == nil {
+			stmt = cborPairs{{"alg", AlgES256}, {"sig", sign(t, a.key, signed)}}
+		} else {
+			stmt = cborPairs{
+				{"alg", a.attAlg},
+				{"sig", sign(t, a.attKey, signed)},
+				{"x5c", []interface{}{a.attCert}},
+			}
+		}
+	}
+
+	resp := RegistrationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  "public-key",
+	}
+	resp.Response.ClientDataJSON = cdj
+	resp.Response.AttestationObject = cborEncode(cborPairs{
+		{"fmt", format},
+		{"attStmt", stmt},
+		{"authData", ad},
+	})
+	return resp
+}
+
+// assert responds to the request options, incrementing the signature counter
+func (a *authenticator) assert(t *testing.T, opts *RequestOptions, userID int64) AssertionResponse {
+	t.Helper()
+
+	a.signCount++
+	cdj := a.clientData("webauthn.get", opts.Challenge)
+	ad := a.authData(false)
+	cdHash := sha256.Sum256(cdj)
+
+	resp := AssertionResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  "public-key",
+	}
+	resp.Response.ClientDataJSON = cdj
+	resp.Response.AuthenticatorData = ad
+	resp.Response.Signature = sign(t, a.key, append(append([]byte{}, ad...), cdHash[:]...))
+	resp.Response.UserHandle = userHandle(userID)
+	return resp
+}
+
+// attestWith sets up packed attestation using a self signed certificate of the given key
+func (a *authenticator) attestWith(t *testing.T, key crypto.Signer, alg int) {
+	t.Helper()
+
+	aaguid, err := asn1.Marshal(a.aaguid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Organization:       []string{"Padlock"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Padlock test authenticator",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{{Id: oidAAGUID, Value: aaguid}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.attCert = der
+	a.attKey = key
+	a.attAlg = alg
+}
+
+type webauthnFixture struct {
+	wa   *WebAuthn
+	user *users.User
+}
+
+func testWebAuthn(t *testing.T, userVerification string, fn func(t *testing.T, f webauthnFixture)) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		cfg := Config{
+			RPID:             testRPID,
+			RPName:           "Padlock",
+			Origins:          []string{testOrigin},
+			UserVerification: userVerification,
+		}
+		email := "jane@example.com"
+		fn(t, webauthnFixture{
+			wa:   New(appcontext.New(logger.New()), driver, cfg, db, cache.NewMemory()),
+			user: &users.User{ID: databasetest.User(t, driver, db, email), Email: email},
+		})
+	})
+}
+
+func (f webauthnFixture) register(t *testing.T, a *authenticator, format string) (*Credential, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	opts, err := f.wa.BeginRegistration(ctx, f.user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.wa.FinishRegistration(ctx, f.user, "key", a.register(t, opts, format))
+}
+
+func (f webauthnFixture) assert(t *testing.T, a *authenticator) (*AssertionResult, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	opts, err := f.wa.BeginAssertion(ctx, f.user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.wa.FinishAssertion(ctx, f.user, a.assert(t, opts, f.user.ID))
+}
+
+func TestRegistrationAssertion(t *testing.T) {
+	attKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		x5c    bool
+	}{
+		{name: "none", format: attestationNone},
+		{name: "packed self attestation", format: attestationPacked},
+		{name: "packed x5c", format: attestationPacked, x5c: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testWebAuthn(t, UserVerificationPreferred, func(t *testing.T, f webauthnFixture) {
+				a := newAuthenticator(t)
+				if tt.x5c {
+					a.attestWith(t, attKey, AlgES256)
+				}
+
+				cred, err := f.register(t, a, tt.format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if cred.Attestation != tt.format || cred.Algorithm != AlgES256 || !cred.UserVerified {
+					t.Fatalf("unexpected credential %+v", cred)
+				}
+
+				for i := 1; i <= 2; i++ {
+					result, err := f.assert(t, a)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if result.SignCount != uint32(i) || !result.UserVerified {
+						t.Fatalf("expected sign count %d, got %+v", i, result)
+					}
+				}
+
+				// the same authenticator cannot be registered again
+				_, err = f.register(t, a, tt.format)
+				if !errors.Is(err, ErrCredentialExists) {
+					t.Fatalf("expected ErrCredentialExists, got %v", err)
+				}
+			})
+		})
+	}
+}
+
+func TestSignCountRegression(t *testing.T) {
+	testWebAuthn(t, UserVerificationPreferred, func(t *testing.T, f webauthnFixture) {
+		a := newAuthenticator(t)
+		a.signCount = 10
+		_, err := f.register(t, a, attestationNone)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a cloned authenticator would report a counter less than or equal to the last one
+		a.signCount = 9
+		_, err = f.assert(t, a)
+		if !errors.Is(err, ErrSignCountRegression) {
+			t.Fatalf("expected ErrSignCountRegression, got %v", err)
+		}
+
+		a.signCount = 20
+		result, err := f.assert(t, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.SignCount != 21 {
+			t.Fatalf("expected sign count 21, got %d", result.SignCount)
+		}
+	})
+}
+
+func TestUserVerificationRequired(t *testing.T) {
+	testWebAuthn(t, UserVerificationRequired, func(t *testing.T, f webauthnFixture) {
+		a := newAuthenticator(t)
+		a.flags = flagUserPresent
+		_, err := f.register(t, a, attestationNone)
+		if !errors.Is(err, ErrUserNotVerified) {
+			t.Fatalf("expected ErrUserNotVerified, got %v", err)
+		}
+
+		a.flags = flagUserPresent | flagUserVerified
+		_, err = f.register(t, a, attestationNone)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a.flags = flagUserPresent
+		_, err = f.assert(t, a)
+		if !errors.Is(err, ErrUserNotVerified) {
+			t.Fatalf("expected ErrUserNotVerified, got %v", err)
+		}
+	})
+}
+
+func TestCeremonyMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+		rpID   string
+		err    error
+	}{
+		{name: "origin", origin: "https://padlock.example.com", rpID: testRPID, err: ErrInvalidClientData},
+		{name: "rp ID", origin: testOrigin, rpID: "padlock.example.com", err: ErrInvalidAuthData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testWebAuthn(t, UserVerificationPreferred, func(t *testing.T, f webauthnFixture) {
+				a := newAuthenticator(t)
+				a.origin, a.rpID = tt.origin, tt.rpID
+				_, err := f.register(t, a, attestationNone)
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("registration: expected %v, got %v", tt.err, err)
+				}
+
+				a.origin, a.rpID = testOrigin, testRPID
+				_, err = f.register(t, a, attestationNone)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				a.origin, a.rpID = tt.origin, tt.rpID
+				_, err = f.assert(t, a)
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("assertion: expected %v, got %v", tt.err, err)
+				}
+			})
+		})
+	}
+}
+
+func TestChallengeReuse(t *testing.T) {
+	testWebAuthn(t, UserVerificationPreferred, func(t *testing.T, f webauthnFixture) {
+		ctx := context.Background()
+		a := newAuthenticator(t)
+
+		opts, err := f.wa.BeginRegistration(ctx, f.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp := a.register(t, opts, attestationNone)
+		_, err = f.wa.FinishRegistration(ctx, f.user, "key", resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = f.wa.FinishRegistration(ctx, f.user, "key", resp)
+		if !errors.Is(err, ErrNoChallenge) {
+			t.Fatalf("expected ErrNoChallenge, got %v", err)
+		}
+	})
+}
+
+func TestPackedAttestationAlgorithm(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key  crypto.Signer
+		alg  int
+	}{
+		// the signature is valid, but ES256 requires a P-256 key
+		{name: "ES256 with P-384 certificate", key: p384, alg: AlgES256},
+		{name: "ES256 with Ed25519 certificate", key: edKey, alg: AlgES256},
+		{name: "EdDSA with P-384 certificate", key: p384, alg: AlgEdDSA},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testWebAuthn(t, UserVerificationPreferred, func(t *testing.T, f webauthnFixture) {
+				a := newAuthenticator(t)
+				a.attestWith(t, tt.key, tt.alg)
+
+				_, err := f.register(t, a, attestationPacked)
+				if !errors.Is(err, ErrInvalidAttestation) {
+					t.Fatalf("expected ErrInvalidAttestation, got %v", err)
+				}
+			})
+		})
+	}
+}
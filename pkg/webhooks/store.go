@@ -0,0 +1,463 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+)
+
+const (
+	endpointsTable  = "webhookEndpoints"
+	deliveriesTable = "webhookDeliveries"
+	attemptsTable   = "webhookAttempts"
+
+	endpointColumns = "id,appid,url,secret,events,active,createdat,updatedat"
+	deliveryColumns = "id,endpointid,appid,eventid,event,payload,status,attempts,nextattemptat,createdat,deliveredat"
+	attemptColumns  = "id,deliveryid,statuscode,response,error,duration,createdat"
+)
+
+type store interface {
+	CreateEndpoint(ctx context.Context, e Endpoint) (*Endpoint, error)
+	ReadEndpoint(ctx context.Context, appID, id int64) (*Endpoint, error)
+	ListEndpoints(ctx context.Context, appID int64) ([]Endpoint, error)
+	UpdateEndpoint(ctx context.Context, e Endpoint) (*Endpoint, error)
+	UpdateSecret(ctx context.Context, appID, id int64, secret string, at time.Time) (*Endpoint, error)
+	DeleteEndpoint(ctx context.Context, appID, id int64) error
+
+	CreateDeliveries(ctx context.Context, list []Delivery) error
+	ReadDelivery(ctx context.Context, appID, id int64) (*Delivery, error)
+	ListDeliveries(ctx context.Context, appID, endpointID int64, limit int) ([]Delivery, error)
+	// ClaimDue returns the pending deliveries due at 'now', and pushes their next attempt to
+	// 'lease' so that they're not claimed again in the meanwhile
+	ClaimDue(ctx context.Context, now, lease time.Time, limit int) ([]Delivery, error)
+	// Reschedule sets the next attempt of the pending delivery, without counting it as an attempt
+	Reschedule(ctx context.Context, id int64, at time.Time) error
+	// RecordAttempt logs the attempt & updates the delivery with its outcome
+	RecordAttempt(ctx context.Context, d Delivery, a Attempt) error
+	// Requeue resets the delivery to be attempted again at 'at'
+	Requeue(ctx context.Context, appID, id int64, at time.Time) (*Delivery, error)
+	ListAttempts(ctx context.Context, deliveryID int64) ([]Attempt, error)
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type dbStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// newStore returns the store of the driver
+func newStore(appCtx *appcontext.AppContext, driver database.Driver, sdb *sql.DB) store {
+	if driver == database.SQLite {
+		return &sqliteStore{
+			appCtx: appCtx,
+			db:     sdb,
+		}
+	}
+	return &dbStore{
+		appCtx: appCtx,
+		db:     sdb,
+	}
+}
+
+func (dbs *dbStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, fmt.Sprintf("$%d", i+1))
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func (dbs *dbStore) rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && err != sql.ErrTxDone && dbs.appCtx.Logging {
+		dbs.appCtx.Logger.Error(err)
+	}
+}
+
+// events are stored as a comma separated string
+func joinEvents(events []Event) string {
+	list := make([]string, 0, len(events))
+	for _, e := range events {
+		list = append(list, string(e))
+	}
+	return strings.Join(list, ",")
+}
+
+func splitEvents(str string) []Event {
+	events := make([]Event, 0, len(validEvents))
+	for _, e := range strings.Split(str, ",") {
+		if e != "" {
+			events = append(events, Event(e))
+		}
+	}
+	return events
+}
+
+func scanEndpoint(row scanner) (*Endpoint, error) {
+	e := Endpoint{}
+	events := ""
+	createdAt := pq.NullTime{}
+	updatedAt := pq.NullTime{}
+
+	err := row.Scan(
+		&e.ID,
+		&e.AppID,
+		&e.URL,
+		&e.secret,
+		&events,
+		&e.Active,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	e.Events = splitEvents(events)
+	if createdAt.Valid {
+		e.CreatedAt = &createdAt.Time
+	}
+	if updatedAt.Valid {
+		e.UpdatedAt = &updatedAt.Time
+	}
+
+	return &e, nil
+}
+
+func scanDelivery(row scanner) (*Delivery, error) {
+	d := Delivery{}
+	payload := ""
+	status := ""
+	nextAttemptAt := pq.NullTime{}
+	createdAt := pq.NullTime{}
+	deliveredAt := pq.NullTime{}
+
+	err := row.Scan(
+		&d.ID,
+		&d.EndpointID,
+		&d.AppID,
+		&d.EventID,
+		&d.Event,
+		&payload,
+		&status,
+		&d.AttemptCount,
+		&nextAttemptAt,
+		&createdAt,
+		&deliveredAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	d.Payload = []byte(payload)
+	d.Status = DeliveryStatus(status)
+	if nextAttemptAt.Valid {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if createdAt.Valid {
+		d.CreatedAt = &createdAt.Time
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+
+	return &d, nil
+}
+
+func (dbs *dbStore) listDeliveries(ctx context.Context, stmt string, args ...interface{}) ([]Delivery, error) {
+	rows, err := dbs.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Delivery, 0)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *d)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) CreateEndpoint(ctx context.Context, e Endpoint) (*Endpoint, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s RETURNING id",
+		endpointsTable,
+		dbs.prepColVals("appid", "url", "secret", "events", "active", "createdat", "updatedat"),
+	)
+
+	err := dbs.db.QueryRowContext(
+		ctx,
+		stmt,
+		e.AppID,
+		e.URL,
+		e.secret,
+		joinEvents(e.Events),
+		e.Active,
+		e.CreatedAt,
+		e.UpdatedAt,
+	).Scan(&e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (dbs *dbStore) ReadEndpoint(ctx context.Context, appID, id int64) (*Endpoint, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=$1 AND appid=$2", endpointColumns, endpointsTable)
+	return scanEndpoint(dbs.db.QueryRowContext(ctx, stmt, id, appID))
+}
+
+func (dbs *dbStore) ListEndpoints(ctx context.Context, appID int64) ([]Endpoint, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE appid=$1 ORDER BY id", endpointColumns, endpointsTable)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, appID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Endpoint, 0)
+	for rows.Next() {
+		e, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *e)
+	}
+
+	return list, rows.Err()
+}
+
+func (dbs *dbStore) UpdateEndpoint(ctx context.Context, e Endpoint) (*Endpoint, error) {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET url=$1, events=$2, active=$3, updatedat=$4 WHERE id=$5 AND appid=$6 RETURNING %s",
+		endpointsTable,
+		endpointColumns,
+	)
+
+	return scanEndpoint(
+		dbs.db.QueryRowContext(
+			ctx,
+			stmt,
+			e.URL,
+			joinEvents(e.Events),
+			e.Active,
+			e.UpdatedAt,
+			e.ID,
+			e.AppID,
+		),
+	)
+}
+
+func (dbs *dbStore) UpdateSecret(ctx context.Context, appID, id int64, secret string, at time.Time) (*Endpoint, error) {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET secret=$1, updatedat=$2 WHERE id=$3 AND appid=$4 RETURNING %s",
+		endpointsTable,
+		endpointColumns,
+	)
+
+	return scanEndpoint(
+		dbs.db.QueryRowContext(ctx, stmt, secret, at, id, appID),
+	)
+}
+
+// DeleteEndpoint deletes the endpoint, its deliveries & their attempts are deleted by the
+// foreign key cascade
+func (dbs *dbStore) DeleteEndpoint(ctx context.Context, appID, id int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=$1 AND appid=$2", endpointsTable)
+
+	result, err := dbs.db.ExecContext(ctx, stmt, id, appID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (dbs *dbStore) CreateDeliveries(ctx context.Context, list []Delivery) error {
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer dbs.rollback(tx)
+
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		deliveriesTable,
+		dbs.prepColVals(
+			"endpointid",
+			"appid",
+			"eventid",
+			"event",
+			"payload",
+			"status",
+			"attempts",
+			"nextattemptat",
+			"createdat",
+		),
+	)
+
+	for _, d := range list {
+		_, err = tx.ExecContext(
+			ctx,
+			stmt,
+			d.EndpointID,
+			d.AppID,
+			d.EventID,
+			d.Event,
+			string(d.Payload),
+			d.Status,
+			d.AttemptCount,
+			d.NextAttemptAt,
+			d.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (dbs *dbStore) ReadDelivery(ctx context.Context, appID, id int64) (*Delivery, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=$1 AND appid=$2", deliveryColumns, deliveriesTable)
+	return scanDelivery(dbs.db.QueryRowContext(ctx, stmt, id, appID))
+}
+
+func (dbs *dbStore) ListDeliveries(ctx context.Context, appID, endpointID int64, limit int) ([]Delivery, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE appid=$1 AND endpointid=$2 ORDER BY id DESC LIMIT $3",
+		deliveryColumns,
+		deliveriesTable,
+	)
+	return dbs.listDeliveries(ctx, stmt, appID, endpointID, limit)
+}
+
+func (dbs *dbStore) ClaimDue(ctx context.Context, now, lease time.Time, limit int) ([]Delivery, error) {
+	// SKIP LOCKED lets multiple instances claim different deliveries concurrently
+	stmt := fmt.Sprintf(
+		`UPDATE %s SET nextattemptat=$1 WHERE id IN (
+			SELECT id FROM %s WHERE status=$2 AND nextattemptat <= $3
+			ORDER BY nextattemptat LIMIT $4 FOR UPDATE SKIP LOCKED
+		) RETURNING %s`,
+		deliveriesTable,
+		deliveriesTable,
+		deliveryColumns,
+	)
+	return dbs.listDeliveries(ctx, stmt, lease, StatusPending, now, limit)
+}
+
+func (dbs *dbStore) Reschedule(ctx context.Context, id int64, at time.Time) error {
+	stmt := fmt.Sprintf("UPDATE %s SET nextattemptat=$1 WHERE id=$2 AND status=$3", deliveriesTable)
+	_, err := dbs.db.ExecContext(ctx, stmt, at, id, StatusPending)
+	return err
+}
+
+func (dbs *dbStore) RecordAttempt(ctx context.Context, d Delivery, a Attempt) error {
+	tx, err := dbs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer dbs.rollback(tx)
+
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		attemptsTable,
+		dbs.prepColVals("deliveryid", "statuscode", "response", "error", "duration", "createdat"),
+	)
+	_, err = tx.ExecContext(ctx, stmt, a.DeliveryID, a.StatusCode, a.Response, a.Error, a.Duration, a.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	stmt = fmt.Sprintf(
+		"UPDATE %s SET status=$1, attempts=$2, nextattemptat=$3, deliveredat=$4 WHERE id=$5",
+		deliveriesTable,
+	)
+	_, err = tx.ExecContext(ctx, stmt, d.Status, d.AttemptCount, d.NextAttemptAt, d.DeliveredAt, d.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (dbs *dbStore) Requeue(ctx context.Context, appID, id int64, at time.Time) (*Delivery, error) {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET status=$1, attempts=0, nextattemptat=$2, deliveredat=NULL WHERE id=$3 AND appid=$4 RETURNING %s",
+		deliveriesTable,
+		deliveryColumns,
+	)
+	return scanDelivery(dbs.db.QueryRowContext(ctx, stmt, StatusPending, at, id, appID))
+}
+
+func (dbs *dbStore) ListAttempts(ctx context.Context, deliveryID int64) ([]Attempt, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE deliveryid=$1 ORDER BY id", attemptColumns, attemptsTable)
+
+	rows, err := dbs.db.QueryContext(ctx, stmt, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Attempt, 0)
+	for rows.Next() {
+		a := Attempt{}
+		createdAt := pq.NullTime{}
+		err := rows.Scan(
+			&a.ID,
+			&a.DeliveryID,
+			&a.StatusCode,
+			&a.Response,
+			&a.Error,
+			&a.Duration,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if createdAt.Valid {
+			a.CreatedAt = &createdAt.Time
+		}
+		list = append(list, a)
+	}
+
+	return list, rows.Err()
+}
@@ -0,0 +1,449 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+)
+
+// sqliteStore is the store of SQLite, it behaves exactly like dbStore. SQLite (as bundled) does
+// not support RETURNING, so the rows are read again after writing them
+type sqliteStore struct {
+	appCtx *appcontext.AppContext
+	db     *sql.DB
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// sqliteTime returns the time in UTC truncated to seconds, as stored by Postgres. SQLite stores
+// the times as text, which are compared correctly only if they're all in the same time zone
+func sqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Truncate(time.Second)
+}
+
+// sqliteIn returns the condition matching the column with any of the IDs, since SQLite does
+// not support arrays
+func sqliteIn(col string, ids []int64) (string, []interface{}) {
+	if len(ids) == 0 {
+		return "FALSE", nil
+	}
+
+	vals := make([]string, 0, len(ids))
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		vals = append(vals, "?")
+		args = append(args, id)
+	}
+	return fmt.Sprintf("%s IN (%s)", col, strings.Join(vals, ", ")), args
+}
+
+func (ss *sqliteStore) prepColVals(cols ...string) string {
+	count := len(cols)
+	if count == 0 {
+		return ""
+	}
+
+	vals := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		vals = append(vals, "?")
+	}
+
+	return fmt.Sprintf("(%s) VALUES(%s)", strings.Join(cols, ", "), strings.Join(vals, ", "))
+}
+
+func (ss *sqliteStore) rollback(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && err != sql.ErrTxDone && ss.appCtx.Logging {
+		ss.appCtx.Logger.Error(err)
+	}
+}
+
+func (ss *sqliteStore) listDeliveries(ctx context.Context, q queryer, stmt string, args ...interface{}) ([]Delivery, error) {
+	rows, err := q.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Delivery, 0)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *d)
+	}
+
+	return list, rows.Err()
+}
+
+func (ss *sqliteStore) CreateEndpoint(ctx context.Context, e Endpoint) (*Endpoint, error) {
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		endpointsTable,
+		ss.prepColVals("appid", "url", "secret", "events", "active", "createdat", "updatedat"),
+	)
+
+	result, err := ss.db.ExecContext(
+		ctx,
+		stmt,
+		e.AppID,
+		e.URL,
+		e.secret,
+		joinEvents(e.Events),
+		e.Active,
+		sqliteTime(e.CreatedAt),
+		sqliteTime(e.UpdatedAt),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	e.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (ss *sqliteStore) readEndpoint(ctx context.Context, q queryer, appID, id int64) (*Endpoint, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=? AND appid=?", endpointColumns, endpointsTable)
+	return scanEndpoint(q.QueryRowContext(ctx, stmt, id, appID))
+}
+
+func (ss *sqliteStore) ReadEndpoint(ctx context.Context, appID, id int64) (*Endpoint, error) {
+	return ss.readEndpoint(ctx, ss.db, appID, id)
+}
+
+func (ss *sqliteStore) ListEndpoints(ctx context.Context, appID int64) ([]Endpoint, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE appid=? ORDER BY id", endpointColumns, endpointsTable)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, appID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Endpoint, 0)
+	for rows.Next() {
+		e, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *e)
+	}
+
+	return list, rows.Err()
+}
+
+// updateEndpoint runs the update statement on the endpoint & returns the updated endpoint, or
+// ErrNotFound if the endpoint does not exist
+func (ss *sqliteStore) updateEndpoint(ctx context.Context, appID, id int64, stmt string, args ...interface{}) (*Endpoint, error) {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ss.rollback(tx)
+
+	result, err := tx.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, ErrNotFound
+	}
+
+	e, err := ss.readEndpoint(ctx, tx, appID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, tx.Commit()
+}
+
+func (ss *sqliteStore) UpdateEndpoint(ctx context.Context, e Endpoint) (*Endpoint, error) {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET url=?, events=?, active=?, updatedat=? WHERE id=? AND appid=?",
+		endpointsTable,
+	)
+
+	return ss.updateEndpoint(
+		ctx,
+		e.AppID,
+		e.ID,
+		stmt,
+		e.URL,
+		joinEvents(e.Events),
+		e.Active,
+		sqliteTime(e.UpdatedAt),
+		e.ID,
+		e.AppID,
+	)
+}
+
+func (ss *sqliteStore) UpdateSecret(ctx context.Context, appID, id int64, secret string, at time.Time) (*Endpoint, error) {
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET secret=?, updatedat=? WHERE id=? AND appid=?",
+		endpointsTable,
+	)
+
+	return ss.updateEndpoint(ctx, appID, id, stmt, secret, sqliteTime(&at), id, appID)
+}
+
+// DeleteEndpoint deletes the endpoint, its deliveries & their attempts are deleted by the
+// foreign key cascade
+func (ss *sqliteStore) DeleteEndpoint(ctx context.Context, appID, id int64) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id=? AND appid=?", endpointsTable)
+
+	result, err := ss.db.ExecContext(ctx, stmt, id, appID)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (ss *sqliteStore) CreateDeliveries(ctx context.Context, list []Delivery) error {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer ss.rollback(tx)
+
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		deliveriesTable,
+		ss.prepColVals(
+			"endpointid",
+			"appid",
+			"eventid",
+			"event",
+			"payload",
+			"status",
+			"attempts",
+			"nextattemptat",
+			"createdat",
+		),
+	)
+
+	for _, d := range list {
+		_, err = tx.ExecContext(
+			ctx,
+			stmt,
+			d.EndpointID,
+			d.AppID,
+			d.EventID,
+			d.Event,
+			string(d.Payload),
+			d.Status,
+			d.AttemptCount,
+			sqliteTime(d.NextAttemptAt),
+			sqliteTime(d.CreatedAt),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (ss *sqliteStore) readDelivery(ctx context.Context, q queryer, appID, id int64) (*Delivery, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE id=? AND appid=?", deliveryColumns, deliveriesTable)
+	return scanDelivery(q.QueryRowContext(ctx, stmt, id, appID))
+}
+
+func (ss *sqliteStore) ReadDelivery(ctx context.Context, appID, id int64) (*Delivery, error) {
+	return ss.readDelivery(ctx, ss.db, appID, id)
+}
+
+func (ss *sqliteStore) ListDeliveries(ctx context.Context, appID, endpointID int64, limit int) ([]Delivery, error) {
+	stmt := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE appid=? AND endpointid=? ORDER BY id DESC LIMIT ?",
+		deliveryColumns,
+		deliveriesTable,
+	)
+	return ss.listDeliveries(ctx, ss.db, stmt, appID, endpointID, limit)
+}
+
+// ClaimDue claims the due deliveries within a transaction. SQLite allows only one writer at a
+// time, so the concurrent claims wait for each other rather than claiming the same deliveries
+func (ss *sqliteStore) ClaimDue(ctx context.Context, now, lease time.Time, limit int) ([]Delivery, error) {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ss.rollback(tx)
+
+	stmt := fmt.Sprintf(
+		"SELECT id FROM %s WHERE status=? AND nextattemptat <= ? ORDER BY nextattemptat LIMIT ?",
+		deliveriesTable,
+	)
+	rows, err := tx.QueryContext(ctx, stmt, StatusPending, sqliteTime(&now), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, limit)
+	for rows.Next() {
+		id := int64(0)
+		err = rows.Scan(&id)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return []Delivery{}, nil
+	}
+
+	cond, args := sqliteIn("id", ids)
+	stmt = fmt.Sprintf("UPDATE %s SET nextattemptat=? WHERE %s", deliveriesTable, cond)
+	_, err = tx.ExecContext(ctx, stmt, append([]interface{}{sqliteTime(&lease)}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt = fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY nextattemptat, id", deliveryColumns, deliveriesTable, cond)
+	list, err := ss.listDeliveries(ctx, tx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, tx.Commit()
+}
+
+func (ss *sqliteStore) Reschedule(ctx context.Context, id int64, at time.Time) error {
+	stmt := fmt.Sprintf("UPDATE %s SET nextattemptat=? WHERE id=? AND status=?", deliveriesTable)
+	_, err := ss.db.ExecContext(ctx, stmt, sqliteTime(&at), id, StatusPending)
+	return err
+}
+
+func (ss *sqliteStore) RecordAttempt(ctx context.Context, d Delivery, a Attempt) error {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer ss.rollback(tx)
+
+	stmt := fmt.Sprintf(
+		"INSERT INTO %s %s",
+		attemptsTable,
+		ss.prepColVals("deliveryid", "statuscode", "response", "error", "duration", "createdat"),
+	)
+	_, err = tx.ExecContext(ctx, stmt, a.DeliveryID, a.StatusCode, a.Response, a.Error, a.Duration, sqliteTime(a.CreatedAt))
+	if err != nil {
+		return err
+	}
+
+	stmt = fmt.Sprintf(
+		"UPDATE %s SET status=?, attempts=?, nextattemptat=?, deliveredat=? WHERE id=?",
+		deliveriesTable,
+	)
+	_, err = tx.ExecContext(ctx, stmt, d.Status, d.AttemptCount, sqliteTime(d.NextAttemptAt), sqliteTime(d.DeliveredAt), d.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ss *sqliteStore) Requeue(ctx context.Context, appID, id int64, at time.Time) (*Delivery, error) {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer ss.rollback(tx)
+
+	stmt := fmt.Sprintf(
+		"UPDATE %s SET status=?, attempts=0, nextattemptat=?, deliveredat=NULL WHERE id=? AND appid=?",
+		deliveriesTable,
+	)
+	result, err := tx.ExecContext(ctx, stmt, StatusPending, sqliteTime(&at), id, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, ErrDeliveryNotFound
+	}
+
+	d, err := ss.readDelivery(ctx, tx, appID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, tx.Commit()
+}
+
+func (ss *sqliteStore) ListAttempts(ctx context.Context, deliveryID int64) ([]Attempt, error) {
+	stmt := fmt.Sprintf("SELECT %s FROM %s WHERE deliveryid=? ORDER BY id", attemptColumns, attemptsTable)
+
+	rows, err := ss.db.QueryContext(ctx, stmt, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Attempt, 0)
+	for rows.Next() {
+		a := Attempt{}
+		createdAt := sql.NullTime{}
+		err := rows.Scan(
+			&a.ID,
+			&a.DeliveryID,
+			&a.StatusCode,
+			&a.Response,
+			&a.Error,
+			&a.Duration,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if createdAt.Valid {
+			a.CreatedAt = &createdAt.Time
+		}
+		list = append(list, a)
+	}
+
+	return list, rows.Err()
+}
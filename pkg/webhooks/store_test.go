@@ -0,0 +1,262 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+type fixture struct {
+	st    store
+	appID int64
+	// otherAppID is an app whose endpoints should never be visible through appID
+	otherAppID int64
+}
+
+func testStore(t *testing.T, fn func(t *testing.T, f fixture)) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		orgID := databasetest.Org(t, driver, db, "acme")
+		fn(t, fixture{
+			st:         newStore(appcontext.New(logger.New()), driver, db),
+			appID:      databasetest.App(t, driver, db, orgID, "billing"),
+			otherAppID: databasetest.App(t, driver, db, orgID, "shipping"),
+		})
+	})
+}
+
+func (f fixture) endpoint(t *testing.T, appID int64, url string) *Endpoint {
+	t.Helper()
+
+	now := time.Now()
+	e, err := f.st.CreateEndpoint(context.Background(), Endpoint{
+		AppID:     appID,
+		URL:       url,
+		Events:    []Event{EventSubjectEnrolled, EventSubjectLockedOut},
+		Active:    true,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+		secret:    "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func (f fixture) deliveries(t *testing.T, e *Endpoint, nextAttemptAt ...time.Time) []Delivery {
+	t.Helper()
+
+	now := time.Now()
+	list := make([]Delivery, 0, len(nextAttemptAt))
+	for i := range nextAttemptAt {
+		list = append(list, Delivery{
+			EndpointID:    e.ID,
+			AppID:         e.AppID,
+			EventID:       "evt" + string(rune('a'+i)),
+			Event:         EventSubjectEnrolled,
+			Payload:       []byte(`{"externalId":"jane"}`),
+			Status:        StatusPending,
+			NextAttemptAt: &nextAttemptAt[i],
+			CreatedAt:     &now,
+		})
+	}
+
+	err := f.st.CreateDeliveries(context.Background(), list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := f.st.ListDeliveries(context.Background(), e.AppID, e.ID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+func TestStoreEndpoints(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		first := f.endpoint(t, f.appID, "https://example.com/first")
+		second := f.endpoint(t, f.appID, "https://example.com/second")
+		f.endpoint(t, f.otherAppID, "https://example.com/other")
+
+		e, err := f.st.ReadEndpoint(ctx, f.appID, first.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.URL != first.URL || !e.Active || e.secret != "secret" || len(e.Events) != 2 || e.Events[1] != EventSubjectLockedOut {
+			t.Fatalf("expected %+v, got %+v", first, e)
+		}
+
+		_, err = f.st.ReadEndpoint(ctx, f.otherAppID, first.ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected the endpoint of another app to be not found, got %v", err)
+		}
+
+		list, err := f.st.ListEndpoints(ctx, f.appID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID {
+			t.Fatalf("expected the endpoints of the app, got %+v", list)
+		}
+
+		now := time.Now()
+		first.URL = "https://example.com/updated"
+		first.Events = []Event{EventSubjectUnenrolled}
+		first.Active = false
+		first.UpdatedAt = &now
+		e, err = f.st.UpdateEndpoint(ctx, *first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.URL != first.URL || e.Active || len(e.Events) != 1 || e.Events[0] != EventSubjectUnenrolled {
+			t.Fatalf("expected %+v, got %+v", first, e)
+		}
+
+		e, err = f.st.UpdateSecret(ctx, f.appID, first.ID, "rotated", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.secret != "rotated" {
+			t.Fatalf("expected the secret to be rotated, got %s", e.secret)
+		}
+
+		_, err = f.st.UpdateSecret(ctx, f.otherAppID, first.ID, "rotated", now)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		first.AppID = f.otherAppID
+		_, err = f.st.UpdateEndpoint(ctx, *first)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		err = f.st.DeleteEndpoint(ctx, f.appID, first.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.st.DeleteEndpoint(ctx, f.appID, first.ID)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStoreClaimDue(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		e := f.endpoint(t, f.appID, "https://example.com/hook")
+
+		now := time.Now()
+		list := f.deliveries(t, e, now.Add(-time.Minute), now.Add(-time.Hour), now.Add(time.Hour))
+		// the deliveries are listed latest first
+		notDue, older, due := list[0], list[1], list[2]
+
+		claimed, err := f.st.ClaimDue(ctx, now, now.Add(time.Minute), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := map[int64]bool{}
+		for _, d := range claimed {
+			ids[d.ID] = true
+		}
+		if len(claimed) != 2 || !ids[older.ID] || !ids[due.ID] {
+			t.Fatalf("expected the due deliveries, got %+v", claimed)
+		}
+		for _, d := range claimed {
+			if d.NextAttemptAt == nil || !d.NextAttemptAt.After(now) {
+				t.Fatalf("expected the delivery to be leased, got %+v", d)
+			}
+			if string(d.Payload) != `{"externalId":"jane"}` {
+				t.Fatalf("expected the payload to be intact, got %s", d.Payload)
+			}
+		}
+
+		// the leased deliveries are not claimed again till the lease expires
+		claimed, err = f.st.ClaimDue(ctx, now, now.Add(time.Minute), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(claimed) != 0 {
+			t.Fatalf("expected no deliveries to be claimed, got %+v", claimed)
+		}
+
+		claimed, err = f.st.ClaimDue(ctx, now.Add(2*time.Minute), now.Add(3*time.Minute), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(claimed) != 1 || claimed[0].ID == notDue.ID {
+			t.Fatalf("expected 1 of the expired leases to be claimed, got %+v", claimed)
+		}
+	})
+}
+
+func TestStoreAttempts(t *testing.T) {
+	testStore(t, func(t *testing.T, f fixture) {
+		ctx := context.Background()
+		e := f.endpoint(t, f.appID, "https://example.com/hook")
+
+		now := time.Now()
+		d := f.deliveries(t, e, now)[0]
+
+		next := now.Add(time.Minute)
+		d.AttemptCount = 1
+		d.NextAttemptAt = &next
+		err := f.st.RecordAttempt(ctx, d, Attempt{DeliveryID: d.ID, StatusCode: 500, Response: "oops", Duration: 12, CreatedAt: &now})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d.AttemptCount = 2
+		d.Status = StatusDelivered
+		d.DeliveredAt = &now
+		err = f.st.RecordAttempt(ctx, d, Attempt{DeliveryID: d.ID, StatusCode: 200, Duration: 8, CreatedAt: &now})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		read, err := f.st.ReadDelivery(ctx, f.appID, d.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read.Status != StatusDelivered || read.AttemptCount != 2 || read.DeliveredAt == nil {
+			t.Fatalf("expected the delivery to be delivered, got %+v", read)
+		}
+
+		attempts, err := f.st.ListAttempts(ctx, d.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(attempts) != 2 || attempts[0].StatusCode != 500 || attempts[0].Response != "oops" || attempts[1].StatusCode != 200 {
+			t.Fatalf("expected both the attempts in order, got %+v", attempts)
+		}
+
+		requeued, err := f.st.Requeue(ctx, f.appID, d.ID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if requeued.Status != StatusPending || requeued.AttemptCount != 0 || requeued.DeliveredAt != nil {
+			t.Fatalf("expected the delivery to be pending again, got %+v", requeued)
+		}
+
+		_, err = f.st.Requeue(ctx, f.otherAppID, d.ID, now)
+		if !errors.Is(err, ErrDeliveryNotFound) {
+			t.Fatalf("expected ErrDeliveryNotFound, got %v", err)
+		}
+
+		_, err = f.st.ReadDelivery(ctx, f.otherAppID, d.ID)
+		if !errors.Is(err, ErrDeliveryNotFound) {
+			t.Fatalf("expected ErrDeliveryNotFound, got %v", err)
+		}
+	})
+}
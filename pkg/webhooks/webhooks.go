@@ -0,0 +1,802 @@
+// Package webhooks notifies registered applications of the events of their users (subjects),
+// by POSTing HMAC signed payloads to the endpoints configured by the application. Deliveries
+// are queued in the database, and retried with exponential backoff till they're dead lettered
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/apperr"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/rbac"
+)
+
+// Event is the type of event an endpoint can subscribe to
+type Event string
+
+// DeliveryStatus is the status of a delivery
+type DeliveryStatus string
+
+const (
+	// EventSubjectEnrolled is sent when a subject confirms the enrollment, by verifying a code
+	// for the first time
+	EventSubjectEnrolled = Event("subject.enrolled")
+	// EventSubjectUnenrolled is sent when the enrollment of a subject is removed
+	EventSubjectUnenrolled = Event("subject.unenrolled")
+	// EventSubjectLockedOut is sent when a subject is locked out for too many failed attempts
+	EventSubjectLockedOut = Event("subject.locked_out")
+	// EventSubjectRecoveryCodeUsed is sent when a subject is verified using a recovery code
+	EventSubjectRecoveryCodeUsed = Event("subject.recovery_code_used")
+
+	// StatusPending deliveries are yet to be delivered, and would be (re)tried
+	StatusPending = DeliveryStatus("pending")
+	// StatusDelivered deliveries were acknowledged by the endpoint with a 2xx response
+	StatusDelivered = DeliveryStatus("delivered")
+	// StatusDead deliveries failed Config.MaxAttempts times, and are not retried anymore
+	StatusDead = DeliveryStatus("dead")
+
+	// SignatureHeader has the signature of the payload, in the format 't=<unix ts>,v1=<hex>'
+	SignatureHeader = "Padlock-Signature"
+	// EventHeader has the type of the event delivered
+	EventHeader = "Padlock-Event"
+	// DeliveryHeader has the ID of the delivery, which is the same for all attempts
+	DeliveryHeader = "Padlock-Delivery"
+
+	secretPrefix = "whsec_"
+	// maxResponseLength is the maximum length of the response body stored in the delivery log
+	maxResponseLength = 512
+)
+
+var (
+	ErrInvalidURL       = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no URL provided, it should be an absolute http(s) URL")
+	ErrPrivateURL       = apperr.New(apperr.CodeInvalidInput, "Sorry, the URL should not point to a private/internal address")
+	ErrInvalidEvent     = apperr.New(apperr.CodeInvalidInput, "Sorry, invalid/no events provided")
+	ErrNotFound         = apperr.New(apperr.CodeNotFound, "Sorry, webhook endpoint not found")
+	ErrDeliveryNotFound = apperr.New(apperr.CodeNotFound, "Sorry, webhook delivery not found")
+	ErrInvalidSignature = apperr.New(apperr.CodeUnauthenticated, "Sorry, invalid webhook signature")
+	ErrUnexpected       = apperr.New(apperr.CodeInternal, "Sorry, an unexpected error occurred")
+
+	validEvents = map[Event]bool{
+		EventSubjectEnrolled:         true,
+		EventSubjectUnenrolled:       true,
+		EventSubjectLockedOut:        true,
+		EventSubjectRecoveryCodeUsed: true,
+	}
+
+	// privateNetworks are the private, shared, benchmarking, NAT64 & "this network" address
+	// ranges. The loopback & link local addresses are checked separately
+	privateNetworks = parseCIDRs(
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"fc00::/7",
+		// NAT64, which translates to the IPv4 addresses & could reach the private ones
+		"64:ff9b::/96",
+	)
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	list := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		list = append(list, n)
+	}
+	return list
+}
+
+// publicIP checks if the IP is routable on the internet, i.e. it's not a loopback, private,
+// link local (which includes the cloud metadata address 169.254.169.254) or unspecified address
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialControl rejects the connections to non public addresses, so that the endpoints cannot be
+// used to reach the internal network. It's called with the resolved address of every connection,
+// including the ones made for redirects, so hosts resolving to internal addresses are rejected too
+func dialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("webhooks: connecting to %s is not allowed", host)
+	}
+	return nil
+}
+
+// Endpoint is a URL of an application, to which the subscribed events are delivered
+type Endpoint struct {
+	ID     int64   `json:"id,omitempty"`
+	AppID  int64   `json:"appId,omitempty"`
+	URL    string  `json:"url,omitempty"`
+	Events []Event `json:"events,omitempty"`
+	// Active is false for endpoints which are disabled, no new events are delivered to them
+	Active bool `json:"active"`
+	// Secret is used to sign the payloads, it's only available in the response of Create
+	Secret    string     `json:"secret,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+
+	secret string
+}
+
+// Subscribed checks if the endpoint is subscribed to the event
+func (e *Endpoint) Subscribed(event Event) bool {
+	for _, ev := range e.Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
+// validate validates the endpoint. The URLs with private/internal hosts are rejected early, unless
+// allowPrivate is true, though the addresses the hosts resolve to are only checked while delivering
+func (e *Endpoint) validate(allowPrivate bool) error {
+	u, err := url.Parse(strings.TrimSpace(e.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	e.URL = u.String()
+
+	if !allowPrivate {
+		host := strings.ToLower(u.Hostname())
+		ip := net.ParseIP(host)
+		if host == "localhost" || strings.HasSuffix(host, ".localhost") || (ip != nil && !publicIP(ip)) {
+			return ErrPrivateURL
+		}
+	}
+
+	if len(e.Events) == 0 {
+		return ErrInvalidEvent
+	}
+	for _, ev := range e.Events {
+		if !validEvents[ev] {
+			return ErrInvalidEvent
+		}
+	}
+
+	return nil
+}
+
+// Payload is the body POSTed to the endpoints
+type Payload struct {
+	// ID is the ID of the event, which is the same across all the endpoints it's delivered to
+	ID        string      `json:"id"`
+	Type      Event       `json:"type"`
+	AppID     int64       `json:"appId"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// Delivery is an event queued to be delivered to an endpoint
+type Delivery struct {
+	ID         int64           `json:"id,omitempty"`
+	EndpointID int64           `json:"endpointId,omitempty"`
+	AppID      int64           `json:"appId,omitempty"`
+	EventID    string          `json:"eventId,omitempty"`
+	Event      Event           `json:"event,omitempty"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Status     DeliveryStatus  `json:"status,omitempty"`
+	// AttemptCount is the number of attempts made since the delivery was (re)queued
+	AttemptCount  int        `json:"attemptCount"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	CreatedAt     *time.Time `json:"createdAt,omitempty"`
+	DeliveredAt   *time.Time `json:"deliveredAt,omitempty"`
+	// Log has all the attempts made, it's only available when reading a single delivery
+	Log []Attempt `json:"log,omitempty"`
+}
+
+// Attempt is a log of an attempt to deliver
+type Attempt struct {
+	ID         int64 `json:"id,omitempty"`
+	DeliveryID int64 `json:"deliveryId,omitempty"`
+	// StatusCode is the HTTP status of the response, 0 if there was no response
+	StatusCode int    `json:"statusCode"`
+	Response   string `json:"response,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Duration is the time taken for the request in milliseconds
+	Duration  int64      `json:"duration"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// Config has all the configurations for delivering webhooks
+type Config struct {
+	// MaxAttempts is the number of attempts, after which a delivery is dead lettered
+	MaxAttempts int
+	// Backoff is the delay before the first retry, it's doubled for every subsequent retry
+	Backoff time.Duration
+	// MaxBackoff is the maximum delay between retries
+	MaxBackoff time.Duration
+	// PollInterval is the interval at which the queue is checked for due deliveries
+	PollInterval time.Duration
+	// BatchSize is the maximum number of deliveries attempted per poll
+	BatchSize int
+	// Timeout is the timeout of a single attempt
+	Timeout time.Duration
+	// AllowPrivateNetworks allows the endpoints to be on loopback, private & link local addresses,
+	// it should be enabled only when all the applications are trusted (e.g. in development)
+	AllowPrivateNetworks bool
+}
+
+// DefaultConfig is the default configuration for delivering webhooks. With it, a delivery is
+// retried for about 20 hours before it's dead lettered
+var DefaultConfig = Config{
+	MaxAttempts:  13,
+	Backoff:      time.Second * 30,
+	MaxBackoff:   time.Hour * 6,
+	PollInterval: time.Second * 5,
+	BatchSize:    20,
+	Timeout:      time.Second * 10,
+}
+
+// backoff returns the delay before the next attempt, after the given number of attempts
+func (cfg Config) backoff(attempts int) time.Duration {
+	delay := cfg.Backoff
+	for i := 1; i < attempts && delay < cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > cfg.MaxBackoff {
+		delay = cfg.MaxBackoff
+	}
+	return delay
+}
+
+// Authorizer checks if the caller, identified by the context, is allowed to manage the webhooks
+// of an app
+type Authorizer interface {
+	Authorize(ctx context.Context, perm rbac.Permission, appID int64) error
+}
+
+// Webhooks handles all the service methods made available by this package
+type Webhooks struct {
+	appCtx *appcontext.AppContext
+	cfg    Config
+	store  store
+	auth   Authorizer
+	client *http.Client
+}
+
+func (wh *Webhooks) unexpected(err error) error {
+	if wh.appCtx.Logging {
+		wh.appCtx.Logger.Error(err)
+	}
+	return ErrUnexpected
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the value of the signature header, for the payload sent at the given time. The
+// signature is the hex encoded HMAC-SHA256 of '<unix ts>.<payload>'
+func Sign(secret string, at time.Time, payload []byte) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, signature(secret, ts, payload))
+}
+
+func signature(secret, ts string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature verifies the signature header of a received payload. Signatures older than
+// tolerance are rejected, to prevent replaying of old payloads
+func VerifySignature(secret, header string, payload []byte, tolerance time.Duration) error {
+	ts, sig := "", ""
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			{
+				ts = kv[1]
+			}
+		case "v1":
+			{
+				sig = kv[1]
+			}
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+
+	age := time.Since(time.Unix(unix, 0))
+	if tolerance > 0 && (age > tolerance || age < -tolerance) {
+		return ErrInvalidSignature
+	}
+
+	if subtle.ConstantTimeCompare([]byte(sig), []byte(signature(secret, ts, payload))) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Create creates a new endpoint for the application. The returned endpoint has the secret set,
+// which the application should use to verify the signatures
+func (wh *Webhooks) Create(ctx context.Context, e Endpoint) (*Endpoint, error) {
+	err := e.validate(wh.cfg.AllowPrivateNetworks)
+	if err != nil {
+		return nil, err
+	}
+
+	err = wh.auth.Authorize(ctx, rbac.PermAppHooks, e.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, wh.unexpected(err)
+	}
+
+	now := time.Now().UTC()
+	e.ID = 0
+	e.Active = true
+	e.secret = secretPrefix + secret
+	e.CreatedAt = &now
+	e.UpdatedAt = &now
+
+	created, err := wh.store.CreateEndpoint(ctx, e)
+	if err != nil {
+		return nil, wh.unexpected(err)
+	}
+
+	created.Secret = created.secret
+	return created, nil
+}
+
+// List lists all the endpoints of the application
+func (wh *Webhooks) List(ctx context.Context, appID int64) ([]Endpoint, error) {
+	err := wh.auth.Authorize(ctx, rbac.PermAppHooks, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := wh.store.ListEndpoints(ctx, appID)
+	if err != nil {
+		return nil, wh.unexpected(err)
+	}
+	return list, nil
+}
+
+// Update updates the URL, events & active status of the endpoint
+func (wh *Webhooks) Update(ctx context.Context, e Endpoint) (*Endpoint, error) {
+	err := e.validate(wh.cfg.AllowPrivateNetworks)
+	if err != nil {
+		return nil, err
+	}
+
+	err = wh.auth.Authorize(ctx, rbac.PermAppHooks, e.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	e.UpdatedAt = &now
+
+	updated, err := wh.store.UpdateEndpoint(ctx, e)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, err
+		}
+		return nil, wh.unexpected(err)
+	}
+	return updated, nil
+}
+
+// RotateSecret replaces the secret of the endpoint with a new one, the returned endpoint has
+// the new secret set. The payloads are signed with the new secret right away, so the
+// application should start accepting it before the rotation
+func (wh *Webhooks) RotateSecret(ctx context.Context, appID, id int64) (*Endpoint, error) {
+	err := wh.auth.Authorize(ctx, rbac.PermAppHooks, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, wh.unexpected(err)
+	}
+
+	updated, err := wh.store.UpdateSecret(ctx, appID, id, secretPrefix+secret, time.Now().UTC())
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, err
+		}
+		return nil, wh.unexpected(err)
+	}
+
+	updated.Secret = updated.secret
+	return updated, nil
+}
+
+// Delete deletes the endpoint, along with all its deliveries
+func (wh *Webhooks) Delete(ctx context.Context, appID, id int64) error {
+	err := wh.auth.Authorize(ctx, rbac.PermAppHooks, appID)
+	if err != nil {
+		return err
+	}
+
+	err = wh.store.DeleteEndpoint(ctx, appID, id)
+	if err != nil {
+		if err == ErrNotFound {
+			return err
+		}
+		return wh.unexpected(err)
+	}
+	return nil
+}
+
+// Deliveries lists the latest deliveries of the endpoint
+func (wh *Webhooks) Deliveries(ctx context.Context, appID, endpointID int64, limit int) ([]Delivery, error) {
+	err := wh.auth.Authorize(ctx, rbac.PermAppHooks, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	list, err := wh.store.ListDeliveries(ctx, appID, endpointID, limit)
+	if err != nil {
+		return nil, wh.unexpected(err)
+	}
+	return list, nil
+}
+
+// Delivery returns the delivery, along with the log of all its attempts
+func (wh *Webhooks) Delivery(ctx context.Context, appID, id int64) (*Delivery, error) {
+	err := wh.auth.Authorize(ctx, rbac.PermAppHooks, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := wh.store.ReadDelivery(ctx, appID, id)
+	if err != nil {
+		if err == ErrDeliveryNotFound {
+			return nil, err
+		}
+		return nil, wh.unexpected(err)
+	}
+
+	d.Log, err = wh.store.ListAttempts(ctx, id)
+	if err != nil {
+		return nil, wh.unexpected(err)
+	}
+
+	return d, nil
+}
+
+// Redeliver queues the delivery to be delivered again immediately, irrespective of its status.
+// The attempt count is reset, so a dead lettered delivery gets all its retries again
+func (wh *Webhooks) Redeliver(ctx context.Context, appID, id int64) (*Delivery, error) {
+	err := wh.auth.Authorize(ctx, rbac.PermAppHooks, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := wh.store.Requeue(ctx, appID, id, time.Now().UTC())
+	if err != nil {
+		if err == ErrDeliveryNotFound {
+			return nil, err
+		}
+		return nil, wh.unexpected(err)
+	}
+	return d, nil
+}
+
+// Publish queues the event for delivery to all the active endpoints of the application which
+// are subscribed to it
+func (wh *Webhooks) Publish(ctx context.Context, appID int64, event Event, data interface{}) error {
+	endpoints, err := wh.store.ListEndpoints(ctx, appID)
+	if err != nil {
+		return wh.unexpected(err)
+	}
+
+	subscribed := make([]Endpoint, 0, len(endpoints))
+	for _, e := range endpoints {
+		if e.Active && e.Subscribed(event) {
+			subscribed = append(subscribed, e)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	eventID, err := randomHex(16)
+	if err != nil {
+		return wh.unexpected(err)
+	}
+
+	now := time.Now().UTC()
+	payload, err := json.Marshal(Payload{
+		ID:        eventID,
+		Type:      event,
+		AppID:     appID,
+		CreatedAt: now,
+		Data:      data,
+	})
+	if err != nil {
+		return wh.unexpected(err)
+	}
+
+	deliveries := make([]Delivery, 0, len(subscribed))
+	for _, e := range subscribed {
+		deliveries = append(deliveries, Delivery{
+			EndpointID:    e.ID,
+			AppID:         appID,
+			EventID:       eventID,
+			Event:         event,
+			Payload:       payload,
+			Status:        StatusPending,
+			NextAttemptAt: &now,
+			CreatedAt:     &now,
+		})
+	}
+
+	err = wh.store.CreateDeliveries(ctx, deliveries)
+	if err != nil {
+		return wh.unexpected(err)
+	}
+	return nil
+}
+
+// Start delivers the due deliveries every Config.PollInterval, till the context is cancelled.
+// It's safe to run on multiple instances, each due delivery is claimed by only one of them
+func (wh *Webhooks) Start(ctx context.Context) {
+	ticker := time.NewTicker(wh.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		wh.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			{
+				return
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue attempts the due deliveries, till there are no more due or the context is
+// cancelled
+func (wh *Webhooks) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		// a claimed delivery is not claimed again till the lease expires, so if this instance
+		// dies mid way, another one would pick it up. The deliveries of a batch are attempted one
+		// after the other, so the lease has to outlast all of them timing out
+		now := time.Now().UTC()
+		lease := now.Add(wh.cfg.Timeout * time.Duration(wh.cfg.BatchSize+1))
+		due, err := wh.store.ClaimDue(ctx, now, lease, wh.cfg.BatchSize)
+		if err != nil {
+			_ = wh.unexpected(err)
+			return
+		}
+
+		endpoints := make(map[int64]*Endpoint, len(due))
+		for _, d := range due {
+			if ctx.Err() != nil {
+				// stopped mid way, the rest of the batch is left for the other instances
+				wh.release(d)
+				continue
+			}
+
+			e, ok := endpoints[d.EndpointID]
+			if !ok {
+				e, err = wh.store.ReadEndpoint(ctx, d.AppID, d.EndpointID)
+				if err != nil && err != ErrNotFound {
+					_ = wh.unexpected(err)
+					wh.reschedule(ctx, d)
+					continue
+				}
+				endpoints[d.EndpointID] = e
+			}
+
+			wh.attempt(ctx, d, e)
+		}
+
+		if len(due) < wh.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// reschedule releases the claimed delivery without attempting it, to be retried after
+// Config.Backoff rather than when the lease expires
+func (wh *Webhooks) reschedule(ctx context.Context, d Delivery) {
+	err := wh.store.Reschedule(ctx, d.ID, time.Now().UTC().Add(wh.cfg.Backoff))
+	if err != nil {
+		_ = wh.unexpected(err)
+	}
+}
+
+// release releases the claimed delivery to be attempted right away, without counting it as an
+// attempt. It's used when the worker is stopped, so the context of the worker is not used
+func (wh *Webhooks) release(d Delivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), wh.cfg.Timeout)
+	defer cancel()
+
+	err := wh.store.Reschedule(ctx, d.ID, time.Now().UTC())
+	if err != nil {
+		_ = wh.unexpected(err)
+	}
+}
+
+// attempt makes a single attempt to deliver, and updates the delivery according to the outcome
+func (wh *Webhooks) attempt(ctx context.Context, d Delivery, e *Endpoint) {
+	start := time.Now().UTC()
+	a := Attempt{DeliveryID: d.ID, CreatedAt: &start}
+
+	// deliveries of disabled endpoints are dead lettered right away, they can be redelivered
+	// once the endpoint is enabled again
+	disabled := e == nil || !e.Active
+	if disabled {
+		a.Error = "endpoint is deleted/disabled"
+	} else {
+		status, response, err := wh.post(ctx, d, e)
+		if err != nil && ctx.Err() != nil {
+			// the failure is due to the worker being stopped, not the endpoint
+			wh.release(d)
+			return
+		}
+		a.StatusCode = status
+		a.Response = response
+		if err != nil {
+			a.Error = err.Error()
+		}
+	}
+	a.Duration = int64(time.Since(start) / time.Millisecond)
+
+	d.AttemptCount++
+	switch {
+	case a.Error == "":
+		{
+			d.Status = StatusDelivered
+			d.DeliveredAt = &start
+			d.NextAttemptAt = nil
+		}
+	case disabled || d.AttemptCount >= wh.cfg.MaxAttempts:
+		{
+			d.Status = StatusDead
+			d.NextAttemptAt = nil
+		}
+	default:
+		{
+			next := start.Add(wh.cfg.backoff(d.AttemptCount))
+			d.NextAttemptAt = &next
+		}
+	}
+
+	err := wh.store.RecordAttempt(ctx, d, a)
+	if err != nil {
+		_ = wh.unexpected(err)
+	}
+}
+
+// post POSTs the signed payload to the endpoint, and returns the status & (truncated) body of
+// the response
+func (wh *Webhooks) post(ctx context.Context, d Delivery, e *Endpoint) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Padlock-Webhooks")
+	req.Header.Set(SignatureHeader, Sign(e.secret, time.Now(), d.Payload))
+	req.Header.Set(EventHeader, string(d.Event))
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(d.ID, 10))
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseLength))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(body), fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+func New(appCtx *appcontext.AppContext, driver database.Driver, cfg Config, sdb *sql.DB, auth Authorizer) *Webhooks {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = DefaultConfig.MaxAttempts
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultConfig.Backoff
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = DefaultConfig.MaxBackoff
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultConfig.PollInterval
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = DefaultConfig.BatchSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultConfig.Timeout
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   cfg.Timeout,
+		KeepAlive: time.Second * 30,
+	}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = dialControl
+	}
+
+	return &Webhooks{
+		appCtx: appCtx,
+		cfg:    cfg,
+		store:  newStore(appCtx, driver, sdb),
+		auth:   auth,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// the requests are not proxied, since the proxy would connect to the endpoints
+			// bypassing the dialer's checks
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: cfg.Timeout,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     time.Second * 90,
+			},
+		},
+	}
+}
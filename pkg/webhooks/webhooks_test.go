@@ -0,0 +1,348 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bnkamalesh/padlock/pkg/appcontext"
+	"github.com/bnkamalesh/padlock/pkg/platform/database"
+	"github.com/bnkamalesh/padlock/pkg/platform/database/databasetest"
+	"github.com/bnkamalesh/padlock/pkg/platform/logger"
+)
+
+func TestSignature(t *testing.T) {
+	payload := []byte(`{"id":"1","type":"subject.enrolled"}`)
+	now := time.Now()
+	valid := Sign("whsec_1", now, payload)
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		payload []byte
+		err     error
+	}{
+		{"valid", "whsec_1", valid, payload, nil},
+		{"spaces & unknown parts", "whsec_1", " v0=abc, " + strings.Replace(valid, ",", " , ", 1), payload, nil},
+		{"other secret", "whsec_2", valid, payload, ErrInvalidSignature},
+		{"tampered payload", "whsec_1", valid, []byte(`{"id":"2","type":"subject.enrolled"}`), ErrInvalidSignature},
+		{"old", "whsec_1", Sign("whsec_1", now.Add(-time.Minute*10), payload), payload, ErrInvalidSignature},
+		{"future", "whsec_1", Sign("whsec_1", now.Add(time.Minute*10), payload), payload, ErrInvalidSignature},
+		{"tampered timestamp", "whsec_1", strings.Replace(valid, "t=", "t=1", 1), payload, ErrInvalidSignature},
+		{"no timestamp", "whsec_1", valid[strings.Index(valid, ",")+1:], payload, ErrInvalidSignature},
+		{"no signature", "whsec_1", valid[:strings.Index(valid, ",")], payload, ErrInvalidSignature},
+		{"empty", "whsec_1", "", payload, ErrInvalidSignature},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifySignature(tc.secret, tc.header, tc.payload, time.Minute*5)
+			if err != tc.err {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	// without a tolerance, the age is not checked
+	err := VerifySignature("whsec_1", Sign("whsec_1", now.Add(-time.Hour), payload), payload, 0)
+	if err != nil {
+		t.Fatalf("expected the old signature to be valid without a tolerance, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		url          string
+		allowPrivate bool
+		err          error
+	}{
+		{"https://example.com/hook", false, nil},
+		{"http://93.184.216.34:8080/hook", false, nil},
+		{"ftp://example.com/hook", false, ErrInvalidURL},
+		{"/hook", false, ErrInvalidURL},
+		{"http://localhost/hook", false, ErrPrivateURL},
+		{"http://api.localhost/hook", false, ErrPrivateURL},
+		{"http://127.0.0.1/hook", false, ErrPrivateURL},
+		{"http://[::1]/hook", false, ErrPrivateURL},
+		{"http://10.1.2.3/hook", false, ErrPrivateURL},
+		{"http://172.16.0.1/hook", false, ErrPrivateURL},
+		{"http://192.168.1.1/hook", false, ErrPrivateURL},
+		{"http://169.254.169.254/latest/meta-data", false, ErrPrivateURL},
+		{"http://[fd00::1]/hook", false, ErrPrivateURL},
+		{"http://[::ffff:10.0.0.1]/hook", false, ErrPrivateURL},
+		{"http://0.0.0.0/hook", false, ErrPrivateURL},
+		{"http://127.0.0.1/hook", true, nil},
+	}
+
+	for _, tc := range tests {
+		e := Endpoint{URL: tc.url, Events: []Event{EventSubjectEnrolled}}
+		err := e.validate(tc.allowPrivate)
+		if err != tc.err {
+			t.Errorf("%s (private allowed: %v): expected %v, got %v", tc.url, tc.allowPrivate, tc.err, err)
+		}
+	}
+
+	e := Endpoint{URL: "https://example.com/hook", Events: []Event{"subject.unknown"}}
+	err := e.validate(false)
+	if err != ErrInvalidEvent {
+		t.Fatalf("expected ErrInvalidEvent, got %v", err)
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:80", "[::1]:443", "10.0.0.1:80", "169.254.169.254:80", "[fe80::1]:80"} {
+		if dialControl("tcp", addr, nil) == nil {
+			t.Errorf("expected %s to be rejected", addr)
+		}
+	}
+	for _, addr := range []string{"93.184.216.34:443", "[2606:2800:220:1:248:1893:25c8:1946]:443"} {
+		err := dialControl("tcp", addr, nil)
+		if err != nil {
+			t.Errorf("expected %s to be allowed, got %v", addr, err)
+		}
+	}
+}
+
+// receiver is an endpoint which records the deliveries it receives. If arrived is set, the
+// arrival of a delivery is sent to it, and the response is held till the request is cancelled
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	status   int
+	received []string
+	arrived  chan struct{}
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// the body is read first, else the cancellation of the request is not noticed
+	body, _ := ioutil.ReadAll(req.Body)
+	if r.arrived != nil {
+		r.arrived <- struct{}{}
+		<-req.Context().Done()
+		return
+	}
+
+	err := VerifySignature(r.secret, req.Header.Get(SignatureHeader), body, time.Minute)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	r.received = append(r.received, req.Header.Get(EventHeader))
+	w.WriteHeader(r.status)
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.received)
+}
+
+// failingStore fails to read the endpoints
+type failingStore struct {
+	store
+}
+
+func (fs failingStore) ReadEndpoint(ctx context.Context, appID, id int64) (*Endpoint, error) {
+	return nil, errors.New("connection reset")
+}
+
+type deliveryFixture struct {
+	wh       *Webhooks
+	appID    int64
+	endpoint *Endpoint
+	receiver *receiver
+}
+
+func testDelivery(t *testing.T, driver database.Driver, db *sql.DB, cfg Config) *deliveryFixture {
+	t.Helper()
+
+	r := &receiver{secret: "whsec_1", status: http.StatusOK}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	wh := New(appcontext.New(logger.New()), driver, cfg, db, nil)
+	orgID := databasetest.Org(t, driver, db, "acme")
+	appID := databasetest.App(t, driver, db, orgID, "billing")
+
+	now := time.Now()
+	e, err := wh.store.CreateEndpoint(context.Background(), Endpoint{
+		AppID:     appID,
+		URL:       srv.URL,
+		Events:    []Event{EventSubjectRecoveryCodeUsed},
+		Active:    true,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+		secret:    r.secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &deliveryFixture{wh: wh, appID: appID, endpoint: e, receiver: r}
+}
+
+func (f *deliveryFixture) delivery(t *testing.T) *Delivery {
+	t.Helper()
+	list, err := f.wh.store.ListDeliveries(context.Background(), f.appID, f.endpoint.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 delivery, got %+v", list)
+	}
+	return &list[0]
+}
+
+func TestDeliver(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		f := testDelivery(t, driver, db, Config{AllowPrivateNetworks: true})
+
+		err := f.wh.Publish(ctx, f.appID, EventSubjectRecoveryCodeUsed, map[string]string{"externalId": "jane"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f.wh.deliverDue(ctx)
+		if f.receiver.count() != 1 || f.receiver.received[0] != string(EventSubjectRecoveryCodeUsed) {
+			t.Fatalf("expected the event to be received, got %v", f.receiver.received)
+		}
+
+		d := f.delivery(t)
+		if d.Status != StatusDelivered || d.AttemptCount != 1 {
+			t.Fatalf("expected the delivery to be delivered, got %+v", d)
+		}
+	})
+}
+
+func TestDeliverPrivateNetwork(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		f := testDelivery(t, driver, db, Config{})
+
+		err := f.wh.Publish(ctx, f.appID, EventSubjectRecoveryCodeUsed, map[string]string{"externalId": "jane"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f.wh.deliverDue(ctx)
+		if f.receiver.count() != 0 {
+			t.Fatalf("expected the loopback endpoint not to be called, got %v", f.receiver.received)
+		}
+
+		d, err := f.wh.store.ReadDelivery(ctx, f.appID, f.delivery(t).ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attempts, err := f.wh.store.ListAttempts(ctx, d.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Status != StatusPending || len(attempts) != 1 || !strings.Contains(attempts[0].Error, "not allowed") {
+			t.Fatalf("expected the attempt to fail, got %+v & %+v", d, attempts)
+		}
+	})
+}
+
+func TestDeliverReschedule(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		ctx := context.Background()
+		cfg := Config{AllowPrivateNetworks: true, Backoff: time.Minute, Timeout: time.Hour}
+		f := testDelivery(t, driver, db, cfg)
+
+		err := f.wh.Publish(ctx, f.appID, EventSubjectRecoveryCodeUsed, map[string]string{"externalId": "jane"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		working := f.wh.store
+		f.wh.store = failingStore{store: working}
+		f.wh.deliverDue(ctx)
+		f.wh.store = working
+
+		// the delivery is released to be retried after the backoff, rather than the lease
+		d := f.delivery(t)
+		if d.Status != StatusPending || d.AttemptCount != 0 || d.NextAttemptAt == nil ||
+			d.NextAttemptAt.After(time.Now().Add(time.Minute*2)) {
+			t.Fatalf("expected the delivery to be rescheduled, got %+v", d)
+		}
+
+		claimed, err := f.wh.store.ClaimDue(ctx, time.Now().Add(time.Minute*2), time.Now().Add(time.Hour), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(claimed) != 1 {
+			t.Fatalf("expected the delivery to be due after the backoff, got %+v", claimed)
+		}
+	})
+}
+
+func TestDeliverStopped(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T, driver database.Driver, db *sql.DB) {
+		cfg := Config{AllowPrivateNetworks: true, Backoff: time.Hour, Timeout: time.Minute}
+		f := testDelivery(t, driver, db, cfg)
+		f.receiver.arrived = make(chan struct{})
+
+		err := f.wh.Publish(context.Background(), f.appID, EventSubjectRecoveryCodeUsed, map[string]string{"externalId": "jane"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			f.wh.deliverDue(ctx)
+			close(done)
+		}()
+		<-f.receiver.arrived
+		cancel()
+		<-done
+
+		// the delivery is released right away, and the interrupted attempt is not counted
+		d := f.delivery(t)
+		if d.Status != StatusPending || d.AttemptCount != 0 || d.NextAttemptAt == nil || d.NextAttemptAt.After(time.Now()) {
+			t.Fatalf("expected the delivery to be released, got %+v", d)
+		}
+		attempts, err := f.wh.store.ListAttempts(context.Background(), d.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(attempts) != 0 {
+			t.Fatalf("expected no attempts, got %+v", attempts)
+		}
+	})
+}
+
+func TestPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":         true,
+		"2001:4860::8888": true,
+		"100.63.255.255":  true,
+		"100.64.0.1":      false,
+		"172.31.255.255":  false,
+		"172.32.0.1":      true,
+		"::":              false,
+		"ff02::1":         false,
+		"198.18.0.1":      false,
+		"198.20.0.1":      true,
+		"64:ff9b::a00:1":  false,
+	}
+
+	for ip, public := range tests {
+		if publicIP(net.ParseIP(ip)) != public {
+			t.Errorf("%s: expected public: %v", ip, public)
+		}
+	}
+}
@@ -1,36 +0,0 @@
-TRUNCATE applicationOwners RESTART IDENTITY
-TRUNCATE users RESTART IDENTITY CASCADE
-TRUNCATE applications RESTART IDENTITY CASCADE
-
-DROP TABLE applicationOwners
-DROP TABLE applications
-DROP TABLE users
-
-CREATE TABLE IF NOT EXISTS applications(
-id SERIAL PRIMARY KEY,
-name VARCHAR(255) UNIQUE NOT NULL,
-description VARCHAR(2048),
-totp JSON,
-createdat timestamp(0) with time zone,
-updatedat timestamp(0) with time zone
-)
-
-CREATE TABLE IF NOT EXISTS users(
-	id SERIAL PRIMARY KEY,
-	name VARCHAR(255),
-	email VARCHAR(510) UNIQUE NOT NULL,
-	phone VARCHAR(30),
-	password VARCHAR(255),
-	salt VARCHAR(64),
-	createdat timestamp(0) with time zone,
-	updatedat timestamp(0) with time zone
-)
-
-CREATE TABLE IF NOT EXISTS applicationOwners(
-id SERIAL PRIMARY KEY,
-appID INTEGER,
-userID INTEGER,
-createdat timestamp(0) with time zone,
-FOREIGN KEY (appID) REFERENCES applications (id),
-FOREIGN KEY (userID) REFERENCES users (id) 
-)
\ No newline at end of file